@@ -7,6 +7,10 @@ import (
 	"os"
 	"os/signal"
 	config "scs-operator/config"
+	alarm_repository "scs-operator/internal/app/alarm/repository"
+	guard_repository "scs-operator/internal/app/guard/repository"
+	guidance_template_repository "scs-operator/internal/app/guidance-template/repository"
+	incident_repository "scs-operator/internal/app/incident/repository"
 	"scs-operator/internal/container"
 	"scs-operator/internal/models"
 	"scs-operator/internal/processor"
@@ -14,6 +18,7 @@ import (
 	"scs-operator/pkg/db"
 	kafka_client "scs-operator/pkg/kafka"
 	"scs-operator/pkg/logger"
+	mqtt_client "scs-operator/pkg/mqtt"
 	"strings"
 	"sync"
 	"syscall"
@@ -22,6 +27,7 @@ import (
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 	"github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
 
 	_ "scs-operator/docs" // This will be generated by swag init
 )
@@ -71,21 +77,143 @@ func main() {
 		appLogger.Info("Postgres connected")
 	}
 
+	// AutoMigrate only creates missing check constraints, so drop the ones
+	// whose allowed values changed and let it recreate them
+	for _, constraint := range []struct {
+		model interface{}
+		name  string
+	}{
+		{&models.Alarm{}, "chk_alarms_status"},
+	} {
+		if psqlDb.Migrator().HasConstraint(constraint.model, constraint.name) {
+			if err := psqlDb.Migrator().DropConstraint(constraint.model, constraint.name); err != nil {
+				appLogger.Fatalf("Dropping constraint %s failed: %s", constraint.name, err)
+			}
+		}
+	}
+	// Drop indexes that no longer exist on the models
+	for _, index := range []struct {
+		model interface{}
+		name  string
+	}{
+		// Incidents may now hold archived guidance next to the active one
+		{&models.IncidentGuidance{}, "idx_incident_guidance"},
+	} {
+		if psqlDb.Migrator().HasIndex(index.model, index.name) {
+			if err := psqlDb.Migrator().DropIndex(index.model, index.name); err != nil {
+				appLogger.Fatalf("Dropping index %s failed: %s", index.name, err)
+			}
+		}
+	}
+
+	// Incident alarms carry who linked them, so use the model as join table
+	if err := psqlDb.SetupJoinTable(&models.Incident{}, "Alarms", &models.IncidentAlarm{}); err != nil {
+		appLogger.Fatalf("Setting up incident alarms join table failed: %s", err)
+	}
+
+	// Templates were not versioned before this table existed
+	versionedTemplates := psqlDb.Migrator().HasTable(&models.GuidanceTemplateVersion{})
+
 	// Auto-migrate models
 	err = psqlDb.AutoMigrate(
 		&models.Premise{},
+		&models.Device{},
+		&models.MaintenanceWindow{},
 		&models.Alarm{},
 		&models.Incident{},
+		&models.IncidentAlarm{},
 		&models.IncidentGuidance{},
 		&models.IncidentGuidanceStep{},
+		&models.IncidentGuidanceAssignment{},
+		&models.IncidentActivity{},
+		&models.Qualification{},
+		&models.UserQualification{},
+		&models.GuidanceTemplateVersion{},
 		&models.GuidanceTemplate{},
 		&models.GuidanceStep{},
 		&models.IncidentMedia{},
 		&models.UserPremise{},
+		&models.GuardPosition{},
+		&models.AuditLog{},
+		&models.PatrolRoute{},
+		&models.PatrolCheckpoint{},
+		&models.PatrolRun{},
+		&models.PatrolRunCheckpoint{},
+		&models.PatrolScan{},
+		&models.GuardShift{},
+		&models.SyncOperation{},
 	)
 	if err != nil {
 		appLogger.Fatalf("Database migration failed: %s", err)
 	}
+	// Full-text search indexes, AutoMigrate cannot express them
+	for _, statement := range incident_repository.SearchIndexes {
+		if err := psqlDb.Exec(statement).Error; err != nil {
+			appLogger.Fatalf("Creating search index failed: %s", err)
+		}
+	}
+	// Guard location history is partitioned, AutoMigrate cannot create it
+	for _, statement := range guard_repository.GuardLocationHistoryTable {
+		if err := psqlDb.Exec(statement).Error; err != nil {
+			appLogger.Fatalf("Creating guard location history failed: %s", err)
+		}
+	}
+	// Incidents used to reference a single alarm, move those links to the join table
+	if psqlDb.Migrator().HasColumn(&models.Incident{}, "alarm_id") {
+		err = psqlDb.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`INSERT INTO incident_alarms (incident_id, alarm_id, created_at)
+				SELECT id, alarm_id, created_at FROM incidents WHERE alarm_id IS NOT NULL
+				ON CONFLICT DO NOTHING`).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&models.Incident{}, "alarm_id")
+		})
+		if err != nil {
+			appLogger.Fatalf("Migrating incident alarms failed: %s", err)
+		}
+	}
+	// Triage used to link an alarm to one incident through alarms.incident_id
+	if psqlDb.Migrator().HasColumn(&models.Alarm{}, "incident_id") {
+		err = psqlDb.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`INSERT INTO incident_alarms (incident_id, alarm_id, created_at)
+				SELECT incident_id, id, updated_at FROM alarms WHERE incident_id IS NOT NULL
+				ON CONFLICT DO NOTHING`).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&models.Alarm{}, "incident_id")
+		})
+		if err != nil {
+			appLogger.Fatalf("Migrating triage incident links failed: %s", err)
+		}
+	}
+	// Alarms carried the device as free text before the device registry
+	if psqlDb.Migrator().HasColumn(&models.Alarm{}, "device") {
+		err = psqlDb.Transaction(func(tx *gorm.DB) error {
+			for _, statement := range alarm_repository.LegacyDeviceBackfill {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			appLogger.Fatalf("Linking alarms to registered devices failed: %s", err)
+		}
+	}
+	// Publish the templates of an unversioned database as version 1
+	if !versionedTemplates {
+		err = psqlDb.Transaction(func(tx *gorm.DB) error {
+			for _, statement := range guidance_template_repository.VersionBackfill {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			appLogger.Fatalf("Publishing unversioned guidance templates failed: %s", err)
+		}
+	}
 	// Initialize Kafka producer
 	producer := startKafkaProducer("notification.triggered", &cfg, appLogger)
 
@@ -102,7 +230,13 @@ func main() {
 	}
 
 	// Create shared repositories and services using container
-	deps := container.NewContainer(psqlDb, producer)
+	deps := container.NewContainer(&cfg, psqlDb, producer)
+
+	// Partition the location history before the first location can arrive,
+	// a location stored in the default partition blocks creating its month
+	if err := deps.GuardService.EnsureLocationPartitions(ctx); err != nil {
+		appLogger.Errorf("Creating guard location partitions failed: %v", err)
+	}
 
 	// Start Kafka producer
 
@@ -126,9 +260,37 @@ func main() {
 		}
 	}()
 
-	// Start Kafka consumer in a separate goroutine with shared services
-	wg.Add(1) // Increment the WaitGroup counter
-	go startKafkaConsumer("alarm.triggered", &cfg, appLogger, consumerCtx, &wg, deps)
+	// Start Kafka consumers in separate goroutines with shared services
+	wg.Add(3) // Increment the WaitGroup counter
+	go startKafkaConsumer("alarm.triggered", &cfg, appLogger, consumerCtx, &wg, processor.NewAlarmProcessor(*deps.AlarmService, appLogger))
+	go startKafkaConsumer("device.heartbeat", &cfg, appLogger, consumerCtx, &wg, processor.NewHeartbeatProcessor(*deps.DeviceService, appLogger))
+	go startKafkaConsumer("guard.location", &cfg, appLogger, consumerCtx, &wg, processor.NewGuardLocationProcessor(*deps.GuardService, appLogger))
+
+	// Start the device heartbeat checker
+	wg.Add(1)
+	go startHeartbeatChecker(&cfg, appLogger, consumerCtx, &wg, deps)
+
+	// Start the patrol checker
+	wg.Add(1)
+	go startPatrolChecker(&cfg, appLogger, consumerCtx, &wg, deps)
+
+	// Start the lone-worker check-in escalator
+	wg.Add(1)
+	go startCheckInEscalator(&cfg, appLogger, consumerCtx, &wg, deps)
+
+	// Start the alarm lease sweeper
+	wg.Add(1)
+	go startLeaseSweeper(&cfg, appLogger, consumerCtx, &wg, deps)
+
+	// Start the guard location partition maintainer
+	wg.Add(1)
+	go startLocationPartitioner(appLogger, consumerCtx, &wg, deps)
+
+	// Start MQTT subscriber for IoT sensors when a broker is configured
+	if cfg.Mqtt.Brokers != "" {
+		wg.Add(1)
+		go startMqttSubscriber(&cfg, appLogger, consumerCtx, &wg, deps)
+	}
 
 	// Block until a signal is received
 	<-quit
@@ -157,7 +319,7 @@ func main() {
 	appLogger.Info("Server and consumer stopped.")
 }
 
-func startKafkaConsumer(topic string, cfg *config.Config, logger *logger.ApiLogger, ctx context.Context, wg *sync.WaitGroup, container *container.Container) {
+func startKafkaConsumer(topic string, cfg *config.Config, logger *logger.ApiLogger, ctx context.Context, wg *sync.WaitGroup, processor processor.Processor) {
 	// Ensure wg.Done() is called when the function exits
 	defer wg.Done()
 	// Initialize Kafka consumer
@@ -172,9 +334,6 @@ func startKafkaConsumer(topic string, cfg *config.Config, logger *logger.ApiLogg
 		CommitInterval: 1000,
 		StartOffset:    kafka.FirstOffset,
 	}
-	// Use the shared alarm service instead of creating new instances
-	processor := processor.NewAlarmProcessor(*container.AlarmService, logger)
-
 	consumer := kafka_client.NewConsumer(&kafkaCfg, &consumerCfg)
 	defer func() {
 		logger.Info("Closing Kafka consumer...")
@@ -183,7 +342,7 @@ func startKafkaConsumer(topic string, cfg *config.Config, logger *logger.ApiLogg
 		}
 		logger.Info("Kafka consumer closed.")
 	}()
-	logger.Info("Kafka consumer initialized")
+	logger.Infof("Kafka consumer initialized for topic %s", topic)
 
 	// Continuously read messages
 	for {
@@ -206,6 +365,162 @@ func startKafkaConsumer(topic string, cfg *config.Config, logger *logger.ApiLogg
 	}
 }
 
+func startHeartbeatChecker(cfg *config.Config, logger *logger.ApiLogger, ctx context.Context, wg *sync.WaitGroup, container *container.Container) {
+	// Ensure wg.Done() is called when the function exits
+	defer wg.Done()
+	ticker := time.NewTicker(cfg.Device.HeartbeatCheckInterval)
+	defer ticker.Stop()
+	logger.Infof("Heartbeat checker running every %s", cfg.Device.HeartbeatCheckInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Context canceled. Stopping heartbeat checker.")
+			return
+		case <-ticker.C:
+			if err := container.DeviceService.CheckHeartbeats(ctx); err != nil {
+				logger.Errorf("Heartbeat check failed: %v", err)
+			}
+		}
+	}
+}
+
+func startPatrolChecker(cfg *config.Config, logger *logger.ApiLogger, ctx context.Context, wg *sync.WaitGroup, container *container.Container) {
+	// Ensure wg.Done() is called when the function exits
+	defer wg.Done()
+	ticker := time.NewTicker(cfg.Patrol.CheckInterval)
+	defer ticker.Stop()
+	logger.Infof("Patrol checker running every %s", cfg.Patrol.CheckInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Context canceled. Stopping patrol checker.")
+			return
+		case <-ticker.C:
+			if err := container.PatrolService.CheckPatrols(ctx); err != nil {
+				logger.Errorf("Patrol check failed: %v", err)
+			}
+		}
+	}
+}
+
+func startCheckInEscalator(cfg *config.Config, logger *logger.ApiLogger, ctx context.Context, wg *sync.WaitGroup, container *container.Container) {
+	// Ensure wg.Done() is called when the function exits
+	defer wg.Done()
+	ticker := time.NewTicker(cfg.LoneWorker.CheckInterval)
+	defer ticker.Stop()
+	logger.Infof("Check-in escalator running every %s", cfg.LoneWorker.CheckInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Context canceled. Stopping check-in escalator.")
+			return
+		case <-ticker.C:
+			if err := container.GuardService.CheckShifts(ctx); err != nil {
+				logger.Errorf("Check-in escalation failed: %v", err)
+			}
+		}
+	}
+}
+
+func startLeaseSweeper(cfg *config.Config, logger *logger.ApiLogger, ctx context.Context, wg *sync.WaitGroup, container *container.Container) {
+	// Ensure wg.Done() is called when the function exits
+	defer wg.Done()
+	ticker := time.NewTicker(cfg.Alarm.LeaseSweepInterval)
+	defer ticker.Stop()
+	logger.Infof("Alarm lease sweeper running every %s", cfg.Alarm.LeaseSweepInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Context canceled. Stopping alarm lease sweeper.")
+			return
+		case <-ticker.C:
+			released, err := container.AlarmService.ReleaseExpiredLeases(ctx)
+			if err != nil {
+				logger.Errorf("Alarm lease sweep failed: %v", err)
+				continue
+			}
+			if released > 0 {
+				logger.Infof("Returned %d alarms with expired leases to the queue", released)
+			}
+		}
+	}
+}
+
+// startLocationPartitioner keeps the guard location history partitioned
+// months ahead, checking daily.
+func startLocationPartitioner(logger *logger.ApiLogger, ctx context.Context, wg *sync.WaitGroup, container *container.Container) {
+	// Ensure wg.Done() is called when the function exits
+	defer wg.Done()
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Context canceled. Stopping guard location partitioner.")
+			return
+		case <-ticker.C:
+			if err := container.GuardService.EnsureLocationPartitions(ctx); err != nil {
+				logger.Errorf("Creating guard location partitions failed: %v", err)
+			}
+		}
+	}
+}
+
+func startMqttSubscriber(cfg *config.Config, logger *logger.ApiLogger, ctx context.Context, wg *sync.WaitGroup, container *container.Container) {
+	// Ensure wg.Done() is called when the function exits
+	defer wg.Done()
+	patterns, err := mqtt_client.ParseTopicPatterns(cfg.Mqtt.Topics)
+	if err != nil {
+		logger.Errorf("Invalid MQTT topics: %v", err)
+		return
+	}
+	topics := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		topics = append(topics, pattern.Filter())
+	}
+	mqttCfg := mqtt_client.Config{
+		Brokers:  strings.Split(cfg.Mqtt.Brokers, ","),
+		ClientID: cfg.Mqtt.ClientID,
+		Username: cfg.Mqtt.Username,
+		Password: cfg.Mqtt.Password,
+	}
+	subscriberCfg := mqtt_client.SubscriberConfig{
+		Topics:               topics,
+		QoS:                  1,
+		CleanSession:         false,
+		ConnectRetryInterval: 5 * time.Second,
+		MaxReconnectInterval: 2 * time.Minute,
+		OnSubscribeError: func(err error) {
+			logger.Errorf("Subscribing to MQTT topics failed, retrying: %v", err)
+		},
+	}
+	// Use the shared alarm service so sensor alarms follow the Kafka path
+	processor := processor.NewMqttAlarmProcessor(*container.AlarmService, patterns, logger)
+
+	subscriber := mqtt_client.NewSubscriber(&mqttCfg, &subscriberCfg, func(topic string, payload []byte) error {
+		if err := processor.Process(topic, payload); err != nil {
+			logger.Errorf("Leaving MQTT message on %s unacknowledged for redelivery: %v", topic, err)
+			return err
+		}
+		return nil
+	})
+	defer func() {
+		logger.Info("Closing MQTT subscriber...")
+		subscriber.Close()
+		logger.Info("MQTT subscriber closed.")
+	}()
+	logger.Infof("MQTT subscriber initialized for topics %v", topics)
+
+	if err := subscriber.Run(ctx); err != nil {
+		logger.Errorf("MQTT subscriber stopped: %v", err)
+	}
+}
+
 func startKafkaProducer(topic string, cfg *config.Config, logger *logger.ApiLogger) *kafka_client.Producer {
 	// Initialize Kafka producer
 	kafkaCfg := kafka_client.Config{
@@ -5,10 +5,20 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Logger   Logger
-	Kafka    KafkaConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Logger     Logger
+	Kafka      KafkaConfig
+	Mqtt       MqttConfig
+	Device     DeviceConfig
+	Alarm      AlarmConfig
+	Incident   IncidentConfig
+	Stream     StreamConfig
+	Guard      GuardConfig
+	Patrol     PatrolConfig
+	LoneWorker LoneWorkerConfig
+	Assignment AssignmentConfig
+	Sync       SyncConfig
 }
 
 // Logger config
@@ -36,3 +46,82 @@ type DatabaseConfig struct {
 type KafkaConfig struct {
 	Brokers string `env:"KAFKA_BROKERS"`
 }
+
+// MqttConfig configures the MQTT bridge for IoT sensors. Topics is a comma
+// separated list of topic patterns such as "sensors/{premise_id}/{device}/alarm".
+type MqttConfig struct {
+	Brokers  string `env:"MQTT_BROKERS"`
+	ClientID string `env:"MQTT_CLIENT_ID" envDefault:"scs-operator"`
+	Username string `env:"MQTT_USERNAME"`
+	Password string `env:"MQTT_PASSWORD"`
+	Topics   string `env:"MQTT_TOPICS" envDefault:"sensors/{premise_id}/{device}/alarm"`
+}
+
+type DeviceConfig struct {
+	HeartbeatCheckInterval time.Duration `env:"HEARTBEAT_CHECK_INTERVAL" envDefault:"30s"`
+}
+
+// AlarmConfig configures the operator alarm queue. Claimed alarms are leased
+// for LeaseDuration; expired leases are cleared every LeaseSweepInterval.
+type AlarmConfig struct {
+	LeaseDuration      time.Duration `env:"ALARM_LEASE_DURATION" envDefault:"5m"`
+	LeaseSweepInterval time.Duration `env:"ALARM_LEASE_SWEEP_INTERVAL" envDefault:"30s"`
+}
+
+// IncidentConfig configures incident media uploads, stored under MediaDir.
+// GeofenceRadius is how far from their premise, in meters, guidance steps
+// requiring a location may be completed, unless the premise sets its own.
+type IncidentConfig struct {
+	MediaDir       string  `env:"INCIDENT_MEDIA_DIR" envDefault:"uploads/incidents"`
+	MediaMaxSize   int64   `env:"INCIDENT_MEDIA_MAX_SIZE" envDefault:"52428800"`
+	GeofenceRadius float64 `env:"INCIDENT_GEOFENCE_RADIUS" envDefault:"200"`
+}
+
+// StreamConfig configures the alarm and incident event stream. The last
+// BufferSize events are kept so reconnecting clients can resume.
+type StreamConfig struct {
+	BufferSize        int           `env:"STREAM_BUFFER_SIZE" envDefault:"1000"`
+	KeepAliveInterval time.Duration `env:"STREAM_KEEPALIVE_INTERVAL" envDefault:"15s"`
+}
+
+// GuardConfig configures guard positions. A guard whose last position is older
+// than PositionMaxAge is not offered as the nearest available guard.
+type GuardConfig struct {
+	PositionMaxAge time.Duration `env:"GUARD_POSITION_MAX_AGE" envDefault:"15m"`
+}
+
+// LoneWorkerConfig configures lone-worker check-ins. A missed check-in warns
+// the guard, raises a man-down alarm AlarmDelay later and notifies the
+// supervisors SupervisorDelay after the alarm. Shifts are checked every
+// CheckInterval.
+type LoneWorkerConfig struct {
+	CheckInInterval time.Duration `env:"LONE_WORKER_CHECK_IN_INTERVAL" envDefault:"30m"`
+	AlarmDelay      time.Duration `env:"LONE_WORKER_ALARM_DELAY" envDefault:"5m"`
+	SupervisorDelay time.Duration `env:"LONE_WORKER_SUPERVISOR_DELAY" envDefault:"5m"`
+	CheckInterval   time.Duration `env:"LONE_WORKER_CHECK_INTERVAL" envDefault:"30s"`
+}
+
+// PatrolConfig configures the patrol checker, which raises alarms for missed
+// checkpoints and overdue patrols.
+type PatrolConfig struct {
+	CheckInterval time.Duration `env:"PATROL_CHECK_INTERVAL" envDefault:"1m"`
+}
+
+// AssignmentConfig weighs the guards of a premise when guidance is assigned
+// without an assignee. The guard with the lowest cost is picked: each open
+// incident costs OpenIncidentCost, being off shift costs OffDutyCost and each
+// kilometre to the incident costs DistanceCost. Guards without a recent
+// position count as UnknownDistance kilometres away.
+type AssignmentConfig struct {
+	OpenIncidentCost float64 `env:"ASSIGNMENT_OPEN_INCIDENT_COST" envDefault:"10"`
+	OffDutyCost      float64 `env:"ASSIGNMENT_OFF_DUTY_COST" envDefault:"25"`
+	DistanceCost     float64 `env:"ASSIGNMENT_DISTANCE_COST" envDefault:"1"`
+	UnknownDistance  float64 `env:"ASSIGNMENT_UNKNOWN_DISTANCE" envDefault:"20"`
+}
+
+// SyncConfig configures the delta sync of the guard app. Sync tokens reach
+// back Overlap before the sync that issued them, so changes committed while
+// it ran are sent again rather than missed.
+type SyncConfig struct {
+	Overlap time.Duration `env:"SYNC_TOKEN_OVERLAP" envDefault:"1m"`
+}
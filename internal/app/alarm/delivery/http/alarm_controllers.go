@@ -4,6 +4,7 @@ import (
 	"scs-operator/internal/app/alarm/dto"
 	services "scs-operator/internal/app/alarm/service"
 	"scs-operator/pkg/errors"
+	"scs-operator/pkg/query"
 	"scs-operator/pkg/validation"
 
 	"github.com/labstack/echo/v4"
@@ -19,13 +20,25 @@ func NewHandler(svc services.Service) *Handler {
 	return &Handler{svc: svc}
 }
 
-// GetAlarms retrieves alarms with optional status filtering
-// @Summary Get alarms
-// @Description Get all alarms with optional status filtering
+// GetAlarms retrieves a filtered, sorted and paginated list of alarms
+// @Summary Get alarms with pagination
+// @Description Get a paginated list of alarms with optional filtering and multi-field sorting. Follow next_cursor for stable keyset pagination; the total is counted unless a cursor is given.
 // @Tags alarms
 // @Accept json
 // @Produce json
-// @Param status query string false "Filter by alarm status (new, ignored, dispatched)"
+// @Param page query int false "Page number, ignored with a cursor" default(1)
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param include_total query bool false "Count the matching alarms, defaults to true without a cursor"
+// @Param status query string false "Filter by alarm status (new, acknowledged, ignored, dispatched, suppressed). Suppressed alarms are excluded unless requested."
+// @Param premise_id query string false "Filter by premise ID"
+// @Param include_sub_premises query bool false "Include alarms of sub-premises when filtering by premise"
+// @Param severity query string false "Filter by severity (low, medium, high)"
+// @Param type query string false "Filter by alarm type"
+// @Param device_id query string false "Filter by device ID"
+// @Param triggered_from query string false "Only alarms triggered at or after this RFC3339 time"
+// @Param triggered_to query string false "Only alarms triggered before this RFC3339 time"
+// @Param sort query string false "Comma separated sort fields (triggered_at, created_at, severity, status, type), prefix with - for descending" default(-triggered_at)
 // @Success 200 {object} types.AlarmListResponse
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
@@ -33,12 +46,20 @@ func NewHandler(svc services.Service) *Handler {
 // @Router /alarms [get]
 func (h *Handler) GetAlarms() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		status := c.QueryParam("status")
-		premises, err := h.svc.GetAlarms(c.Request().Context(), status)
+		getAlarmsDto := &dto.GetAlarmsDto{Request: query.NewRequest()}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, getAlarmsDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		// Validate the DTO
+		if err := validation.ValidateStruct(getAlarmsDto); err != nil {
+			return err
+		}
+		alarms, err := h.svc.GetAlarms(c.Request().Context(), getAlarmsDto)
 		if err != nil {
 			return err
 		}
-		return c.JSON(200, premises)
+		return c.JSON(200, alarms)
 	}
 }
 
@@ -53,6 +74,7 @@ func (h *Handler) GetAlarms() echo.HandlerFunc {
 // @Success 200 {object} models.Alarm
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Security BearerAuth
 // @Router /alarms/{id} [patch]
@@ -68,11 +90,162 @@ func (h *Handler) UpdateAlarm() echo.HandlerFunc {
 		if err := validation.ValidateStruct(updateAlarmDto); err != nil {
 			return err
 		}
-		alarm, err := h.svc.UpdateAlarm(c.Request().Context(), alarmID, updateAlarmDto)
+		userID, _ := c.Get("user_id").(string)
+		alarm, err := h.svc.UpdateAlarm(c.Request().Context(), alarmID, userID, updateAlarmDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, alarm)
+
+	}
+}
+
+// BulkAction applies one triage action to many alarms at once
+// @Summary Bulk alarm triage
+// @Description Acknowledge, ignore, dispatch or link to an incident a list of alarms, or up to 1000 alarms matching a filter, in one transaction. Returns the result for every alarm; with all_or_nothing a single failure rolls back the batch.
+// @Tags alarms
+// @Accept json
+// @Produce json
+// @Param action path string true "Bulk action" Enums(acknowledge, ignore, dispatch, link-incident)
+// @Param request body dto.BulkAlarmActionDto true "Alarms to act on"
+// @Success 200 {object} types.BulkResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /alarms/bulk/{action} [post]
+func (h *Handler) BulkAction(action string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bulkDto := &dto.BulkAlarmActionDto{}
+		if err := c.Bind(bulkDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		// Validate the DTO
+		if err := validation.ValidateStruct(bulkDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		response, err := h.svc.BulkAction(c.Request().Context(), action, userID, bulkDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, response)
+	}
+}
+
+// ClaimAlarm leases the next queued alarm to the current operator
+// @Summary Claim next alarm
+// @Description Claim the highest priority queued alarm (severity, then age) with a time-limited lease. An operator already holding a lease gets that alarm back with the lease renewed.
+// @Tags alarms
+// @Produce json
+// @Success 200 {object} models.Alarm
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /alarms/claim [post]
+func (h *Handler) ClaimAlarm() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, _ := c.Get("user_id").(string)
+		alarm, err := h.svc.ClaimNextAlarm(c.Request().Context(), userID)
 		if err != nil {
 			return err
 		}
 		return c.JSON(200, alarm)
+	}
+}
 
+// ReleaseAlarm returns a claimed alarm to the queue
+// @Summary Release alarm
+// @Description Release the current operator's lease on an alarm so another operator can claim it
+// @Tags alarms
+// @Produce json
+// @Param id path string true "Alarm ID"
+// @Success 200 {object} models.Alarm
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /alarms/{id}/release [post]
+func (h *Handler) ReleaseAlarm() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, _ := c.Get("user_id").(string)
+		alarm, err := h.svc.ReleaseAlarm(c.Request().Context(), c.Param("id"), userID)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, alarm)
+	}
+}
+
+// HandoverAlarm hands a claimed alarm over to another operator
+// @Summary Hand over alarm
+// @Description Move the current operator's lease on an alarm to another operator, who gets a fresh lease
+// @Tags alarms
+// @Accept json
+// @Produce json
+// @Param id path string true "Alarm ID"
+// @Param request body dto.HandoverAlarmDto true "Operator taking over"
+// @Success 200 {object} models.Alarm
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /alarms/{id}/handover [post]
+func (h *Handler) HandoverAlarm() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		handoverDto := &dto.HandoverAlarmDto{}
+		if err := c.Bind(handoverDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		// Validate the DTO
+		if err := validation.ValidateStruct(handoverDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		alarm, err := h.svc.HandoverAlarm(c.Request().Context(), c.Param("id"), userID, handoverDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, alarm)
+	}
+}
+
+// EscalateAlarm creates an incident from an alarm
+// @Summary Escalate alarm to incident
+// @Description Create an incident prefilled from the alarm's premise, severity and description. The alarm is linked to the incident and dispatched.
+// @Tags alarms
+// @Accept json
+// @Produce json
+// @Param id path string true "Alarm ID"
+// @Param request body dto.EscalateAlarmDto false "Incident fields overriding the prefilled values"
+// @Success 201 {object} models.Incident
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /alarms/{id}/escalate [post]
+func (h *Handler) EscalateAlarm() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		escalateDto := &dto.EscalateAlarmDto{}
+		if err := c.Bind(escalateDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		// Validate the DTO
+		if err := validation.ValidateStruct(escalateDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		incident, err := h.svc.EscalateAlarm(c.Request().Context(), c.Param("id"), userID, escalateDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, incident)
 	}
 }
@@ -1,10 +1,20 @@
 package http
 
 import (
+	services "scs-operator/internal/app/alarm/service"
+
 	"github.com/labstack/echo/v4"
 )
 
 func (h *Handler) RegisterRoutes(g *echo.Group) {
 	g.GET("", h.GetAlarms())
 	g.PATCH("/:id", h.UpdateAlarm())
+	g.POST("/bulk/acknowledge", h.BulkAction(services.BulkActionAcknowledge))
+	g.POST("/bulk/ignore", h.BulkAction(services.BulkActionIgnore))
+	g.POST("/bulk/dispatch", h.BulkAction(services.BulkActionDispatch))
+	g.POST("/bulk/link-incident", h.BulkAction(services.BulkActionLinkIncident))
+	g.POST("/claim", h.ClaimAlarm())
+	g.POST("/:id/release", h.ReleaseAlarm())
+	g.POST("/:id/handover", h.HandoverAlarm())
+	g.POST("/:id/escalate", h.EscalateAlarm())
 }
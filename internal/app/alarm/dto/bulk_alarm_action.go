@@ -0,0 +1,22 @@
+package dto
+
+// BulkAlarmActionDto selects the alarms of a bulk action either by ID or by filter.
+type BulkAlarmActionDto struct {
+	AlarmIDs []string         `json:"alarm_ids" validate:"omitempty,max=1000,dive,uuid"`
+	Filter   *BulkAlarmFilter `json:"filter"`
+	// IncidentID is required by the link-incident action
+	IncidentID string `json:"incident_id" validate:"omitempty,uuid"`
+	// AllOrNothing rolls the whole batch back when a single alarm fails
+	AllOrNothing bool `json:"all_or_nothing"`
+}
+
+type BulkAlarmFilter struct {
+	Status             string `json:"status" validate:"omitempty,oneof=new acknowledged ignored dispatched suppressed"`
+	PremiseID          string `json:"premise_id" validate:"omitempty,uuid"`
+	IncludeSubPremises bool   `json:"include_sub_premises"`
+	Severity           string `json:"severity" validate:"omitempty,oneof=low medium high"`
+	Type               string `json:"type"`
+	DeviceID           string `json:"device_id" validate:"omitempty,uuid"`
+	TriggeredFrom      string `json:"triggered_from"`
+	TriggeredTo        string `json:"triggered_to"`
+}
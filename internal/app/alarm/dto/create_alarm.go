@@ -6,4 +6,19 @@ type CreateAlarmDto struct {
 	Description string `json:"description"`
 	TriggeredAt string `json:"triggered_at"`
 	Severity    string `json:"severity"`
+	DeviceID    string `json:"device_id"`
+	// DeviceSerial identifies the device when the sender does not know its ID.
+	DeviceSerial string `json:"device_serial"`
+	// Device is the serial under its name from before the device registry.
+	//
+	// Deprecated: use DeviceSerial. Accepted until the next release.
+	Device string `json:"device"`
+}
+
+// Serial returns the device serial, falling back to the deprecated field.
+func (d *CreateAlarmDto) Serial() string {
+	if d.DeviceSerial != "" {
+		return d.DeviceSerial
+	}
+	return d.Device
 }
@@ -0,0 +1,11 @@
+package dto
+
+// EscalateAlarmDto overrides the incident fields prefilled from the alarm.
+type EscalateAlarmDto struct {
+	Name               string `json:"name"`
+	Description        string `json:"description"`
+	Severity           string `json:"severity" validate:"omitempty,oneof=low medium high"`
+	Location           string `json:"location"`
+	GuidanceTemplateID string `json:"guidance_template_id" validate:"required_with=Assignee,omitempty,uuid"`
+	Assignee           string `json:"assignee_id" validate:"omitempty,uuid"`
+}
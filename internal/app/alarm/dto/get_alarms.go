@@ -0,0 +1,16 @@
+package dto
+
+import "scs-operator/pkg/query"
+
+// GetAlarmsDto holds the query parameters of GET /alarms.
+type GetAlarmsDto struct {
+	query.Request
+	Status             string `query:"status" validate:"omitempty,oneof=new acknowledged ignored dispatched suppressed"`
+	PremiseID          string `query:"premise_id" validate:"omitempty,uuid"`
+	IncludeSubPremises bool   `query:"include_sub_premises"`
+	Severity           string `query:"severity" validate:"omitempty,oneof=low medium high"`
+	Type               string `query:"type"`
+	DeviceID           string `query:"device_id" validate:"omitempty,uuid"`
+	TriggeredFrom      string `query:"triggered_from"`
+	TriggeredTo        string `query:"triggered_to"`
+}
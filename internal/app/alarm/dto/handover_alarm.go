@@ -0,0 +1,5 @@
+package dto
+
+type HandoverAlarmDto struct {
+	UserID string `json:"user_id" validate:"required,uuid"`
+}
@@ -4,8 +4,13 @@ import (
 	"context"
 	"fmt"
 	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/query"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AlarmRepository struct {
@@ -17,37 +22,236 @@ func NewAlarmRepository(db *gorm.DB) *AlarmRepository {
 }
 
 func (r *AlarmRepository) CreateAlarm(ctx context.Context, Alarm *models.Alarm) (*models.Alarm, error) {
-	if err := r.db.WithContext(ctx).Create(Alarm).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Create(Alarm).Error; err != nil {
 		return nil, fmt.Errorf("failed to create Alarm: %w", err)
 	}
 	return Alarm, nil
 }
-func (r *AlarmRepository) GetAlarms(ctx context.Context, status string) ([]models.Alarm, error) {
-	var Alarms []models.Alarm
-	query := r.db.WithContext(ctx).Preload("Premise")
-	if status != "" {
-		query = query.Where("status = ?", status)
+
+// LegacyDeviceBackfill links alarms created before the device registry, which
+// carried the device as free text, to the registered device with that serial.
+// Text matching no device is kept as the alarm's device serial.
+var LegacyDeviceBackfill = []string{
+	`UPDATE alarms SET device_id = devices.id FROM devices
+		WHERE alarms.device_id IS NULL AND alarms.device <> '' AND devices.serial = alarms.device`,
+	`UPDATE alarms SET device_serial = device WHERE device_id IS NULL AND device <> ''`,
+	`ALTER TABLE alarms DROP COLUMN device`,
+}
+
+// AlarmFilter narrows down GetAlarms. Empty fields are ignored.
+type AlarmFilter struct {
+	Status        string
+	PremiseIDs    []uuid.UUID
+	Severity      string
+	Type          string
+	DeviceID      string
+	TriggeredFrom *time.Time
+	TriggeredTo   *time.Time
+}
+
+// severityRank ranks severities rather than sorting them alphabetically.
+const severityRank = "CASE %s WHEN 'high' THEN 3 WHEN 'medium' THEN 2 WHEN 'low' THEN 1 ELSE 0 END"
+
+// AlarmSchema lists the fields alarms can be sorted by.
+var AlarmSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"triggered_at": {Column: "triggered_at"},
+		"created_at":   {Column: "created_at"},
+		"status":       {Column: "status"},
+		"type":         {Column: "type"},
+		"severity":     {Column: "severity", Expr: severityRank},
+	},
+	DefaultSort: "-triggered_at",
+}
+
+func (r *AlarmRepository) filterAlarms(ctx context.Context, filter AlarmFilter) *gorm.DB {
+	db := database.Conn(ctx, r.db).Model(&models.Alarm{})
+	if filter.Status == "" {
+		// Suppressed alarms are reported per maintenance window
+		db = db.Where("status <> 'suppressed'")
 	}
-	if err := query.Find(&Alarms).Error; err != nil {
-		return nil, fmt.Errorf("failed to get Alarms: %w", err)
+	return db.Scopes(
+		query.Equal("status", filter.Status),
+		query.In("premise_id", filter.PremiseIDs),
+		query.Equal("severity", filter.Severity),
+		query.Equal("type", filter.Type),
+		query.Equal("device_id", filter.DeviceID),
+		query.Between("triggered_at", filter.TriggeredFrom, filter.TriggeredTo),
+	)
+}
+
+func (r *AlarmRepository) GetAlarms(ctx context.Context, filter AlarmFilter, params query.Params) ([]models.Alarm, query.PageInfo, error) {
+	Alarms, page, err := query.Find[models.Alarm](r.filterAlarms(ctx, filter).Preload("Premise").Preload("Device").Preload("LeasedBy"), params)
+	if err != nil {
+		return nil, page, fmt.Errorf("failed to get Alarms: %w", err)
 	}
-	return Alarms, nil
+	return Alarms, page, nil
+}
+
+func (r *AlarmRepository) GetAlarmsCount(ctx context.Context, filter AlarmFilter) (int64, error) {
+	var count int64
+	if err := r.filterAlarms(ctx, filter).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to get Alarms count: %w", err)
+	}
+	return count, nil
 }
 
 func (r *AlarmRepository) GetAlarmByID(ctx context.Context, id string) (*models.Alarm, error) {
 	var Alarm models.Alarm
 
-	if err := r.db.WithContext(ctx).First(&Alarm, "id = ?", id).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Preload("LeasedBy").First(&Alarm, "id = ?", id).Error; err != nil {
 		return nil, fmt.Errorf("failed to get Alarm: %w", err)
 	}
 
 	return &Alarm, nil
 }
 
+func (r *AlarmRepository) GetAlarmsByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Alarm, error) {
+	var Alarms []models.Alarm
+	if err := database.Conn(ctx, r.db).Where("id IN ?", ids).Find(&Alarms).Error; err != nil {
+		return nil, fmt.Errorf("failed to get Alarms: %w", err)
+	}
+	return Alarms, nil
+}
+
 func (r *AlarmRepository) UpdateAlarm(ctx context.Context, id string, Alarm *models.Alarm) (*models.Alarm, error) {
-	result := r.db.WithContext(ctx).Model(&models.Alarm{}).Where("id = ?", id).Updates(Alarm)
+	result := database.Conn(ctx, r.db).Model(&models.Alarm{}).Where("id = ?", id).Updates(Alarm)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to update Alarm: %w", result.Error)
 	}
 	return Alarm, nil
 }
+
+func (r *AlarmRepository) GetAlarmsByDeviceID(ctx context.Context, deviceID string, limit int) ([]models.Alarm, error) {
+	var Alarms []models.Alarm
+	if err := database.Conn(ctx, r.db).Where("device_id = ?", deviceID).Order("triggered_at desc").Limit(limit).Find(&Alarms).Error; err != nil {
+		return nil, fmt.Errorf("failed to get Alarms: %w", err)
+	}
+	return Alarms, nil
+}
+
+// GetAlarmCountsByDeviceID returns the total number of alarms raised by a device
+// and how many of them operators ignored as false alarms.
+func (r *AlarmRepository) GetAlarmCountsByDeviceID(ctx context.Context, deviceID string) (int64, int64, error) {
+	var counts struct {
+		Total   int64
+		Ignored int64
+	}
+	if err := database.Conn(ctx, r.db).Model(&models.Alarm{}).
+		Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE status = 'ignored') AS ignored").
+		Where("device_id = ?", deviceID).
+		Scan(&counts).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to get Alarms count: %w", err)
+	}
+	return counts.Total, counts.Ignored, nil
+}
+
+func (r *AlarmRepository) GetAlarmsByMaintenanceWindowID(ctx context.Context, windowID string) ([]models.Alarm, error) {
+	var Alarms []models.Alarm
+	if err := database.Conn(ctx, r.db).Preload("Premise").Preload("Device").
+		Where("maintenance_window_id = ?", windowID).
+		Order("triggered_at desc").Find(&Alarms).Error; err != nil {
+		return nil, fmt.Errorf("failed to get Alarms: %w", err)
+	}
+	return Alarms, nil
+}
+
+// GetAlarmIDs returns up to limit IDs of alarms matching the filter.
+func (r *AlarmRepository) GetAlarmIDs(ctx context.Context, filter AlarmFilter, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := r.filterAlarms(ctx, filter).Order("triggered_at").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get Alarm IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// LockAlarms loads the given alarms with a row lock held until the surrounding
+// transaction ends.
+func (r *AlarmRepository) LockAlarms(ctx context.Context, ids []uuid.UUID) ([]models.Alarm, error) {
+	var Alarms []models.Alarm
+	if err := database.Conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).Find(&Alarms).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock Alarms: %w", err)
+	}
+	return Alarms, nil
+}
+
+func (r *AlarmRepository) UpdateAlarmsFields(ctx context.Context, ids []uuid.UUID, fields map[string]interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := database.Conn(ctx, r.db).Model(&models.Alarm{}).Where("id IN ?", ids).Updates(fields).Error; err != nil {
+		return fmt.Errorf("failed to update Alarms: %w", err)
+	}
+	return nil
+}
+
+// LinkAlarmsToIncident links alarms to an incident, ignoring existing links.
+func (r *AlarmRepository) LinkAlarmsToIncident(ctx context.Context, links []models.IncidentAlarm) error {
+	if len(links) == 0 {
+		return nil
+	}
+	if err := database.Conn(ctx, r.db).Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+		return fmt.Errorf("failed to link Alarms to incident: %w", err)
+	}
+	return nil
+}
+
+// QueuedAlarmStatuses are the statuses of alarms waiting for an operator.
+var QueuedAlarmStatuses = []string{"new", "acknowledged"}
+
+// ClaimNextAlarm locks the highest priority queued alarm that is not leased,
+// skipping rows locked by concurrent claims, and leases it to userID. It
+// returns nil when the queue is empty. Must run inside a transaction.
+func (r *AlarmRepository) ClaimNextAlarm(ctx context.Context, userID uuid.UUID, now time.Time, expiresAt time.Time) (*models.Alarm, error) {
+	var Alarms []models.Alarm
+	if err := database.Conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status IN ?", QueuedAlarmStatuses).
+		Where("leased_by_id IS NULL OR lease_expires_at <= ?", now).
+		Order(fmt.Sprintf(severityRank, "severity") + " DESC").Order("triggered_at").Order("id").
+		Limit(1).Find(&Alarms).Error; err != nil {
+		return nil, fmt.Errorf("failed to claim Alarm: %w", err)
+	}
+	if len(Alarms) == 0 {
+		return nil, nil
+	}
+	if err := r.SetAlarmLease(ctx, Alarms[0].ID, &userID, &expiresAt); err != nil {
+		return nil, err
+	}
+	return &Alarms[0], nil
+}
+
+// GetLeasedAlarmByUser returns the queued alarm userID holds an unexpired lease on, if any.
+func (r *AlarmRepository) GetLeasedAlarmByUser(ctx context.Context, userID uuid.UUID, now time.Time) (*models.Alarm, error) {
+	var Alarms []models.Alarm
+	if err := database.Conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("leased_by_id = ? AND lease_expires_at > ?", userID, now).
+		Where("status IN ?", QueuedAlarmStatuses).
+		Order("lease_expires_at").Limit(1).Find(&Alarms).Error; err != nil {
+		return nil, fmt.Errorf("failed to get leased Alarm: %w", err)
+	}
+	if len(Alarms) == 0 {
+		return nil, nil
+	}
+	return &Alarms[0], nil
+}
+
+// SetAlarmLease leases the alarm to userID until expiresAt, or clears the lease when userID is nil.
+func (r *AlarmRepository) SetAlarmLease(ctx context.Context, id uuid.UUID, userID *uuid.UUID, expiresAt *time.Time) error {
+	if err := database.Conn(ctx, r.db).Model(&models.Alarm{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"leased_by_id": userID, "lease_expires_at": expiresAt}).Error; err != nil {
+		return fmt.Errorf("failed to update Alarm lease: %w", err)
+	}
+	return nil
+}
+
+// ReleaseExpiredLeases clears every lease that expired before now.
+func (r *AlarmRepository) ReleaseExpiredLeases(ctx context.Context, now time.Time) (int64, error) {
+	result := database.Conn(ctx, r.db).Model(&models.Alarm{}).
+		Where("leased_by_id IS NOT NULL AND lease_expires_at <= ?", now).
+		Updates(map[string]interface{}{"leased_by_id": nil, "lease_expires_at": nil})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to release expired Alarm leases: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
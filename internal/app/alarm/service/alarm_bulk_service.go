@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"scs-operator/internal/app/alarm/dto"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	"scs-operator/pkg/errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	BulkActionAcknowledge  = "acknowledge"
+	BulkActionIgnore       = "ignore"
+	BulkActionDispatch     = "dispatch"
+	BulkActionLinkIncident = "link-incident"
+
+	maxBulkAlarms = 1000
+)
+
+// bulkTransitions lists the statuses an alarm may be in for each action and the
+// status it moves to. An empty from list accepts any status.
+var bulkTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	BulkActionAcknowledge: {from: []string{"new"}, to: "acknowledged"},
+	BulkActionIgnore:      {from: []string{"new", "acknowledged"}, to: "ignored"},
+	BulkActionDispatch:    {from: []string{"new", "acknowledged"}, to: "dispatched"},
+	// Alarms linked to an incident are dispatched whatever their status
+	BulkActionLinkIncident: {to: "dispatched"},
+}
+
+var errBulkRolledBack = stdErrors.New("bulk action rolled back")
+
+// BulkAction applies action to every selected alarm in a single transaction and
+// reports the outcome per alarm. Alarms that cannot take the action are reported
+// as failed; with AllOrNothing set, any failure rolls the whole batch back.
+func (s *Service) BulkAction(ctx context.Context, action string, actorID string, bulkDto *dto.BulkAlarmActionDto) (*types.BulkResponse, error) {
+	ids, err := s.resolveBulkAlarmIDs(ctx, bulkDto)
+	if err != nil {
+		return nil, err
+	}
+	var actor *uuid.UUID
+	if parsedActorID, err := uuid.Parse(actorID); err == nil {
+		actor = &parsedActorID
+	}
+	var incidentID uuid.UUID
+	if action == BulkActionLinkIncident {
+		if bulkDto.IncidentID == "" {
+			return nil, errors.NewBadRequestError("incident_id is required")
+		}
+		incident, err := s.incidentRepo.GetIncidentByID(ctx, bulkDto.IncidentID)
+		if err != nil {
+			return nil, errors.NewNotFoundError("incident")
+		}
+		incidentID = incident.ID
+	}
+
+	results := make([]types.BulkItemResult, len(ids))
+	var succeeded []uuid.UUID
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		alarms, err := s.alarmRepo.LockAlarms(ctx, ids)
+		if err != nil {
+			return err
+		}
+		alarmsByID := make(map[uuid.UUID]models.Alarm, len(alarms))
+		for _, alarm := range alarms {
+			alarmsByID[alarm.ID] = alarm
+		}
+
+		succeeded = succeeded[:0]
+		failed := false
+		for i, id := range ids {
+			results[i] = types.BulkItemResult{ID: id.String()}
+			alarm, ok := alarmsByID[id]
+			if !ok {
+				results[i].Error = "alarm not found"
+				failed = true
+				continue
+			}
+			if leasedByOther(alarm, actorID, time.Now()) {
+				results[i].Error = "alarm is claimed by another operator"
+				failed = true
+				continue
+			}
+			if reason := checkBulkTransition(action, alarm); reason != "" {
+				results[i].Error = reason
+				failed = true
+				continue
+			}
+			results[i].Success = true
+			succeeded = append(succeeded, id)
+		}
+		if failed && bulkDto.AllOrNothing {
+			return errBulkRolledBack
+		}
+
+		if err := s.applyBulkAction(ctx, action, actor, incidentID, succeeded); err != nil {
+			return err
+		}
+		details, _ := json.Marshal(map[string]string{"incident_id": bulkDto.IncidentID})
+		auditLogs := make([]models.AuditLog, 0, len(succeeded))
+		for _, id := range succeeded {
+			auditLog := models.AuditLog{
+				ActorID:    actor,
+				Action:     "alarm." + action,
+				EntityType: "alarm",
+				EntityID:   id,
+			}
+			if action == BulkActionLinkIncident {
+				auditLog.Details = models.JSONB(details)
+			}
+			auditLogs = append(auditLogs, auditLog)
+		}
+		return s.auditRepo.CreateAuditLogs(ctx, auditLogs)
+	})
+	if err != nil && !stdErrors.Is(err, errBulkRolledBack) {
+		return nil, errors.NewDatabaseError("bulk "+action+" alarms", err)
+	}
+
+	response := &types.BulkResponse{Action: action, Results: results}
+	for i := range results {
+		if err != nil && results[i].Success {
+			results[i].Success = false
+			results[i].Error = "rolled back"
+		}
+		if results[i].Success {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	if response.Succeeded > 0 {
+		s.publishAlarmBatch(ctx, action, actorID, bulkDto.IncidentID, succeeded)
+		if alarms, err := s.alarmRepo.GetAlarmsByIDs(ctx, succeeded); err == nil {
+			for i := range alarms {
+				s.publishAlarmChange("alarm.updated", &alarms[i])
+			}
+		}
+	}
+	return response, nil
+}
+
+// resolveBulkAlarmIDs returns the explicitly listed alarm IDs or, when a filter
+// is given instead, the IDs of the first matching alarms.
+func (s *Service) resolveBulkAlarmIDs(ctx context.Context, bulkDto *dto.BulkAlarmActionDto) ([]uuid.UUID, error) {
+	if len(bulkDto.AlarmIDs) > 0 && bulkDto.Filter != nil {
+		return nil, errors.NewBadRequestError("Provide either alarm_ids or filter, not both")
+	}
+	if len(bulkDto.AlarmIDs) > 0 {
+		ids := make([]uuid.UUID, 0, len(bulkDto.AlarmIDs))
+		seen := make(map[uuid.UUID]bool, len(bulkDto.AlarmIDs))
+		for _, rawID := range bulkDto.AlarmIDs {
+			id, err := uuid.Parse(rawID)
+			if err != nil {
+				return nil, errors.NewBadRequestError("Invalid alarm ID format")
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
+	if bulkDto.Filter == nil {
+		return nil, errors.NewBadRequestError("Either alarm_ids or filter is required")
+	}
+	filter, err := s.buildAlarmFilter(ctx, *bulkDto.Filter)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := s.alarmRepo.GetAlarmIDs(ctx, filter, maxBulkAlarms)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get alarms", err)
+	}
+	if len(ids) == 0 {
+		return nil, errors.NewNotFoundError("alarms matching filter")
+	}
+	return ids, nil
+}
+
+// checkBulkTransition returns why alarm cannot take action, or "" when it can.
+func checkBulkTransition(action string, alarm models.Alarm) string {
+	transition := bulkTransitions[action]
+	if len(transition.from) == 0 {
+		return ""
+	}
+	for _, from := range transition.from {
+		if alarm.Status == from {
+			return ""
+		}
+	}
+	return "cannot " + action + " alarm with status " + alarm.Status
+}
+
+func (s *Service) applyBulkAction(ctx context.Context, action string, actor *uuid.UUID, incidentID uuid.UUID, ids []uuid.UUID) error {
+	if action == BulkActionLinkIncident {
+		links := make([]models.IncidentAlarm, 0, len(ids))
+		for _, id := range ids {
+			links = append(links, models.IncidentAlarm{IncidentID: incidentID, AlarmID: id, LinkedByID: actor})
+		}
+		if err := s.alarmRepo.LinkAlarmsToIncident(ctx, links); err != nil {
+			return err
+		}
+	}
+	fields := map[string]interface{}{"status": bulkTransitions[action].to}
+	if action == BulkActionAcknowledge {
+		fields["acknowledged_by_id"] = actor
+		fields["acknowledged_at"] = time.Now()
+	} else {
+		// Ignored and dispatched alarms leave the operator queue
+		fields["leased_by_id"] = nil
+		fields["lease_expires_at"] = nil
+	}
+	return s.alarmRepo.UpdateAlarmsFields(ctx, ids, fields)
+}
+
+// publishAlarmBatch sends a single event covering every alarm changed by a bulk action.
+func (s *Service) publishAlarmBatch(ctx context.Context, action string, actorID string, incidentID string, ids []uuid.UUID) {
+	alarmIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		alarmIDs = append(alarmIDs, id.String())
+	}
+	message := types.Message[types.AlarmBatchEvent]{
+		Type: "alarm.bulk_" + action,
+		Payload: types.AlarmBatchEvent{
+			Action:     action,
+			AlarmIDs:   alarmIDs,
+			ActorID:    actorID,
+			IncidentID: incidentID,
+		},
+	}
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	// The batch is already committed, a failed notification must not fail the request
+	_ = s.producer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(uuid.NewString()),
+		Value: messageBytes,
+	})
+}
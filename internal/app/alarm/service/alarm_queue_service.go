@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"scs-operator/internal/app/alarm/dto"
+	"scs-operator/internal/models"
+	"scs-operator/pkg/errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ClaimNextAlarm leases the highest priority queued alarm to the operator. An
+// operator already holding a lease gets that alarm back with the lease renewed,
+// so an alarm is never worked by two operators and no operator hoards alarms.
+func (s *Service) ClaimNextAlarm(ctx context.Context, actorID string) (*models.Alarm, error) {
+	operatorID, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid user")
+	}
+	var claimedID uuid.UUID
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		now := time.Now()
+		expiresAt := now.Add(s.leaseDuration)
+		alarm, err := s.alarmRepo.GetLeasedAlarmByUser(ctx, operatorID, now)
+		if err != nil {
+			return errors.NewDatabaseError("get leased alarm", err)
+		}
+		if alarm != nil {
+			if err := s.alarmRepo.SetAlarmLease(ctx, alarm.ID, &operatorID, &expiresAt); err != nil {
+				return errors.NewDatabaseError("renew alarm lease", err)
+			}
+			claimedID = alarm.ID
+			return nil
+		}
+		alarm, err = s.alarmRepo.ClaimNextAlarm(ctx, operatorID, now, expiresAt)
+		if err != nil {
+			return errors.NewDatabaseError("claim alarm", err)
+		}
+		if alarm == nil {
+			return errors.NewNotFoundError("queued alarm")
+		}
+		claimedID = alarm.ID
+		return s.auditLease(ctx, "alarm.claim", &operatorID, alarm.ID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getChangedAlarm(ctx, claimedID.String())
+}
+
+// ReleaseAlarm returns an alarm the operator holds back to the queue.
+func (s *Service) ReleaseAlarm(ctx context.Context, id string, actorID string) (*models.Alarm, error) {
+	operatorID, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid user")
+	}
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		alarm, err := s.lockLeasedAlarm(ctx, id, operatorID)
+		if err != nil {
+			return err
+		}
+		if err := s.alarmRepo.SetAlarmLease(ctx, alarm.ID, nil, nil); err != nil {
+			return errors.NewDatabaseError("release alarm", err)
+		}
+		return s.auditLease(ctx, "alarm.release", &operatorID, alarm.ID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getChangedAlarm(ctx, id)
+}
+
+// HandoverAlarm moves the operator's lease on an alarm to another operator,
+// who gets a fresh lease.
+func (s *Service) HandoverAlarm(ctx context.Context, id string, actorID string, handoverDto *dto.HandoverAlarmDto) (*models.Alarm, error) {
+	operatorID, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid user")
+	}
+	target, err := s.userRepo.GetUserByID(ctx, handoverDto.UserID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("user")
+	}
+	if target.ID == operatorID {
+		return nil, errors.NewBadRequestError("Cannot hand an alarm over to yourself")
+	}
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		alarm, err := s.lockLeasedAlarm(ctx, id, operatorID)
+		if err != nil {
+			return err
+		}
+		expiresAt := time.Now().Add(s.leaseDuration)
+		if err := s.alarmRepo.SetAlarmLease(ctx, alarm.ID, &target.ID, &expiresAt); err != nil {
+			return errors.NewDatabaseError("hand over alarm", err)
+		}
+		return s.auditLease(ctx, "alarm.handover", &operatorID, alarm.ID, map[string]string{"to_user_id": target.ID.String()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getChangedAlarm(ctx, id)
+}
+
+// ReleaseExpiredLeases clears lapsed leases so the alarms no longer show a holder.
+// Claims ignore expired leases anyway, this only keeps the alarms accurate.
+func (s *Service) ReleaseExpiredLeases(ctx context.Context) (int64, error) {
+	released, err := s.alarmRepo.ReleaseExpiredLeases(ctx, time.Now())
+	if err != nil {
+		return 0, errors.NewDatabaseError("release expired alarm leases", err)
+	}
+	return released, nil
+}
+
+// lockAlarm loads the alarm with a row lock held until the surrounding transaction ends.
+func (s *Service) lockAlarm(ctx context.Context, id string) (*models.Alarm, error) {
+	alarmID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.NewBadRequestError("Invalid alarm ID format")
+	}
+	alarms, err := s.alarmRepo.LockAlarms(ctx, []uuid.UUID{alarmID})
+	if err != nil {
+		return nil, errors.NewDatabaseError("lock alarm", err)
+	}
+	if len(alarms) == 0 {
+		return nil, errors.NewNotFoundError("alarm")
+	}
+	return &alarms[0], nil
+}
+
+// lockLeasedAlarm locks the alarm and checks operatorID holds an unexpired lease on it.
+func (s *Service) lockLeasedAlarm(ctx context.Context, id string, operatorID uuid.UUID) (*models.Alarm, error) {
+	alarm, err := s.lockAlarm(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !isLeaseActive(*alarm, time.Now()) || *alarm.LeasedByID != operatorID {
+		return nil, errors.NewConflictError("Alarm is not claimed by you")
+	}
+	return alarm, nil
+}
+
+// leasedByOther reports whether someone other than actorID holds an unexpired lease on alarm.
+func leasedByOther(alarm models.Alarm, actorID string, now time.Time) bool {
+	return isLeaseActive(alarm, now) && alarm.LeasedByID.String() != actorID
+}
+
+func isLeaseActive(alarm models.Alarm, now time.Time) bool {
+	return alarm.LeasedByID != nil && alarm.LeaseExpiresAt != nil && alarm.LeaseExpiresAt.After(now)
+}
+
+func (s *Service) auditLease(ctx context.Context, action string, actor *uuid.UUID, alarmID uuid.UUID, details map[string]string) error {
+	auditLog := models.AuditLog{ActorID: actor, Action: action, EntityType: "alarm", EntityID: alarmID}
+	if details != nil {
+		detailBytes, _ := json.Marshal(details)
+		auditLog.Details = models.JSONB(detailBytes)
+	}
+	if err := s.auditRepo.CreateAuditLogs(ctx, []models.AuditLog{auditLog}); err != nil {
+		return errors.NewDatabaseError("create audit log", err)
+	}
+	return nil
+}
+
+// getChangedAlarm reloads an alarm after a committed change and pushes it to
+// streaming clients.
+func (s *Service) getChangedAlarm(ctx context.Context, id string) (*models.Alarm, error) {
+	alarm, err := s.alarmRepo.GetAlarmByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewNotFoundError("alarm")
+	}
+	s.publishAlarmChange("alarm.updated", alarm)
+	return alarm, nil
+}
@@ -3,27 +3,50 @@ package services
 import (
 	"context"
 	"encoding/json"
+	stdErrors "errors"
 	"scs-operator/internal/app/alarm/dto"
 	alarmRepositories "scs-operator/internal/app/alarm/repository"
+	auditRepositories "scs-operator/internal/app/audit/repository"
+	deviceRepositories "scs-operator/internal/app/device/repository"
+	incidentDto "scs-operator/internal/app/incident/dto"
+	incidentRepositories "scs-operator/internal/app/incident/repository"
+	incidentServices "scs-operator/internal/app/incident/service"
+	maintenanceWindowRepositories "scs-operator/internal/app/maintenance-window/repository"
 	premiseRepositories "scs-operator/internal/app/premise/repository"
+	userRepositories "scs-operator/internal/app/user/repository"
 	"scs-operator/internal/models"
 	"scs-operator/internal/types"
+	database "scs-operator/pkg/db"
 	"scs-operator/pkg/errors"
 	kafka_client "scs-operator/pkg/kafka"
+	"scs-operator/pkg/query"
+	"scs-operator/pkg/stream"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
 )
 
 type Service struct {
-	alarmRepo   alarmRepositories.AlarmRepository
-	premiseRepo premiseRepositories.PremiseRepository
-	producer    kafka_client.Producer
+	alarmRepo             alarmRepositories.AlarmRepository
+	premiseRepo           premiseRepositories.PremiseRepository
+	deviceRepo            deviceRepositories.DeviceRepository
+	maintenanceWindowRepo maintenanceWindowRepositories.MaintenanceWindowRepository
+	incidentRepo          incidentRepositories.IncidentRepository
+	incidentService       incidentServices.Service
+	auditRepo             auditRepositories.AuditRepository
+	userRepo              userRepositories.UserRepository
+	transactor            database.Transactor
+	producer              kafka_client.Producer
+	broker                stream.Broker
+	// leaseDuration is how long an operator may hold a claimed alarm
+	leaseDuration time.Duration
 }
 
-func NewAlarmService(alarmRepo alarmRepositories.AlarmRepository, premiseRepo premiseRepositories.PremiseRepository, producer kafka_client.Producer) *Service {
-	return &Service{alarmRepo: alarmRepo, premiseRepo: premiseRepo, producer: producer}
+func NewAlarmService(alarmRepo alarmRepositories.AlarmRepository, premiseRepo premiseRepositories.PremiseRepository, deviceRepo deviceRepositories.DeviceRepository, maintenanceWindowRepo maintenanceWindowRepositories.MaintenanceWindowRepository, incidentRepo incidentRepositories.IncidentRepository, incidentService incidentServices.Service, auditRepo auditRepositories.AuditRepository, userRepo userRepositories.UserRepository, transactor database.Transactor, producer kafka_client.Producer, broker stream.Broker, leaseDuration time.Duration) *Service {
+	return &Service{alarmRepo: alarmRepo, premiseRepo: premiseRepo, deviceRepo: deviceRepo, maintenanceWindowRepo: maintenanceWindowRepo, incidentRepo: incidentRepo, incidentService: incidentService, auditRepo: auditRepo, userRepo: userRepo, transactor: transactor, producer: producer, broker: broker, leaseDuration: leaseDuration}
 }
 
 func (s *Service) CreateAlarm(ctx context.Context, createAlarmDto *dto.CreateAlarmDto) (*models.Alarm, error) {
@@ -40,6 +63,20 @@ func (s *Service) CreateAlarm(ctx context.Context, createAlarmDto *dto.CreateAla
 		}
 		alarm.TriggeredAt = parsedTime
 	}
+	device, err := s.resolveDevice(ctx, createAlarmDto)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		alarm.DeviceSerial = createAlarmDto.Serial()
+	} else {
+		alarm.DeviceID = &device.ID
+		alarm.Device = device
+		// Alarms from a device belong to the premise it is installed on
+		if createAlarmDto.PremiseID == "" {
+			createAlarmDto.PremiseID = device.PremiseID.String()
+		}
+	}
 	if createAlarmDto.PremiseID != "" {
 		premiseID, err := uuid.Parse(createAlarmDto.PremiseID)
 
@@ -55,22 +92,39 @@ func (s *Service) CreateAlarm(ctx context.Context, createAlarmDto *dto.CreateAla
 		alarm.Premise = premise
 		alarm.PremiseID = premiseID
 	}
+	window, err := s.findActiveMaintenanceWindow(ctx, alarm)
+	if err != nil {
+		return nil, errors.NewDatabaseError("check maintenance windows", err)
+	}
+	if window != nil {
+		alarm.Status = "suppressed"
+		alarm.MaintenanceWindowID = &window.ID
+	}
 	createdAlarm, err := s.alarmRepo.CreateAlarm(ctx, alarm)
 	if err != nil {
 		return nil, errors.NewDatabaseError("create alarm", err)
 	}
+	// Callers raising the alarm inside their own transaction may still roll it back
+	database.AfterCommit(ctx, func() { s.announceAlarmCreated(ctx, createdAlarm) })
+	return createdAlarm, nil
+}
+
+// announceAlarmCreated pushes a committed alarm to streaming clients and Kafka.
+func (s *Service) announceAlarmCreated(ctx context.Context, createdAlarm *models.Alarm) {
+	s.publishAlarmChange("alarm.created", createdAlarm)
+	// Suppressed alarms are only kept for reporting and must not notify anyone
+	if createdAlarm.Status == "suppressed" {
+		return
+	}
 	message := types.Message[models.Alarm]{
 		Type:    "alarm.created",
 		Payload: *createdAlarm,
 	}
 	messageBytes, err := json.Marshal(message)
-
-	// Send Kafka message after successful alarm creation
-
 	if err != nil {
 		// Log error but don't fail the operation since alarm was created successfully
 		// You might want to add proper logging here
-		return createdAlarm, nil
+		return
 	}
 
 	producerMessage := kafka.Message{
@@ -78,33 +132,223 @@ func (s *Service) CreateAlarm(ctx context.Context, createAlarmDto *dto.CreateAla
 		Value: messageBytes,
 	}
 
-	if err := s.producer.WriteMessages(ctx, producerMessage); err != nil {
-		// Log error but don't fail the operation since alarm was created successfully
-		// You might want to add proper logging here
-		return createdAlarm, nil
+	// Log error but don't fail the operation since alarm was created successfully
+	// You might want to add proper logging here
+	_ = s.producer.WriteMessages(ctx, producerMessage)
+}
+
+func (s *Service) GetAlarms(ctx context.Context, getAlarmsDto *dto.GetAlarmsDto) (*types.PaginateResponse[models.Alarm], error) {
+	params, err := query.New(alarmRepositories.AlarmSchema, getAlarmsDto.Request)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	filter, err := s.buildAlarmFilter(ctx, dto.BulkAlarmFilter{
+		Status:             getAlarmsDto.Status,
+		PremiseID:          getAlarmsDto.PremiseID,
+		IncludeSubPremises: getAlarmsDto.IncludeSubPremises,
+		Severity:           getAlarmsDto.Severity,
+		Type:               getAlarmsDto.Type,
+		DeviceID:           getAlarmsDto.DeviceID,
+		TriggeredFrom:      getAlarmsDto.TriggeredFrom,
+		TriggeredTo:        getAlarmsDto.TriggeredTo,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return createdAlarm, nil
+	alarms, page, err := s.alarmRepo.GetAlarms(ctx, filter, params)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get alarms", err)
+	}
+	if params.IncludeTotal {
+		total, err := s.alarmRepo.GetAlarmsCount(ctx, filter)
+		if err != nil {
+			return nil, errors.NewDatabaseError("get alarms count", err)
+		}
+		page.Total = &total
+	}
+	return types.NewPaginateResponse(alarms, page), nil
+}
 
+// UpdateAlarm changes the status of an alarm. The alarm is locked while its
+// lease is checked, so an operator claiming it concurrently is not overridden.
+func (s *Service) UpdateAlarm(ctx context.Context, id string, actorID string, updateAlarmDto *dto.UpdateAlarmDto) (*models.Alarm, error) {
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		alarm, err := s.lockAlarm(ctx, id)
+		if err != nil {
+			return err
+		}
+		if leasedByOther(*alarm, actorID, time.Now()) {
+			return errors.NewConflictError("Alarm is claimed by another operator")
+		}
+		fields := map[string]interface{}{"status": updateAlarmDto.Status}
+		// Alarms leaving the queue no longer need an operator lease
+		if alarm.LeasedByID != nil && !slices.Contains(alarmRepositories.QueuedAlarmStatuses, updateAlarmDto.Status) {
+			fields["leased_by_id"] = nil
+			fields["lease_expires_at"] = nil
+		}
+		if err := s.alarmRepo.UpdateAlarmsFields(ctx, []uuid.UUID{alarm.ID}, fields); err != nil {
+			return errors.NewDatabaseError("update alarm", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getChangedAlarm(ctx, id)
 }
 
-func (s *Service) GetAlarms(ctx context.Context, status string) ([]models.Alarm, error) {
-	alarms, err := s.alarmRepo.GetAlarms(ctx, status)
+// publishAlarmChange pushes a committed alarm change to streaming clients.
+func (s *Service) publishAlarmChange(eventType string, alarm *models.Alarm) {
+	premiseID := ""
+	if alarm.PremiseID != uuid.Nil {
+		premiseID = alarm.PremiseID.String()
+	}
+	s.broker.Publish(eventType, premiseID, alarm)
+}
+
+// resolveDevice looks up the device that raised an alarm by ID or serial. It
+// returns nil when no device is given or the device is not registered.
+func (s *Service) resolveDevice(ctx context.Context, createAlarmDto *dto.CreateAlarmDto) (*models.Device, error) {
+	var device *models.Device
+	var err error
+	if createAlarmDto.DeviceID != "" {
+		deviceID, parseErr := uuid.Parse(createAlarmDto.DeviceID)
+		if parseErr != nil {
+			return nil, errors.NewBadRequestError("Invalid device ID format")
+		}
+		device, err = s.deviceRepo.GetDeviceByID(ctx, deviceID.String())
+	} else if serial := createAlarmDto.Serial(); serial != "" {
+		device, err = s.deviceRepo.GetDeviceBySerial(ctx, serial)
+	} else {
+		return nil, nil
+	}
+	// An alarm from a device missing from the registry is still an alarm
+	if stdErrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, errors.NewDatabaseError("get alarms", err)
+		return nil, errors.NewDatabaseError("get device", err)
 	}
-	return alarms, nil
+	return device, nil
 }
 
-func (s *Service) UpdateAlarm(ctx context.Context, id string, updateAlarmDto *dto.UpdateAlarmDto) (*models.Alarm, error) {
-	alarm, err := s.alarmRepo.GetAlarmByID(ctx, id)
+// findActiveMaintenanceWindow returns the maintenance window covering the
+// alarm's device or premise at the time it was triggered, if any.
+func (s *Service) findActiveMaintenanceWindow(ctx context.Context, alarm *models.Alarm) (*models.MaintenanceWindow, error) {
+	triggeredAt := alarm.TriggeredAt
+	if triggeredAt.IsZero() {
+		triggeredAt = time.Now()
+	}
+	var premiseID *uuid.UUID
+	var ancestorIDs []uuid.UUID
+	if alarm.PremiseID != uuid.Nil {
+		premiseID = &alarm.PremiseID
+		ids, err := s.premiseRepo.GetAncestorIDs(ctx, alarm.PremiseID.String())
+		if err != nil {
+			return nil, err
+		}
+		// The first ID is the premise itself
+		if len(ids) > 1 {
+			ancestorIDs = ids[1:]
+		}
+	}
+	windows, err := s.maintenanceWindowRepo.GetCandidateWindows(ctx, premiseID, ancestorIDs, alarm.DeviceID, triggeredAt)
 	if err != nil {
-		return nil, errors.NewNotFoundError("alarm not found")
+		return nil, err
+	}
+	for i := range windows {
+		if windows[i].IsActiveAt(triggeredAt) {
+			return &windows[i], nil
+		}
 	}
-	alarm.Status = updateAlarmDto.Status
-	updatedAlarm, err := s.alarmRepo.UpdateAlarm(ctx, id, alarm)
+	return nil, nil
+}
+
+// buildAlarmFilter turns filter parameters into a repository filter, expanding
+// the premise to its sub-premises when requested.
+func (s *Service) buildAlarmFilter(ctx context.Context, params dto.BulkAlarmFilter) (alarmRepositories.AlarmFilter, error) {
+	filter := alarmRepositories.AlarmFilter{
+		Status:   params.Status,
+		Severity: params.Severity,
+		Type:     params.Type,
+		DeviceID: params.DeviceID,
+	}
+	if params.PremiseID != "" {
+		premiseID, err := uuid.Parse(params.PremiseID)
+		if err != nil {
+			return filter, errors.NewBadRequestError("Invalid premise ID format")
+		}
+		filter.PremiseIDs = []uuid.UUID{premiseID}
+		if params.IncludeSubPremises {
+			ids, err := s.premiseRepo.GetDescendantIDs(ctx, premiseID.String())
+			if err != nil {
+				return filter, errors.NewDatabaseError("get sub-premises", err)
+			}
+			filter.PremiseIDs = ids
+		}
+	}
+	var err error
+	if filter.TriggeredFrom, err = query.ParseTime("triggered_from", params.TriggeredFrom); err != nil {
+		return filter, errors.NewBadRequestError(err.Error())
+	}
+	if filter.TriggeredTo, err = query.ParseTime("triggered_to", params.TriggeredTo); err != nil {
+		return filter, errors.NewBadRequestError(err.Error())
+	}
+	return filter, nil
+}
+
+// EscalateAlarm creates an incident for the alarm, prefilled from its premise,
+// severity and description. The alarm is linked and dispatched. It stays locked
+// until the incident is created, so it cannot be escalated twice or claimed by
+// another operator meanwhile.
+func (s *Service) EscalateAlarm(ctx context.Context, id string, actorID string, escalateDto *dto.EscalateAlarmDto) (*models.Incident, error) {
+	var incident *models.Incident
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		alarm, err := s.lockAlarm(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(alarmRepositories.QueuedAlarmStatuses, alarm.Status) {
+			return errors.NewConflictError("Alarm is " + alarm.Status + " and can no longer be escalated")
+		}
+		if leasedByOther(*alarm, actorID, time.Now()) {
+			return errors.NewConflictError("Alarm is claimed by another operator")
+		}
+		createIncidentDto := &incidentDto.CreateIncidentDto{
+			Name:               alarm.Type + " alarm",
+			Description:        alarm.Description,
+			AlarmIDs:           []string{alarm.ID.String()},
+			Severity:           alarm.Severity,
+			GuidanceTemplateID: escalateDto.GuidanceTemplateID,
+			Assignee:           escalateDto.Assignee,
+		}
+		if alarm.PremiseID != uuid.Nil {
+			premise, err := s.premiseRepo.GetPremiseByID(ctx, alarm.PremiseID.String())
+			if err != nil {
+				return errors.NewNotFoundError("premise")
+			}
+			createIncidentDto.Name = alarm.Type + " alarm at " + premise.Name
+			createIncidentDto.PremiseID = premise.ID.String()
+			createIncidentDto.Location = premise.Address
+		}
+		if escalateDto.Name != "" {
+			createIncidentDto.Name = escalateDto.Name
+		}
+		if escalateDto.Description != "" {
+			createIncidentDto.Description = escalateDto.Description
+		}
+		if escalateDto.Severity != "" {
+			createIncidentDto.Severity = escalateDto.Severity
+		}
+		if escalateDto.Location != "" {
+			createIncidentDto.Location = escalateDto.Location
+		}
+		incident, err = s.incidentService.CreateIncident(ctx, actorID, createIncidentDto)
+		return err
+	})
 	if err != nil {
-		return nil, errors.NewDatabaseError("update alarm", err)
+		return nil, err
 	}
-	return updatedAlarm, nil
+	return incident, nil
 }
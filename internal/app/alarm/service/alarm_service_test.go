@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"scs-operator/internal/app/alarm/dto"
+	alarmRepositories "scs-operator/internal/app/alarm/repository"
+	auditRepositories "scs-operator/internal/app/audit/repository"
+	deviceRepositories "scs-operator/internal/app/device/repository"
+	incidentRepositories "scs-operator/internal/app/incident/repository"
+	maintenanceWindowRepositories "scs-operator/internal/app/maintenance-window/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	userRepositories "scs-operator/internal/app/user/repository"
+	"scs-operator/internal/testsupport"
+	"scs-operator/internal/testsupport/incidenttest"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/query"
+	"scs-operator/pkg/stream"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	testAlarmID   = "3e9f5d7b-88c4-4c4b-8c88-6c3d0c6e5f04"
+	testDeviceID  = "6a0b4c8d-2e1f-4a3b-9c5d-7e8f9a0b1c2d"
+	testPremiseID = "c18c4a6e-118d-4fde-9f11-5f2a9f5b4c13"
+)
+
+// The alarm service cannot use alarmtest, which imports this package
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := testsupport.NewMockDB(t)
+	svc := NewAlarmService(
+		*alarmRepositories.NewAlarmRepository(db),
+		*premiseRepositories.NewPremiseRepository(db),
+		*deviceRepositories.NewDeviceRepository(db),
+		*maintenanceWindowRepositories.NewMaintenanceWindowRepository(db),
+		*incidentRepositories.NewIncidentRepository(db),
+		*incidenttest.NewService(t, db),
+		*auditRepositories.NewAuditRepository(db),
+		*userRepositories.NewUserRepository(db),
+		*database.NewTransactor(db),
+		testsupport.NewProducer(t),
+		*stream.NewBroker(10),
+		5*time.Minute,
+	)
+	return svc, mock
+}
+
+// expectDevicePremise looks up the premise of the test device and finds no
+// maintenance window covering it.
+func expectDevicePremise(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testPremiseID, "Head office"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`WITH RECURSIVE ancestors`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testPremiseID))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "maintenance_windows"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+// expectAlarmInsert expects the alarm to be inserted. Alarms linked to a
+// device also upsert the device and its premise they were loaded with.
+func expectAlarmInsert(mock sqlmock.Sqlmock, linked bool) {
+	mock.ExpectBegin()
+	if linked {
+		mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "premises"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "devices"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+	mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "alarms"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testAlarmID))
+	mock.ExpectCommit()
+}
+
+func TestCreateAlarmResolvesDevice(t *testing.T) {
+	deviceRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "serial", "premise_id"}).AddRow(testDeviceID, "door-3", testPremiseID)
+	}
+	tests := []struct {
+		name           string
+		createAlarmDto dto.CreateAlarmDto
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+		expectDeviceID bool
+		expectSerial   string
+	}{
+		{
+			name:           "Registered serial links the device and its premise",
+			createAlarmDto: dto.CreateAlarmDto{Type: "intrusion", Severity: "high", DeviceSerial: "door-3"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "devices" WHERE serial = $1`)).
+					WithArgs("door-3", 1).
+					WillReturnRows(deviceRows())
+				expectDevicePremise(mock)
+				expectAlarmInsert(mock, true)
+			},
+			expectDeviceID: true,
+		},
+		{
+			name:           "Deprecated device field is read as the serial",
+			createAlarmDto: dto.CreateAlarmDto{Type: "intrusion", Severity: "high", Device: "door-3"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "devices" WHERE serial = $1`)).
+					WithArgs("door-3", 1).
+					WillReturnRows(deviceRows())
+				expectDevicePremise(mock)
+				expectAlarmInsert(mock, true)
+			},
+			expectDeviceID: true,
+		},
+		{
+			name:           "Unregistered serial is kept on the alarm",
+			createAlarmDto: dto.CreateAlarmDto{Type: "intrusion", Severity: "high", DeviceSerial: "door-9"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "devices" WHERE serial = $1`)).
+					WithArgs("door-9", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				expectAlarmInsert(mock, false)
+			},
+			expectSerial: "door-9",
+		},
+		{
+			name:           "Unregistered device ID is stored without a device",
+			createAlarmDto: dto.CreateAlarmDto{Type: "intrusion", Severity: "high", DeviceID: testDeviceID},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "devices" WHERE id = $1`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				expectAlarmInsert(mock, false)
+			},
+		},
+		{
+			name:           "Invalid device ID",
+			createAlarmDto: dto.CreateAlarmDto{Type: "intrusion", Severity: "high", DeviceID: "door-3"},
+			expect:         func(sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Device lookup failure is not mistaken for an unknown device",
+			createAlarmDto: dto.CreateAlarmDto{Type: "intrusion", Severity: "high", DeviceSerial: "door-3"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "devices" WHERE serial = $1`)).
+					WillReturnError(testsupport.ErrInjected)
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			alarm, err := svc.CreateAlarm(context.Background(), &tt.createAlarmDto)
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := alarm.DeviceID != nil; got != tt.expectDeviceID {
+				t.Errorf("expected device linked %v, got %v", tt.expectDeviceID, got)
+			}
+			if tt.expectDeviceID && alarm.PremiseID.String() != testPremiseID {
+				t.Errorf("expected the device's premise, got %s", alarm.PremiseID)
+			}
+			if alarm.DeviceSerial != tt.expectSerial {
+				t.Errorf("expected device serial %q, got %q", tt.expectSerial, alarm.DeviceSerial)
+			}
+		})
+	}
+}
+
+func TestGetAlarms(t *testing.T) {
+	const subPremiseID = "d29d5b7f-229e-4a0f-8a22-6a3b0a6c5d24"
+	withTotal := true
+	tests := []struct {
+		name           string
+		getAlarmsDto   dto.GetAlarmsDto
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name: "Premise filter covers sub-premises, sorted by severity rank",
+			getAlarmsDto: dto.GetAlarmsDto{
+				Request:            query.Request{Page: 1, Limit: 10, Sort: "-severity,triggered_at", IncludeTotal: &withTotal},
+				PremiseID:          testPremiseID,
+				IncludeSubPremises: true,
+				Type:               "intrusion",
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				// UNION rather than UNION ALL, so a cycle cannot loop forever
+				mock.ExpectQuery(`WITH RECURSIVE descendants AS \(\s+SELECT id FROM premises WHERE id = \$1\s+UNION\s+SELECT`).
+					WithArgs(testPremiseID).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testPremiseID).AddRow(subPremiseID))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "alarms" WHERE status <> 'suppressed' AND premise_id IN ($1,$2) AND type = $3 ORDER BY CASE "alarms"."severity" WHEN 'high' THEN 3 WHEN 'medium' THEN 2 WHEN 'low' THEN 1 ELSE 0 END DESC, "alarms"."triggered_at" ASC, "alarms"."id" ASC LIMIT $4`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testAlarmID))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT count(*) FROM "alarms" WHERE status <> 'suppressed' AND premise_id IN ($1,$2) AND type = $3`)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			},
+		},
+		{
+			name:           "Unknown sort field",
+			getAlarmsDto:   dto.GetAlarmsDto{Request: query.Request{Page: 1, Limit: 10, Sort: "description"}},
+			expect:         func(sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid triggered-at range",
+			getAlarmsDto:   dto.GetAlarmsDto{Request: query.Request{Page: 1, Limit: 10}, TriggeredFrom: "yesterday"},
+			expect:         func(sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			page, err := svc.GetAlarms(context.Background(), &tt.getAlarmsDto)
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(page.Data) != 1 || page.Data[0].ID.String() != testAlarmID {
+				t.Errorf("expected alarm %s, got %+v", testAlarmID, page.Data)
+			}
+		})
+	}
+}
+
+func TestUpdateAlarm(t *testing.T) {
+	const operatorID = "5b8e2c1d-7f3a-4e6b-9d2c-1a4f6e8b0c37"
+	const otherOperatorID = "8c9f3d2e-0a4b-4f7c-ae3d-2b5a7f9c1d48"
+	lockAlarm := testsupport.QuoteSQL(`SELECT * FROM "alarms" WHERE id IN ($1) FOR UPDATE`)
+	leasedRows := func(leasedByID string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "status", "leased_by_id", "lease_expires_at"}).
+			AddRow(testAlarmID, "acknowledged", leasedByID, time.Now().Add(time.Minute))
+	}
+	tests := []struct {
+		name           string
+		id             string
+		status         string
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name:   "Lease holder dispatches the alarm and releases the lease",
+			id:     testAlarmID,
+			status: "dispatched",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockAlarm).WithArgs(testAlarmID).WillReturnRows(leasedRows(operatorID))
+				testsupport.ExpectExec(mock, `UPDATE "alarms" SET "lease_expires_at"=$1,"leased_by_id"=$2,"status"=$3,"updated_at"=$4 WHERE id IN ($5)`, nil)
+				mock.ExpectCommit()
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "alarms" WHERE id = $1`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(testAlarmID, "dispatched"))
+			},
+		},
+		{
+			name:   "Lease holder keeps the lease while the alarm stays queued",
+			id:     testAlarmID,
+			status: "new",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockAlarm).WithArgs(testAlarmID).WillReturnRows(leasedRows(operatorID))
+				testsupport.ExpectExec(mock, `UPDATE "alarms" SET "status"=$1,"updated_at"=$2 WHERE id IN ($3)`, nil)
+				mock.ExpectCommit()
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "alarms" WHERE id = $1`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(testAlarmID, "new"))
+			},
+		},
+		{
+			name:   "Alarm claimed by another operator",
+			id:     testAlarmID,
+			status: "dispatched",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockAlarm).WithArgs(testAlarmID).WillReturnRows(leasedRows(otherOperatorID))
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:   "Alarm not found",
+			id:     testAlarmID,
+			status: "dispatched",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockAlarm).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Invalid alarm ID",
+			id:     "alarm-1",
+			status: "dispatched",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			alarm, err := svc.UpdateAlarm(context.Background(), tt.id, operatorID, &dto.UpdateAlarmDto{Status: tt.status})
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if alarm.Status != tt.status {
+				t.Errorf("expected status %s, got %s", tt.status, alarm.Status)
+			}
+		})
+	}
+}
+
+func TestEscalateAlarm(t *testing.T) {
+	const operatorID = "5b8e2c1d-7f3a-4e6b-9d2c-1a4f6e8b0c37"
+	const otherOperatorID = "8c9f3d2e-0a4b-4f7c-ae3d-2b5a7f9c1d48"
+	const incidentID = "0b6c2a4e-55f1-4f1e-9f55-3f0a7f3b2c01"
+	lockAlarm := testsupport.QuoteSQL(`SELECT * FROM "alarms" WHERE id IN ($1) FOR UPDATE`)
+	alarmRows := func(status string, leasedByID interface{}) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "type", "severity", "status", "premise_id", "leased_by_id", "lease_expires_at"}).
+			AddRow(testAlarmID, "intrusion", "high", status, testPremiseID, leasedByID, time.Now().Add(time.Minute))
+	}
+	premiseRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "address"}).AddRow(testPremiseID, "Head office", "Main street 1")
+	}
+	tests := []struct {
+		name           string
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name: "Queued alarm is escalated while locked",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockAlarm).WithArgs(testAlarmID).WillReturnRows(alarmRows("acknowledged", operatorID))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).WillReturnRows(premiseRows())
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "alarms" WHERE id IN ($1)`)).
+					WillReturnRows(alarmRows("acknowledged", operatorID))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).WillReturnRows(premiseRows())
+				mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "incidents"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(incidentID))
+				mock.ExpectExec(testsupport.QuoteSQL(`INSERT INTO "incident_alarms"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+				testsupport.ExpectExec(mock, `UPDATE "alarms" SET "lease_expires_at"=$1,"leased_by_id"=$2,"status"=$3,"updated_at"=$4 WHERE id IN ($5)`, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Dispatched alarm cannot be escalated again",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockAlarm).WillReturnRows(alarmRows("dispatched", nil))
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "Alarm claimed by another operator",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockAlarm).WillReturnRows(alarmRows("new", otherOperatorID))
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "Alarm not found",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockAlarm).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "Incident failure keeps the alarm queued",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockAlarm).WillReturnRows(alarmRows("new", nil))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).WillReturnRows(premiseRows())
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "alarms" WHERE id IN ($1)`)).
+					WillReturnRows(alarmRows("new", nil))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).WillReturnRows(premiseRows())
+				mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "incidents"`)).WillReturnError(testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			incident, err := svc.EscalateAlarm(context.Background(), testAlarmID, operatorID, &dto.EscalateAlarmDto{})
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if incident.Name != "intrusion alarm at Head office" {
+				t.Errorf("expected the incident to be named after the alarm and premise, got %q", incident.Name)
+			}
+		})
+	}
+}
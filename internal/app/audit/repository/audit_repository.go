@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) CreateAuditLogs(ctx context.Context, auditLogs []models.AuditLog) error {
+	if len(auditLogs) == 0 {
+		return nil
+	}
+	if err := database.Conn(ctx, r.db).Create(&auditLogs).Error; err != nil {
+		return fmt.Errorf("failed to create audit logs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"scs-operator/internal/app/device/dto"
+	services "scs-operator/internal/app/device/service"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/query"
+	"scs-operator/pkg/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler
+type Handler struct {
+	svc services.Service
+}
+
+// NewHandler constructor
+func NewHandler(svc services.Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// CreateDevice registers a new device
+// @Summary Register a new device
+// @Description Register a sensor or other alarm source on a premise
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param device body dto.CreateDeviceDto true "Device creation data"
+// @Success 201 {object} models.Device
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /devices [post]
+func (h *Handler) CreateDevice() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		createDeviceDto := &dto.CreateDeviceDto{}
+		if err := c.Bind(createDeviceDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		// Validate the DTO
+		if err := validation.ValidateStruct(createDeviceDto); err != nil {
+			return err
+		}
+
+		createdDevice, err := h.svc.CreateDevice(c.Request().Context(), createDeviceDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, createdDevice)
+	}
+}
+
+// GetDevices retrieves a paginated list of devices
+// @Summary Get devices with pagination
+// @Description Get a paginated list of devices with optional premise and status filtering. Follow next_cursor for stable keyset pagination; the total is counted unless a cursor is given.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number, ignored with a cursor" default(1)
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param include_total query bool false "Count the matching devices, defaults to true without a cursor"
+// @Param sort query string false "Comma separated sort fields (created_at, serial, device_type, status), prefix with - for descending" default(-created_at)
+// @Param premise_id query string false "Filter by premise ID"
+// @Param status query string false "Filter by device status (active, inactive, faulty, decommissioned)"
+// @Success 200 {object} types.DeviceListResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /devices [get]
+func (h *Handler) GetDevices() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		getDevicesDto := &dto.GetDevicesDto{Request: query.NewRequest()}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, getDevicesDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		if err := validation.ValidateStruct(getDevicesDto); err != nil {
+			return err
+		}
+		devices, err := h.svc.GetDevices(c.Request().Context(), getDevicesDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, devices)
+	}
+}
+
+// GetDevice retrieves a device with its alarm history
+// @Summary Get device by ID
+// @Description Get a device with its recent alarms and false alarm rate
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param id path string true "Device ID"
+// @Success 200 {object} types.DeviceDetail
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /devices/{id} [get]
+func (h *Handler) GetDevice() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		deviceID := c.Param("id")
+		device, err := h.svc.GetDeviceByID(c.Request().Context(), deviceID)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, device)
+	}
+}
+
+// UpdateDevice updates an existing device
+// @Summary Update device
+// @Description Update an existing device's details or status
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param id path string true "Device ID"
+// @Param device body dto.UpdateDeviceDto true "Device update data"
+// @Success 200 {object} models.Device
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /devices/{id} [patch]
+func (h *Handler) UpdateDevice() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		deviceID := c.Param("id")
+		updateDeviceDto := &dto.UpdateDeviceDto{}
+		if err := c.Bind(updateDeviceDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		// Validate the DTO
+		if err := validation.ValidateStruct(updateDeviceDto); err != nil {
+			return err
+		}
+		updatedDevice, err := h.svc.UpdateDevice(c.Request().Context(), deviceID, updateDeviceDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, updatedDevice)
+	}
+}
+
+// DeleteDevice removes a device without alarm history
+// @Summary Delete device
+// @Description Delete a device. Devices that raised alarms must be decommissioned instead.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param id path string true "Device ID"
+// @Success 200 {string} string "success"
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /devices/{id} [delete]
+func (h *Handler) DeleteDevice() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		deviceID := c.Param("id")
+		if err := h.svc.DeleteDevice(c.Request().Context(), deviceID); err != nil {
+			return err
+		}
+		return c.JSON(200, "success")
+	}
+}
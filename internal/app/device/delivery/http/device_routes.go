@@ -0,0 +1,13 @@
+package http
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handler) RegisterRoutes(g *echo.Group) {
+	g.POST("", h.CreateDevice())
+	g.GET("", h.GetDevices())
+	g.GET("/:id", h.GetDevice())
+	g.PATCH("/:id", h.UpdateDevice())
+	g.DELETE("/:id", h.DeleteDevice())
+}
@@ -0,0 +1,13 @@
+package dto
+
+type CreateDeviceDto struct {
+	DeviceType  string `json:"device_type" validate:"required,max=100"`
+	Vendor      string `json:"vendor" validate:"omitempty,max=100"`
+	Serial      string `json:"serial" validate:"required,max=100"`
+	PremiseID   string `json:"premise_id" validate:"required,uuid"`
+	Location    string `json:"location" validate:"omitempty,max=255"`
+	InstalledAt string `json:"installed_at" validate:"omitempty"`
+	Status      string `json:"status" validate:"omitempty,oneof=active inactive faulty decommissioned"`
+	// HeartbeatInterval in seconds, 0 disables offline detection
+	HeartbeatInterval *int `json:"heartbeat_interval" validate:"omitempty,gte=0"`
+}
@@ -0,0 +1,10 @@
+package dto
+
+import "scs-operator/pkg/query"
+
+// GetDevicesDto holds the query parameters of GET /devices.
+type GetDevicesDto struct {
+	query.Request
+	PremiseID string `query:"premise_id" validate:"omitempty,uuid"`
+	Status    string `query:"status" validate:"omitempty,oneof=active inactive faulty decommissioned"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+// HeartbeatDto is the message devices publish on the device.heartbeat topic.
+type HeartbeatDto struct {
+	DeviceID string `json:"device_id"`
+	Serial   string `json:"serial"`
+	SentAt   string `json:"sent_at"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+type UpdateDeviceDto struct {
+	DeviceType  string `json:"device_type" validate:"omitempty,max=100"`
+	Vendor      string `json:"vendor" validate:"omitempty,max=100"`
+	PremiseID   string `json:"premise_id" validate:"omitempty,uuid"`
+	Location    string `json:"location" validate:"omitempty,max=255"`
+	InstalledAt string `json:"installed_at" validate:"omitempty"`
+	Status      string `json:"status" validate:"omitempty,oneof=active inactive faulty decommissioned"`
+	// HeartbeatInterval in seconds, 0 disables offline detection
+	HeartbeatInterval *int `json:"heartbeat_interval" validate:"omitempty,gte=0"`
+}
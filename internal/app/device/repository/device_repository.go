@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/query"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DeviceRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceRepository(db *gorm.DB) *DeviceRepository {
+	return &DeviceRepository{db: db}
+}
+
+func (r *DeviceRepository) CreateDevice(ctx context.Context, device *models.Device) (*models.Device, error) {
+	if err := r.db.WithContext(ctx).Create(device).Error; err != nil {
+		return nil, fmt.Errorf("failed to create device: %w", err)
+	}
+	return device, nil
+}
+
+// DeviceSchema lists the fields devices can be sorted by.
+var DeviceSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"created_at":  {Column: "created_at"},
+		"serial":      {Column: "serial"},
+		"device_type": {Column: "device_type"},
+		"status":      {Column: "status"},
+	},
+	DefaultSort: "-created_at",
+}
+
+func (r *DeviceRepository) filterDevices(ctx context.Context, premiseID string, status string) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&models.Device{}).Scopes(
+		query.Equal("premise_id", premiseID),
+		query.Equal("status", status),
+	)
+}
+
+func (r *DeviceRepository) GetDevices(ctx context.Context, premiseID string, status string, params query.Params) ([]models.Device, query.PageInfo, error) {
+	devices, page, err := query.Find[models.Device](r.filterDevices(ctx, premiseID, status).Preload("Premise"), params)
+	if err != nil {
+		return nil, page, fmt.Errorf("failed to get devices: %w", err)
+	}
+	return devices, page, nil
+}
+
+func (r *DeviceRepository) GetDevicesCount(ctx context.Context, premiseID string, status string) (int64, error) {
+	var count int64
+	if err := r.filterDevices(ctx, premiseID, status).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to get devices count: %w", err)
+	}
+	return count, nil
+}
+
+func (r *DeviceRepository) GetDeviceByID(ctx context.Context, id string) (*models.Device, error) {
+	var device models.Device
+	if err := r.db.WithContext(ctx).Preload("Premise").First(&device, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	return &device, nil
+}
+
+func (r *DeviceRepository) GetDeviceBySerial(ctx context.Context, serial string) (*models.Device, error) {
+	var device models.Device
+	if err := r.db.WithContext(ctx).First(&device, "serial = ?", serial).Error; err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	return &device, nil
+}
+
+func (r *DeviceRepository) UpdateDevice(ctx context.Context, id string, device *models.Device) (*models.Device, error) {
+	result := r.db.WithContext(ctx).Model(&models.Device{}).Where("id = ?", id).Updates(device)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update device: %w", result.Error)
+	}
+	return device, nil
+}
+
+func (r *DeviceRepository) DeleteDevice(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Delete(&models.Device{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	return nil
+}
+
+func (r *DeviceRepository) SetHeartbeatInterval(ctx context.Context, id string, interval int) error {
+	result := r.db.WithContext(ctx).Model(&models.Device{}).Where("id = ?", id).Update("heartbeat_interval", interval)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update heartbeat interval: %w", result.Error)
+	}
+	return nil
+}
+
+// RecordHeartbeat moves the device's last seen time forward and reports
+// whether it did; stale heartbeats delivered out of order are ignored.
+func (r *DeviceRepository) RecordHeartbeat(ctx context.Context, id string, seenAt time.Time) (bool, error) {
+	result := database.Conn(ctx, r.db).Model(&models.Device{}).
+		Where("id = ? AND (last_seen_at IS NULL OR last_seen_at < ?)", id, seenAt).
+		Update("last_seen_at", seenAt)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record heartbeat: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// MarkDeviceOnline flags the device as online and reports whether it was
+// offline before, i.e. whether a recovery has to be announced.
+func (r *DeviceRepository) MarkDeviceOnline(ctx context.Context, id string) (bool, error) {
+	result := database.Conn(ctx, r.db).Model(&models.Device{}).
+		Where("id = ? AND connectivity = 'offline'", id).
+		Update("connectivity", "online")
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark device online: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+	if err := database.Conn(ctx, r.db).Model(&models.Device{}).
+		Where("id = ? AND connectivity = 'unknown'", id).
+		Update("connectivity", "online").Error; err != nil {
+		return false, fmt.Errorf("failed to mark device online: %w", err)
+	}
+	return false, nil
+}
+
+// GetOverdueDevices returns active, monitored devices that have not sent a
+// heartbeat within their interval and are not yet flagged offline.
+func (r *DeviceRepository) GetOverdueDevices(ctx context.Context, now time.Time) ([]models.Device, error) {
+	var devices []models.Device
+	if err := r.db.WithContext(ctx).
+		Where("status = 'active' AND heartbeat_interval > 0 AND connectivity <> 'offline'").
+		Where("COALESCE(last_seen_at, created_at) + make_interval(secs => heartbeat_interval) < ?", now).
+		Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("failed to get overdue devices: %w", err)
+	}
+	return devices, nil
+}
+
+// MarkDeviceOffline flags an overdue device as offline. It reports false when a
+// heartbeat arrived in the meantime or another checker got there first.
+func (r *DeviceRepository) MarkDeviceOffline(ctx context.Context, id string, now time.Time) (bool, error) {
+	result := database.Conn(ctx, r.db).Model(&models.Device{}).
+		Where("id = ? AND connectivity <> 'offline'", id).
+		Where("COALESCE(last_seen_at, created_at) + make_interval(secs => heartbeat_interval) < ?", now).
+		Update("connectivity", "offline")
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark device offline: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
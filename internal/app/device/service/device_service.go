@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	alarmDto "scs-operator/internal/app/alarm/dto"
+	alarmRepositories "scs-operator/internal/app/alarm/repository"
+	alarmServices "scs-operator/internal/app/alarm/service"
+	"scs-operator/internal/app/device/dto"
+	deviceRepositories "scs-operator/internal/app/device/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/query"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// alarmHistoryLimit caps the number of alarms returned with a device.
+const alarmHistoryLimit = 50
+
+type Service struct {
+	deviceRepo   deviceRepositories.DeviceRepository
+	premiseRepo  premiseRepositories.PremiseRepository
+	alarmRepo    alarmRepositories.AlarmRepository
+	alarmService alarmServices.Service
+	transactor   database.Transactor
+}
+
+func NewDeviceService(deviceRepo deviceRepositories.DeviceRepository, premiseRepo premiseRepositories.PremiseRepository, alarmRepo alarmRepositories.AlarmRepository, alarmService alarmServices.Service, transactor database.Transactor) *Service {
+	return &Service{deviceRepo: deviceRepo, premiseRepo: premiseRepo, alarmRepo: alarmRepo, alarmService: alarmService, transactor: transactor}
+}
+
+func (s *Service) CreateDevice(ctx context.Context, createDeviceDto *dto.CreateDeviceDto) (*models.Device, error) {
+	device := &models.Device{
+		DeviceType: createDeviceDto.DeviceType,
+		Vendor:     createDeviceDto.Vendor,
+		Serial:     createDeviceDto.Serial,
+		Location:   createDeviceDto.Location,
+		Status:     createDeviceDto.Status,
+	}
+	if device.Status == "" {
+		device.Status = "active"
+	}
+	if createDeviceDto.HeartbeatInterval != nil {
+		device.HeartbeatInterval = *createDeviceDto.HeartbeatInterval
+	}
+	premiseID, err := uuid.Parse(createDeviceDto.PremiseID)
+	if err != nil {
+		return nil, errors.NewBadRequestError("Invalid premise ID format")
+	}
+	if _, err := s.premiseRepo.GetPremiseByID(ctx, premiseID.String()); err != nil {
+		return nil, errors.NewNotFoundError("premise")
+	}
+	device.PremiseID = premiseID
+	if createDeviceDto.InstalledAt != "" {
+		installedAt, err := time.Parse("2006-01-02", createDeviceDto.InstalledAt)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid installed_at format, expected YYYY-MM-DD")
+		}
+		device.InstalledAt = &installedAt
+	}
+	createdDevice, err := s.deviceRepo.CreateDevice(ctx, device)
+	if err != nil {
+		// The unique index decides, a lookup first would race concurrent creates
+		if database.IsUniqueViolation(err) {
+			return nil, errors.NewConflictError("device with this serial already exists")
+		}
+		return nil, errors.NewDatabaseError("create device", err)
+	}
+	return createdDevice, nil
+}
+
+func (s *Service) GetDevices(ctx context.Context, getDevicesDto *dto.GetDevicesDto) (*types.PaginateResponse[models.Device], error) {
+	params, err := query.New(deviceRepositories.DeviceSchema, getDevicesDto.Request)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	devices, page, err := s.deviceRepo.GetDevices(ctx, getDevicesDto.PremiseID, getDevicesDto.Status, params)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get devices", err)
+	}
+	if params.IncludeTotal {
+		total, err := s.deviceRepo.GetDevicesCount(ctx, getDevicesDto.PremiseID, getDevicesDto.Status)
+		if err != nil {
+			return nil, errors.NewDatabaseError("get devices count", err)
+		}
+		page.Total = &total
+	}
+	return types.NewPaginateResponse(devices, page), nil
+}
+
+func (s *Service) GetDeviceByID(ctx context.Context, id string) (*types.DeviceDetail, error) {
+	device, err := s.deviceRepo.GetDeviceByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewNotFoundError("device")
+	}
+	alarms, err := s.alarmRepo.GetAlarmsByDeviceID(ctx, id, alarmHistoryLimit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get device alarms", err)
+	}
+	total, ignored, err := s.alarmRepo.GetAlarmCountsByDeviceID(ctx, id)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get device alarm counts", err)
+	}
+	detail := &types.DeviceDetail{
+		Device:          *device,
+		AlarmCount:      total,
+		FalseAlarmCount: ignored,
+		Alarms:          alarms,
+	}
+	if total > 0 {
+		detail.FalseAlarmRate = float64(ignored) / float64(total)
+	}
+	return detail, nil
+}
+
+func (s *Service) UpdateDevice(ctx context.Context, id string, updateDeviceDto *dto.UpdateDeviceDto) (*models.Device, error) {
+	device, err := s.deviceRepo.GetDeviceByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewNotFoundError("device")
+	}
+	if updateDeviceDto.DeviceType != "" {
+		device.DeviceType = updateDeviceDto.DeviceType
+	}
+	if updateDeviceDto.Vendor != "" {
+		device.Vendor = updateDeviceDto.Vendor
+	}
+	if updateDeviceDto.Location != "" {
+		device.Location = updateDeviceDto.Location
+	}
+	if updateDeviceDto.Status != "" {
+		device.Status = updateDeviceDto.Status
+	}
+	if updateDeviceDto.PremiseID != "" {
+		premiseID, err := uuid.Parse(updateDeviceDto.PremiseID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid premise ID format")
+		}
+		premise, err := s.premiseRepo.GetPremiseByID(ctx, premiseID.String())
+		if err != nil {
+			return nil, errors.NewNotFoundError("premise")
+		}
+		device.PremiseID = premiseID
+		device.Premise = premise
+	}
+	if updateDeviceDto.InstalledAt != "" {
+		installedAt, err := time.Parse("2006-01-02", updateDeviceDto.InstalledAt)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid installed_at format, expected YYYY-MM-DD")
+		}
+		device.InstalledAt = &installedAt
+	}
+	updatedDevice, err := s.deviceRepo.UpdateDevice(ctx, id, device)
+	if err != nil {
+		return nil, errors.NewDatabaseError("update device", err)
+	}
+	// Updates skips zero values, so the interval is written on its own to allow disabling it
+	if updateDeviceDto.HeartbeatInterval != nil {
+		if err := s.deviceRepo.SetHeartbeatInterval(ctx, id, *updateDeviceDto.HeartbeatInterval); err != nil {
+			return nil, errors.NewDatabaseError("update device", err)
+		}
+		updatedDevice.HeartbeatInterval = *updateDeviceDto.HeartbeatInterval
+	}
+	return updatedDevice, nil
+}
+
+func (s *Service) DeleteDevice(ctx context.Context, id string) error {
+	if _, err := s.deviceRepo.GetDeviceByID(ctx, id); err != nil {
+		return errors.NewNotFoundError("device")
+	}
+	total, _, err := s.alarmRepo.GetAlarmCountsByDeviceID(ctx, id)
+	if err != nil {
+		return errors.NewDatabaseError("get device alarm counts", err)
+	}
+	if total > 0 {
+		return errors.NewConflictError("device has alarm history; set its status to decommissioned instead")
+	}
+	if err := s.deviceRepo.DeleteDevice(ctx, id); err != nil {
+		return errors.NewDatabaseError("delete device", err)
+	}
+	return nil
+}
+
+// RecordHeartbeat stores a device heartbeat and raises a device_online alarm
+// when the device was previously reported offline.
+func (s *Service) RecordHeartbeat(ctx context.Context, heartbeatDto *dto.HeartbeatDto) error {
+	var device *models.Device
+	var err error
+	switch {
+	case heartbeatDto.DeviceID != "":
+		device, err = s.deviceRepo.GetDeviceByID(ctx, heartbeatDto.DeviceID)
+	case heartbeatDto.Serial != "":
+		device, err = s.deviceRepo.GetDeviceBySerial(ctx, heartbeatDto.Serial)
+	default:
+		return errors.NewBadRequestError("heartbeat must carry a device ID or serial")
+	}
+	if err != nil {
+		return errors.NewNotFoundError("device")
+	}
+	seenAt := time.Now()
+	if heartbeatDto.SentAt != "" {
+		sentAt, err := time.Parse(time.RFC3339, heartbeatDto.SentAt)
+		if err != nil {
+			return errors.NewBadRequestError("Invalid sent_at format, expected RFC3339")
+		}
+		// Never trust device clocks that run ahead of ours
+		if sentAt.Before(seenAt) {
+			seenAt = sentAt
+		}
+	}
+	// The device is only marked online together with its recovery alarm
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		recorded, err := s.deviceRepo.RecordHeartbeat(ctx, device.ID.String(), seenAt)
+		if err != nil {
+			return errors.NewDatabaseError("record heartbeat", err)
+		}
+		// A heartbeat older than the last one says nothing about the device now
+		if !recorded {
+			return nil
+		}
+		recovered, err := s.deviceRepo.MarkDeviceOnline(ctx, device.ID.String())
+		if err != nil {
+			return errors.NewDatabaseError("mark device online", err)
+		}
+		if !recovered {
+			return nil
+		}
+		_, err = s.alarmService.CreateAlarm(ctx, &alarmDto.CreateAlarmDto{
+			DeviceID:    device.ID.String(),
+			Type:        "device_online",
+			Description: fmt.Sprintf("Device %s (%s) is back online", device.Serial, device.DeviceType),
+			Severity:    "low",
+		})
+		return err
+	})
+}
+
+// CheckHeartbeats flags every device that missed its heartbeat interval as
+// offline and raises a device_offline alarm for it. A device that fails is
+// left for the next check and does not hold up the others.
+func (s *Service) CheckHeartbeats(ctx context.Context) error {
+	now := time.Now()
+	devices, err := s.deviceRepo.GetOverdueDevices(ctx, now)
+	if err != nil {
+		return errors.NewDatabaseError("get overdue devices", err)
+	}
+	var failures []error
+	for _, device := range devices {
+		if err := s.markDeviceOffline(ctx, device, now); err != nil {
+			failures = append(failures, fmt.Errorf("device %s: %w", device.Serial, err))
+		}
+	}
+	return stdErrors.Join(failures...)
+}
+
+// markDeviceOffline flags the device offline and raises its alarm in one
+// transaction, so a device is never offline without an alarm.
+func (s *Service) markDeviceOffline(ctx context.Context, device models.Device, now time.Time) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		marked, err := s.deviceRepo.MarkDeviceOffline(ctx, device.ID.String(), now)
+		if err != nil {
+			return errors.NewDatabaseError("mark device offline", err)
+		}
+		if !marked {
+			return nil
+		}
+		lastSeen := "never"
+		if device.LastSeenAt != nil {
+			lastSeen = device.LastSeenAt.Format(time.RFC3339)
+		}
+		_, err = s.alarmService.CreateAlarm(ctx, &alarmDto.CreateAlarmDto{
+			DeviceID:    device.ID.String(),
+			Type:        "device_offline",
+			Description: fmt.Sprintf("Device %s (%s) missed its heartbeat, last seen %s", device.Serial, device.DeviceType, lastSeen),
+			Severity:    "medium",
+		})
+		return err
+	})
+}
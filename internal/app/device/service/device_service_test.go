@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	alarmRepositories "scs-operator/internal/app/alarm/repository"
+	"scs-operator/internal/app/device/dto"
+	deviceRepositories "scs-operator/internal/app/device/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	"scs-operator/internal/testsupport"
+	"scs-operator/internal/testsupport/alarmtest"
+	database "scs-operator/pkg/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	testDeviceID      = "6a0b4c8d-2e1f-4a3b-9c5d-7e8f9a0b1c2d"
+	testOtherDeviceID = "7b1c5d9e-3f2a-4b4c-8d6e-8f9a0b1c2d3e"
+	testPremiseID     = "c18c4a6e-118d-4fde-9f11-5f2a9f5b4c13"
+	testAlarmID       = "3e9f5d7b-88c4-4c4b-8c88-6c3d0c6e5f04"
+)
+
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := testsupport.NewMockDB(t)
+	svc := NewDeviceService(
+		*deviceRepositories.NewDeviceRepository(db),
+		*premiseRepositories.NewPremiseRepository(db),
+		*alarmRepositories.NewAlarmRepository(db),
+		*alarmtest.NewService(t, db),
+		*database.NewTransactor(db),
+	)
+	return svc, mock
+}
+
+func TestCreateDevice(t *testing.T) {
+	tests := []struct {
+		name           string
+		insertErr      error
+		expectedStatus int
+	}{
+		{name: "Created", expectedStatus: http.StatusOK},
+		{name: "Serial taken", insertErr: &pgconn.PgError{Code: "23505"}, expectedStatus: http.StatusConflict},
+		{name: "Database failure", insertErr: testsupport.ErrInjected, expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testPremiseID, "Head office"))
+			mock.ExpectBegin()
+			insert := mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "devices"`))
+			if tt.insertErr != nil {
+				insert.WillReturnError(tt.insertErr)
+				mock.ExpectRollback()
+			} else {
+				insert.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testDeviceID))
+				mock.ExpectCommit()
+			}
+			device, err := svc.CreateDevice(context.Background(), &dto.CreateDeviceDto{
+				DeviceType: "door_contact",
+				Serial:     "door-3",
+				PremiseID:  testPremiseID,
+			})
+			if tt.expectedStatus != http.StatusOK {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if device.ID.String() != testDeviceID {
+				t.Errorf("expected device %s, got %s", testDeviceID, device.ID)
+			}
+		})
+	}
+}
+
+// expectDeviceAlarm expects the alarm service to create an alarm for the
+// device, failing the insert with err when given.
+func expectDeviceAlarm(mock sqlmock.Sqlmock, deviceID string, err error) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "devices" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "serial", "premise_id"}).AddRow(deviceID, "door-3", testPremiseID))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE "premises"."id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testPremiseID, "Head office"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testPremiseID, "Head office"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`WITH RECURSIVE ancestors`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testPremiseID))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "maintenance_windows"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	// The alarm's premise, then the device with its own premise
+	mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "premises"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "premises"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "devices"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	insert := mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "alarms"`))
+	if err != nil {
+		insert.WillReturnError(err)
+		return
+	}
+	insert.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testAlarmID))
+}
+
+func TestRecordHeartbeat(t *testing.T) {
+	expectDevice := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "devices" WHERE serial = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "serial", "premise_id"}).AddRow(testDeviceID, "door-3", testPremiseID))
+	}
+	recordHeartbeat := `UPDATE "devices" SET "last_seen_at"=$1,"updated_at"=$2 WHERE id = $3 AND (last_seen_at IS NULL OR last_seen_at < $4)`
+	markOnline := `UPDATE "devices" SET "connectivity"=$1,"updated_at"=$2 WHERE id = $3 AND connectivity = 'offline'`
+	tests := []struct {
+		name           string
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name: "Stale heartbeat is ignored",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectDevice(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec(testsupport.QuoteSQL(recordHeartbeat)).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Recovered device raises device_online",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectDevice(mock)
+				mock.ExpectBegin()
+				testsupport.ExpectExec(mock, recordHeartbeat, nil)
+				testsupport.ExpectExec(mock, markOnline, nil)
+				expectDeviceAlarm(mock, testDeviceID, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Device stays offline when its alarm fails",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectDevice(mock)
+				mock.ExpectBegin()
+				testsupport.ExpectExec(mock, recordHeartbeat, nil)
+				testsupport.ExpectExec(mock, markOnline, nil)
+				expectDeviceAlarm(mock, testDeviceID, testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			err := svc.RecordHeartbeat(context.Background(), &dto.HeartbeatDto{
+				Serial: "door-3",
+				SentAt: time.Now().Add(-time.Minute).Format(time.RFC3339),
+			})
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckHeartbeats(t *testing.T) {
+	svc, mock := newTestService(t)
+	markOffline := `UPDATE "devices" SET "connectivity"=$1,"updated_at"=$2 WHERE (id = $3 AND connectivity <> 'offline') AND COALESCE(last_seen_at, created_at) + make_interval(secs => heartbeat_interval) < $4`
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "devices" WHERE (status = 'active'`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "serial", "premise_id"}).
+			AddRow(testDeviceID, "door-3", testPremiseID).
+			AddRow(testOtherDeviceID, "door-4", testPremiseID))
+	// The first device's alarm fails, it is rolled back to be retried and the
+	// second device is still checked
+	mock.ExpectBegin()
+	testsupport.ExpectExec(mock, markOffline, nil)
+	expectDeviceAlarm(mock, testDeviceID, testsupport.ErrInjected)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	testsupport.ExpectExec(mock, markOffline, nil)
+	expectDeviceAlarm(mock, testOtherDeviceID, nil)
+	mock.ExpectCommit()
+
+	err := svc.CheckHeartbeats(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "device door-3") {
+		t.Fatalf("expected the failure of door-3, got %v", err)
+	}
+}
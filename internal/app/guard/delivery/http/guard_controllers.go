@@ -3,6 +3,9 @@ package http
 import (
 	"scs-operator/internal/app/guard/dto"
 	services "scs-operator/internal/app/guard/service"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/query"
+	"scs-operator/pkg/validation"
 
 	"github.com/labstack/echo/v4"
 )
@@ -43,6 +46,161 @@ func (h *Handler) Create() echo.HandlerFunc {
 	}
 }
 
+// GetGuards retrieves a paginated list of guards
+// @Summary Get guards with pagination
+// @Description Get a paginated list of guard users. Follow next_cursor for stable keyset pagination; the total is counted unless a cursor is given.
+// @Tags guards
+// @Produce json
+// @Param page query int false "Page number, ignored with a cursor" default(1)
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param include_total query bool false "Count the guards, defaults to true without a cursor"
+// @Param sort query string false "Comma separated sort fields (name, email, created_at), prefix with - for descending" default(name)
+// @Success 200 {object} types.GuardListResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards [get]
+func (h *Handler) GetGuards() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		request := query.NewRequest()
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &request); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		if err := validation.ValidateStruct(request); err != nil {
+			return err
+		}
+		guards, err := h.svc.GetGuards(c.Request().Context(), request)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, guards)
+	}
+}
+
+// ReportLocation records the caller's position
+// @Summary Report my location
+// @Description Report the calling guard's position, given as latitude and longitude or as a GeoJSON point. The position is added to the guard's track and becomes their latest position unless a more recent one was reported. Devices may publish the same payload with a guard_id on the guard.location Kafka topic instead.
+// @Tags guards
+// @Accept json
+// @Produce json
+// @Param location body dto.ReportLocationDto true "Position"
+// @Success 200 {object} models.GuardPosition
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/me/location [post]
+func (h *Handler) ReportLocation() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		reportLocationDto := &dto.ReportLocationDto{}
+		if err := c.Bind(reportLocationDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(reportLocationDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		position, err := h.svc.ReportLocation(c.Request().Context(), userID, reportLocationDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, position)
+	}
+}
+
+// GetGuardLocations lists the latest guard positions
+// @Summary Get guard locations
+// @Description Get the last reported position of every guard for the live map. Positions older than GUARD_POSITION_MAX_AGE are stale and only returned with include_stale.
+// @Tags guards
+// @Produce json
+// @Param include_stale query bool false "Also return stale positions" default(false)
+// @Success 200 {array} types.GuardLocation
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/locations [get]
+func (h *Handler) GetGuardLocations() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		locationsDto := &dto.GetGuardLocationsDto{}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, locationsDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+		locations, err := h.svc.GetGuardLocations(c.Request().Context(), locationsDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, locations)
+	}
+}
+
+// GetGuardTrack replays a guard's route
+// @Summary Get guard track
+// @Description Get the positions a guard reported in a time range, oldest first, to replay their route during an incident review. truncated is set when the range holds more positions than limit.
+// @Tags guards
+// @Produce json
+// @Param id path string true "Guard ID"
+// @Param from query string true "Reported at or after, RFC3339"
+// @Param to query string false "Reported before, RFC3339, defaults to now"
+// @Param limit query int false "Maximum number of positions (max 10000)" default(5000)
+// @Success 200 {object} types.GuardTrack
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/{id}/track [get]
+func (h *Handler) GetGuardTrack() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		trackDto := &dto.GetGuardTrackDto{Limit: 5000}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, trackDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		if err := validation.ValidateStruct(trackDto); err != nil {
+			return err
+		}
+		track, err := h.svc.GetGuardTrack(c.Request().Context(), c.Param("id"), trackDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, track)
+	}
+}
+
+// GetNearestGuards finds the available guards closest to a point
+// @Summary Get the nearest available guards
+// @Description Get the guards closest to a point, nearest first. Only guards that reported a position within GUARD_POSITION_MAX_AGE and hold no active guidance on an unresolved incident are returned.
+// @Tags guards
+// @Produce json
+// @Param latitude query number true "Latitude"
+// @Param longitude query number true "Longitude"
+// @Param max_distance query number false "Maximum distance in meters, at most 100000"
+// @Param limit query int false "Maximum number of guards (max 50)" default(5)
+// @Success 200 {array} types.NearestGuard
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/nearest [get]
+func (h *Handler) GetNearestGuards() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		nearestDto := &dto.GetNearestGuardsDto{Limit: 5}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, nearestDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		if err := validation.ValidateStruct(nearestDto); err != nil {
+			return err
+		}
+		guards, err := h.svc.GetNearestGuards(c.Request().Context(), nearestDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, guards)
+	}
+}
+
 // func (h *Handler) AssignPremises() echo.HandlerFunc {
 // 	return func(c echo.Context) error {
 // 		assignPremisesDto := &dto.AssignPremisesDto{}
@@ -56,3 +214,173 @@ func (h *Handler) Create() echo.HandlerFunc {
 // 		return c.JSON(200, "success")
 // 	}
 // }
+
+// StartShift starts a lone-worker shift
+// @Summary Start my shift
+// @Description Start a lone-worker shift of the calling guard on a premise. The guard must check in every check_in_interval_minutes, LONE_WORKER_CHECK_IN_INTERVAL by default. A missed check-in warns the guard, then raises a guard_man_down alarm on the premise, then notifies the premise's supervisors.
+// @Tags guards
+// @Accept json
+// @Produce json
+// @Param shift body dto.StartShiftDto true "Shift"
+// @Success 201 {object} models.GuardShift
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/me/shift [post]
+func (h *Handler) StartShift() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		startShiftDto := &dto.StartShiftDto{}
+		if err := c.Bind(startShiftDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(startShiftDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		shift, err := h.svc.StartShift(c.Request().Context(), userID, startShiftDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, shift)
+	}
+}
+
+// EndShift ends the caller's shift
+// @Summary End my shift
+// @Description End the calling guard's lone-worker shift, which stops their check-ins
+// @Tags guards
+// @Produce json
+// @Success 200 {object} models.GuardShift
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/me/shift/end [patch]
+func (h *Handler) EndShift() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, _ := c.Get("user_id").(string)
+		shift, err := h.svc.EndShift(c.Request().Context(), userID)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, shift)
+	}
+}
+
+// CheckIn records a lone-worker check-in
+// @Summary Check in
+// @Description Confirm the calling guard is fine. The next check-in is due one interval from now and a running escalation stops; an alarm raised already stays in the operator queue. A position may be given as with POST /guards/me/location.
+// @Tags guards
+// @Accept json
+// @Produce json
+// @Param check_in body dto.CheckInDto false "Position"
+// @Success 200 {object} models.GuardShift
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/me/check-in [post]
+func (h *Handler) CheckIn() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		checkInDto := &dto.CheckInDto{}
+		if err := c.Bind(checkInDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(checkInDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		shift, err := h.svc.CheckIn(c.Request().Context(), userID, checkInDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, shift)
+	}
+}
+
+// Panic raises a panic alarm
+// @Summary Press the panic button
+// @Description Raise a high-severity guard_panic alarm for the calling guard on the premise of their shift, or the premise they were first assigned to when off shift, and notify its supervisors over Kafka. A position may be given as with POST /guards/me/location, otherwise the last reported one is used.
+// @Tags guards
+// @Accept json
+// @Produce json
+// @Param panic body dto.PanicDto false "Panic"
+// @Success 201 {object} models.Alarm
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/me/panic [post]
+func (h *Handler) Panic() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		panicDto := &dto.PanicDto{}
+		if err := c.Bind(panicDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(panicDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		alarm, err := h.svc.Panic(c.Request().Context(), userID, panicDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, alarm)
+	}
+}
+
+// GetActiveShifts lists the guards on shift
+// @Summary Get guard shifts
+// @Description Get the lone-worker shifts that have not ended with their check-in status, the next check-in due first
+// @Tags guards
+// @Produce json
+// @Success 200 {array} models.GuardShift
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/shifts [get]
+func (h *Handler) GetActiveShifts() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		shifts, err := h.svc.GetActiveShifts(c.Request().Context())
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, shifts)
+	}
+}
+
+// UpdateShift changes a guard's check-in interval
+// @Summary Update guard shift
+// @Description Change the check-in interval of a guard's shift. The next check-in is due one new interval after the last one.
+// @Tags guards
+// @Accept json
+// @Produce json
+// @Param id path string true "Guard ID"
+// @Param shift body dto.UpdateShiftDto true "Check-in interval"
+// @Success 200 {object} models.GuardShift
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guards/{id}/shift [patch]
+func (h *Handler) UpdateShift() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		updateShiftDto := &dto.UpdateShiftDto{}
+		if err := c.Bind(updateShiftDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(updateShiftDto); err != nil {
+			return err
+		}
+		shift, err := h.svc.UpdateShift(c.Request().Context(), c.Param("id"), updateShiftDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, shift)
+	}
+}
@@ -6,5 +6,16 @@ import (
 
 func (h *Handler) RegisterRoutes(g *echo.Group) {
 	g.POST("", h.Create())
+	g.GET("", h.GetGuards())
+	g.POST("/me/location", h.ReportLocation())
+	g.POST("/me/shift", h.StartShift())
+	g.PATCH("/me/shift/end", h.EndShift())
+	g.POST("/me/check-in", h.CheckIn())
+	g.POST("/me/panic", h.Panic())
+	g.GET("/shifts", h.GetActiveShifts())
+	g.PATCH("/:id/shift", h.UpdateShift())
+	g.GET("/nearest", h.GetNearestGuards())
+	g.GET("/locations", h.GetGuardLocations())
+	g.GET("/:id/track", h.GetGuardTrack())
 	// g.POST("/assign-premises", h.AssignPremises())
 }
@@ -0,0 +1,14 @@
+package dto
+
+// GetGuardLocationsDto holds the query parameters of GET /guards/locations.
+type GetGuardLocationsDto struct {
+	// IncludeStale also returns guards whose last position is outdated
+	IncludeStale bool `query:"include_stale"`
+}
+
+// GetGuardTrackDto holds the query parameters of GET /guards/:id/track.
+type GetGuardTrackDto struct {
+	From  string `query:"from" validate:"required"`
+	To    string `query:"to"`
+	Limit int    `query:"limit" validate:"gte=1,lte=10000"`
+}
@@ -0,0 +1,10 @@
+package dto
+
+// GetNearestGuardsDto holds the query parameters of GET /guards/nearest.
+type GetNearestGuardsDto struct {
+	Latitude  *float64 `query:"latitude" validate:"required"`
+	Longitude *float64 `query:"longitude" validate:"required"`
+	// MaxDistance is in meters, zero does not limit the distance
+	MaxDistance float64 `query:"max_distance" validate:"omitempty,gt=0,lte=100000"`
+	Limit       int     `query:"limit" validate:"gte=1,lte=50"`
+}
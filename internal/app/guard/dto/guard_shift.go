@@ -0,0 +1,24 @@
+package dto
+
+// StartShiftDto starts a lone-worker shift of the calling guard.
+type StartShiftDto struct {
+	PremiseID string `json:"premise_id" validate:"required,uuid"`
+	// CheckInIntervalMinutes defaults to LONE_WORKER_CHECK_IN_INTERVAL
+	CheckInIntervalMinutes int `json:"check_in_interval_minutes,omitempty" validate:"omitempty,gte=1,lte=720"`
+}
+
+// UpdateShiftDto changes the check-in interval of a guard's shift.
+type UpdateShiftDto struct {
+	CheckInIntervalMinutes int `json:"check_in_interval_minutes" validate:"required,gte=1,lte=720"`
+}
+
+// CheckInDto is a lone-worker check-in, optionally with the guard's position.
+type CheckInDto struct {
+	ReportLocationDto
+}
+
+// PanicDto is a panic button press, optionally with the guard's position.
+type PanicDto struct {
+	Message string `json:"message,omitempty" validate:"omitempty,max=500"`
+	ReportLocationDto
+}
@@ -0,0 +1,21 @@
+package dto
+
+import "scs-operator/pkg/geo"
+
+// ReportLocationDto is a position reported by a guard's device. The position
+// is given either as latitude and longitude or as a GeoJSON point.
+type ReportLocationDto struct {
+	Latitude  *float64   `json:"latitude,omitempty"`
+	Longitude *float64   `json:"longitude,omitempty"`
+	Geometry  *geo.Point `json:"geometry,omitempty"`
+	// Accuracy is the accuracy radius in meters
+	Accuracy *float64 `json:"accuracy,omitempty" validate:"omitempty,gte=0"`
+	// ReportedAt is when the position was taken, RFC3339, defaults to now
+	ReportedAt string `json:"reported_at,omitempty"`
+}
+
+// GuardLocationDto is the message guard devices publish on the guard.location topic.
+type GuardLocationDto struct {
+	GuardID string `json:"guard_id"`
+	ReportLocationDto
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GuardLocationHistoryTable creates the location history, range partitioned
+// by month on reported_at. Positions outside the created partitions, such as
+// late uploads from long before, land in the default partition.
+var GuardLocationHistoryTable = []string{
+	`CREATE TABLE IF NOT EXISTS guard_location_history (
+		user_id uuid NOT NULL REFERENCES users (id),
+		latitude double precision NOT NULL CHECK (latitude BETWEEN -90 AND 90),
+		longitude double precision NOT NULL CHECK (longitude BETWEEN -180 AND 180),
+		accuracy double precision,
+		reported_at timestamptz NOT NULL,
+		received_at timestamptz NOT NULL DEFAULT now()
+	) PARTITION BY RANGE (reported_at)`,
+	`CREATE TABLE IF NOT EXISTS guard_location_history_default PARTITION OF guard_location_history DEFAULT`,
+	`CREATE INDEX IF NOT EXISTS idx_guard_location_history_track ON guard_location_history (user_id, reported_at)`,
+}
+
+type GuardLocationRepository struct {
+	db *gorm.DB
+}
+
+func NewGuardLocationRepository(db *gorm.DB) *GuardLocationRepository {
+	return &GuardLocationRepository{db: db}
+}
+
+// EnsurePartitions creates the monthly partitions from the month of from on
+// for the given number of months, skipping those that exist.
+func (r *GuardLocationRepository) EnsurePartitions(ctx context.Context, from time.Time, months int) error {
+	start := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < months; i++ {
+		end := start.AddDate(0, 1, 0)
+		statement := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS guard_location_history_%s PARTITION OF guard_location_history
+			FOR VALUES FROM ('%s') TO ('%s')`, start.Format("2006_01"), start.Format(time.RFC3339), end.Format(time.RFC3339))
+		if err := database.Conn(ctx, r.db).Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create guard location partition %s: %w", start.Format("2006-01"), err)
+		}
+		start = end
+	}
+	return nil
+}
+
+func (r *GuardLocationRepository) CreateGuardLocation(ctx context.Context, location *models.GuardLocation) error {
+	if err := database.Conn(ctx, r.db).Create(location).Error; err != nil {
+		return fmt.Errorf("failed to create guard location: %w", err)
+	}
+	return nil
+}
+
+// GetTrack returns the guard's positions reported in [from, to), oldest first.
+func (r *GuardLocationRepository) GetTrack(ctx context.Context, userID string, from time.Time, to time.Time, limit int) ([]models.GuardLocation, error) {
+	var locations []models.GuardLocation
+	if err := database.Conn(ctx, r.db).
+		Where("user_id = ? AND reported_at >= ? AND reported_at < ?", userID, from, to).
+		Order("reported_at").Limit(limit).Find(&locations).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guard track: %w", err)
+	}
+	return locations, nil
+}
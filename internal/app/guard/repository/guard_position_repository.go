@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/geo"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GuardPositionRepository struct {
+	db *gorm.DB
+}
+
+func NewGuardPositionRepository(db *gorm.DB) *GuardPositionRepository {
+	return &GuardPositionRepository{db: db}
+}
+
+// UpsertGuardPosition stores the guard's latest position. A position reported
+// before the stored one arrived late and is ignored.
+func (r *GuardPositionRepository) UpsertGuardPosition(ctx context.Context, position *models.GuardPosition) error {
+	if err := database.Conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"latitude", "longitude", "accuracy", "reported_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "excluded.reported_at >= guard_positions.reported_at"}}},
+	}).Create(position).Error; err != nil {
+		return fmt.Errorf("failed to upsert guard position: %w", err)
+	}
+	return nil
+}
+
+// LocatedGuard is a guard with their last reported position.
+type LocatedGuard struct {
+	UserID     uuid.UUID
+	Name       string
+	Email      string
+	Latitude   float64
+	Longitude  float64
+	Accuracy   *float64
+	ReportedAt time.Time
+}
+
+// NearbyGuard is a located guard and their distance in meters.
+type NearbyGuard struct {
+	LocatedGuard
+	Distance float64
+}
+
+const locatedGuardColumns = "guard_positions.user_id, users.name, users.email, guard_positions.latitude, guard_positions.longitude, guard_positions.accuracy, guard_positions.reported_at"
+
+func (r *GuardPositionRepository) locatedGuards(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, r.db).Table("guard_positions").
+		Joins("JOIN users ON users.id = guard_positions.user_id").
+		Where("users.role = 'guard'")
+}
+
+// GetGuardPositions returns the last position of every guard, optionally only
+// those reported since the given time, by name.
+func (r *GuardPositionRepository) GetGuardPositions(ctx context.Context, since *time.Time) ([]LocatedGuard, error) {
+	db := r.locatedGuards(ctx).Select(locatedGuardColumns)
+	if since != nil {
+		db = db.Where("guard_positions.reported_at >= ?", *since)
+	}
+	var guards []LocatedGuard
+	if err := db.Order("users.name").Scan(&guards).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guard positions: %w", err)
+	}
+	return guards, nil
+}
+
+// GetNearestAvailableGuards returns the guards closest to center, nearest
+// first. Guards are available when they reported a position since the given
+// time and hold no active guidance on an unresolved incident. A maxDistance of
+// zero does not limit the distance.
+func (r *GuardPositionRepository) GetNearestAvailableGuards(ctx context.Context, center geo.Point, since time.Time, maxDistance float64, limit int) ([]NearbyGuard, error) {
+	distance := geo.DistanceSQL("guard_positions.latitude", "guard_positions.longitude")
+	db := r.locatedGuards(ctx).
+		Select(locatedGuardColumns+", "+distance+" AS distance", center.DistanceArgs()...).
+		Where("guard_positions.reported_at >= ?", since).
+		Where(`NOT EXISTS (SELECT 1 FROM incident_guidances ig JOIN incidents i ON i.id = ig.incident_id
+			WHERE ig.assignee_id = guard_positions.user_id AND ig.status = 'active' AND i.status <> 'resolved')`)
+	if maxDistance > 0 {
+		db = db.Scopes(geo.Near("guard_positions.latitude", "guard_positions.longitude", center, maxDistance))
+	}
+	var guards []NearbyGuard
+	if err := db.Order("distance").Limit(limit).Scan(&guards).Error; err != nil {
+		return nil, fmt.Errorf("failed to get nearest guards: %w", err)
+	}
+	return guards, nil
+}
+
+func (r *GuardPositionRepository) GetGuardPosition(ctx context.Context, userID string) (*models.GuardPosition, error) {
+	var position models.GuardPosition
+	if err := database.Conn(ctx, r.db).First(&position, "user_id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guard position: %w", err)
+	}
+	return &position, nil
+}
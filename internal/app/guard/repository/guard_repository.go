@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/query"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -22,18 +26,103 @@ func (r *GuardRepository) Create(ctx context.Context, guard *models.User) (*mode
 	}
 	return guard, nil
 }
-func (r *GuardRepository) GetGuards(ctx context.Context, page int, limit int) ([]models.User, error) {
-	var guards []models.User
-	if err := r.db.WithContext(ctx).Limit(limit).Offset((page - 1) * limit).Where("role = 'guard'").Find(&guards).Error; err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+
+// GuardSchema lists the fields guards can be sorted by.
+var GuardSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"name":       {Column: "name"},
+		"email":      {Column: "email"},
+		"created_at": {Column: "created_at"},
+	},
+	DefaultSort: "name",
+}
+
+func (r *GuardRepository) GetGuards(ctx context.Context, params query.Params) ([]models.User, query.PageInfo, error) {
+	guards, page, err := query.Find[models.User](r.db.WithContext(ctx).Model(&models.User{}).Where("role = 'guard'"), params)
+	if err != nil {
+		return nil, page, fmt.Errorf("failed to get users: %w", err)
 	}
-	return guards, nil
+	return guards, page, nil
 }
 
 func (r *GuardRepository) GetGuardsCount(ctx context.Context) (int64, error) {
 	var count int64
-	if err := r.db.WithContext(ctx).Model(&models.User{}).Count(&count).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&models.User{}).Where("role = 'guard'").Count(&count).Error; err != nil {
 		return 0, fmt.Errorf("failed to get guards count: %w", err)
 	}
 	return count, nil
 }
+
+func (r *GuardRepository) GetGuardByID(ctx context.Context, id string) (*models.User, error) {
+	var guard models.User
+	if err := r.db.WithContext(ctx).Where("id = ? AND role = 'guard'", id).First(&guard).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guard: %w", err)
+	}
+	return &guard, nil
+}
+
+// GetSupervisors returns the admins and operators assigned to the premise.
+func (r *GuardRepository) GetSupervisors(ctx context.Context, premiseID string) ([]models.User, error) {
+	var supervisors []models.User
+	if err := r.db.WithContext(ctx).
+		Where("role IN ('admin', 'operator') AND id IN (SELECT user_id FROM user_premises WHERE premise_id = ?)", premiseID).
+		Find(&supervisors).Error; err != nil {
+		return nil, fmt.Errorf("failed to get supervisors: %w", err)
+	}
+	return supervisors, nil
+}
+
+// GetAssignedPremiseID returns the premise the guard was first assigned to, or
+// uuid.Nil when they are assigned to none.
+func (r *GuardRepository) GetAssignedPremiseID(ctx context.Context, guardID string) (uuid.UUID, error) {
+	var premiseIDs []uuid.UUID
+	if err := r.db.WithContext(ctx).Model(&models.UserPremise{}).Where("user_id = ?", guardID).
+		Order("created_at").Limit(1).Pluck("premise_id", &premiseIDs).Error; err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get guard premise: %w", err)
+	}
+	if len(premiseIDs) == 0 {
+		return uuid.Nil, nil
+	}
+	return premiseIDs[0], nil
+}
+
+func (r *GuardRepository) GetAdmins(ctx context.Context) ([]models.User, error) {
+	var admins []models.User
+	if err := r.db.WithContext(ctx).Where("role = 'admin'").Find(&admins).Error; err != nil {
+		return nil, fmt.Errorf("failed to get admins: %w", err)
+	}
+	return admins, nil
+}
+
+// AssigneeCandidate is a guard of a premise with what picking an assignee
+// weighs: their open incidents, whether they are on shift and their position.
+type AssigneeCandidate struct {
+	UserID        uuid.UUID
+	Name          string
+	OpenIncidents int
+	OnDuty        bool
+	// The position is only set when it was reported recently enough
+	Latitude  *float64
+	Longitude *float64
+}
+
+// GetAssigneeCandidates returns the guards assigned to the premise by name.
+// Positions reported before positionsSince are left out. Scopes narrow the
+// guards further, such as to those qualified for a guidance template.
+func (r *GuardRepository) GetAssigneeCandidates(ctx context.Context, premiseID uuid.UUID, positionsSince time.Time, scopes ...func(*gorm.DB) *gorm.DB) ([]AssigneeCandidate, error) {
+	var candidates []AssigneeCandidate
+	if err := database.Conn(ctx, r.db).Table("users").
+		Select(`users.id AS user_id, users.name,
+			(SELECT count(DISTINCT ig.incident_id) FROM incident_guidances ig JOIN incidents i ON i.id = ig.incident_id
+				WHERE ig.assignee_id = users.id AND ig.status = 'active' AND i.status <> 'resolved') AS open_incidents,
+			EXISTS (SELECT 1 FROM guard_shifts gs WHERE gs.user_id = users.id AND gs.ended_at IS NULL) AS on_duty,
+			guard_positions.latitude, guard_positions.longitude`).
+		Joins("LEFT JOIN guard_positions ON guard_positions.user_id = users.id AND guard_positions.reported_at >= ?", positionsSince).
+		Where("users.role = 'guard' AND users.id IN (SELECT user_id FROM user_premises WHERE premise_id = ?)", premiseID).
+		Scopes(scopes...).
+		Order("users.name").
+		Scan(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to get assignee candidates: %w", err)
+	}
+	return candidates, nil
+}
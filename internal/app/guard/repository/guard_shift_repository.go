@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type GuardShiftRepository struct {
+	db *gorm.DB
+}
+
+func NewGuardShiftRepository(db *gorm.DB) *GuardShiftRepository {
+	return &GuardShiftRepository{db: db}
+}
+
+func (r *GuardShiftRepository) CreateGuardShift(ctx context.Context, shift *models.GuardShift) (*models.GuardShift, error) {
+	if err := database.Conn(ctx, r.db).Create(shift).Error; err != nil {
+		return nil, fmt.Errorf("failed to create guard shift: %w", err)
+	}
+	return shift, nil
+}
+
+// GetActiveShift returns the shift of the guard that has not ended.
+func (r *GuardShiftRepository) GetActiveShift(ctx context.Context, userID string) (*models.GuardShift, error) {
+	var shift models.GuardShift
+	if err := database.Conn(ctx, r.db).Where("user_id = ? AND ended_at IS NULL", userID).First(&shift).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guard shift: %w", err)
+	}
+	return &shift, nil
+}
+
+// GetActiveShifts returns the shifts that have not ended, the next check-in
+// due first.
+func (r *GuardShiftRepository) GetActiveShifts(ctx context.Context) ([]models.GuardShift, error) {
+	var shifts []models.GuardShift
+	if err := database.Conn(ctx, r.db).Preload("User").Preload("Premise").
+		Where("ended_at IS NULL").Order("next_check_in_at").Find(&shifts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guard shifts: %w", err)
+	}
+	return shifts, nil
+}
+
+func (r *GuardShiftRepository) UpdateGuardShiftFields(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	if err := database.Conn(ctx, r.db).Model(&models.GuardShift{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+		return fmt.Errorf("failed to update guard shift: %w", err)
+	}
+	return nil
+}
+
+// GetEscalations returns the shifts at the given escalation whose check-in was
+// due before dueBefore.
+func (r *GuardShiftRepository) GetEscalations(ctx context.Context, escalation string, dueBefore time.Time) ([]models.GuardShift, error) {
+	var shifts []models.GuardShift
+	if err := database.Conn(ctx, r.db).Preload("User").
+		Where("ended_at IS NULL AND escalation = ? AND next_check_in_at < ?", escalation, dueBefore).
+		Order("next_check_in_at").Find(&shifts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guard shift escalations: %w", err)
+	}
+	return shifts, nil
+}
+
+// Escalate moves the shift's missed check-in to the next escalation. It
+// reports whether the shift was unchanged since it was read, so a check-in in
+// between stops the escalation and every escalation happens once.
+func (r *GuardShiftRepository) Escalate(ctx context.Context, shift models.GuardShift, escalation string) (bool, error) {
+	result := database.Conn(ctx, r.db).Model(&models.GuardShift{}).
+		Where("id = ? AND ended_at IS NULL AND escalation = ? AND next_check_in_at = ?", shift.ID, shift.Escalation, shift.NextCheckInAt).
+		Update("escalation", escalation)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to escalate guard shift: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
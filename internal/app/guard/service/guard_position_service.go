@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"scs-operator/internal/app/guard/dto"
+	repositories "scs-operator/internal/app/guard/repository"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/geo"
+	"scs-operator/pkg/query"
+	"time"
+)
+
+// locationPartitionMonths is how many monthly history partitions, the current
+// one included, are kept ahead.
+const locationPartitionMonths = 3
+
+// ReportLocation records the caller's position.
+func (s *Service) ReportLocation(ctx context.Context, userID string, reportLocationDto *dto.ReportLocationDto) (*models.GuardPosition, error) {
+	return s.recordLocation(ctx, userID, reportLocationDto)
+}
+
+// RecordLocation records a position published by a guard's device.
+func (s *Service) RecordLocation(ctx context.Context, guardLocationDto *dto.GuardLocationDto) (*models.GuardPosition, error) {
+	if guardLocationDto.GuardID == "" {
+		return nil, errors.NewBadRequestError("location must carry a guard ID")
+	}
+	return s.recordLocation(ctx, guardLocationDto.GuardID, &guardLocationDto.ReportLocationDto)
+}
+
+// recordLocation adds the position to the guard's history and makes it their
+// latest position unless a more recent one is stored already.
+func (s *Service) recordLocation(ctx context.Context, guardID string, reportLocationDto *dto.ReportLocationDto) (*models.GuardPosition, error) {
+	guard, err := s.guardRepo.GetGuardByID(ctx, guardID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guard")
+	}
+	point, err := geo.Resolve(reportLocationDto.Latitude, reportLocationDto.Longitude, reportLocationDto.Geometry)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	if point == nil {
+		return nil, errors.NewBadRequestError("A position is required")
+	}
+	reportedAt, err := query.ParseTime("reported_at", reportLocationDto.ReportedAt)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	now := time.Now()
+	// Device clocks drift, a position is never taken in the future
+	if reportedAt == nil || reportedAt.After(now) {
+		reportedAt = &now
+	}
+	position := &models.GuardPosition{
+		UserID:     guard.ID,
+		Latitude:   point.Latitude,
+		Longitude:  point.Longitude,
+		Accuracy:   reportLocationDto.Accuracy,
+		ReportedAt: *reportedAt,
+	}
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.guardLocationRepo.CreateGuardLocation(ctx, &models.GuardLocation{
+			UserID:     position.UserID,
+			Latitude:   position.Latitude,
+			Longitude:  position.Longitude,
+			Accuracy:   position.Accuracy,
+			ReportedAt: position.ReportedAt,
+			ReceivedAt: now,
+		}); err != nil {
+			return errors.NewDatabaseError("record guard location", err)
+		}
+		if err := s.guardPositionRepo.UpsertGuardPosition(ctx, position); err != nil {
+			return errors.NewDatabaseError("update guard position", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return position, nil
+}
+
+// EnsureLocationPartitions creates the location history partitions of the
+// current and the coming months.
+func (s *Service) EnsureLocationPartitions(ctx context.Context) error {
+	if err := s.guardLocationRepo.EnsurePartitions(ctx, time.Now(), locationPartitionMonths); err != nil {
+		return errors.NewDatabaseError("create guard location partitions", err)
+	}
+	return nil
+}
+
+// GetGuardLocations returns the last position of every guard for the live map.
+func (s *Service) GetGuardLocations(ctx context.Context, locationsDto *dto.GetGuardLocationsDto) ([]types.GuardLocation, error) {
+	freshSince := time.Now().Add(-s.positionMaxAge)
+	var since *time.Time
+	if !locationsDto.IncludeStale {
+		since = &freshSince
+	}
+	guards, err := s.guardPositionRepo.GetGuardPositions(ctx, since)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get guard locations", err)
+	}
+	locations := make([]types.GuardLocation, 0, len(guards))
+	for _, guard := range guards {
+		locations = append(locations, guardLocation(guard, freshSince))
+	}
+	return locations, nil
+}
+
+// GetGuardTrack returns the positions a guard reported between from and to.
+func (s *Service) GetGuardTrack(ctx context.Context, guardID string, trackDto *dto.GetGuardTrackDto) (*types.GuardTrack, error) {
+	from, err := query.ParseTime("from", trackDto.From)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	to, err := query.ParseTime("to", trackDto.To)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	if to == nil {
+		now := time.Now()
+		to = &now
+	}
+	if !from.Before(*to) {
+		return nil, errors.NewBadRequestError("from must be before to")
+	}
+	guard, err := s.guardRepo.GetGuardByID(ctx, guardID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guard")
+	}
+	// Read one more position to tell whether the track was cut off
+	locations, err := s.guardLocationRepo.GetTrack(ctx, guard.ID.String(), *from, *to, trackDto.Limit+1)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get guard track", err)
+	}
+	track := &types.GuardTrack{GuardID: guard.ID.String(), From: *from, To: *to, Points: make([]types.TrackPoint, 0, len(locations))}
+	if len(locations) > trackDto.Limit {
+		locations, track.Truncated = locations[:trackDto.Limit], true
+	}
+	for _, location := range locations {
+		track.Points = append(track.Points, types.TrackPoint{
+			Position:   geo.Point{Latitude: location.Latitude, Longitude: location.Longitude},
+			Accuracy:   location.Accuracy,
+			ReportedAt: location.ReportedAt,
+		})
+	}
+	return track, nil
+}
+
+// GetNearestGuards returns the available guards closest to a point, nearest
+// first. Guards that have not reported a position recently are left out.
+func (s *Service) GetNearestGuards(ctx context.Context, nearestDto *dto.GetNearestGuardsDto) ([]types.NearestGuard, error) {
+	center, err := geo.NewPoint(nearestDto.Latitude, nearestDto.Longitude)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	since := time.Now().Add(-s.positionMaxAge)
+	guards, err := s.guardPositionRepo.GetNearestAvailableGuards(ctx, *center, since, nearestDto.MaxDistance, nearestDto.Limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get nearest guards", err)
+	}
+	nearest := make([]types.NearestGuard, 0, len(guards))
+	for _, guard := range guards {
+		nearest = append(nearest, types.NearestGuard{GuardLocation: guardLocation(guard.LocatedGuard, since), Distance: guard.Distance})
+	}
+	return nearest, nil
+}
+
+func guardLocation(guard repositories.LocatedGuard, freshSince time.Time) types.GuardLocation {
+	return types.GuardLocation{
+		ID:         guard.UserID.String(),
+		Name:       guard.Name,
+		Email:      guard.Email,
+		Position:   geo.Point{Latitude: guard.Latitude, Longitude: guard.Longitude},
+		Accuracy:   guard.Accuracy,
+		ReportedAt: guard.ReportedAt,
+		Stale:      guard.ReportedAt.Before(freshSince),
+	}
+}
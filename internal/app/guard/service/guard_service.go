@@ -2,19 +2,37 @@ package services
 
 import (
 	"context"
+	alarmServices "scs-operator/internal/app/alarm/service"
 	"scs-operator/internal/app/guard/dto"
 	repositories "scs-operator/internal/app/guard/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
 	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/errors"
+	kafka_client "scs-operator/pkg/kafka"
+	"scs-operator/pkg/query"
 	"scs-operator/pkg/utils"
+	"time"
 )
 
 type Service struct {
-	guardRepo        repositories.GuardRepository
-	guardPremiseRepo repositories.GuardPremiseRepository
+	guardRepo         repositories.GuardRepository
+	guardPremiseRepo  repositories.GuardPremiseRepository
+	guardPositionRepo repositories.GuardPositionRepository
+	guardLocationRepo repositories.GuardLocationRepository
+	guardShiftRepo    repositories.GuardShiftRepository
+	premiseRepo       premiseRepositories.PremiseRepository
+	alarmService      alarmServices.Service
+	transactor        database.Transactor
+	producer          kafka_client.Producer
+	// positionMaxAge is how long a reported position counts as current
+	positionMaxAge time.Duration
+	checkInPolicy  CheckInPolicy
 }
 
-func NewGuardService(guardRepo repositories.GuardRepository, guardPremiseRepo repositories.GuardPremiseRepository) *Service {
-	return &Service{guardRepo: guardRepo, guardPremiseRepo: guardPremiseRepo}
+func NewGuardService(guardRepo repositories.GuardRepository, guardPremiseRepo repositories.GuardPremiseRepository, guardPositionRepo repositories.GuardPositionRepository, guardLocationRepo repositories.GuardLocationRepository, guardShiftRepo repositories.GuardShiftRepository, premiseRepo premiseRepositories.PremiseRepository, alarmService alarmServices.Service, transactor database.Transactor, producer kafka_client.Producer, positionMaxAge time.Duration, checkInPolicy CheckInPolicy) *Service {
+	return &Service{guardRepo: guardRepo, guardPremiseRepo: guardPremiseRepo, guardPositionRepo: guardPositionRepo, guardLocationRepo: guardLocationRepo, guardShiftRepo: guardShiftRepo, premiseRepo: premiseRepo, alarmService: alarmService, transactor: transactor, producer: producer, positionMaxAge: positionMaxAge, checkInPolicy: checkInPolicy}
 }
 
 func (s *Service) Create(ctx context.Context, createGuardDto *dto.CreateGuardDto) (*models.User, error) {
@@ -38,6 +56,25 @@ func (s *Service) Create(ctx context.Context, createGuardDto *dto.CreateGuardDto
 
 }
 
+func (s *Service) GetGuards(ctx context.Context, request query.Request) (*types.PaginateResponse[models.User], error) {
+	params, err := query.New(repositories.GuardSchema, request)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	guards, page, err := s.guardRepo.GetGuards(ctx, params)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get guards", err)
+	}
+	if params.IncludeTotal {
+		total, err := s.guardRepo.GetGuardsCount(ctx)
+		if err != nil {
+			return nil, errors.NewDatabaseError("get guards count", err)
+		}
+		page.Total = &total
+	}
+	return types.NewPaginateResponse(guards, page), nil
+}
+
 // func (s *Service) AssignPremises(ctx context.Context, guardID string, premiseID string) error {
 // 	guard, err := uuid.Parse(guardID)
 // 	if err != nil {
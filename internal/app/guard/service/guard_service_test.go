@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"scs-operator/internal/app/guard/dto"
+	repositories "scs-operator/internal/app/guard/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	"scs-operator/internal/testsupport"
+	"scs-operator/internal/testsupport/alarmtest"
+	database "scs-operator/pkg/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/gorm"
+)
+
+const (
+	testGuardID   = "5a1b7f9d-aa6e-4e6d-8eaa-8e5f2e8a7b06"
+	testShiftID   = "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f"
+	testPremiseID = "b2d4f6a8-1c3e-4a5b-9d7f-0e2c4a6b8d1f"
+	testAlarmID   = "3e9f5d7b-88c4-4c4b-8c88-6c3d0c6e5f04"
+)
+
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := testsupport.NewMockDB(t)
+	svc := NewGuardService(
+		*repositories.NewGuardRepository(db),
+		*repositories.NewGuardPremiseRepository(db),
+		*repositories.NewGuardPositionRepository(db),
+		*repositories.NewGuardLocationRepository(db),
+		*repositories.NewGuardShiftRepository(db),
+		*premiseRepositories.NewPremiseRepository(db),
+		*alarmtest.NewService(t, db),
+		*database.NewTransactor(db),
+		testsupport.NewProducer(t),
+		15*time.Minute,
+		CheckInPolicy{Interval: 30 * time.Minute, AlarmDelay: 5 * time.Minute, SupervisorDelay: 5 * time.Minute},
+	)
+	return svc, mock
+}
+
+func expectGuardLookup(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "users" WHERE id = $1 AND role = 'guard'`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role"}).AddRow(testGuardID, "Guard", "guard"))
+}
+
+func TestRecordLocation(t *testing.T) {
+	latitude, longitude := 52.3676, 4.9041
+	tests := []struct {
+		name           string
+		location       dto.GuardLocationDto
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{name: "Guard is required", location: dto.GuardLocationDto{ReportLocationDto: dto.ReportLocationDto{Latitude: &latitude, Longitude: &longitude}}, expectedStatus: http.StatusBadRequest},
+		{
+			name:     "Position is required",
+			location: dto.GuardLocationDto{GuardID: testGuardID},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectGuardLookup(mock)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "History and latest position are written together",
+			location: dto.GuardLocationDto{GuardID: testGuardID, ReportLocationDto: dto.ReportLocationDto{Latitude: &latitude, Longitude: &longitude, ReportedAt: "2024-05-01T10:00:00Z"}},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectGuardLookup(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec(testsupport.QuoteSQL(`INSERT INTO "guard_location_history"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(testsupport.QuoteSQL(`INSERT INTO "guard_positions" ("user_id","latitude","longitude","accuracy","reported_at") VALUES ($1,$2,$3,$4,$5) ON CONFLICT ("user_id") DO UPDATE SET "latitude"="excluded"."latitude","longitude"="excluded"."longitude","accuracy"="excluded"."accuracy","reported_at"="excluded"."reported_at" WHERE excluded.reported_at >= guard_positions.reported_at`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:     "Failed history write rolls back",
+			location: dto.GuardLocationDto{GuardID: testGuardID, ReportLocationDto: dto.ReportLocationDto{Latitude: &latitude, Longitude: &longitude}},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectGuardLookup(mock)
+				mock.ExpectBegin()
+				mock.ExpectExec(testsupport.QuoteSQL(`INSERT INTO "guard_location_history"`)).WillReturnError(gorm.ErrInvalidData)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			position, err := svc.RecordLocation(context.Background(), &tt.location)
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if position.ReportedAt.Format(time.RFC3339) != tt.location.ReportedAt {
+				t.Errorf("expected the reported time, got %v", position.ReportedAt)
+			}
+		})
+	}
+}
+
+func TestGetGuardTrack(t *testing.T) {
+	t.Run("From must be before to", func(t *testing.T) {
+		svc, _ := newTestService(t)
+		_, err := svc.GetGuardTrack(context.Background(), testGuardID, &dto.GetGuardTrackDto{From: "2024-05-01T11:00:00Z", To: "2024-05-01T10:00:00Z", Limit: 10})
+		testsupport.AssertAppError(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("Long tracks are truncated", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		expectGuardLookup(mock)
+		first := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows([]string{"user_id", "latitude", "longitude", "reported_at"})
+		for i := 0; i < 3; i++ {
+			rows.AddRow(testGuardID, 52.3676, 4.9041, first.Add(time.Duration(i)*time.Minute))
+		}
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guard_location_history" WHERE user_id = $1 AND reported_at >= $2 AND reported_at < $3 ORDER BY reported_at LIMIT $4`)).
+			WithArgs(testGuardID, first, first.Add(time.Hour), 3).
+			WillReturnRows(rows)
+
+		track, err := svc.GetGuardTrack(context.Background(), testGuardID, &dto.GetGuardTrackDto{From: "2024-05-01T10:00:00Z", To: "2024-05-01T11:00:00Z", Limit: 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(track.Points) != 2 || !track.Truncated || !track.Points[0].ReportedAt.Equal(first) {
+			t.Errorf("expected the first 2 points and a truncated track, got %+v", track)
+		}
+	})
+}
+
+func TestGetNearestGuards(t *testing.T) {
+	svc, mock := newTestService(t)
+	latitude, longitude := 52.3676, 4.9041
+	mock.ExpectQuery(`(?s)AS distance FROM "guard_positions" JOIN users .+NOT EXISTS .+ ORDER BY distance LIMIT`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "email", "latitude", "longitude", "reported_at", "distance"}).
+			AddRow(testGuardID, "Guard", "guard@example.com", 52.37, 4.9, time.Now(), 410.5))
+
+	guards, err := svc.GetNearestGuards(context.Background(), &dto.GetNearestGuardsDto{Latitude: &latitude, Longitude: &longitude, Limit: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(guards) != 1 || guards[0].ID != testGuardID || guards[0].Distance != 410.5 || guards[0].Stale {
+		t.Errorf("unexpected guards %+v", guards)
+	}
+}
+
+func TestCheckIn(t *testing.T) {
+	t.Run("Guard must be on shift", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guard_shifts" WHERE user_id = $1 AND ended_at IS NULL`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := svc.CheckIn(context.Background(), testGuardID, &dto.CheckInDto{})
+		testsupport.AssertAppError(t, err, http.StatusNotFound)
+	})
+
+	t.Run("Check-in stops the escalation", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guard_shifts" WHERE user_id = $1 AND ended_at IS NULL`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "check_in_interval_minutes", "escalation"}).
+				AddRow(testShiftID, testGuardID, 20, "alarm"))
+		mock.ExpectBegin()
+		mock.ExpectExec(testsupport.QuoteSQL(`UPDATE "guard_shifts" SET "escalation"=$1,"last_check_in_at"=$2,"next_check_in_at"=$3,"updated_at"=$4 WHERE id = $5`)).
+			WithArgs("none", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), testShiftID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		shift, err := svc.CheckIn(context.Background(), testGuardID, &dto.CheckInDto{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if shift.Escalation != "none" || shift.NextCheckInAt.Sub(shift.LastCheckInAt) != 20*time.Minute {
+			t.Errorf("expected the next check-in in 20 minutes without escalation, got %+v", shift)
+		}
+	})
+}
+
+// expectPremiseAlarm expects the alarm service to raise an alarm on the test
+// premise, failing the insert with err when given. Alarms raised outside a
+// transaction are inserted in their own.
+func expectPremiseAlarm(mock sqlmock.Sqlmock, inTransaction bool, err error) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).
+		WithArgs(testPremiseID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testPremiseID, "Head office"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`WITH RECURSIVE ancestors`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testPremiseID))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "maintenance_windows"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if !inTransaction {
+		mock.ExpectBegin()
+	}
+	mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "premises"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	insert := mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "alarms"`))
+	if err != nil {
+		insert.WillReturnError(err)
+		return
+	}
+	insert.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testAlarmID))
+	if !inTransaction {
+		mock.ExpectCommit()
+	}
+}
+
+// expectNoPosition finds no reported position for the test guard.
+func expectNoPosition(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guard_positions" WHERE user_id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func TestPanic(t *testing.T) {
+	activeShift := testsupport.QuoteSQL(`SELECT * FROM "guard_shifts" WHERE user_id = $1 AND ended_at IS NULL`)
+	assignedPremise := testsupport.QuoteSQL(`SELECT "premise_id" FROM "user_premises" WHERE user_id = $1`)
+	expectSupervisors := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "users" WHERE role IN ('admin', 'operator')`)).
+			WithArgs(testPremiseID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("8c9f3d2e-0a4b-4f7c-ae3d-2b5a7f9c1d48", "Supervisor"))
+	}
+	tests := []struct {
+		name           string
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name: "Alarm is raised on the premise of the shift",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectGuardLookup(mock)
+				expectNoPosition(mock)
+				mock.ExpectQuery(activeShift).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "premise_id"}).AddRow(testShiftID, testGuardID, testPremiseID))
+				expectPremiseAlarm(mock, false, nil)
+				expectSupervisors(mock)
+			},
+		},
+		{
+			name: "Guard off shift raises it on their assigned premise",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectGuardLookup(mock)
+				expectNoPosition(mock)
+				mock.ExpectQuery(activeShift).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery(assignedPremise).WithArgs(testGuardID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"premise_id"}).AddRow(testPremiseID))
+				expectPremiseAlarm(mock, false, nil)
+				expectSupervisors(mock)
+			},
+		},
+		{
+			name: "Guard off shift without a premise",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectGuardLookup(mock)
+				expectNoPosition(mock)
+				mock.ExpectQuery(activeShift).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery(assignedPremise).WillReturnRows(sqlmock.NewRows([]string{"premise_id"}))
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "Shift lookup failure is not mistaken for being off shift",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectGuardLookup(mock)
+				expectNoPosition(mock)
+				mock.ExpectQuery(activeShift).WillReturnError(testsupport.ErrInjected)
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			alarm, err := svc.Panic(context.Background(), testGuardID, &dto.PanicDto{Message: "Intruder"})
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if alarm.PremiseID.String() != testPremiseID || alarm.Type != "guard_panic" {
+				t.Errorf("expected a guard_panic alarm on %s, got %+v", testPremiseID, alarm)
+			}
+		})
+	}
+}
+
+func TestCheckShifts(t *testing.T) {
+	escalations := testsupport.QuoteSQL(`SELECT * FROM "guard_shifts" WHERE ended_at IS NULL AND escalation = $1 AND next_check_in_at < $2 ORDER BY next_check_in_at`)
+	escalate := testsupport.QuoteSQL(`UPDATE "guard_shifts" SET "escalation"=$1,"updated_at"=$2 WHERE id = $3 AND ended_at IS NULL AND escalation = $4 AND next_check_in_at = $5`)
+	dueAt := time.Now().Add(-time.Hour)
+	expectShift := func(mock sqlmock.Sqlmock, escalation string, alarmID interface{}) {
+		mock.ExpectQuery(escalations).
+			WithArgs(escalation, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "premise_id", "escalation", "next_check_in_at", "alarm_id"}).
+				AddRow(testShiftID, testGuardID, testPremiseID, escalation, dueAt, alarmID))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "users" WHERE "users"."id" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testGuardID, "Guard"))
+	}
+	expectNoShifts := func(mock sqlmock.Sqlmock, escalation string) {
+		mock.ExpectQuery(escalations).
+			WithArgs(escalation, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	t.Run("Check-in in between stops the escalation", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		expectShift(mock, "none", nil)
+		mock.ExpectBegin()
+		mock.ExpectExec(escalate).
+			WithArgs("warning", sqlmock.AnyArg(), testShiftID, "none", dueAt).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		expectNoShifts(mock, "warning")
+		expectNoShifts(mock, "alarm")
+
+		if err := svc.CheckShifts(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Long missed check-in escalates to the alarm and the supervisors", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		expectShift(mock, "none", nil)
+		mock.ExpectBegin()
+		mock.ExpectExec(escalate).WithArgs("warning", sqlmock.AnyArg(), testShiftID, "none", dueAt).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// The escalation, alarm and its reference are written together
+		expectShift(mock, "warning", nil)
+		mock.ExpectBegin()
+		mock.ExpectExec(escalate).WithArgs("alarm", sqlmock.AnyArg(), testShiftID, "warning", dueAt).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectNoPosition(mock)
+		expectPremiseAlarm(mock, true, nil)
+		mock.ExpectExec(testsupport.QuoteSQL(`UPDATE "guard_shifts" SET "alarm_id"=$1,"updated_at"=$2 WHERE id = $3`)).
+			WithArgs(testAlarmID, sqlmock.AnyArg(), testShiftID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectShift(mock, "alarm", testAlarmID)
+		mock.ExpectBegin()
+		mock.ExpectExec(escalate).WithArgs("supervisors", sqlmock.AnyArg(), testShiftID, "alarm", dueAt).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectNoPosition(mock)
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "users" WHERE role IN ('admin', 'operator')`)).
+			WithArgs(testPremiseID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("8c9f3d2e-0a4b-4f7c-ae3d-2b5a7f9c1d48", "Supervisor"))
+
+		if err := svc.CheckShifts(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Failed alarm leaves the shift to be retried", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		expectNoShifts(mock, "none")
+		expectShift(mock, "warning", nil)
+		mock.ExpectBegin()
+		mock.ExpectExec(escalate).WithArgs("alarm", sqlmock.AnyArg(), testShiftID, "warning", dueAt).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectNoPosition(mock)
+		expectPremiseAlarm(mock, true, testsupport.ErrInjected)
+		mock.ExpectRollback()
+		// The supervisor step still runs for the other shifts
+		expectNoShifts(mock, "alarm")
+
+		err := svc.CheckShifts(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "shift of Guard") {
+			t.Fatalf("expected the failure of the guard's shift, got %v", err)
+		}
+	})
+}
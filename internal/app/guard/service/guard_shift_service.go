@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	alarmDto "scs-operator/internal/app/alarm/dto"
+	"scs-operator/internal/app/guard/dto"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/geo"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
+)
+
+// CheckInPolicy is how lone-worker check-ins are escalated. A missed check-in
+// warns the guard when it is due, raises a man-down alarm AlarmDelay later and
+// notifies the supervisors SupervisorDelay after the alarm.
+type CheckInPolicy struct {
+	// Interval is the check-in interval of shifts that do not set one
+	Interval        time.Duration
+	AlarmDelay      time.Duration
+	SupervisorDelay time.Duration
+}
+
+// StartShift starts a lone-worker shift of the guard. The first check-in is
+// due one interval from now.
+func (s *Service) StartShift(ctx context.Context, guardID string, startShiftDto *dto.StartShiftDto) (*models.GuardShift, error) {
+	guard, err := s.guardRepo.GetGuardByID(ctx, guardID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guard")
+	}
+	premise, err := s.premiseRepo.GetPremiseByID(ctx, startShiftDto.PremiseID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("premise")
+	}
+	if _, err := s.guardShiftRepo.GetActiveShift(ctx, guardID); err == nil {
+		return nil, errors.NewConflictError("Guard is already on shift")
+	}
+	interval := startShiftDto.CheckInIntervalMinutes
+	if interval == 0 {
+		interval = int(s.checkInPolicy.Interval.Minutes())
+	}
+	now := time.Now()
+	shift, err := s.guardShiftRepo.CreateGuardShift(ctx, &models.GuardShift{
+		UserID:                 guard.ID,
+		PremiseID:              premise.ID,
+		CheckInIntervalMinutes: interval,
+		StartedAt:              now,
+		LastCheckInAt:          now,
+		NextCheckInAt:          now.Add(time.Duration(interval) * time.Minute),
+		Escalation:             "none",
+	})
+	if err != nil {
+		return nil, errors.NewDatabaseError("start guard shift", err)
+	}
+	return shift, nil
+}
+
+// EndShift ends the guard's shift, which stops its check-ins.
+func (s *Service) EndShift(ctx context.Context, guardID string) (*models.GuardShift, error) {
+	shift, err := s.guardShiftRepo.GetActiveShift(ctx, guardID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("shift")
+	}
+	now := time.Now()
+	if err := s.guardShiftRepo.UpdateGuardShiftFields(ctx, shift.ID, map[string]interface{}{"ended_at": now}); err != nil {
+		return nil, errors.NewDatabaseError("end guard shift", err)
+	}
+	shift.EndedAt = &now
+	return shift, nil
+}
+
+// UpdateShift changes the check-in interval of the guard's shift. The next
+// check-in is due one new interval after the last one.
+func (s *Service) UpdateShift(ctx context.Context, guardID string, updateShiftDto *dto.UpdateShiftDto) (*models.GuardShift, error) {
+	shift, err := s.guardShiftRepo.GetActiveShift(ctx, guardID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("shift")
+	}
+	shift.CheckInIntervalMinutes = updateShiftDto.CheckInIntervalMinutes
+	shift.NextCheckInAt = shift.LastCheckInAt.Add(time.Duration(shift.CheckInIntervalMinutes) * time.Minute)
+	if err := s.guardShiftRepo.UpdateGuardShiftFields(ctx, shift.ID, map[string]interface{}{
+		"check_in_interval_minutes": shift.CheckInIntervalMinutes,
+		"next_check_in_at":          shift.NextCheckInAt,
+	}); err != nil {
+		return nil, errors.NewDatabaseError("update guard shift", err)
+	}
+	return shift, nil
+}
+
+func (s *Service) GetActiveShifts(ctx context.Context) ([]models.GuardShift, error) {
+	shifts, err := s.guardShiftRepo.GetActiveShifts(ctx)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get guard shifts", err)
+	}
+	return shifts, nil
+}
+
+// CheckIn records that the guard is fine. The next check-in is due one
+// interval from now and a running escalation stops; an alarm raised already
+// stays in the operator queue.
+func (s *Service) CheckIn(ctx context.Context, guardID string, checkInDto *dto.CheckInDto) (*models.GuardShift, error) {
+	shift, err := s.guardShiftRepo.GetActiveShift(ctx, guardID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("shift")
+	}
+	if hasPosition(&checkInDto.ReportLocationDto) {
+		if _, err := s.recordLocation(ctx, guardID, &checkInDto.ReportLocationDto); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	shift.LastCheckInAt = now
+	shift.NextCheckInAt = now.Add(time.Duration(shift.CheckInIntervalMinutes) * time.Minute)
+	shift.Escalation = "none"
+	if err := s.guardShiftRepo.UpdateGuardShiftFields(ctx, shift.ID, map[string]interface{}{
+		"last_check_in_at": shift.LastCheckInAt,
+		"next_check_in_at": shift.NextCheckInAt,
+		"escalation":       shift.Escalation,
+	}); err != nil {
+		return nil, errors.NewDatabaseError("check in", err)
+	}
+	return shift, nil
+}
+
+// Panic raises a high-severity alarm for the guard right away and notifies
+// the supervisors of the premise they are on shift at. Guards off shift raise
+// it on the premise they were first assigned to.
+func (s *Service) Panic(ctx context.Context, guardID string, panicDto *dto.PanicDto) (*models.Alarm, error) {
+	guard, err := s.guardRepo.GetGuardByID(ctx, guardID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guard")
+	}
+	var position *geo.Point
+	if hasPosition(&panicDto.ReportLocationDto) {
+		reported, err := s.recordLocation(ctx, guardID, &panicDto.ReportLocationDto)
+		if err != nil {
+			return nil, err
+		}
+		position = &geo.Point{Latitude: reported.Latitude, Longitude: reported.Longitude}
+	} else {
+		position = s.lastPosition(ctx, guardID)
+	}
+	event := types.LoneWorkerEvent{GuardID: guard.ID.String(), GuardName: guard.Name, Position: position, Message: panicDto.Message}
+	shift, err := s.guardShiftRepo.GetActiveShift(ctx, guardID)
+	switch {
+	case err == nil:
+		event.PremiseID = shift.PremiseID.String()
+		event.ShiftID = shift.ID.String()
+	case stdErrors.Is(err, gorm.ErrRecordNotFound):
+		premiseID, err := s.guardRepo.GetAssignedPremiseID(ctx, guardID)
+		if err != nil {
+			return nil, errors.NewDatabaseError("get guard premise", err)
+		}
+		if premiseID == uuid.Nil {
+			return nil, errors.NewConflictError("Guard is not on shift and not assigned to a premise")
+		}
+		event.PremiseID = premiseID.String()
+	default:
+		return nil, errors.NewDatabaseError("get guard shift", err)
+	}
+	description := fmt.Sprintf("%s pressed the panic button%s", guard.Name, positionText(position))
+	if panicDto.Message != "" {
+		description += ": " + panicDto.Message
+	}
+	alarm, err := s.alarmService.CreateAlarm(ctx, &alarmDto.CreateAlarmDto{
+		PremiseID:   event.PremiseID,
+		Type:        "guard_panic",
+		Description: description,
+		Severity:    "high",
+	})
+	if err != nil {
+		return nil, err
+	}
+	event.AlarmID = alarm.ID.String()
+	s.notifySupervisors(ctx, "guard.panic", event)
+	return alarm, nil
+}
+
+// CheckShifts escalates missed check-ins: the guard is warned when a check-in
+// is due, a man-down alarm is raised on their premise after the alarm delay
+// and the premise's supervisors are notified after the supervisor delay. A
+// check-in that was missed for long is escalated all the way in one pass. A
+// shift failing to escalate does not hold up the others.
+func (s *Service) CheckShifts(ctx context.Context) error {
+	now := time.Now()
+	steps := []struct {
+		from      string
+		dueBefore time.Time
+		escalate  func(context.Context, models.GuardShift) error
+	}{
+		{from: "none", dueBefore: now, escalate: s.warnGuard},
+		{from: "warning", dueBefore: now.Add(-s.checkInPolicy.AlarmDelay), escalate: s.raiseManDownAlarm},
+		{from: "alarm", dueBefore: now.Add(-s.checkInPolicy.AlarmDelay - s.checkInPolicy.SupervisorDelay), escalate: s.notifyManDown},
+	}
+	var failures []error
+	for _, step := range steps {
+		shifts, err := s.guardShiftRepo.GetEscalations(ctx, step.from, step.dueBefore)
+		if err != nil {
+			failures = append(failures, errors.NewDatabaseError("get missed check-ins", err))
+			continue
+		}
+		for _, shift := range shifts {
+			if err := step.escalate(ctx, shift); err != nil {
+				failures = append(failures, fmt.Errorf("shift of %s: %w", shiftGuardName(shift), err))
+			}
+		}
+	}
+	return stdErrors.Join(failures...)
+}
+
+// warnGuard asks the guard to check in.
+func (s *Service) warnGuard(ctx context.Context, shift models.GuardShift) error {
+	escalated, err := s.guardShiftRepo.Escalate(ctx, shift, "warning")
+	if err != nil {
+		return errors.NewDatabaseError("escalate missed check-in", err)
+	}
+	if !escalated {
+		return nil
+	}
+	event := shiftEvent(shift)
+	event.RecipientID = event.GuardID
+	s.notify(ctx, "guard.check_in_warning", []types.LoneWorkerEvent{event})
+	return nil
+}
+
+// raiseManDownAlarm escalates the shift and raises its alarm in one
+// transaction, so a shift never waits for its supervisors without an alarm.
+func (s *Service) raiseManDownAlarm(ctx context.Context, shift models.GuardShift) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		escalated, err := s.guardShiftRepo.Escalate(ctx, shift, "alarm")
+		if err != nil {
+			return errors.NewDatabaseError("escalate missed check-in", err)
+		}
+		if !escalated {
+			return nil
+		}
+		position := s.lastPosition(ctx, shift.UserID.String())
+		alarm, err := s.alarmService.CreateAlarm(ctx, &alarmDto.CreateAlarmDto{
+			PremiseID:   shift.PremiseID.String(),
+			Type:        "guard_man_down",
+			Description: fmt.Sprintf("%s missed their check-in due %s%s", shiftGuardName(shift), shift.NextCheckInAt.Format(time.RFC3339), positionText(position)),
+			Severity:    "high",
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.guardShiftRepo.UpdateGuardShiftFields(ctx, shift.ID, map[string]interface{}{"alarm_id": alarm.ID}); err != nil {
+			return errors.NewDatabaseError("update guard shift", err)
+		}
+		return nil
+	})
+}
+
+// notifyManDown notifies the supervisors of the shift's premise, referring
+// them to the shift's alarm.
+func (s *Service) notifyManDown(ctx context.Context, shift models.GuardShift) error {
+	escalated, err := s.guardShiftRepo.Escalate(ctx, shift, "supervisors")
+	if err != nil {
+		return errors.NewDatabaseError("escalate missed check-in", err)
+	}
+	if !escalated {
+		return nil
+	}
+	event := shiftEvent(shift)
+	event.Position = s.lastPosition(ctx, shift.UserID.String())
+	s.notifySupervisors(ctx, "guard.man_down", event)
+	return nil
+}
+
+// notifySupervisors sends the event to the admins and operators of its
+// premise, or to every admin when the premise has none.
+func (s *Service) notifySupervisors(ctx context.Context, eventType string, event types.LoneWorkerEvent) {
+	var supervisors []models.User
+	if event.PremiseID != "" {
+		supervisors, _ = s.guardRepo.GetSupervisors(ctx, event.PremiseID)
+	}
+	if len(supervisors) == 0 {
+		supervisors, _ = s.guardRepo.GetAdmins(ctx)
+	}
+	events := make([]types.LoneWorkerEvent, 0, len(supervisors))
+	for _, supervisor := range supervisors {
+		event.RecipientID = supervisor.ID.String()
+		events = append(events, event)
+	}
+	s.notify(ctx, eventType, events)
+}
+
+func (s *Service) notify(ctx context.Context, eventType string, events []types.LoneWorkerEvent) {
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		messageBytes, err := json.Marshal(types.Message[types.LoneWorkerEvent]{Type: eventType, Payload: event})
+		if err != nil {
+			continue
+		}
+		messages = append(messages, kafka.Message{Key: []byte(event.RecipientID), Value: messageBytes})
+	}
+	if len(messages) == 0 {
+		return
+	}
+	// A failed notification must not fail the escalation, a rolled back one must not notify
+	database.AfterCommit(ctx, func() { _ = s.producer.WriteMessages(ctx, messages...) })
+}
+
+// lastPosition returns the guard's last reported position, if any.
+func (s *Service) lastPosition(ctx context.Context, guardID string) *geo.Point {
+	position, err := s.guardPositionRepo.GetGuardPosition(ctx, guardID)
+	if err != nil {
+		return nil
+	}
+	return &geo.Point{Latitude: position.Latitude, Longitude: position.Longitude}
+}
+
+func hasPosition(reportLocationDto *dto.ReportLocationDto) bool {
+	return reportLocationDto.Latitude != nil || reportLocationDto.Longitude != nil || reportLocationDto.Geometry != nil
+}
+
+func shiftEvent(shift models.GuardShift) types.LoneWorkerEvent {
+	dueAt := shift.NextCheckInAt
+	event := types.LoneWorkerEvent{
+		GuardID:   shift.UserID.String(),
+		GuardName: shiftGuardName(shift),
+		PremiseID: shift.PremiseID.String(),
+		ShiftID:   shift.ID.String(),
+		DueAt:     &dueAt,
+	}
+	if shift.AlarmID != nil {
+		event.AlarmID = shift.AlarmID.String()
+	}
+	return event
+}
+
+func shiftGuardName(shift models.GuardShift) string {
+	if shift.User == nil {
+		return "Guard " + shift.UserID.String()
+	}
+	return shift.User.Name
+}
+
+func positionText(position *geo.Point) string {
+	if position == nil {
+		return ""
+	}
+	return fmt.Sprintf(", last seen at %.5f, %.5f", position.Latitude, position.Longitude)
+}
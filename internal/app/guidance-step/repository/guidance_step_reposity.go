@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -39,7 +41,7 @@ func (r *GuidanceStepRepository) GetGuidanceStepByID(ctx context.Context, id str
 }
 
 func (r *GuidanceStepRepository) CreateGuidanceSteps(ctx context.Context, steps []models.GuidanceStep) ([]models.GuidanceStep, error) {
-	if err := r.db.WithContext(ctx).Create(steps).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Create(steps).Error; err != nil {
 		return nil, fmt.Errorf("failed to create GuidanceSteps: %w", err)
 	}
 	return steps, nil
@@ -58,3 +60,17 @@ func (r *GuidanceStepRepository) DeleteGuidanceSteps(ctx context.Context, ids []
 	}
 	return nil
 }
+
+// ReplaceGuidanceSteps makes the steps the only ones of the template.
+func (r *GuidanceStepRepository) ReplaceGuidanceSteps(ctx context.Context, guidanceTemplateID uuid.UUID, steps []models.GuidanceStep) error {
+	if err := database.Conn(ctx, r.db).Delete(&models.GuidanceStep{}, "guidance_template_id = ?", guidanceTemplateID).Error; err != nil {
+		return fmt.Errorf("failed to delete guidance steps: %w", err)
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	if err := database.Conn(ctx, r.db).Create(steps).Error; err != nil {
+		return fmt.Errorf("failed to create guidance steps: %w", err)
+	}
+	return nil
+}
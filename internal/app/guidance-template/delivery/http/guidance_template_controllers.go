@@ -21,7 +21,7 @@ func NewHandler(svc services.Service) *Handler {
 
 // CreateGuidanceTemplate creates a new guidance template
 // @Summary Create a new guidance template
-// @Description Create a new guidance template with steps
+// @Description Create a new guidance template with steps. The steps are published as version 1 right away unless draft is set.
 // @Tags guidance-templates
 // @Accept json
 // @Produce json
@@ -43,7 +43,8 @@ func (h *Handler) CreateGuidanceTemplate() echo.HandlerFunc {
 			return err
 		}
 
-		createdGuidanceTemplate, err := h.svc.CreateGuidanceTemplate(c.Request().Context(), createGuidanceTemplateDto)
+		userID, _ := c.Get("user_id").(string)
+		createdGuidanceTemplate, err := h.svc.CreateGuidanceTemplate(c.Request().Context(), userID, createGuidanceTemplateDto)
 		if err != nil {
 			return err
 		}
@@ -97,7 +98,7 @@ func (h *Handler) GetGuidanceGuidanceTemplate() echo.HandlerFunc {
 
 // UpdateGuidanceTemplate updates an existing guidance template
 // @Summary Update guidance template
-// @Description Update an existing guidance template and its steps
+// @Description Update the draft of a guidance template and its steps. Steps are removed, updated and added together or not at all, then renumbered from 1 in the order of their step numbers, which must be unique. Incidents keep being given the published version until the draft is published.
 // @Tags guidance-templates
 // @Accept json
 // @Produce json
@@ -129,3 +130,175 @@ func (h *Handler) UpdateGuidanceTemplate() echo.HandlerFunc {
 
 	}
 }
+
+// ReorderGuidanceSteps reorders the steps of a guidance template
+// @Summary Reorder guidance template steps
+// @Description Renumber the draft steps of a guidance template in the given order. step_ids must list every step of the template once.
+// @Tags guidance-templates
+// @Accept json
+// @Produce json
+// @Param id path string true "Guidance Template ID"
+// @Param order body dto.ReorderGuidanceStepsDto true "Step order"
+// @Success 200 {object} models.GuidanceTemplate
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guidance-templates/{id}/steps/order [put]
+func (h *Handler) ReorderGuidanceSteps() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		reorderDto := &dto.ReorderGuidanceStepsDto{}
+		if err := c.Bind(reorderDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(reorderDto); err != nil {
+			return err
+		}
+		guidanceTemplate, err := h.svc.ReorderGuidanceSteps(c.Request().Context(), c.Param("id"), reorderDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, guidanceTemplate)
+	}
+}
+
+// PublishGuidanceTemplate publishes the draft of a guidance template
+// @Summary Publish guidance template
+// @Description Publish the draft of a guidance template as its next version. Published versions never change; incidents are given the steps of the published version and record which version they follow.
+// @Tags guidance-templates
+// @Accept json
+// @Produce json
+// @Param id path string true "Guidance Template ID"
+// @Param publish body dto.PublishGuidanceTemplateDto false "Publication note"
+// @Success 201 {object} models.GuidanceTemplateVersion
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guidance-templates/{id}/publish [post]
+func (h *Handler) PublishGuidanceTemplate() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		publishDto := &dto.PublishGuidanceTemplateDto{}
+		if err := c.Bind(publishDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(publishDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		version, err := h.svc.PublishGuidanceTemplate(c.Request().Context(), c.Param("id"), userID, publishDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, version)
+	}
+}
+
+// GetGuidanceTemplateVersions lists the versions of a guidance template
+// @Summary Get guidance template versions
+// @Description Get the published versions of a guidance template with their steps, latest first
+// @Tags guidance-templates
+// @Produce json
+// @Param id path string true "Guidance Template ID"
+// @Success 200 {array} models.GuidanceTemplateVersion
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guidance-templates/{id}/versions [get]
+func (h *Handler) GetGuidanceTemplateVersions() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		versions, err := h.svc.GetGuidanceTemplateVersions(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, versions)
+	}
+}
+
+// GetGuidanceTemplateVersion gets a version of a guidance template
+// @Summary Get guidance template version
+// @Description Get a published version of a guidance template with its steps
+// @Tags guidance-templates
+// @Produce json
+// @Param id path string true "Guidance Template ID"
+// @Param version path int true "Version"
+// @Success 200 {object} models.GuidanceTemplateVersion
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guidance-templates/{id}/versions/{version} [get]
+func (h *Handler) GetGuidanceTemplateVersion() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		version, err := h.svc.GetGuidanceTemplateVersion(c.Request().Context(), c.Param("id"), c.Param("version"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, version)
+	}
+}
+
+// DiffGuidanceTemplate compares two versions of a guidance template
+// @Summary Diff guidance template versions
+// @Description Compare two versions of a guidance template, given by number or as draft. Compares the published version with the draft by default. Steps are matched by ID.
+// @Tags guidance-templates
+// @Produce json
+// @Param id path string true "Guidance Template ID"
+// @Param from query string false "Version to compare from, defaults to the published version"
+// @Param to query string false "Version to compare to, defaults to draft"
+// @Success 200 {object} types.GuidanceTemplateDiff
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guidance-templates/{id}/diff [get]
+func (h *Handler) DiffGuidanceTemplate() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		diffDto := &dto.GetGuidanceTemplateDiffDto{}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, diffDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+		diff, err := h.svc.DiffGuidanceTemplate(c.Request().Context(), c.Param("id"), diffDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, diff)
+	}
+}
+
+// RollbackGuidanceTemplate restores a previous version of a guidance template
+// @Summary Roll back guidance template
+// @Description Publish a copy of a previous version as the next version and reset the draft to it. The versions in between are kept.
+// @Tags guidance-templates
+// @Accept json
+// @Produce json
+// @Param id path string true "Guidance Template ID"
+// @Param version path int true "Version to restore"
+// @Param publish body dto.PublishGuidanceTemplateDto false "Publication note"
+// @Success 201 {object} models.GuidanceTemplateVersion
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /guidance-templates/{id}/versions/{version}/rollback [post]
+func (h *Handler) RollbackGuidanceTemplate() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		publishDto := &dto.PublishGuidanceTemplateDto{}
+		if err := c.Bind(publishDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(publishDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		version, err := h.svc.RollbackGuidanceTemplate(c.Request().Context(), c.Param("id"), c.Param("version"), userID, publishDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, version)
+	}
+}
@@ -9,4 +9,10 @@ func (h *Handler) RegisterRoutes(g *echo.Group) {
 	g.PUT("/:id", h.UpdateGuidanceTemplate())
 	g.GET("", h.GetGuidanceGuidanceTemplates())
 	g.GET("/:id", h.GetGuidanceGuidanceTemplate())
+	g.PUT("/:id/steps/order", h.ReorderGuidanceSteps())
+	g.POST("/:id/publish", h.PublishGuidanceTemplate())
+	g.GET("/:id/versions", h.GetGuidanceTemplateVersions())
+	g.GET("/:id/versions/:version", h.GetGuidanceTemplateVersion())
+	g.POST("/:id/versions/:version/rollback", h.RollbackGuidanceTemplate())
+	g.GET("/:id/diff", h.DiffGuidanceTemplate())
 }
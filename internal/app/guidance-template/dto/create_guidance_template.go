@@ -4,11 +4,41 @@ type CreateGuidanceTemplateDto struct {
 	Name        string  `json:"name" validate:"required"`
 	Description string  `json:"description" validate:"required"`
 	Category    *string `json:"category"`
-	Steps       []Step  `json:"steps"`
+	Steps       []Step  `json:"steps" validate:"omitempty,dive"`
+	// RequiredQualificationIDs must be held, unexpired, by the guidance assignee
+	RequiredQualificationIDs []string `json:"required_qualification_ids" validate:"omitempty,dive,uuid"`
+	// Draft leaves the template unpublished, otherwise version 1 is published
+	Draft bool `json:"draft"`
 }
 type Step struct {
 	ID          *string `json:"id" validate:"omitempty,uuid"`
-	StepNumber  int     `json:"step_number" validate:"required"`
+	StepNumber  int     `json:"step_number" validate:"required,min=1"`
 	Title       string  `json:"title" validate:"required"`
 	Description string  `json:"description" validate:"required"`
+	// Type is how the step is done, checkbox when not given
+	Type string `json:"type" validate:"omitempty,oneof=checkbox yes_no single_choice text number photo signature"`
+	// Options are the answers of a single_choice step
+	Options []string `json:"options" validate:"omitempty,dive,required"`
+	// Branches lead on from the step depending on its answer
+	Branches []Branch `json:"branches" validate:"omitempty,dive"`
+	// Evidence is the proof the step must be completed with
+	Evidence Evidence `json:"evidence"`
+}
+
+// Evidence requires at least MinPhotos photos, a note, a signature, or a
+// position within the premise geofence when a step is completed.
+type Evidence struct {
+	MinPhotos int  `json:"min_photos" validate:"min=0,max=20"`
+	Note      bool `json:"note"`
+	Signature bool `json:"signature"`
+	Location  bool `json:"location"`
+}
+
+// Branch leads to the step with GoToStep, the step number the step has in the
+// same request, or ends the guidance. A branch without an answer is taken for
+// any other answer.
+type Branch struct {
+	Answer   string `json:"answer" validate:"max=255"`
+	GoToStep int    `json:"go_to_step" validate:"omitempty,min=1"`
+	End      bool   `json:"end"`
 }
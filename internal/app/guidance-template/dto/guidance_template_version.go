@@ -0,0 +1,14 @@
+package dto
+
+// PublishGuidanceTemplateDto publishes the draft of a template, or restores a
+// previous version, as a new version.
+type PublishGuidanceTemplateDto struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+// GetGuidanceTemplateDiffDto picks the versions to compare, by number or
+// "draft". From defaults to the published version, To to the draft.
+type GetGuidanceTemplateDiffDto struct {
+	From string `query:"from"`
+	To   string `query:"to"`
+}
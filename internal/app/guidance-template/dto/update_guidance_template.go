@@ -4,7 +4,14 @@ type UpdateGuidanceTemplateDto struct {
 	Name        string   `json:"name" validate:"required"`
 	Description string   `json:"description" validate:"required"`
 	Category    *string  `json:"category"`
-	AddSteps    []Step   `json:"add_steps"`
-	UpdateSteps []Step   `json:"update_steps"`
-	RemoveSteps []string `json:"remove_steps"`
+	AddSteps    []Step   `json:"add_steps" validate:"omitempty,dive"`
+	UpdateSteps []Step   `json:"update_steps" validate:"omitempty,dive"`
+	RemoveSteps []string `json:"remove_steps" validate:"omitempty,dive,uuid"`
+	// RequiredQualificationIDs replaces the required qualifications when given
+	RequiredQualificationIDs *[]string `json:"required_qualification_ids" validate:"omitempty,dive,uuid"`
+}
+
+// ReorderGuidanceStepsDto lists every step of a template in its new order.
+type ReorderGuidanceStepsDto struct {
+	StepIDs []string `json:"step_ids" validate:"required,min=1,dive,uuid"`
 }
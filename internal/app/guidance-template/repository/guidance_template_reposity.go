@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type GuidanceTemplateRepository struct {
@@ -17,14 +20,14 @@ func NewGuidanceTemplateRepository(db *gorm.DB) *GuidanceTemplateRepository {
 }
 
 func (r *GuidanceTemplateRepository) CreateGuidanceTemplate(ctx context.Context, GuidanceTemplate *models.GuidanceTemplate) (*models.GuidanceTemplate, error) {
-	if err := r.db.WithContext(ctx).Create(GuidanceTemplate).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Create(GuidanceTemplate).Error; err != nil {
 		return nil, fmt.Errorf("failed to create GuidanceTemplate: %w", err)
 	}
 	return GuidanceTemplate, nil
 }
 func (r *GuidanceTemplateRepository) GetGuidanceTemplates(ctx context.Context) ([]models.GuidanceTemplate, error) {
 	var GuidanceTemplates []models.GuidanceTemplate
-	if err := r.db.WithContext(ctx).Preload("GuidanceSteps").Find(&GuidanceTemplates).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("GuidanceSteps").Preload("PublishedVersion").Preload("RequiredQualifications").Find(&GuidanceTemplates).Error; err != nil {
 		return nil, fmt.Errorf("failed to get GuidanceTemplates: %w", err)
 	}
 	return GuidanceTemplates, nil
@@ -32,16 +35,54 @@ func (r *GuidanceTemplateRepository) GetGuidanceTemplates(ctx context.Context) (
 
 func (r *GuidanceTemplateRepository) GetGuidanceTemplateByID(ctx context.Context, id string) (*models.GuidanceTemplate, error) {
 	var GuidanceTemplate models.GuidanceTemplate
-	if err := r.db.WithContext(ctx).Preload("GuidanceSteps").First(&GuidanceTemplate, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("GuidanceSteps").Preload("PublishedVersion").Preload("RequiredQualifications").First(&GuidanceTemplate, "id = ?", id).Error; err != nil {
 		return nil, fmt.Errorf("failed to get GuidanceTemplate: %w", err)
 	}
 	return &GuidanceTemplate, nil
 }
 
 func (r *GuidanceTemplateRepository) UpdateGuidanceTemplate(ctx context.Context, id string, guidanceTemplate *models.GuidanceTemplate) (*models.GuidanceTemplate, error) {
-	result := r.db.WithContext(ctx).Model(&models.GuidanceTemplate{}).Where("id = ?", id).Updates(guidanceTemplate)
+	result := r.db.WithContext(ctx).Model(&models.GuidanceTemplate{}).Omit("RequiredQualifications", "PublishedVersion").Where("id = ?", id).Updates(guidanceTemplate)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to update guidance template: %w", result.Error)
 	}
 	return guidanceTemplate, nil
 }
+
+// ReplaceRequiredQualifications makes the qualifications the only ones the
+// template requires.
+func (r *GuidanceTemplateRepository) ReplaceRequiredQualifications(ctx context.Context, guidanceTemplate *models.GuidanceTemplate, qualifications []models.Qualification) error {
+	if err := database.Conn(ctx, r.db).Model(guidanceTemplate).Association("RequiredQualifications").Replace(qualifications); err != nil {
+		return fmt.Errorf("failed to replace required qualifications: %w", err)
+	}
+	return nil
+}
+
+// GetGuidanceTemplateForUpdate locks the template until the transaction ends,
+// so versions of the same template are published one after the other. The
+// template comes with its draft steps and published version.
+func (r *GuidanceTemplateRepository) GetGuidanceTemplateForUpdate(ctx context.Context, id string) (*models.GuidanceTemplate, error) {
+	var guidanceTemplate models.GuidanceTemplate
+	if err := database.Conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&guidanceTemplate, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guidance template: %w", err)
+	}
+	if err := database.Conn(ctx, r.db).Where("guidance_template_id = ?", guidanceTemplate.ID).Order("step_number").
+		Find(&guidanceTemplate.GuidanceSteps).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guidance steps: %w", err)
+	}
+	if guidanceTemplate.PublishedVersionID != nil {
+		guidanceTemplate.PublishedVersion = &models.GuidanceTemplateVersion{}
+		if err := database.Conn(ctx, r.db).First(guidanceTemplate.PublishedVersion, "id = ?", *guidanceTemplate.PublishedVersionID).Error; err != nil {
+			return nil, fmt.Errorf("failed to get published guidance template version: %w", err)
+		}
+	}
+	return &guidanceTemplate, nil
+}
+
+func (r *GuidanceTemplateRepository) UpdateGuidanceTemplateFields(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	if err := database.Conn(ctx, r.db).Model(&models.GuidanceTemplate{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+		return fmt.Errorf("failed to update guidance template: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GuidanceTemplateVersionRepository stores published template versions. It
+// has no update method, versions are immutable.
+type GuidanceTemplateVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewGuidanceTemplateVersionRepository(db *gorm.DB) *GuidanceTemplateVersionRepository {
+	return &GuidanceTemplateVersionRepository{db: db}
+}
+
+func (r *GuidanceTemplateVersionRepository) CreateGuidanceTemplateVersion(ctx context.Context, version *models.GuidanceTemplateVersion) (*models.GuidanceTemplateVersion, error) {
+	if err := database.Conn(ctx, r.db).Create(version).Error; err != nil {
+		return nil, fmt.Errorf("failed to create guidance template version: %w", err)
+	}
+	return version, nil
+}
+
+// GetGuidanceTemplateVersions returns the versions of a template, latest first.
+func (r *GuidanceTemplateVersionRepository) GetGuidanceTemplateVersions(ctx context.Context, guidanceTemplateID uuid.UUID) ([]models.GuidanceTemplateVersion, error) {
+	var versions []models.GuidanceTemplateVersion
+	if err := database.Conn(ctx, r.db).Preload("PublishedBy").Where("guidance_template_id = ?", guidanceTemplateID).
+		Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guidance template versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (r *GuidanceTemplateVersionRepository) GetGuidanceTemplateVersion(ctx context.Context, guidanceTemplateID uuid.UUID, version int) (*models.GuidanceTemplateVersion, error) {
+	var templateVersion models.GuidanceTemplateVersion
+	if err := database.Conn(ctx, r.db).Preload("PublishedBy").
+		First(&templateVersion, "guidance_template_id = ? AND version = ?", guidanceTemplateID, version).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guidance template version: %w", err)
+	}
+	return &templateVersion, nil
+}
+
+// GetLatestVersionNumber returns the highest version of a template, 0 when it
+// was never published.
+func (r *GuidanceTemplateVersionRepository) GetLatestVersionNumber(ctx context.Context, guidanceTemplateID uuid.UUID) (int, error) {
+	var latest int
+	if err := database.Conn(ctx, r.db).Model(&models.GuidanceTemplateVersion{}).
+		Where("guidance_template_id = ?", guidanceTemplateID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return 0, fmt.Errorf("failed to get latest guidance template version: %w", err)
+	}
+	return latest, nil
+}
+
+// VersionBackfill publishes version 1 of every template, so templates created
+// before versioning keep being usable. It runs once, when versioning is
+// introduced. Guidance assigned before keeps no version. The steps are stored
+// as GuidanceStep marshals them, so version 1 equals the draft.
+var VersionBackfill = []string{
+	`INSERT INTO guidance_template_versions (guidance_template_id, version, name, description, category, steps, note, created_at, updated_at)
+	SELECT t.id, 1, t.name, t.description, t.category,
+		COALESCE((SELECT jsonb_agg(jsonb_build_object(
+			'id', s.id, 'created_at', s.created_at, 'guidance_template_id', s.guidance_template_id,
+			'step_number', s.step_number, 'title', s.title, 'description', s.description,
+			'type', s.type, 'options', s.options, 'branches', s.branches,
+			'evidence', jsonb_build_object('min_photos', s.evidence_min_photos, 'note', s.evidence_note,
+				'signature', s.evidence_signature, 'location', s.evidence_location)) ORDER BY s.step_number)
+			FROM guidance_steps s WHERE s.guidance_template_id = t.id), '[]'::jsonb),
+		'Published when versioning was introduced', now(), now()
+	FROM guidance_templates t
+	WHERE NOT EXISTS (SELECT 1 FROM guidance_template_versions v WHERE v.guidance_template_id = t.id)`,
+	`UPDATE guidance_templates t SET published_version_id = v.id
+	FROM guidance_template_versions v
+	WHERE v.guidance_template_id = t.id AND v.version = 1 AND t.published_version_id IS NULL`,
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"encoding/json"
+	"regexp"
+	"scs-operator/internal/models"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestVersionBackfill(t *testing.T) {
+	if len(VersionBackfill) != 2 {
+		t.Fatalf("expected the insert of version 1 and its publication, got %d statements", len(VersionBackfill))
+	}
+	insert, publish := VersionBackfill[0], VersionBackfill[1]
+
+	t.Run("Steps are stored as GuidanceStep marshals them", func(t *testing.T) {
+		goTo := uuid.New()
+		data, err := json.Marshal(models.GuidanceStep{
+			Options:  models.StringList{"yes"},
+			Branches: models.StepBranches{{Answer: "yes", GoToStepID: &goTo}},
+		})
+		if err != nil {
+			t.Fatalf("failed to marshal step: %v", err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("failed to unmarshal step: %v", err)
+		}
+		var evidence map[string]json.RawMessage
+		if err := json.Unmarshal(fields["evidence"], &evidence); err != nil {
+			t.Fatalf("failed to unmarshal evidence: %v", err)
+		}
+		var expected []string
+		for field := range fields {
+			// The template is never part of a snapshot
+			if field != "guidance_template" {
+				expected = append(expected, field)
+			}
+		}
+		for field := range evidence {
+			expected = append(expected, field)
+		}
+
+		var stored []string
+		for _, match := range regexp.MustCompile(`'(\w+)', `).FindAllStringSubmatch(insert, -1) {
+			stored = append(stored, match[1])
+		}
+		sort.Strings(expected)
+		sort.Strings(stored)
+		if strings.Join(stored, ",") != strings.Join(expected, ",") {
+			t.Errorf("expected the step fields %v, got %v", expected, stored)
+		}
+	})
+
+	t.Run("Versioned templates are left alone", func(t *testing.T) {
+		if !strings.Contains(insert, "WHERE NOT EXISTS (SELECT 1 FROM guidance_template_versions v WHERE v.guidance_template_id = t.id)") {
+			t.Errorf("expected version 1 only for templates without versions, got %s", insert)
+		}
+		if !strings.Contains(publish, "v.version = 1 AND t.published_version_id IS NULL") {
+			t.Errorf("expected only unpublished templates to be published, got %s", publish)
+		}
+	})
+}
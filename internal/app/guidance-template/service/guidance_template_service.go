@@ -2,50 +2,89 @@ package services
 
 import (
 	"context"
+	"fmt"
 	guidanceStepRepositories "scs-operator/internal/app/guidance-step/repository"
 	"scs-operator/internal/app/guidance-template/dto"
 	guidanceTemplateRepositories "scs-operator/internal/app/guidance-template/repository"
+	qualificationRepositories "scs-operator/internal/app/qualification/repository"
 	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
 	"scs-operator/pkg/errors"
+	"sort"
 
 	"github.com/google/uuid"
 )
 
 type Service struct {
-	guidanceTemplateRepo guidanceTemplateRepositories.GuidanceTemplateRepository
-	guidanceStepRepo     guidanceStepRepositories.GuidanceStepRepository
+	guidanceTemplateRepo        guidanceTemplateRepositories.GuidanceTemplateRepository
+	guidanceTemplateVersionRepo guidanceTemplateRepositories.GuidanceTemplateVersionRepository
+	guidanceStepRepo            guidanceStepRepositories.GuidanceStepRepository
+	qualificationRepo           qualificationRepositories.QualificationRepository
+	transactor                  database.Transactor
 }
 
-func NewGuidanceTemplateService(guidanceTemplateRepo guidanceTemplateRepositories.GuidanceTemplateRepository, guidanceStepRepo guidanceStepRepositories.GuidanceStepRepository) *Service {
-	return &Service{guidanceTemplateRepo: guidanceTemplateRepo, guidanceStepRepo: guidanceStepRepo}
+func NewGuidanceTemplateService(guidanceTemplateRepo guidanceTemplateRepositories.GuidanceTemplateRepository, guidanceTemplateVersionRepo guidanceTemplateRepositories.GuidanceTemplateVersionRepository, guidanceStepRepo guidanceStepRepositories.GuidanceStepRepository, qualificationRepo qualificationRepositories.QualificationRepository, transactor database.Transactor) *Service {
+	return &Service{guidanceTemplateRepo: guidanceTemplateRepo, guidanceTemplateVersionRepo: guidanceTemplateVersionRepo, guidanceStepRepo: guidanceStepRepo, qualificationRepo: qualificationRepo, transactor: transactor}
 }
 
-func (s *Service) CreateGuidanceTemplate(ctx context.Context, createGuidanceTemplateDto *dto.CreateGuidanceTemplateDto) (*models.GuidanceTemplate, error) {
-	guidanceTemplate := &models.GuidanceTemplate{
-		Name:        createGuidanceTemplateDto.Name,
-		Description: createGuidanceTemplateDto.Description,
-	}
-
-	createdGuidanceTemplate, err := s.guidanceTemplateRepo.CreateGuidanceTemplate(ctx, guidanceTemplate)
+// CreateGuidanceTemplate creates the template with its steps and publishes
+// them as version 1, unless it is created as a draft.
+func (s *Service) CreateGuidanceTemplate(ctx context.Context, actorID string, createGuidanceTemplateDto *dto.CreateGuidanceTemplateDto) (*models.GuidanceTemplate, error) {
+	qualifications, err := s.getQualifications(ctx, createGuidanceTemplateDto.RequiredQualificationIDs)
 	if err != nil {
-		return nil, errors.NewDatabaseError("create guidanceTemplate", err)
+		return nil, err
 	}
 	steps := []models.GuidanceStep{}
-	for _, step := range createGuidanceTemplateDto.Steps {
-		steps = append(steps, models.GuidanceStep{
-			StepNumber:         step.StepNumber,
-			Title:              step.Title,
-			Description:        step.Description,
-			GuidanceTemplateID: createdGuidanceTemplate.ID,
-		})
-	}
-	if len(steps) > 0 {
-		_, err = s.guidanceStepRepo.CreateGuidanceSteps(ctx, steps)
+	branches := map[uuid.UUID][]dto.Branch{}
+	for _, input := range createGuidanceTemplateDto.Steps {
+		step := models.GuidanceStep{Base: models.Base{ID: uuid.New()}}
+		applyStep(&step, input, branches)
+		steps = append(steps, step)
+	}
+	steps, err = arrangeSteps(steps, branches)
+	if err != nil {
+		return nil, err
+	}
+	guidanceTemplate := &models.GuidanceTemplate{
+		Name:                   createGuidanceTemplateDto.Name,
+		Description:            createGuidanceTemplateDto.Description,
+		RequiredQualifications: qualifications,
+	}
+	if createGuidanceTemplateDto.Category != nil {
+		guidanceTemplate.Category = *createGuidanceTemplateDto.Category
+	}
+
+	var createdGuidanceTemplate *models.GuidanceTemplate
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		createdGuidanceTemplate, err = s.guidanceTemplateRepo.CreateGuidanceTemplate(ctx, guidanceTemplate)
 		if err != nil {
-			return nil, errors.NewDatabaseError("create guidanceSteps", err)
+			return errors.NewDatabaseError("create guidanceTemplate", err)
 		}
+		for i := range steps {
+			steps[i].GuidanceTemplateID = createdGuidanceTemplate.ID
+		}
+		if len(steps) > 0 {
+			_, err = s.guidanceStepRepo.CreateGuidanceSteps(ctx, steps)
+			if err != nil {
+				return errors.NewDatabaseError("create guidanceSteps", err)
+			}
+		}
+		createdGuidanceTemplate.GuidanceSteps = steps
+		if createGuidanceTemplateDto.Draft {
+			return nil
+		}
+		draft := draftOf(createdGuidanceTemplate)
+		draft.PublishedByID = parseActorID(actorID)
+		createdGuidanceTemplate.PublishedVersion, err = s.publish(ctx, draft)
+		if err != nil {
+			return err
+		}
+		createdGuidanceTemplate.PublishedVersionID = &createdGuidanceTemplate.PublishedVersion.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return createdGuidanceTemplate, nil
 }
 
@@ -65,68 +104,307 @@ func (s *Service) GetGuidanceTemplateByID(ctx context.Context, id string) (*mode
 	return guidanceTemplate, nil
 }
 
+// UpdateGuidanceTemplate edits the draft of a template. Steps are removed,
+// updated and added in one go, then renumbered from 1 in the order of their
+// step numbers, which must be unique. Nothing is changed when any of it fails.
 func (s *Service) UpdateGuidanceTemplate(ctx context.Context, id string, updateGuidanceTemplateDto *dto.UpdateGuidanceTemplateDto) (*models.GuidanceTemplate, error) {
-	guidanceTemplate, err := s.guidanceTemplateRepo.GetGuidanceTemplateByID(ctx, id)
-	if err != nil {
-		return nil, errors.NewNotFoundError("guidance template not found")
+	var qualifications []models.Qualification
+	if updateGuidanceTemplateDto.RequiredQualificationIDs != nil {
+		var err error
+		qualifications, err = s.getQualifications(ctx, *updateGuidanceTemplateDto.RequiredQualificationIDs)
+		if err != nil {
+			return nil, err
+		}
 	}
-	guidanceTemplate.Name = updateGuidanceTemplateDto.Name
-	guidanceTemplate.Description = updateGuidanceTemplateDto.Description
-	updatedGuidanceTemplate, err := s.guidanceTemplateRepo.UpdateGuidanceTemplate(ctx, id, guidanceTemplate)
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		guidanceTemplate, err := s.lockGuidanceTemplate(ctx, id)
+		if err != nil {
+			return err
+		}
+		steps, err := editSteps(guidanceTemplate, updateGuidanceTemplateDto)
+		if err != nil {
+			return err
+		}
+		fields := map[string]interface{}{
+			"name":        updateGuidanceTemplateDto.Name,
+			"description": updateGuidanceTemplateDto.Description,
+		}
+		if updateGuidanceTemplateDto.Category != nil {
+			fields["category"] = *updateGuidanceTemplateDto.Category
+		}
+		if err := s.guidanceTemplateRepo.UpdateGuidanceTemplateFields(ctx, guidanceTemplate.ID, fields); err != nil {
+			return errors.NewDatabaseError("update guidance template", err)
+		}
+		if updateGuidanceTemplateDto.RequiredQualificationIDs != nil {
+			if err := s.guidanceTemplateRepo.ReplaceRequiredQualifications(ctx, guidanceTemplate, qualifications); err != nil {
+				return errors.NewDatabaseError("update required qualifications", err)
+			}
+		}
+		if err := s.guidanceStepRepo.ReplaceGuidanceSteps(ctx, guidanceTemplate.ID, steps); err != nil {
+			return errors.NewDatabaseError("update guidance steps", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.NewDatabaseError("update guidance template", err)
-	}
-	if updateGuidanceTemplateDto.AddSteps != nil {
-		steps := []models.GuidanceStep{}
-		for _, step := range updateGuidanceTemplateDto.AddSteps {
-			steps = append(steps, models.GuidanceStep{
-				StepNumber:         step.StepNumber,
-				Title:              step.Title,
-				Description:        step.Description,
-				GuidanceTemplateID: updatedGuidanceTemplate.ID,
-			})
+		return nil, err
+	}
+	return s.getGuidanceTemplate(ctx, id)
+}
+
+// ReorderGuidanceSteps renumbers the draft steps of a template in the given
+// order, which must list each of its steps once. Branches must still lead to
+// later steps.
+func (s *Service) ReorderGuidanceSteps(ctx context.Context, id string, reorderDto *dto.ReorderGuidanceStepsDto) (*models.GuidanceTemplate, error) {
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		guidanceTemplate, err := s.lockGuidanceTemplate(ctx, id)
+		if err != nil {
+			return err
 		}
-		if len(steps) > 0 {
-			_, err = s.guidanceStepRepo.CreateGuidanceSteps(ctx, steps)
+		current := make(map[uuid.UUID]models.GuidanceStep, len(guidanceTemplate.GuidanceSteps))
+		for _, step := range guidanceTemplate.GuidanceSteps {
+			current[step.ID] = step
+		}
+		if len(reorderDto.StepIDs) != len(current) {
+			return errors.NewBadRequestError(fmt.Sprintf("step_ids must list all %d steps of the template", len(current)))
+		}
+		steps := make([]models.GuidanceStep, 0, len(current))
+		for i, rawID := range reorderDto.StepIDs {
+			stepID, err := uuid.Parse(rawID)
 			if err != nil {
-				return nil, errors.NewDatabaseError("create guidanceSteps", err)
+				return errors.NewBadRequestError("Invalid step ID format")
+			}
+			step, ok := current[stepID]
+			if !ok {
+				return errors.NewBadRequestError(fmt.Sprintf("Step %s is not a step of the template or is listed twice", rawID))
 			}
+			delete(current, stepID)
+			step.StepNumber = i + 1
+			steps = append(steps, step)
 		}
-		if updateGuidanceTemplateDto.UpdateSteps != nil {
-			for _, step := range updateGuidanceTemplateDto.UpdateSteps {
-				if step.ID == nil {
-					continue
-				}
-				id, err := uuid.Parse(*step.ID)
-				if err != nil {
-					return nil, errors.NewBadRequestError("Invalid step ID format")
-				}
-				err = s.guidanceStepRepo.UpdateGuidanceStep(ctx, id.String(), &models.GuidanceStep{
-					StepNumber:  step.StepNumber,
-					Title:       step.Title,
-					Description: step.Description,
-				})
-				if err != nil {
-					return nil, errors.NewDatabaseError("update guidanceStep", err)
-				}
+		if err := checkStepFlow(steps); err != nil {
+			return err
+		}
+		if err := s.guidanceStepRepo.ReplaceGuidanceSteps(ctx, guidanceTemplate.ID, steps); err != nil {
+			return errors.NewDatabaseError("reorder guidance steps", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getGuidanceTemplate(ctx, id)
+}
+
+// editSteps applies the removals, updates and additions to the current steps
+// and renumbers the result. Updated steps are replaced as a whole, branches
+// included; the branches of other steps are kept.
+func editSteps(guidanceTemplate *models.GuidanceTemplate, updateGuidanceTemplateDto *dto.UpdateGuidanceTemplateDto) ([]models.GuidanceStep, error) {
+	current := guidanceTemplate.GuidanceSteps
+	byID := make(map[uuid.UUID]*models.GuidanceStep, len(current))
+	steps := make([]*models.GuidanceStep, 0, len(current)+len(updateGuidanceTemplateDto.AddSteps))
+	for i := range current {
+		step := current[i]
+		byID[step.ID] = &step
+		steps = append(steps, &step)
+	}
+	branches := make(map[uuid.UUID][]dto.Branch)
+	removed := make(map[uuid.UUID]bool, len(updateGuidanceTemplateDto.RemoveSteps))
+	for _, rawID := range updateGuidanceTemplateDto.RemoveSteps {
+		stepID, err := uuid.Parse(rawID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid step ID format")
+		}
+		if byID[stepID] == nil {
+			return nil, errors.NewBadRequestError(fmt.Sprintf("Step %s is not a step of the template", rawID))
+		}
+		removed[stepID] = true
+	}
+	for _, update := range updateGuidanceTemplateDto.UpdateSteps {
+		if update.ID == nil {
+			return nil, errors.NewBadRequestError("update_steps need an id")
+		}
+		stepID, err := uuid.Parse(*update.ID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid step ID format")
+		}
+		step := byID[stepID]
+		if step == nil || removed[stepID] {
+			return nil, errors.NewBadRequestError(fmt.Sprintf("Step %s is not a step of the template", *update.ID))
+		}
+		applyStep(step, update, branches)
+	}
+	for _, add := range updateGuidanceTemplateDto.AddSteps {
+		step := &models.GuidanceStep{Base: models.Base{ID: uuid.New()}, GuidanceTemplateID: guidanceTemplate.ID}
+		applyStep(step, add, branches)
+		steps = append(steps, step)
+	}
+	kept := make([]models.GuidanceStep, 0, len(steps))
+	for _, step := range steps {
+		if !removed[step.ID] {
+			kept = append(kept, *step)
+		}
+	}
+	return arrangeSteps(kept, branches)
+}
+
+// applyStep sets a step from the request. Its branches are kept aside until
+// arrangeSteps resolves their step numbers.
+func applyStep(step *models.GuidanceStep, input dto.Step, branches map[uuid.UUID][]dto.Branch) {
+	step.StepNumber = input.StepNumber
+	step.Title = input.Title
+	step.Description = input.Description
+	step.Type = input.Type
+	if step.Type == "" {
+		step.Type = models.GuidanceStepTypeCheckbox
+	}
+	step.Options = input.Options
+	step.Branches = nil
+	step.Evidence = models.EvidenceRequirements{
+		MinPhotos: input.Evidence.MinPhotos,
+		Note:      input.Evidence.Note,
+		Signature: input.Evidence.Signature,
+		Location:  input.Evidence.Location,
+	}
+	branches[step.ID] = input.Branches
+}
+
+// arrangeSteps points the branches given in the request at the steps with
+// their step numbers, then renumbers the steps and checks where they lead.
+func arrangeSteps(steps []models.GuidanceStep, branches map[uuid.UUID][]dto.Branch) ([]models.GuidanceStep, error) {
+	byNumber := make(map[int]uuid.UUID, len(steps))
+	for _, step := range steps {
+		byNumber[step.StepNumber] = step.ID
+	}
+	for i := range steps {
+		for _, branch := range branches[steps[i].ID] {
+			if branch.End == (branch.GoToStep != 0) {
+				return nil, errors.NewBadRequestError(fmt.Sprintf("Branches of step %d need either go_to_step or end", steps[i].StepNumber))
 			}
-			if updateGuidanceTemplateDto.RemoveSteps != nil {
-				removeStepIds := []string{}
-				for _, stepID := range updateGuidanceTemplateDto.RemoveSteps {
-					id, err := uuid.Parse(stepID)
-					if err != nil {
-						return nil, errors.NewBadRequestError("Invalid step ID format")
-					}
-					removeStepIds = append(removeStepIds, id.String())
-				}
-				if len(removeStepIds) > 0 {
-					err = s.guidanceStepRepo.DeleteGuidanceSteps(ctx, removeStepIds)
-					if err != nil {
-						return nil, errors.NewDatabaseError("delete guidanceSteps", err)
-					}
+			stepBranch := models.StepBranch{Answer: branch.Answer, End: branch.End}
+			if branch.GoToStep != 0 {
+				target, ok := byNumber[branch.GoToStep]
+				if !ok {
+					return nil, errors.NewBadRequestError(fmt.Sprintf("Step %d branches to step %d, which does not exist", steps[i].StepNumber, branch.GoToStep))
 				}
+				stepBranch.GoToStepID = &target
+			}
+			steps[i].Branches = append(steps[i].Branches, stepBranch)
+		}
+	}
+	steps, err := renumberSteps(steps)
+	if err != nil {
+		return nil, err
+	}
+	return steps, checkStepFlow(steps)
+}
+
+// checkStepFlow checks the options and branches of numbered steps. Branches
+// may only lead forward, so every guidance reaches an end.
+func checkStepFlow(steps []models.GuidanceStep) error {
+	numbers := make(map[uuid.UUID]int, len(steps))
+	for _, step := range steps {
+		numbers[step.ID] = step.StepNumber
+	}
+	for _, step := range steps {
+		if step.Type == models.GuidanceStepTypeSingleChoice {
+			if len(step.Options) < 2 {
+				return errors.NewBadRequestError(fmt.Sprintf("Step %d needs at least two options", step.StepNumber))
 			}
+		} else if len(step.Options) > 0 {
+			return errors.NewBadRequestError(fmt.Sprintf("Step %d is not a single_choice step and cannot have options", step.StepNumber))
 		}
+		answers := make(map[string]bool, len(step.Branches))
+		for _, branch := range step.Branches {
+			if answers[branch.Answer] {
+				return errors.NewBadRequestError(fmt.Sprintf("Step %d has more than one branch for the same answer", step.StepNumber))
+			}
+			answers[branch.Answer] = true
+			if branch.Answer != "" && !isBranchAnswer(step, branch.Answer) {
+				return errors.NewBadRequestError(fmt.Sprintf("Step %d cannot be answered with %q", step.StepNumber, branch.Answer))
+			}
+			if branch.GoToStepID == nil {
+				continue
+			}
+			number, ok := numbers[*branch.GoToStepID]
+			if !ok {
+				return errors.NewBadRequestError(fmt.Sprintf("Step %d branches to a step that is not part of the template", step.StepNumber))
+			}
+			if number <= step.StepNumber {
+				return errors.NewBadRequestError(fmt.Sprintf("Step %d can only branch to a later step", step.StepNumber))
+			}
+		}
+	}
+	return nil
+}
+
+// isBranchAnswer reports whether a step can be branched on the answer. Only
+// yes/no and single choice steps have a fixed set of answers.
+func isBranchAnswer(step models.GuidanceStep, answer string) bool {
+	switch step.Type {
+	case models.GuidanceStepTypeYesNo:
+		return answer == "yes" || answer == "no"
+	case models.GuidanceStepTypeSingleChoice:
+		for _, option := range step.Options {
+			if option == answer {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renumberSteps numbers the steps from 1 in the order of their step numbers,
+// closing gaps. Step numbers must be unique.
+func renumberSteps(steps []models.GuidanceStep) ([]models.GuidanceStep, error) {
+	seen := make(map[int]bool, len(steps))
+	for _, step := range steps {
+		if seen[step.StepNumber] {
+			return nil, errors.NewBadRequestError(fmt.Sprintf("Step number %d is used more than once", step.StepNumber))
+		}
+		seen[step.StepNumber] = true
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].StepNumber < steps[j].StepNumber })
+	for i := range steps {
+		steps[i].StepNumber = i + 1
+	}
+	return steps, nil
+}
+
+func (s *Service) getGuidanceTemplate(ctx context.Context, id string) (*models.GuidanceTemplate, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errors.NewBadRequestError("Invalid guidance template ID format")
+	}
+	guidanceTemplate, err := s.guidanceTemplateRepo.GetGuidanceTemplateByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guidance template")
+	}
+	return guidanceTemplate, nil
+}
+
+// getQualifications returns the qualifications with the given IDs, all of
+// which must exist.
+func (s *Service) getQualifications(ctx context.Context, rawIDs []string) ([]models.Qualification, error) {
+	ids := make([]uuid.UUID, 0, len(rawIDs))
+	seen := make(map[uuid.UUID]bool, len(rawIDs))
+	for _, rawID := range rawIDs {
+		id, err := uuid.Parse(rawID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid qualification ID format")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return []models.Qualification{}, nil
+	}
+	qualifications, err := s.qualificationRepo.GetQualificationsByIDs(ctx, ids)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get qualifications", err)
+	}
+	if len(qualifications) != len(ids) {
+		return nil, errors.NewNotFoundError("qualification")
 	}
-	return updatedGuidanceTemplate, nil
+	return qualifications, nil
 }
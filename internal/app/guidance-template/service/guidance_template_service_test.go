@@ -0,0 +1,180 @@
+package services
+
+import (
+	"net/http"
+	"scs-operator/internal/app/guidance-template/dto"
+	"scs-operator/internal/models"
+	"scs-operator/pkg/errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEditSteps(t *testing.T) {
+	first := uuid.MustParse("5a1b7f9d-aa16-4e6d-8eaa-8e5f2e8a7b06")
+	second := uuid.MustParse("6b2c8a0e-bb27-4f7e-9fbb-9f6a3f9b8c07")
+	third := uuid.MustParse("7c3d9b1f-cc38-4a8f-8acc-0a7b4a0c9d08")
+	unknown := "8d4e0c2a-dd49-4b9a-9bdd-1b8c5b1d0e09"
+	stepID := func(id uuid.UUID) *string {
+		raw := id.String()
+		return &raw
+	}
+	newTemplate := func() *models.GuidanceTemplate {
+		return &models.GuidanceTemplate{Base: models.Base{ID: uuid.New()}, GuidanceSteps: []models.GuidanceStep{
+			{Base: models.Base{ID: first}, StepNumber: 1, Title: "Call fire brigade"},
+			{Base: models.Base{ID: second}, StepNumber: 2, Title: "Evacuate"},
+			{Base: models.Base{ID: third}, StepNumber: 3, Title: "Count people"},
+		}}
+	}
+
+	tests := []struct {
+		name           string
+		edit           dto.UpdateGuidanceTemplateDto
+		expectedTitles []string
+		expectedStatus int
+	}{
+		{
+			name:           "Updates apply without additions",
+			edit:           dto.UpdateGuidanceTemplateDto{UpdateSteps: []dto.Step{{ID: stepID(second), StepNumber: 2, Title: "Evacuate the building"}}},
+			expectedTitles: []string{"Call fire brigade", "Evacuate the building", "Count people"},
+		},
+		{
+			name:           "Removals apply without updates and close the gap",
+			edit:           dto.UpdateGuidanceTemplateDto{RemoveSteps: []string{second.String()}},
+			expectedTitles: []string{"Call fire brigade", "Count people"},
+		},
+		{
+			name: "Moved and added steps are renumbered in order",
+			edit: dto.UpdateGuidanceTemplateDto{
+				UpdateSteps: []dto.Step{{ID: stepID(first), StepNumber: 10, Title: "Call fire brigade"}},
+				AddSteps:    []dto.Step{{StepNumber: 5, Title: "Close doors"}},
+			},
+			expectedTitles: []string{"Evacuate", "Count people", "Close doors", "Call fire brigade"},
+		},
+		{
+			name:           "Duplicate step numbers are rejected",
+			edit:           dto.UpdateGuidanceTemplateDto{AddSteps: []dto.Step{{StepNumber: 2, Title: "Close doors"}}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Steps of other templates are rejected",
+			edit:           dto.UpdateGuidanceTemplateDto{RemoveSteps: []string{unknown}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Removed steps cannot be updated",
+			edit: dto.UpdateGuidanceTemplateDto{
+				RemoveSteps: []string{third.String()},
+				UpdateSteps: []dto.Step{{ID: stepID(third), StepNumber: 3, Title: "Count people"}},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guidanceTemplate := newTemplate()
+			steps, err := editSteps(guidanceTemplate, &tt.edit)
+			if tt.expectedStatus != 0 {
+				appErr, ok := errors.IsAppError(err)
+				if !ok || appErr.StatusCode != tt.expectedStatus {
+					t.Fatalf("expected status %d, got %v", tt.expectedStatus, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(steps) != len(tt.expectedTitles) {
+				t.Fatalf("expected %d steps, got %d", len(tt.expectedTitles), len(steps))
+			}
+			for i, step := range steps {
+				if step.Title != tt.expectedTitles[i] || step.StepNumber != i+1 {
+					t.Errorf("expected step %d to be %q, got %d %q", i+1, tt.expectedTitles[i], step.StepNumber, step.Title)
+				}
+				if step.ID != first && step.ID != second && step.ID != third && (step.GuidanceTemplateID != guidanceTemplate.ID || step.ID == uuid.Nil) {
+					t.Errorf("expected added step %q to belong to the template", step.Title)
+				}
+			}
+		})
+	}
+}
+
+func TestArrangeSteps(t *testing.T) {
+	yesNo := func(number int, branches ...dto.Branch) dto.Step {
+		return dto.Step{StepNumber: number, Title: "Is anyone hurt?", Type: models.GuidanceStepTypeYesNo, Branches: branches}
+	}
+	checkbox := func(number int, branches ...dto.Branch) dto.Step {
+		return dto.Step{StepNumber: number, Title: "Call an ambulance", Branches: branches}
+	}
+
+	tests := []struct {
+		name           string
+		steps          []dto.Step
+		expectedStatus int
+	}{
+		{
+			name:  "Branches lead to later steps by number",
+			steps: []dto.Step{yesNo(10, dto.Branch{Answer: "no", GoToStep: 30}), checkbox(20), checkbox(30, dto.Branch{End: true})},
+		},
+		{
+			name:           "Branches cannot lead back",
+			steps:          []dto.Step{checkbox(1), yesNo(2, dto.Branch{Answer: "yes", GoToStep: 1})},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Branches need a target",
+			steps:          []dto.Step{yesNo(1, dto.Branch{Answer: "yes"}), checkbox(2)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Branch targets must exist",
+			steps:          []dto.Step{yesNo(1, dto.Branch{Answer: "yes", GoToStep: 5}), checkbox(2)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Branch answers must fit the step",
+			steps:          []dto.Step{yesNo(1, dto.Branch{Answer: "maybe", End: true}), checkbox(2)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Checkbox steps only branch without an answer",
+			steps:          []dto.Step{checkbox(1, dto.Branch{Answer: "yes", End: true}), checkbox(2)},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Single choice steps need options",
+			steps:          []dto.Step{{StepNumber: 1, Title: "Kind of fire", Type: models.GuidanceStepTypeSingleChoice, Options: []string{"Electrical"}}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := []models.GuidanceStep{}
+			branches := map[uuid.UUID][]dto.Branch{}
+			for _, input := range tt.steps {
+				step := models.GuidanceStep{Base: models.Base{ID: uuid.New()}}
+				applyStep(&step, input, branches)
+				steps = append(steps, step)
+			}
+			arranged, err := arrangeSteps(steps, branches)
+			if tt.expectedStatus != 0 {
+				appErr, ok := errors.IsAppError(err)
+				if !ok || appErr.StatusCode != tt.expectedStatus {
+					t.Fatalf("expected status %d, got %v", tt.expectedStatus, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if arranged[1].Type != models.GuidanceStepTypeCheckbox {
+				t.Errorf("expected steps without a type to be checkboxes, got %q", arranged[1].Type)
+			}
+			if target := arranged[0].Branches[0].GoToStepID; target == nil || *target != arranged[2].ID {
+				t.Errorf("expected the branch to lead to step 3, got %v", target)
+			}
+		})
+	}
+}
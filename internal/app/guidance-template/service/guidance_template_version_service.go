@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"scs-operator/internal/app/guidance-template/dto"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	"scs-operator/pkg/errors"
+	"sort"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// draftVersion is the name of the unpublished side of a diff.
+const draftVersion = "draft"
+
+// PublishGuidanceTemplate freezes the draft of a template as its next version,
+// which incidents are given from then on.
+func (s *Service) PublishGuidanceTemplate(ctx context.Context, id string, actorID string, publishDto *dto.PublishGuidanceTemplateDto) (*models.GuidanceTemplateVersion, error) {
+	var published *models.GuidanceTemplateVersion
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		guidanceTemplate, err := s.lockGuidanceTemplate(ctx, id)
+		if err != nil {
+			return err
+		}
+		draft := draftOf(guidanceTemplate)
+		if current := guidanceTemplate.PublishedVersion; current != nil {
+			if diff := diffVersions(current, draft); diff.Empty() {
+				return errors.NewConflictError(fmt.Sprintf("Draft has no changes since version %d", current.Version))
+			}
+		}
+		draft.Note = publishDto.Note
+		draft.PublishedByID = parseActorID(actorID)
+		published, err = s.publish(ctx, draft)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// RollbackGuidanceTemplate publishes a copy of a previous version as the next
+// version and resets the draft to it. Versions in between stay as they were.
+func (s *Service) RollbackGuidanceTemplate(ctx context.Context, id string, rawVersion string, actorID string, publishDto *dto.PublishGuidanceTemplateDto) (*models.GuidanceTemplateVersion, error) {
+	versionNumber, err := strconv.Atoi(rawVersion)
+	if err != nil {
+		return nil, errors.NewBadRequestError("Invalid version")
+	}
+	var published *models.GuidanceTemplateVersion
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		guidanceTemplate, err := s.lockGuidanceTemplate(ctx, id)
+		if err != nil {
+			return err
+		}
+		target, err := s.guidanceTemplateVersionRepo.GetGuidanceTemplateVersion(ctx, guidanceTemplate.ID, versionNumber)
+		if err != nil {
+			return errors.NewNotFoundError("guidance template version")
+		}
+		if current := guidanceTemplate.PublishedVersion; current != nil && current.Version == target.Version {
+			return errors.NewConflictError(fmt.Sprintf("Version %d is already published", target.Version))
+		}
+		published, err = s.publish(ctx, &models.GuidanceTemplateVersion{
+			GuidanceTemplateID: guidanceTemplate.ID,
+			Name:               target.Name,
+			Description:        target.Description,
+			Category:           target.Category,
+			Steps:              target.Steps,
+			RestoredFrom:       &target.Version,
+			Note:               publishDto.Note,
+			PublishedByID:      parseActorID(actorID),
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.guidanceTemplateRepo.UpdateGuidanceTemplateFields(ctx, guidanceTemplate.ID, map[string]interface{}{
+			"name":        target.Name,
+			"description": target.Description,
+			"category":    target.Category,
+		}); err != nil {
+			return errors.NewDatabaseError("restore guidance template", err)
+		}
+		steps := make([]models.GuidanceStep, 0, len(target.Steps))
+		for _, step := range target.Steps {
+			step.GuidanceTemplateID = guidanceTemplate.ID
+			steps = append(steps, step)
+		}
+		if err := s.guidanceStepRepo.ReplaceGuidanceSteps(ctx, guidanceTemplate.ID, steps); err != nil {
+			return errors.NewDatabaseError("restore guidance steps", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+func (s *Service) GetGuidanceTemplateVersions(ctx context.Context, id string) ([]models.GuidanceTemplateVersion, error) {
+	guidanceTemplate, err := s.getGuidanceTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.guidanceTemplateVersionRepo.GetGuidanceTemplateVersions(ctx, guidanceTemplate.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get guidance template versions", err)
+	}
+	return versions, nil
+}
+
+func (s *Service) GetGuidanceTemplateVersion(ctx context.Context, id string, rawVersion string) (*models.GuidanceTemplateVersion, error) {
+	versionNumber, err := strconv.Atoi(rawVersion)
+	if err != nil {
+		return nil, errors.NewBadRequestError("Invalid version")
+	}
+	guidanceTemplate, err := s.getGuidanceTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.guidanceTemplateVersionRepo.GetGuidanceTemplateVersion(ctx, guidanceTemplate.ID, versionNumber)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guidance template version")
+	}
+	return version, nil
+}
+
+// DiffGuidanceTemplate compares two versions of a template, by default the
+// published version with the draft.
+func (s *Service) DiffGuidanceTemplate(ctx context.Context, id string, diffDto *dto.GetGuidanceTemplateDiffDto) (*types.GuidanceTemplateDiff, error) {
+	guidanceTemplate, err := s.getGuidanceTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.resolveVersion(ctx, guidanceTemplate, diffDto.From)
+	if err != nil {
+		return nil, err
+	}
+	toName := diffDto.To
+	if toName == "" {
+		toName = draftVersion
+	}
+	to, err := s.resolveVersion(ctx, guidanceTemplate, toName)
+	if err != nil {
+		return nil, err
+	}
+	diff := diffVersions(from, to)
+	diff.GuidanceTemplateID = guidanceTemplate.ID.String()
+	diff.From, diff.To = versionName(from), versionName(to)
+	return diff, nil
+}
+
+// resolveVersion returns the numbered version, the draft, or the published
+// version when the name is empty.
+func (s *Service) resolveVersion(ctx context.Context, guidanceTemplate *models.GuidanceTemplate, name string) (*models.GuidanceTemplateVersion, error) {
+	switch name {
+	case "":
+		if guidanceTemplate.PublishedVersion == nil {
+			return nil, errors.NewBadRequestError("Guidance template has no published version")
+		}
+		return guidanceTemplate.PublishedVersion, nil
+	case draftVersion:
+		return draftOf(guidanceTemplate), nil
+	}
+	versionNumber, err := strconv.Atoi(name)
+	if err != nil {
+		return nil, errors.NewBadRequestError("Invalid version, expected a number or draft")
+	}
+	version, err := s.guidanceTemplateVersionRepo.GetGuidanceTemplateVersion(ctx, guidanceTemplate.ID, versionNumber)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guidance template version")
+	}
+	return version, nil
+}
+
+func (s *Service) lockGuidanceTemplate(ctx context.Context, id string) (*models.GuidanceTemplate, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errors.NewBadRequestError("Invalid guidance template ID format")
+	}
+	guidanceTemplate, err := s.guidanceTemplateRepo.GetGuidanceTemplateForUpdate(ctx, id)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guidance template")
+	}
+	return guidanceTemplate, nil
+}
+
+// publish stores the version under the next number and makes it the one
+// incidents are given. Callers hold the template lock.
+func (s *Service) publish(ctx context.Context, version *models.GuidanceTemplateVersion) (*models.GuidanceTemplateVersion, error) {
+	latest, err := s.guidanceTemplateVersionRepo.GetLatestVersionNumber(ctx, version.GuidanceTemplateID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get latest guidance template version", err)
+	}
+	version.Version = latest + 1
+	if _, err := s.guidanceTemplateVersionRepo.CreateGuidanceTemplateVersion(ctx, version); err != nil {
+		return nil, errors.NewDatabaseError("publish guidance template", err)
+	}
+	if err := s.guidanceTemplateRepo.UpdateGuidanceTemplateFields(ctx, version.GuidanceTemplateID, map[string]interface{}{
+		"published_version_id": version.ID,
+	}); err != nil {
+		return nil, errors.NewDatabaseError("publish guidance template", err)
+	}
+	return version, nil
+}
+
+// draftOf is the unpublished version the template's own fields and steps make.
+func draftOf(guidanceTemplate *models.GuidanceTemplate) *models.GuidanceTemplateVersion {
+	steps := make(models.GuidanceSteps, 0, len(guidanceTemplate.GuidanceSteps))
+	for _, step := range guidanceTemplate.GuidanceSteps {
+		step.GuidanceTemplate = nil
+		steps = append(steps, step)
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].StepNumber < steps[j].StepNumber })
+	return &models.GuidanceTemplateVersion{
+		GuidanceTemplateID: guidanceTemplate.ID,
+		Name:               guidanceTemplate.Name,
+		Description:        guidanceTemplate.Description,
+		Category:           guidanceTemplate.Category,
+		Steps:              steps,
+	}
+}
+
+func versionName(version *models.GuidanceTemplateVersion) string {
+	if version.Version == 0 {
+		return draftVersion
+	}
+	return strconv.Itoa(version.Version)
+}
+
+// stepIdentity are the step fields that tell which step it is rather than what
+// it says; they are not compared.
+var stepIdentity = map[string]bool{"id": true, "created_at": true, "guidance_template_id": true, "guidance_template": true}
+
+func diffVersions(from *models.GuidanceTemplateVersion, to *models.GuidanceTemplateVersion) *types.GuidanceTemplateDiff {
+	diff := &types.GuidanceTemplateDiff{
+		Fields:       compareFields(map[string]interface{}{"name": from.Name, "description": from.Description, "category": from.Category}, map[string]interface{}{"name": to.Name, "description": to.Description, "category": to.Category}),
+		AddedSteps:   []models.GuidanceStep{},
+		RemovedSteps: []models.GuidanceStep{},
+		ChangedSteps: []types.GuidanceStepChange{},
+	}
+	fromSteps := make(map[uuid.UUID]models.GuidanceStep, len(from.Steps))
+	for _, step := range from.Steps {
+		fromSteps[step.ID] = step
+	}
+	toSteps := make(map[uuid.UUID]bool, len(to.Steps))
+	for _, step := range to.Steps {
+		toSteps[step.ID] = true
+		previous, ok := fromSteps[step.ID]
+		if !ok {
+			diff.AddedSteps = append(diff.AddedSteps, step)
+			continue
+		}
+		if fields := compareFields(stepFields(previous), stepFields(step)); len(fields) > 0 {
+			diff.ChangedSteps = append(diff.ChangedSteps, types.GuidanceStepChange{StepID: step.ID, Title: step.Title, Fields: fields})
+		}
+	}
+	for _, step := range from.Steps {
+		if !toSteps[step.ID] {
+			diff.RemovedSteps = append(diff.RemovedSteps, step)
+		}
+	}
+	return diff
+}
+
+// stepFields are the fields of a step as the API renders them, so fields
+// added to steps are compared without listing them here.
+func stepFields(step models.GuidanceStep) map[string]interface{} {
+	data, _ := json.Marshal(step)
+	fields := map[string]interface{}{}
+	_ = json.Unmarshal(data, &fields)
+	for field := range stepIdentity {
+		delete(fields, field)
+	}
+	return fields
+}
+
+func compareFields(from map[string]interface{}, to map[string]interface{}) []types.FieldChange {
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	changes := []types.FieldChange{}
+	for _, name := range names {
+		if !reflect.DeepEqual(from[name], to[name]) {
+			changes = append(changes, types.FieldChange{Field: name, From: from[name], To: to[name]})
+		}
+	}
+	return changes
+}
+
+func parseActorID(actorID string) *uuid.UUID {
+	id, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
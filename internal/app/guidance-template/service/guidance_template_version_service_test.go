@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	guidanceStepRepositories "scs-operator/internal/app/guidance-step/repository"
+	"scs-operator/internal/app/guidance-template/dto"
+	guidanceTemplateRepositories "scs-operator/internal/app/guidance-template/repository"
+	qualificationRepositories "scs-operator/internal/app/qualification/repository"
+	"scs-operator/internal/models"
+	"scs-operator/internal/testsupport"
+	database "scs-operator/pkg/db"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+const (
+	testTemplateID = "1c7d3b5f-66a2-4a2f-8a66-4a1b8a4c3d02"
+	testVersionID  = "d29d5b7f-229e-4aef-8a22-6a3bab6c5d14"
+	testStepID     = "7c3d9b1f-cc38-4a8f-8acc-0a7b4a0c9d08"
+	testAddedID    = "8d4e0c2a-dd49-4b9a-9bdd-1b8c5b1d0e09"
+)
+
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := testsupport.NewMockDB(t)
+	svc := NewGuidanceTemplateService(
+		*guidanceTemplateRepositories.NewGuidanceTemplateRepository(db),
+		*guidanceTemplateRepositories.NewGuidanceTemplateVersionRepository(db),
+		*guidanceStepRepositories.NewGuidanceStepRepository(db),
+		*qualificationRepositories.NewQualificationRepository(db),
+		*database.NewTransactor(db),
+	)
+	return svc, mock
+}
+
+// expectTemplateLock locks the Fire template, published as version 2 with a
+// single step. The draft has that step and the extra ones given.
+func expectTemplateLock(mock sqlmock.Sqlmock, extraSteps ...string) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_templates" WHERE id = $1`)+`.*FOR UPDATE`).
+		WithArgs(testTemplateID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "published_version_id"}).AddRow(testTemplateID, "Fire", testVersionID))
+	steps := sqlmock.NewRows([]string{"id", "guidance_template_id", "step_number", "title", "type"}).
+		AddRow(testStepID, testTemplateID, 1, "Call fire brigade", "checkbox")
+	for i, title := range extraSteps {
+		steps.AddRow(testAddedID, testTemplateID, i+2, title, "checkbox")
+	}
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_steps" WHERE guidance_template_id = $1 ORDER BY step_number`)).
+		WillReturnRows(steps)
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_template_versions" WHERE id = $1`)).
+		WithArgs(testVersionID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "guidance_template_id", "version", "name", "steps"}).
+			AddRow(testVersionID, testTemplateID, 2, "Fire",
+				`[{"id":"`+testStepID+`","step_number":1,"title":"Call fire brigade","description":"","type":"checkbox","evidence":{}}]`))
+}
+
+// expectPublish stores the next version after version latest and publishes it.
+func expectPublish(mock sqlmock.Sqlmock, latest int) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT COALESCE(MAX(version), 0) FROM "guidance_template_versions" WHERE guidance_template_id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(latest))
+	mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "guidance_template_versions"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("e3ae6c8a-33af-4bfa-9b33-7b4cbc7d6e15"))
+	mock.ExpectExec(testsupport.QuoteSQL(`UPDATE "guidance_templates" SET "published_version_id"=$1,"updated_at"=$2 WHERE id = $3`)).
+		WithArgs("e3ae6c8a-33af-4bfa-9b33-7b4cbc7d6e15", sqlmock.AnyArg(), testTemplateID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestPublishGuidanceTemplate(t *testing.T) {
+	t.Run("Draft without changes is not published again", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		mock.ExpectBegin()
+		expectTemplateLock(mock)
+		mock.ExpectRollback()
+
+		_, err := svc.PublishGuidanceTemplate(context.Background(), testTemplateID, "", &dto.PublishGuidanceTemplateDto{})
+		testsupport.AssertAppError(t, err, http.StatusConflict)
+	})
+
+	t.Run("Changed draft is published as the next version", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		mock.ExpectBegin()
+		expectTemplateLock(mock, "Evacuate")
+		// Versions are numbered after the latest, which need not be the published one
+		expectPublish(mock, 3)
+		mock.ExpectCommit()
+
+		published, err := svc.PublishGuidanceTemplate(context.Background(), testTemplateID, "", &dto.PublishGuidanceTemplateDto{Note: "Evacuate first"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if published.Version != 4 || len(published.Steps) != 2 || published.Note != "Evacuate first" {
+			t.Errorf("expected version 4 with both steps, got %+v", published)
+		}
+	})
+}
+
+func TestRollbackGuidanceTemplate(t *testing.T) {
+	getVersion := testsupport.QuoteSQL(`SELECT * FROM "guidance_template_versions" WHERE guidance_template_id = $1 AND version = $2`)
+	versionRows := func(version int, steps string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "guidance_template_id", "version", "name", "description", "category", "steps"}).
+			AddRow(uuid.New().String(), testTemplateID, version, "Fire alarm", "Older text", "safety", steps)
+	}
+
+	t.Run("Published version cannot be rolled back to", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		mock.ExpectBegin()
+		expectTemplateLock(mock)
+		mock.ExpectQuery(getVersion).WithArgs(testTemplateID, 2, 1).WillReturnRows(versionRows(2, `[]`))
+		mock.ExpectRollback()
+
+		_, err := svc.RollbackGuidanceTemplate(context.Background(), testTemplateID, "2", "", &dto.PublishGuidanceTemplateDto{})
+		testsupport.AssertAppError(t, err, http.StatusConflict)
+	})
+
+	t.Run("Rollback publishes a copy and resets the draft", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		mock.ExpectBegin()
+		expectTemplateLock(mock, "Evacuate")
+		mock.ExpectQuery(getVersion).WithArgs(testTemplateID, 1, 1).
+			WillReturnRows(versionRows(1, `[{"id":"`+testAddedID+`","step_number":1,"title":"Leave","type":"checkbox","evidence":{}}]`))
+		expectPublish(mock, 2)
+		mock.ExpectExec(testsupport.QuoteSQL(`UPDATE "guidance_templates" SET "category"=$1,"description"=$2,"name"=$3,"updated_at"=$4 WHERE id = $5`)).
+			WithArgs("safety", "Older text", "Fire alarm", sqlmock.AnyArg(), testTemplateID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(testsupport.QuoteSQL(`DELETE FROM "guidance_steps" WHERE guidance_template_id = $1`)).
+			WithArgs(testTemplateID).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		// The draft gets the steps of version 1 back, with their IDs
+		mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "guidance_steps"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), testTemplateID, 1, "Leave", "", "checkbox",
+				nil, nil, 0, false, false, false, testAddedID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testAddedID))
+		mock.ExpectCommit()
+
+		published, err := svc.RollbackGuidanceTemplate(context.Background(), testTemplateID, "1", "", &dto.PublishGuidanceTemplateDto{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if published.Version != 3 || published.RestoredFrom == nil || *published.RestoredFrom != 1 || published.Name != "Fire alarm" {
+			t.Errorf("expected version 3 restored from 1, got %+v", published)
+		}
+	})
+}
+
+func TestDiffVersions(t *testing.T) {
+	kept := uuid.MustParse("5a1b7f9d-aa16-4e6d-8eaa-8e5f2e8a7b06")
+	moved := uuid.MustParse("6b2c8a0e-bb27-4f7e-9fbb-9f6a3f9b8c07")
+	removed := uuid.MustParse("7c3d9b1f-cc38-4a8f-8acc-0a7b4a0c9d08")
+	added := uuid.MustParse("8d4e0c2a-dd49-4b9a-9bdd-1b8c5b1d0e09")
+	step := func(id uuid.UUID, number int, title string) models.GuidanceStep {
+		return models.GuidanceStep{Base: models.Base{ID: id}, StepNumber: number, Title: title}
+	}
+	from := &models.GuidanceTemplateVersion{Version: 1, Name: "Fire", Steps: models.GuidanceSteps{
+		step(kept, 1, "Call fire brigade"), step(moved, 2, "Evacuate"), step(removed, 3, "Wait"),
+	}}
+
+	t.Run("Same content is empty", func(t *testing.T) {
+		to := &models.GuidanceTemplateVersion{Name: "Fire", Steps: from.Steps}
+		if diff := diffVersions(from, to); !diff.Empty() {
+			t.Errorf("expected no changes, got %+v", diff)
+		}
+	})
+
+	t.Run("Steps are matched by ID", func(t *testing.T) {
+		to := &models.GuidanceTemplateVersion{Name: "Fire drill", Steps: models.GuidanceSteps{
+			step(moved, 1, "Evacuate the building"), step(kept, 2, "Call fire brigade"), step(added, 3, "Count people"),
+		}}
+		diff := diffVersions(from, to)
+		if len(diff.Fields) != 1 || diff.Fields[0].Field != "name" {
+			t.Errorf("expected the name to change, got %+v", diff.Fields)
+		}
+		if len(diff.AddedSteps) != 1 || diff.AddedSteps[0].ID != added {
+			t.Errorf("expected one added step, got %+v", diff.AddedSteps)
+		}
+		if len(diff.RemovedSteps) != 1 || diff.RemovedSteps[0].ID != removed {
+			t.Errorf("expected one removed step, got %+v", diff.RemovedSteps)
+		}
+		if len(diff.ChangedSteps) != 2 {
+			t.Fatalf("expected two changed steps, got %+v", diff.ChangedSteps)
+		}
+		movedChange := diff.ChangedSteps[0]
+		if movedChange.StepID != moved || len(movedChange.Fields) != 2 || movedChange.Fields[0].Field != "step_number" || movedChange.Fields[1].Field != "title" {
+			t.Errorf("expected the moved step to change number and title, got %+v", movedChange)
+		}
+		if keptChange := diff.ChangedSteps[1]; keptChange.StepID != kept || len(keptChange.Fields) != 1 {
+			t.Errorf("expected the kept step to change number only, got %+v", keptChange)
+		}
+	})
+}
@@ -4,8 +4,8 @@ import (
 	"scs-operator/internal/app/incident/dto"
 	services "scs-operator/internal/app/incident/service"
 	"scs-operator/pkg/errors"
+	"scs-operator/pkg/query"
 	"scs-operator/pkg/validation"
-	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
@@ -22,13 +22,14 @@ func NewHandler(svc services.Service) *Handler {
 
 // CreateIncident creates a new incident
 // @Summary Create a new incident
-// @Description Create a new incident with the provided information
+// @Description Create a new incident linked to zero or more alarms. Linked alarms are moved to dispatched. Guidance given without an assignee goes to a guard of the premise picked automatically.
 // @Tags incidents
 // @Accept json
 // @Produce json
 // @Param incident body dto.CreateIncidentDto true "Incident creation data"
 // @Success 201 {object} models.Incident
 // @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Security BearerAuth
 // @Router /incidents [post]
@@ -44,7 +45,8 @@ func (h *Handler) CreateIncident() echo.HandlerFunc {
 			return err
 		}
 
-		createdIncident, err := h.svc.CreateIncident(c.Request().Context(), createIncidentDto)
+		userID, _ := c.Get("user_id").(string)
+		createdIncident, err := h.svc.CreateIncident(c.Request().Context(), userID, createIncidentDto)
 		if err != nil {
 			return err
 		}
@@ -78,7 +80,8 @@ func (h *Handler) UpdateIncident() echo.HandlerFunc {
 		if err := validation.ValidateStruct(updateIncidentDto); err != nil {
 			return err
 		}
-		incident, err := h.svc.UpdateIncident(c.Request().Context(), incidentID, updateIncidentDto)
+		userID, _ := c.Get("user_id").(string)
+		incident, err := h.svc.UpdateIncident(c.Request().Context(), incidentID, userID, updateIncidentDto)
 		if err != nil {
 			return err
 		}
@@ -86,14 +89,24 @@ func (h *Handler) UpdateIncident() echo.HandlerFunc {
 	}
 }
 
-// GetIncidents retrieves a paginated list of incidents
+// GetIncidents retrieves a filtered, sorted and paginated list of incidents
 // @Summary Get incidents with pagination
-// @Description Get a paginated list of all incidents
+// @Description Get a paginated list of incidents with optional filtering and sorting. Follow next_cursor for stable keyset pagination; the total is counted unless a cursor is given.
 // @Tags incidents
 // @Accept json
 // @Produce json
-// @Param page query int false "Page number" default(1)
-// @Param limit query int false "Number of items per page" default(10)
+// @Param page query int false "Page number, ignored with a cursor" default(1)
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param include_total query bool false "Count the matching incidents, defaults to true without a cursor"
+// @Param sort query string false "Comma separated sort fields (created_at, name, status, severity), prefix with - for descending" default(-created_at)
+// @Param status query string false "Status" Enums(new, in_progress, resolved)
+// @Param severity query string false "Severity" Enums(low, medium, high)
+// @Param premise_id query string false "Premise ID"
+// @Param include_sub_premises query bool false "Include the sub-premises of premise_id"
+// @Param assignee_id query string false "Assignee of the active guidance"
+// @Param created_from query string false "Created at or after, RFC3339"
+// @Param created_to query string false "Created before, RFC3339"
 // @Success 200 {object} types.IncidentListResponse
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
@@ -101,24 +114,89 @@ func (h *Handler) UpdateIncident() echo.HandlerFunc {
 // @Router /incidents [get]
 func (h *Handler) GetIncidents() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		page := c.QueryParam("page")
-		limit := c.QueryParam("limit")
-		if page == "" {
-			page = "1"
+		getIncidentsDto := &dto.GetIncidentsDto{Request: query.NewRequest()}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, getIncidentsDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
 		}
-		if limit == "" {
-			limit = "10"
+
+		if err := validation.ValidateStruct(getIncidentsDto); err != nil {
+			return err
 		}
-		pageInt, err := strconv.Atoi(page)
+		incidents, err := h.svc.GetIncidents(c.Request().Context(), getIncidentsDto)
 		if err != nil {
-			return errors.NewBadRequestError("Invalid page number")
+			return err
 		}
-		limitInt, err := strconv.Atoi(limit)
+		return c.JSON(200, incidents)
+	}
+}
+
+// SearchIncidents searches incidents
+// @Summary Search incidents
+// @Description Full-text search over incident name, description, location, comments and linked alarm descriptions, best matches first. Returns highlighted snippets and facet counts for status, severity, premise, assignee and creation date.
+// @Tags incidents
+// @Produce json
+// @Param q query string false "Search text, supports quotes, OR and -word"
+// @Param status query string false "Status" Enums(new, in_progress, resolved)
+// @Param severity query string false "Severity" Enums(low, medium, high)
+// @Param premise_id query string false "Premise ID"
+// @Param include_sub_premises query bool false "Include the sub-premises of premise_id"
+// @Param assignee_id query string false "Assignee of the active guidance"
+// @Param created_from query string false "Created at or after, RFC3339"
+// @Param created_to query string false "Created before, RFC3339"
+// @Param bucket query string false "Created date facet bucket" Enums(day, week, month) default(day)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Number of items per page" default(10)
+// @Success 200 {object} types.IncidentSearchResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/search [get]
+func (h *Handler) SearchIncidents() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		searchDto := &dto.SearchIncidentsDto{Page: 1, Limit: 10, Bucket: "day"}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, searchDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		if err := validation.ValidateStruct(searchDto); err != nil {
+			return err
+		}
+		result, err := h.svc.SearchIncidents(c.Request().Context(), searchDto)
 		if err != nil {
-			return errors.NewBadRequestError("Invalid limit number")
+			return err
 		}
+		return c.JSON(200, result)
+	}
+}
 
-		incidents, err := h.svc.GetIncidents(c.Request().Context(), pageInt, limitInt)
+// GetIncidentsGeo lists incidents on a map
+// @Summary Get incidents in an area
+// @Description Get the incidents with a position within a bounding box, newest first, or within a radius around a point, nearest first, as a GeoJSON feature collection.
+// @Tags incidents
+// @Produce json
+// @Param bbox query string false "Bounding box as min_longitude,min_latitude,max_longitude,max_latitude"
+// @Param latitude query number false "Latitude of the center"
+// @Param longitude query number false "Longitude of the center"
+// @Param radius query number false "Radius around the center in meters, at most 100000"
+// @Param status query string false "Status" Enums(new, in_progress, resolved)
+// @Param severity query string false "Severity" Enums(low, medium, high)
+// @Param limit query int false "Maximum number of incidents" default(100)
+// @Success 200 {object} geo.FeatureCollection
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/geo [get]
+func (h *Handler) GetIncidentsGeo() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		geoDto := &dto.GetIncidentsGeoDto{Limit: 100}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, geoDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		if err := validation.ValidateStruct(geoDto); err != nil {
+			return err
+		}
+		incidents, err := h.svc.GetIncidentsGeo(c.Request().Context(), geoDto)
 		if err != nil {
 			return err
 		}
@@ -152,7 +230,7 @@ func (h *Handler) GetIncident() echo.HandlerFunc {
 
 // AssignGuidance assigns guidance template to an incident
 // @Summary Assign guidance to incident
-// @Description Assign a guidance template to an incident with an assignee
+// @Description Assign a guidance template to an incident with an assignee. Incidents that already have guidance must reassign or replace it.
 // @Tags incidents
 // @Accept json
 // @Produce json
@@ -161,6 +239,7 @@ func (h *Handler) GetIncident() echo.HandlerFunc {
 // @Success 201 {object} models.IncidentGuidance
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Security BearerAuth
 // @Router /incidents/{id}/assign-guidance [post]
@@ -175,7 +254,8 @@ func (h *Handler) AssignGuidance() echo.HandlerFunc {
 		if err := validation.ValidateStruct(assignGuidance); err != nil {
 			return err
 		}
-		createdIncidentGuidance, err := h.svc.AssignGuidance(c.Request().Context(), incidentID, assignGuidance)
+		userID, _ := c.Get("user_id").(string)
+		createdIncidentGuidance, err := h.svc.AssignGuidance(c.Request().Context(), incidentID, userID, assignGuidance)
 		if err != nil {
 			return err
 		}
@@ -185,7 +265,7 @@ func (h *Handler) AssignGuidance() echo.HandlerFunc {
 
 // GetIncidentGuidance retrieves guidance for an incident
 // @Summary Get incident guidance
-// @Description Get the guidance assigned to a specific incident
+// @Description Get the active guidance assigned to a specific incident
 // @Tags incidents
 // @Accept json
 // @Produce json
@@ -207,6 +287,116 @@ func (h *Handler) GetIncidentGuidance() echo.HandlerFunc {
 	}
 }
 
+// ReassignGuidance hands an incident's guidance to another assignee
+// @Summary Reassign incident guidance
+// @Description Reassign the active guidance of an incident to another user, keeping step progress. The reason is recorded in the assignment history.
+// @Tags incidents
+// @Accept json
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param request body dto.ReassignGuidanceDto true "New assignee and reason"
+// @Success 200 {object} models.IncidentGuidance
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/{id}/guidance/reassign [post]
+func (h *Handler) ReassignGuidance() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		reassignGuidanceDto := &dto.ReassignGuidanceDto{}
+		if err := c.Bind(reassignGuidanceDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(reassignGuidanceDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		incidentGuidance, err := h.svc.ReassignGuidance(c.Request().Context(), c.Param("id"), userID, reassignGuidanceDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, incidentGuidance)
+	}
+}
+
+// ReplaceGuidance replaces an incident's guidance with another template
+// @Summary Replace incident guidance
+// @Description Archive the active guidance of an incident together with its steps and assign a new template. The assignee is kept unless another one is given.
+// @Tags incidents
+// @Accept json
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param request body dto.ReplaceGuidanceDto true "New template, optional assignee and reason"
+// @Success 201 {object} models.IncidentGuidance
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/{id}/guidance/replace [post]
+func (h *Handler) ReplaceGuidance() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		replaceGuidanceDto := &dto.ReplaceGuidanceDto{}
+		if err := c.Bind(replaceGuidanceDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(replaceGuidanceDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		incidentGuidance, err := h.svc.ReplaceGuidance(c.Request().Context(), c.Param("id"), userID, replaceGuidanceDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, incidentGuidance)
+	}
+}
+
+// GetGuidanceHistory retrieves the guidance history of an incident
+// @Summary Get incident guidance history
+// @Description Get every guidance of an incident, archived ones with their steps included, and every assignment, reassignment and replacement
+// @Tags incidents
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Success 200 {object} types.GuidanceHistory
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/{id}/guidance/history [get]
+func (h *Handler) GetGuidanceHistory() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		history, err := h.svc.GetGuidanceHistory(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, history)
+	}
+}
+
+// GetEligibleAssignees lists the guards qualified for an incident's guidance
+// @Summary Get eligible guidance assignees
+// @Description Get the guards holding every qualification the guidance template requires, unexpired. Defaults to the incident's active guidance template
+// @Tags incidents
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param guidance_template_id query string false "Guidance template ID"
+// @Success 200 {object} types.EligibleAssignees
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/{id}/eligible-assignees [get]
+func (h *Handler) GetEligibleAssignees() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		eligible, err := h.svc.GetEligibleAssignees(c.Request().Context(), c.Param("id"), c.QueryParam("guidance_template_id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, eligible)
+	}
+}
+
 // CompleteIncident marks an incident as completed
 // @Summary Complete incident
 // @Description Mark an incident as resolved/completed
@@ -223,10 +413,145 @@ func (h *Handler) GetIncidentGuidance() echo.HandlerFunc {
 func (h *Handler) CompleteIncident() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		incidentID := c.Param("id")
-		err := h.svc.CompleteIncident(c.Request().Context(), incidentID)
+		userID, _ := c.Get("user_id").(string)
+		err := h.svc.CompleteIncident(c.Request().Context(), incidentID, userID)
 		if err != nil {
 			return err
 		}
 		return c.JSON(200, "success")
 	}
 }
+
+// GetActivity retrieves the activity feed of an incident
+// @Summary Get incident activity
+// @Description Get a paginated activity feed of an incident, newest first. Holds comments and system entries for status changes, completed guidance steps, media uploads and guidance assignments.
+// @Tags incidents
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param page query int false "Page number, ignored with a cursor" default(1)
+// @Param limit query int false "Number of items per page (max 100)" default(20)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param include_total query bool false "Count the entries, defaults to true without a cursor"
+// @Param sort query string false "created_at, prefix with - for descending" default(-created_at)
+// @Success 200 {object} types.IncidentActivityListResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/{id}/activity [get]
+func (h *Handler) GetActivity() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		request := query.NewRequest()
+		request.Limit = 20
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &request); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		if err := validation.ValidateStruct(request); err != nil {
+			return err
+		}
+		activity, err := h.svc.GetActivity(c.Request().Context(), c.Param("id"), request)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, activity)
+	}
+}
+
+// AddComment posts a comment to the activity feed of an incident
+// @Summary Comment on incident
+// @Description Post a comment to the activity feed of an incident. Mentioned users receive a notification.
+// @Tags incidents
+// @Accept json
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param comment body dto.CreateCommentDto true "Comment and mentioned users"
+// @Success 201 {object} models.IncidentActivity
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/{id}/activity [post]
+func (h *Handler) AddComment() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		commentDto := &dto.CreateCommentDto{}
+		if err := c.Bind(commentDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(commentDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		activity, err := h.svc.AddComment(c.Request().Context(), c.Param("id"), userID, commentDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, activity)
+	}
+}
+
+// CompleteGuidanceStep marks a guidance step of an incident as completed
+// @Summary Complete guidance step
+// @Description Mark a step of the active guidance of an incident as completed with the answer its type asks for. The answer decides the next step of the guidance, steps it jumps over are skipped. Evidence the step requires, such as photos, a note, a signature or a position within the premise geofence, must be given and is linked to the step
+// @Tags incidents
+// @Accept json
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param stepId path string true "Incident guidance step ID"
+// @Param request body dto.CompleteGuidanceStepDto false "Answer and evidence of the step"
+// @Success 200 {object} models.IncidentGuidanceStep
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/{id}/guidance/steps/{stepId}/complete [patch]
+func (h *Handler) CompleteGuidanceStep() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		completeDto := &dto.CompleteGuidanceStepDto{}
+		if err := c.Bind(completeDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(completeDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		step, err := h.svc.CompleteGuidanceStep(c.Request().Context(), c.Param("id"), c.Param("stepId"), userID, completeDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, step)
+	}
+}
+
+// UploadMedia uploads a photo or video of an incident
+// @Summary Upload incident media
+// @Description Upload an image or video of an incident as multipart form field "file". Pass the "media_id" of media registered offline through the sync API to attach the file to it
+// @Tags incidents
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param file formData file true "Image or video"
+// @Param media_id formData string false "ID of pending media registered offline"
+// @Success 201 {object} models.IncidentMedia
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /incidents/{id}/media [post]
+func (h *Handler) UploadMedia() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return errors.NewBadRequestError("file is required")
+		}
+		userID, _ := c.Get("user_id").(string)
+		media, err := h.svc.UploadMedia(c.Request().Context(), c.Param("id"), userID, c.FormValue("media_id"), file)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, media)
+	}
+}
@@ -7,9 +7,19 @@ import (
 func (h *Handler) RegisterRoutes(g *echo.Group) {
 	g.POST("", h.CreateIncident())
 	g.GET("", h.GetIncidents())
+	g.GET("/search", h.SearchIncidents())
+	g.GET("/geo", h.GetIncidentsGeo())
 	g.GET("/:id", h.GetIncident())
 	g.POST("/:id/assign-guidance", h.AssignGuidance())
+	g.GET("/:id/eligible-assignees", h.GetEligibleAssignees())
 	g.GET("/:id/guidance", h.GetIncidentGuidance())
+	g.POST("/:id/guidance/reassign", h.ReassignGuidance())
+	g.POST("/:id/guidance/replace", h.ReplaceGuidance())
+	g.GET("/:id/guidance/history", h.GetGuidanceHistory())
+	g.PATCH("/:id/guidance/steps/:stepId/complete", h.CompleteGuidanceStep())
+	g.GET("/:id/activity", h.GetActivity())
+	g.POST("/:id/activity", h.AddComment())
+	g.POST("/:id/media", h.UploadMedia())
 	g.PATCH("/:id/complete", h.CompleteIncident())
 	g.PATCH("/:id", h.UpdateIncident())
 }
@@ -0,0 +1,15 @@
+package dto
+
+// CompleteGuidanceStepDto carries the answer to a step: yes or no, one of the
+// step's options, text, a number, or the ID of a photo or signature uploaded
+// as incident media. Checkbox steps take no answer. The other fields are the
+// evidence the step may require, photos and signature given as incident media
+// IDs, and the position the step was completed at.
+type CompleteGuidanceStepDto struct {
+	Answer      string   `json:"answer" validate:"max=4000"`
+	Note        string   `json:"note" validate:"max=4000"`
+	PhotoIDs    []string `json:"photo_ids" validate:"omitempty,max=20,dive,uuid"`
+	SignatureID string   `json:"signature_id" validate:"omitempty,uuid"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+}
@@ -0,0 +1,7 @@
+package dto
+
+type CreateCommentDto struct {
+	Message string `json:"message" validate:"required,max=4000"`
+	// MentionIDs are the users @mentioned in the message, they get notified
+	MentionIDs []string `json:"mention_ids" validate:"omitempty,max=50,dive,uuid"`
+}
@@ -1,11 +1,23 @@
 package dto
 
+import "scs-operator/pkg/geo"
+
 type CreateIncidentDto struct {
-	Name               string `json:"name" validate:"required"`
-	Description        string `json:"description" validate:"required"`
-	AlarmId            string `json:"alarm_id" validate:"required"`
-	Severity           string `json:"severity" validate:"required"`
-	Location           string `json:"location" validate:"required"`
-	GuidanceTemplateID string `json:"guidance_template_id" validate:"required"`
-	Assignee           string `json:"assignee_id" validate:"required"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description" validate:"required"`
+	// AlarmIDs link the incident to alarms, which are moved to dispatched.
+	// Leave empty for incidents reported outside the alarm system.
+	AlarmIDs  []string `json:"alarm_ids" validate:"omitempty,dive,uuid"`
+	PremiseID string   `json:"premise_id" validate:"omitempty,uuid"`
+	Severity  string   `json:"severity" validate:"required,oneof=low medium high"`
+	Location  string   `json:"location" validate:"required"`
+	// The position is given either as latitude and longitude or as a GeoJSON
+	// point, and defaults to the position of the premise
+	Latitude  *float64   `json:"latitude,omitempty"`
+	Longitude *float64   `json:"longitude,omitempty"`
+	Geometry  *geo.Point `json:"geometry,omitempty"`
+	// Guidance is assigned when a template is given. Without an assignee a
+	// guard of the premise is picked automatically
+	GuidanceTemplateID string `json:"guidance_template_id" validate:"required_with=Assignee,omitempty,uuid"`
+	Assignee           string `json:"assignee_id" validate:"omitempty,uuid"`
 }
@@ -0,0 +1,20 @@
+package dto
+
+import "scs-operator/pkg/query"
+
+// IncidentFilterDto holds the filters shared by GET /incidents and GET /incidents/search.
+type IncidentFilterDto struct {
+	Status             string `query:"status" validate:"omitempty,oneof=new in_progress resolved"`
+	Severity           string `query:"severity" validate:"omitempty,oneof=low medium high"`
+	PremiseID          string `query:"premise_id" validate:"omitempty,uuid"`
+	IncludeSubPremises bool   `query:"include_sub_premises"`
+	AssigneeID         string `query:"assignee_id" validate:"omitempty,uuid"`
+	CreatedFrom        string `query:"created_from"`
+	CreatedTo          string `query:"created_to"`
+}
+
+// GetIncidentsDto holds the query parameters of GET /incidents.
+type GetIncidentsDto struct {
+	query.Request
+	IncidentFilterDto
+}
@@ -0,0 +1,14 @@
+package dto
+
+// GetIncidentsGeoDto holds the query parameters of GET /incidents/geo. The
+// area is either a bbox or a radius around latitude and longitude.
+type GetIncidentsGeoDto struct {
+	BBox      string   `query:"bbox"`
+	Latitude  *float64 `query:"latitude"`
+	Longitude *float64 `query:"longitude"`
+	// Radius is in meters
+	Radius   float64 `query:"radius" validate:"omitempty,gt=0,lte=100000"`
+	Status   string  `query:"status" validate:"omitempty,oneof=new in_progress resolved"`
+	Severity string  `query:"severity" validate:"omitempty,oneof=low medium high"`
+	Limit    int     `query:"limit" validate:"gte=1,lte=500"`
+}
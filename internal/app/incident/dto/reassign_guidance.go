@@ -0,0 +1,6 @@
+package dto
+
+type ReassignGuidanceDto struct {
+	Assignee string `json:"assignee_id" validate:"required,uuid"`
+	Reason   string `json:"reason" validate:"required"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+import "time"
+
+// RegisterMediaDto describes media captured offline whose file is uploaded
+// later.
+type RegisterMediaDto struct {
+	MediaType  string
+	FileName   string
+	FileType   string
+	FileSize   int64
+	CapturedAt *time.Time
+}
@@ -0,0 +1,8 @@
+package dto
+
+type ReplaceGuidanceDto struct {
+	GuidanceTemplateID string `json:"guidance_template_id" validate:"required,uuid"`
+	// Assignee defaults to the assignee of the replaced guidance
+	Assignee string `json:"assignee_id" validate:"omitempty,uuid"`
+	Reason   string `json:"reason" validate:"required"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+// SearchIncidentsDto holds the query parameters of GET /incidents/search.
+type SearchIncidentsDto struct {
+	Page  int `query:"page" validate:"gte=1"`
+	Limit int `query:"limit" validate:"gte=1,lte=100"`
+	// Query is matched against incident name, description, location, comments
+	// and linked alarm descriptions; quotes, OR and -word are supported
+	Query string `query:"q" validate:"max=200"`
+	IncidentFilterDto
+	// Bucket sizes the created date facet
+	Bucket string `query:"bucket" validate:"oneof=day week month"`
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/query"
+
+	"gorm.io/gorm"
+)
+
+type IncidentActivityRepository struct {
+	db *gorm.DB
+}
+
+func NewIncidentActivityRepository(db *gorm.DB) *IncidentActivityRepository {
+	return &IncidentActivityRepository{db: db}
+}
+
+// CreateIncidentActivity stores the activity and links its mentions without
+// touching the mentioned users.
+func (r *IncidentActivityRepository) CreateIncidentActivity(ctx context.Context, activity *models.IncidentActivity) (*models.IncidentActivity, error) {
+	if err := database.Conn(ctx, r.db).Omit("Mentions.*").Create(activity).Error; err != nil {
+		return nil, fmt.Errorf("failed to create incident activity: %w", err)
+	}
+	return activity, nil
+}
+
+// IncidentActivitySchema sorts the activity feed, newest first by default.
+var IncidentActivitySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"created_at": {Column: "created_at"},
+	},
+	DefaultSort: "-created_at",
+}
+
+func (r *IncidentActivityRepository) GetIncidentActivities(ctx context.Context, incidentID string, params query.Params) ([]models.IncidentActivity, query.PageInfo, error) {
+	activities, page, err := query.Find[models.IncidentActivity](database.Conn(ctx, r.db).Model(&models.IncidentActivity{}).
+		Preload("Actor").Preload("Mentions").Where("incident_id = ?", incidentID), params)
+	if err != nil {
+		return nil, page, fmt.Errorf("failed to get incident activities: %w", err)
+	}
+	return activities, page, nil
+}
+
+func (r *IncidentActivityRepository) GetIncidentActivitiesCount(ctx context.Context, incidentID string) (int64, error) {
+	var count int64
+	if err := database.Conn(ctx, r.db).Model(&models.IncidentActivity{}).Where("incident_id = ?", incidentID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to get incident activities count: %w", err)
+	}
+	return count, nil
+}
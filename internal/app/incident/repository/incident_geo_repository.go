@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/pkg/geo"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IncidentAreaFilter selects the incidents of a map area, either a bounding
+// box or the radius around a center.
+type IncidentAreaFilter struct {
+	IncidentFilter
+	Box    *geo.BoundingBox
+	Center *geo.Point
+	Radius float64
+}
+
+// IncidentPosition is an incident pinned on a map. Distance is the distance
+// to the center in meters, it is nil when searching a bounding box.
+type IncidentPosition struct {
+	ID        uuid.UUID
+	Name      string
+	Status    string
+	Severity  string
+	PremiseID *uuid.UUID
+	Latitude  float64
+	Longitude float64
+	Distance  *float64
+	CreatedAt time.Time
+}
+
+// GetIncidentsInArea returns the incidents with a position in the area,
+// nearest first around a center and newest first in a box.
+func (r *IncidentRepository) GetIncidentsInArea(ctx context.Context, filter IncidentAreaFilter, limit int) ([]IncidentPosition, error) {
+	db := r.filterIncidents(ctx, filter.IncidentFilter).
+		Where("incidents.latitude IS NOT NULL AND incidents.longitude IS NOT NULL")
+	columns := "incidents.id, incidents.name, incidents.status, incidents.severity, incidents.premise_id, incidents.latitude, incidents.longitude, incidents.created_at"
+	if filter.Center != nil {
+		db = db.Scopes(geo.Near("incidents.latitude", "incidents.longitude", *filter.Center, filter.Radius)).
+			Select(columns+", "+geo.DistanceSQL("incidents.latitude", "incidents.longitude")+" AS distance", filter.Center.DistanceArgs()...).
+			Order("distance")
+	} else {
+		if filter.Box != nil {
+			db = db.Scopes(geo.Within("incidents.latitude", "incidents.longitude", *filter.Box))
+		}
+		db = db.Select(columns).Order("incidents.created_at DESC")
+	}
+	var positions []IncidentPosition
+	if err := db.Limit(limit).Scan(&positions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get incidents in area: %w", err)
+	}
+	return positions, nil
+}
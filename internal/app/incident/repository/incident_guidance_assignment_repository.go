@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+
+	"gorm.io/gorm"
+)
+
+type IncidentGuidanceAssignmentRepository struct {
+	db *gorm.DB
+}
+
+func NewIncidentGuidanceAssignmentRepository(db *gorm.DB) *IncidentGuidanceAssignmentRepository {
+	return &IncidentGuidanceAssignmentRepository{db: db}
+}
+
+func (r *IncidentGuidanceAssignmentRepository) CreateIncidentGuidanceAssignment(ctx context.Context, assignment *models.IncidentGuidanceAssignment) (*models.IncidentGuidanceAssignment, error) {
+	if err := database.Conn(ctx, r.db).Create(assignment).Error; err != nil {
+		return nil, fmt.Errorf("failed to record guidance assignment: %w", err)
+	}
+	return assignment, nil
+}
+
+func (r *IncidentGuidanceAssignmentRepository) GetIncidentGuidanceAssignmentsByIncidentID(ctx context.Context, incidentID string) ([]models.IncidentGuidanceAssignment, error) {
+	var assignments []models.IncidentGuidanceAssignment
+	if err := database.Conn(ctx, r.db).Preload("Assignee").Preload("PreviousAssignee").Preload("Assigner").
+		Order("created_at").Find(&assignments, "incident_id = ?", incidentID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guidance assignments: %w", err)
+	}
+	return assignments, nil
+}
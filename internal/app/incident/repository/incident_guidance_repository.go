@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type IncidentGuidanceRepository struct {
@@ -16,7 +20,7 @@ func NewIncidentGuidanceRepository(db *gorm.DB) *IncidentGuidanceRepository {
 	return &IncidentGuidanceRepository{db: db}
 }
 func (r *IncidentGuidanceRepository) CreateIncidentGuidance(ctx context.Context, guidance *models.IncidentGuidance) (*models.IncidentGuidance, error) {
-	result := r.db.WithContext(ctx).Create(guidance)
+	result := database.Conn(ctx, r.db).Create(guidance)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to assign guidance: %w", result.Error)
 	}
@@ -24,7 +28,9 @@ func (r *IncidentGuidanceRepository) CreateIncidentGuidance(ctx context.Context,
 }
 func (r *IncidentGuidanceRepository) GetIncidentGuidanceByIncidentID(ctx context.Context, incidentID string) (*models.IncidentGuidance, error) {
 	var incidentGuidance models.IncidentGuidance
-	if err := r.db.WithContext(ctx).Preload("Assignee").Preload("Assigner").Preload("Incident").Preload("IncidentGuidanceSteps").First(&incidentGuidance, "incident_id = ?", incidentID).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Preload("Assignee").Preload("Assigner").Preload("Incident").
+		Preload("IncidentGuidanceSteps", func(db *gorm.DB) *gorm.DB { return db.Order("step_number") }).
+		Preload("IncidentGuidanceSteps.Media").First(&incidentGuidance, "incident_id = ? AND status = 'active'", incidentID).Error; err != nil {
 		return nil, fmt.Errorf("failed to get incident guidance: %w", err)
 	}
 	return &incidentGuidance, nil
@@ -32,8 +38,71 @@ func (r *IncidentGuidanceRepository) GetIncidentGuidanceByIncidentID(ctx context
 
 func (r *IncidentGuidanceRepository) GetIncidentGuidanceByAssigneeID(ctx context.Context, assigneeID string) ([]models.IncidentGuidance, error) {
 	var incidentGuidance []models.IncidentGuidance
-	if err := r.db.WithContext(ctx).Preload("Assignee").Preload("Assigner").Preload("Incident").Preload("IncidentGuidanceSteps").Find(&incidentGuidance, "assignee_id = ?", assigneeID).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Preload("Assignee").Preload("Assigner").Preload("Incident").
+		Preload("IncidentGuidanceSteps", func(db *gorm.DB) *gorm.DB { return db.Order("step_number") }).
+		Preload("IncidentGuidanceSteps.Media").Find(&incidentGuidance, "assignee_id = ? AND status = 'active'", assigneeID).Error; err != nil {
 		return nil, fmt.Errorf("failed to get incident guidance: %w", err)
 	}
 	return incidentGuidance, nil
 }
+
+// LockActiveIncidentGuidance loads the active guidance of an incident with a row
+// lock held until the surrounding transaction ends.
+func (r *IncidentGuidanceRepository) LockActiveIncidentGuidance(ctx context.Context, incidentID string) (*models.IncidentGuidance, error) {
+	var incidentGuidance models.IncidentGuidance
+	if err := database.Conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&incidentGuidance, "incident_id = ? AND status = 'active'", incidentID).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock incident guidance: %w", err)
+	}
+	return &incidentGuidance, nil
+}
+
+func (r *IncidentGuidanceRepository) HasActiveIncidentGuidance(ctx context.Context, incidentID string) (bool, error) {
+	var count int64
+	if err := database.Conn(ctx, r.db).Model(&models.IncidentGuidance{}).
+		Where("incident_id = ? AND status = 'active'", incidentID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check incident guidance: %w", err)
+	}
+	return count > 0, nil
+}
+
+// UpdateIncidentGuidanceAssignee hands the guidance to another assignee. The
+// reason of an automatic choice no longer applies and is cleared.
+func (r *IncidentGuidanceRepository) UpdateIncidentGuidanceAssignee(ctx context.Context, id uuid.UUID, assigneeID uuid.UUID, assignerID *uuid.UUID) error {
+	if err := database.Conn(ctx, r.db).Model(&models.IncidentGuidance{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"assignee_id": assigneeID, "assigner_id": assignerID, "assignment_reason": ""}).Error; err != nil {
+		return fmt.Errorf("failed to reassign incident guidance: %w", err)
+	}
+	return nil
+}
+
+// UpdateIncidentGuidanceNextStep records the step to do next, nil once the
+// guidance is done.
+func (r *IncidentGuidanceRepository) UpdateIncidentGuidanceNextStep(ctx context.Context, id uuid.UUID, nextStepID *uuid.UUID) error {
+	if err := database.Conn(ctx, r.db).Model(&models.IncidentGuidance{}).Where("id = ?", id).
+		Update("next_step_id", nextStepID).Error; err != nil {
+		return fmt.Errorf("failed to update next guidance step: %w", err)
+	}
+	return nil
+}
+
+// ArchiveIncidentGuidance retires a guidance. Its steps, completed or not, stay attached to it.
+func (r *IncidentGuidanceRepository) ArchiveIncidentGuidance(ctx context.Context, id uuid.UUID, archivedAt time.Time) error {
+	if err := database.Conn(ctx, r.db).Model(&models.IncidentGuidance{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": "archived", "archived_at": archivedAt}).Error; err != nil {
+		return fmt.Errorf("failed to archive incident guidance: %w", err)
+	}
+	return nil
+}
+
+// GetIncidentGuidancesByIncidentID returns every guidance of an incident, archived ones included, oldest first.
+func (r *IncidentGuidanceRepository) GetIncidentGuidancesByIncidentID(ctx context.Context, incidentID string) ([]models.IncidentGuidance, error) {
+	var incidentGuidances []models.IncidentGuidance
+	if err := database.Conn(ctx, r.db).Preload("GuidanceTemplate").Preload("Assignee").Preload("Assigner").
+		Preload("IncidentGuidanceSteps", func(db *gorm.DB) *gorm.DB { return db.Order("step_number") }).
+		Preload("IncidentGuidanceSteps.Media").
+		Order("created_at").Find(&incidentGuidances, "incident_id = ?", incidentID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get incident guidances: %w", err)
+	}
+	return incidentGuidances, nil
+}
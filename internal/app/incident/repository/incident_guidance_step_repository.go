@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -16,29 +18,78 @@ func NewIncidentGuidanceStepRepository(db *gorm.DB) *IncidentGuidanceStepReposit
 	return &IncidentGuidanceStepRepository{db: db}
 }
 func (r *IncidentGuidanceStepRepository) CreateIncidentGuidanceStep(ctx context.Context, guidance *models.IncidentGuidanceStep) (*models.IncidentGuidanceStep, error) {
-	if err := r.db.WithContext(ctx).Create(guidance).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Create(guidance).Error; err != nil {
 		return nil, fmt.Errorf("failed to assign guidance: %w", err)
 	}
 	return guidance, nil
 }
 
 func (r *IncidentGuidanceStepRepository) CreateIncidentGuidanceSteps(ctx context.Context, incidentGuidanceSteps []models.IncidentGuidanceStep) ([]models.IncidentGuidanceStep, error) {
-	if err := r.db.WithContext(ctx).Create(incidentGuidanceSteps).Error; err != nil {
+	if len(incidentGuidanceSteps) == 0 {
+		return incidentGuidanceSteps, nil
+	}
+	if err := database.Conn(ctx, r.db).Create(incidentGuidanceSteps).Error; err != nil {
 		return nil, fmt.Errorf("failed to assign guidance: %w", err)
 	}
 	return incidentGuidanceSteps, nil
 }
 
-func (r *IncidentGuidanceStepRepository) UpdateIncidentGuidanceStep(ctx context.Context, id string, isCompleted bool) error {
-	result := r.db.WithContext(ctx).Model(&models.IncidentGuidanceStep{}).Where("id = ?", id).Update("is_completed", isCompleted)
+// CompleteIncidentGuidanceStep marks a step done with the answer, note and
+// position it was completed with.
+func (r *IncidentGuidanceStepRepository) CompleteIncidentGuidanceStep(ctx context.Context, step *models.IncidentGuidanceStep) error {
+	result := database.Conn(ctx, r.db).Model(&models.IncidentGuidanceStep{}).Where("id = ?", step.ID).
+		Updates(map[string]interface{}{
+			"is_completed": true,
+			"completed_at": step.CompletedAt,
+			"answer":       step.Answer,
+			"note":         step.Note,
+			"latitude":     step.Latitude,
+			"longitude":    step.Longitude,
+		})
 	if result.Error != nil {
 		return fmt.Errorf("failed to update guidance step: %w", result.Error)
 	}
 	return nil
 }
+
+// SkipIncidentGuidanceSteps marks steps a branch jumped over as skipped.
+func (r *IncidentGuidanceStepRepository) SkipIncidentGuidanceSteps(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := database.Conn(ctx, r.db).Model(&models.IncidentGuidanceStep{}).Where("id IN ?", ids).
+		Update("skipped", true).Error; err != nil {
+		return fmt.Errorf("failed to skip guidance steps: %w", err)
+	}
+	return nil
+}
+
+// GetIncidentGuidanceSteps returns the steps of a guidance in order.
+func (r *IncidentGuidanceStepRepository) GetIncidentGuidanceSteps(ctx context.Context, incidentGuidanceID uuid.UUID) ([]models.IncidentGuidanceStep, error) {
+	var incidentGuidanceSteps []models.IncidentGuidanceStep
+	if err := database.Conn(ctx, r.db).Order("step_number").
+		Find(&incidentGuidanceSteps, "incident_guidance_id = ?", incidentGuidanceID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get incident guidance steps: %w", err)
+	}
+	return incidentGuidanceSteps, nil
+}
+
 func (r *IncidentGuidanceStepRepository) GetIncidentGuidanceStepByID(ctx context.Context, id string) (*models.IncidentGuidanceStep, error) {
 	var incidentGuidanceStep models.IncidentGuidanceStep
-	if err := r.db.WithContext(ctx).First(&incidentGuidanceStep, "id = ?", id).Error; err != nil {
+	if err := database.Conn(ctx, r.db).First(&incidentGuidanceStep, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get incident guidance step: %w", err)
+	}
+	return &incidentGuidanceStep, nil
+}
+
+// GetIncidentGuidanceStep returns a step of any guidance of the incident,
+// archived guidance included.
+func (r *IncidentGuidanceStepRepository) GetIncidentGuidanceStep(ctx context.Context, incidentID uuid.UUID, id string) (*models.IncidentGuidanceStep, error) {
+	var incidentGuidanceStep models.IncidentGuidanceStep
+	if err := database.Conn(ctx, r.db).
+		Joins("JOIN incident_guidances ON incident_guidances.id = incident_guidance_steps.incident_guidance_id").
+		Where("incident_guidance_steps.id = ? AND incident_guidances.incident_id = ?", id, incidentID).
+		First(&incidentGuidanceStep).Error; err != nil {
 		return nil, fmt.Errorf("failed to get incident guidance step: %w", err)
 	}
 	return &incidentGuidanceStep, nil
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IncidentMediaRepository struct {
+	db *gorm.DB
+}
+
+func NewIncidentMediaRepository(db *gorm.DB) *IncidentMediaRepository {
+	return &IncidentMediaRepository{db: db}
+}
+
+func (r *IncidentMediaRepository) CreateIncidentMedia(ctx context.Context, media *models.IncidentMedia) (*models.IncidentMedia, error) {
+	if err := database.Conn(ctx, r.db).Create(media).Error; err != nil {
+		return nil, fmt.Errorf("failed to create incident media: %w", err)
+	}
+	return media, nil
+}
+
+// GetIncidentMediaByIDs returns the media of the incident with the IDs,
+// uploaded or still pending.
+func (r *IncidentMediaRepository) GetIncidentMediaByIDs(ctx context.Context, incidentID uuid.UUID, ids []uuid.UUID) ([]models.IncidentMedia, error) {
+	var media []models.IncidentMedia
+	if err := database.Conn(ctx, r.db).Find(&media, "id IN ? AND incident_id = ?", ids, incidentID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get incident media: %w", err)
+	}
+	return media, nil
+}
+
+// LinkIncidentMedia makes media the evidence of a guidance step. It reports
+// whether all of it was still unlinked, so media is evidence of one step only.
+func (r *IncidentMediaRepository) LinkIncidentMedia(ctx context.Context, ids []uuid.UUID, stepID uuid.UUID, evidence string) (bool, error) {
+	result := database.Conn(ctx, r.db).Model(&models.IncidentMedia{}).
+		Where("id IN ? AND incident_guidance_step_id IS NULL", ids).
+		Updates(map[string]interface{}{"incident_guidance_step_id": stepID, "evidence": evidence})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to link incident media: %w", result.Error)
+	}
+	return result.RowsAffected == int64(len(ids)), nil
+}
+
+// GetPendingIncidentMedia returns media of the incident registered offline
+// and not uploaded yet.
+func (r *IncidentMediaRepository) GetPendingIncidentMedia(ctx context.Context, incidentID uuid.UUID, id string) (*models.IncidentMedia, error) {
+	var media models.IncidentMedia
+	if err := database.Conn(ctx, r.db).First(&media, "id = ? AND incident_id = ? AND status = 'pending'", id, incidentID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get pending incident media: %w", err)
+	}
+	return &media, nil
+}
+
+// CompleteIncidentMedia stores the uploaded file of pending media. It reports
+// whether the media was still pending, so a file is only attached once.
+func (r *IncidentMediaRepository) CompleteIncidentMedia(ctx context.Context, media *models.IncidentMedia) (bool, error) {
+	result := database.Conn(ctx, r.db).Model(&models.IncidentMedia{}).
+		Where("id = ? AND status = 'pending'", media.ID).
+		Updates(map[string]interface{}{
+			"media_type": media.MediaType,
+			"file_url":   media.FileUrl,
+			"file_size":  media.FileSize,
+			"file_type":  media.FileType,
+			"file_name":  media.FileName,
+			"status":     "uploaded",
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to complete incident media: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/query"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -17,22 +21,66 @@ func NewIncidentRepository(db *gorm.DB) *IncidentRepository {
 }
 
 func (r *IncidentRepository) CreateIncident(ctx context.Context, Incident *models.Incident) (*models.Incident, error) {
-	if err := r.db.WithContext(ctx).Create(Incident).Error; err != nil {
+	if err := database.Conn(ctx, r.db).Create(Incident).Error; err != nil {
 		return nil, fmt.Errorf("failed to create Incident: %w", err)
 	}
 	return Incident, nil
 }
-func (r *IncidentRepository) GetIncidents(ctx context.Context, page int, limit int) ([]models.Incident, error) {
-	var Incidents []models.Incident
-	if err := r.db.WithContext(ctx).Limit(limit).Offset((page - 1) * limit).Preload("IncidentGuidance").Preload("IncidentGuidance.Assignee").Order("created_at desc").Find(&Incidents).Error; err != nil {
-		return nil, fmt.Errorf("failed to get Incidents: %w", err)
+
+// IncidentFilter narrows down GetIncidents. Empty fields are ignored.
+type IncidentFilter struct {
+	Status      string
+	Severity    string
+	PremiseIDs  []uuid.UUID
+	AssigneeID  string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// IncidentSchema lists the fields incidents can be sorted by. Severity is
+// ranked rather than sorted alphabetically.
+var IncidentSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"created_at": {Column: "created_at"},
+		"name":       {Column: "name"},
+		"status":     {Column: "status"},
+		"severity":   {Column: "severity", Expr: "CASE %s WHEN 'high' THEN 3 WHEN 'medium' THEN 2 WHEN 'low' THEN 1 ELSE 0 END"},
+	},
+	DefaultSort: "-created_at",
+}
+
+func (r *IncidentRepository) filterIncidents(ctx context.Context, filter IncidentFilter) *gorm.DB {
+	return database.Conn(ctx, r.db).Model(&models.Incident{}).Scopes(
+		query.Equal("incidents.status", filter.Status),
+		query.Equal("incidents.severity", filter.Severity),
+		query.In("incidents.premise_id", filter.PremiseIDs),
+		query.Between("incidents.created_at", filter.CreatedFrom, filter.CreatedTo),
+		assignedTo(filter.AssigneeID),
+	)
+}
+
+// assignedTo keeps the incidents whose active guidance is assigned to the user.
+func assignedTo(assigneeID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if assigneeID == "" {
+			return db
+		}
+		return db.Where("EXISTS (SELECT 1 FROM incident_guidances ig WHERE ig.incident_id = incidents.id AND ig.status = 'active' AND ig.assignee_id = ?)", assigneeID)
+	}
+}
+
+func (r *IncidentRepository) GetIncidents(ctx context.Context, filter IncidentFilter, params query.Params) ([]models.Incident, query.PageInfo, error) {
+	incidents, page, err := query.Find[models.Incident](r.filterIncidents(ctx, filter).
+		Preload("IncidentGuidance", "status = 'active'").Preload("IncidentGuidance.Assignee"), params)
+	if err != nil {
+		return nil, page, fmt.Errorf("failed to get Incidents: %w", err)
 	}
-	return Incidents, nil
+	return incidents, page, nil
 }
 
-func (r *IncidentRepository) GetIncidentsCount(ctx context.Context) (int64, error) {
+func (r *IncidentRepository) GetIncidentsCount(ctx context.Context, filter IncidentFilter) (int64, error) {
 	var count int64
-	if err := r.db.WithContext(ctx).Model(&models.Incident{}).Count(&count).Error; err != nil {
+	if err := r.filterIncidents(ctx, filter).Count(&count).Error; err != nil {
 		return 0, fmt.Errorf("failed to get Incidents count: %w", err)
 	}
 	return count, nil
@@ -40,8 +88,9 @@ func (r *IncidentRepository) GetIncidentsCount(ctx context.Context) (int64, erro
 
 func (r *IncidentRepository) GetIncidentByID(ctx context.Context, id string) (*models.Incident, error) {
 	var Incident models.Incident
-	if err := r.db.WithContext(ctx).Preload("IncidentGuidance").
-		Preload("Alarm").
+	if err := database.Conn(ctx, r.db).Preload("IncidentGuidance", "status = 'active'").
+		Preload("Alarms").
+		Preload("Premise").
 		Preload("IncidentGuidance.IncidentGuidanceSteps").
 		Preload("IncidentGuidance.Assignee").
 		Preload("IncidentGuidance.Assigner").
@@ -54,7 +103,7 @@ func (r *IncidentRepository) GetIncidentByID(ctx context.Context, id string) (*m
 
 // Update incident
 func (r *IncidentRepository) UpdateIncident(ctx context.Context, id string, Incident *models.Incident) (*models.Incident, error) {
-	result := r.db.WithContext(ctx).Model(&models.Incident{}).Where("id = ?", id).Updates(Incident)
+	result := database.Conn(ctx, r.db).Model(&models.Incident{}).Where("id = ?", id).Updates(Incident)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to update incident: %w", result.Error)
 	}
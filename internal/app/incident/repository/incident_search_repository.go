@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/query"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The search documents below are repeated verbatim in SearchIndexes, Postgres
+// only uses an expression index when the query expression matches it.
+func incidentDocument(table string) string {
+	return fmt.Sprintf(`to_tsvector('english', coalesce(%[1]sname, '') || ' ' || coalesce(%[1]sdescription, '') || ' ' || coalesce(%[1]slocation, ''))`, table)
+}
+
+func commentDocument(table string) string {
+	return fmt.Sprintf(`to_tsvector('english', coalesce(%smessage, ''))`, table)
+}
+
+func alarmDocument(table string) string {
+	return fmt.Sprintf(`to_tsvector('english', coalesce(%sdescription, ''))`, table)
+}
+
+// SearchIndexes creates the GIN indexes backing SearchIncidents.
+var SearchIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_incidents_search ON incidents USING GIN (` + incidentDocument("") + `)`,
+	`CREATE INDEX IF NOT EXISTS idx_incident_activities_comment_search ON incident_activities USING GIN (` + commentDocument("") + `) WHERE type = 'comment'`,
+	`CREATE INDEX IF NOT EXISTS idx_alarms_description_search ON alarms USING GIN (` + alarmDocument("") + `)`,
+}
+
+const headlineOptions = `'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5'`
+
+// headline highlights the matches in a text expression. The text is HTML escaped
+// first, so the <mark> tags are the only markup in the snippet.
+func headline(text string) string {
+	escaped := fmt.Sprintf(`replace(replace(replace(replace(replace(%s, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`, text)
+	return `ts_headline('english', ` + escaped + `, search_query, ` + headlineOptions + `)`
+}
+
+// IncidentSearchFilter narrows down SearchIncidents. Empty fields are ignored.
+type IncidentSearchFilter struct {
+	IncidentFilter
+	Query string
+}
+
+// IncidentSearchHit is a matching incident with its rank and highlighted
+// snippets of the fields that matched.
+type IncidentSearchHit struct {
+	ID                   uuid.UUID
+	Rank                 float64
+	NameHighlight        string
+	DescriptionHighlight string
+	LocationHighlight    string
+	CommentHighlight     string
+	AlarmHighlight       string
+}
+
+// IncidentFacetCount is the number of matching incidents sharing a value.
+type IncidentFacetCount struct {
+	Value string
+	Label string
+	Count int64
+}
+
+// Incident search facets, a facet ignores its own filter so every value stays selectable.
+const (
+	FacetStatus   = "status"
+	FacetSeverity = "severity"
+	FacetPremise  = "premise"
+	FacetAssignee = "assignee"
+	FacetCreated  = "created"
+)
+
+// searchIncidents selects the incidents matching the filter, leaving out the
+// filter of the skipped facet. Matches are joined with the parsed search query
+// as search_query.
+func (r *IncidentRepository) searchIncidents(ctx context.Context, filter IncidentSearchFilter, skip string) *gorm.DB {
+	db := database.Conn(ctx, r.db).Model(&models.Incident{})
+	if filter.Query != "" {
+		db = db.Joins("CROSS JOIN websearch_to_tsquery('english', ?) AS search_query", filter.Query).
+			Where(`(` + incidentDocument("incidents.") + ` @@ search_query
+				OR EXISTS (SELECT 1 FROM incident_activities a WHERE a.incident_id = incidents.id AND a.type = 'comment' AND ` + commentDocument("a.") + ` @@ search_query)
+				OR EXISTS (SELECT 1 FROM incident_alarms ia JOIN alarms al ON al.id = ia.alarm_id WHERE ia.incident_id = incidents.id AND ` + alarmDocument("al.") + ` @@ search_query))`)
+	}
+	if skip != FacetStatus {
+		db = db.Scopes(query.Equal("incidents.status", filter.Status))
+	}
+	if skip != FacetSeverity {
+		db = db.Scopes(query.Equal("incidents.severity", filter.Severity))
+	}
+	if skip != FacetPremise {
+		db = db.Scopes(query.In("incidents.premise_id", filter.PremiseIDs))
+	}
+	if skip != FacetAssignee {
+		db = db.Scopes(assignedTo(filter.AssigneeID))
+	}
+	if skip != FacetCreated {
+		db = db.Scopes(query.Between("incidents.created_at", filter.CreatedFrom, filter.CreatedTo))
+	}
+	return db
+}
+
+// SearchIncidents returns a page of matching incidents, best matches first.
+func (r *IncidentRepository) SearchIncidents(ctx context.Context, filter IncidentSearchFilter, page int, limit int) ([]IncidentSearchHit, error) {
+	var hits []IncidentSearchHit
+	query := r.searchIncidents(ctx, filter, "")
+	if filter.Query != "" {
+		query = query.Select(`incidents.id,
+			ts_rank(` + incidentDocument("incidents.") + `, search_query) AS rank,
+			` + headline("coalesce(incidents.name, '')") + ` AS name_highlight,
+			` + headline("coalesce(incidents.description, '')") + ` AS description_highlight,
+			` + headline("coalesce(incidents.location, '')") + ` AS location_highlight,
+			(SELECT ` + headline("a.message") + ` FROM incident_activities a
+				WHERE a.incident_id = incidents.id AND a.type = 'comment' AND ` + commentDocument("a.") + ` @@ search_query
+				ORDER BY a.created_at DESC LIMIT 1) AS comment_highlight,
+			(SELECT ` + headline("al.description") + ` FROM incident_alarms ia JOIN alarms al ON al.id = ia.alarm_id
+				WHERE ia.incident_id = incidents.id AND ` + alarmDocument("al.") + ` @@ search_query
+				LIMIT 1) AS alarm_highlight`).
+			Order("rank DESC")
+	} else {
+		query = query.Select("incidents.id")
+	}
+	if err := query.Order("incidents.created_at DESC").Order("incidents.id").
+		Limit(limit).Offset((page - 1) * limit).Scan(&hits).Error; err != nil {
+		return nil, fmt.Errorf("failed to search incidents: %w", err)
+	}
+	return hits, nil
+}
+
+func (r *IncidentRepository) CountSearchIncidents(ctx context.Context, filter IncidentSearchFilter) (int64, error) {
+	var count int64
+	if err := r.searchIncidents(ctx, filter, "").Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count incidents: %w", err)
+	}
+	return count, nil
+}
+
+// GetIncidentFacet counts the matching incidents per value of the facet. Date
+// buckets are truncated to bucket, one of day, week or month.
+func (r *IncidentRepository) GetIncidentFacet(ctx context.Context, filter IncidentSearchFilter, facet string, bucket string) ([]IncidentFacetCount, error) {
+	matched := r.searchIncidents(ctx, filter, facet).
+		Select("incidents.id, incidents.status, incidents.severity, incidents.premise_id, incidents.created_at")
+	query := database.Conn(ctx, r.db).Table("(?) AS matched", matched)
+	switch facet {
+	case FacetStatus:
+		query = query.Select("matched.status AS value, COUNT(*) AS count").Group("matched.status")
+	case FacetSeverity:
+		query = query.Select("matched.severity AS value, COUNT(*) AS count").Group("matched.severity")
+	case FacetPremise:
+		query = query.Joins("JOIN premises p ON p.id = matched.premise_id").
+			Select("p.id::text AS value, p.name AS label, COUNT(*) AS count").Group("p.id, p.name")
+	case FacetAssignee:
+		query = query.Joins("JOIN incident_guidances ig ON ig.incident_id = matched.id AND ig.status = 'active'").
+			Joins("JOIN users u ON u.id = ig.assignee_id").
+			Select("u.id::text AS value, u.name AS label, COUNT(*) AS count").Group("u.id, u.name")
+	case FacetCreated:
+		query = query.Select("to_char(date_trunc(?, matched.created_at), 'YYYY-MM-DD') AS value, COUNT(*) AS count", bucket).
+			Group("1").Order("1")
+	default:
+		return nil, fmt.Errorf("unsupported facet %q", facet)
+	}
+	if facet != FacetCreated {
+		query = query.Order("count DESC").Order("value")
+	}
+	var counts []IncidentFacetCount
+	if err := query.Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get %s facet: %w", facet, err)
+	}
+	return counts, nil
+}
+
+// GetIncidentsByIDs loads the incidents with the same relations as GetIncidents.
+func (r *IncidentRepository) GetIncidentsByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Incident, error) {
+	var incidents []models.Incident
+	if err := database.Conn(ctx, r.db).Preload("IncidentGuidance", "status = 'active'").Preload("IncidentGuidance.Assignee").
+		Preload("Premise").Find(&incidents, "id IN ?", ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get Incidents: %w", err)
+	}
+	return incidents, nil
+}
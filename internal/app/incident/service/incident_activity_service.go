@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"path/filepath"
+	"scs-operator/internal/app/incident/dto"
+	repo "scs-operator/internal/app/incident/repository"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/query"
+	"scs-operator/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	ActivityComment            = "comment"
+	ActivityStatusChanged      = "status_changed"
+	ActivityStepCompleted      = "step_completed"
+	ActivityMediaUploaded      = "media_uploaded"
+	ActivityGuidanceAssigned   = "guidance_assigned"
+	ActivityGuidanceReassigned = "guidance_reassigned"
+	ActivityGuidanceReplaced   = "guidance_replaced"
+)
+
+// AddComment posts a comment to the incident's activity feed and notifies
+// every mentioned user once the comment is stored.
+func (s *Service) AddComment(ctx context.Context, incidentID string, actorID string, commentDto *dto.CreateCommentDto) (*models.IncidentActivity, error) {
+	incident, err := s.incidentRepo.GetIncidentByID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("incident")
+	}
+	mentions, err := s.getMentionedUsers(ctx, commentDto.MentionIDs)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := s.incidentActivityRepo.CreateIncidentActivity(ctx, &models.IncidentActivity{
+		IncidentID: incident.ID,
+		ActorID:    parseActorID(actorID),
+		Type:       ActivityComment,
+		Message:    commentDto.Message,
+		Mentions:   mentions,
+	})
+	if err != nil {
+		return nil, errors.NewDatabaseError("create comment", err)
+	}
+	s.notifyMentions(ctx, activity, actorID)
+	s.publishIncidentChange(ctx, "incident.comment_added", incident, activity)
+	return activity, nil
+}
+
+// GetActivity returns a page of the incident's activity feed, newest first.
+func (s *Service) GetActivity(ctx context.Context, incidentID string, request query.Request) (*types.PaginateResponse[models.IncidentActivity], error) {
+	params, err := query.New(repo.IncidentActivitySchema, request)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	if _, err := s.incidentRepo.GetIncidentByID(ctx, incidentID); err != nil {
+		return nil, errors.NewNotFoundError("incident")
+	}
+	activities, page, err := s.incidentActivityRepo.GetIncidentActivities(ctx, incidentID, params)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get incident activity", err)
+	}
+	if params.IncludeTotal {
+		total, err := s.incidentActivityRepo.GetIncidentActivitiesCount(ctx, incidentID)
+		if err != nil {
+			return nil, errors.NewDatabaseError("get incident activity count", err)
+		}
+		page.Total = &total
+	}
+	return types.NewPaginateResponse(activities, page), nil
+}
+
+// CompleteGuidanceStep marks a step of the incident's active guidance as done.
+func (s *Service) CompleteGuidanceStep(ctx context.Context, incidentID string, stepID string, actorID string, completeDto *dto.CompleteGuidanceStepDto) (*models.IncidentGuidanceStep, error) {
+	return s.CompleteGuidanceStepAt(ctx, incidentID, stepID, actorID, completeDto, time.Now())
+}
+
+// CompleteGuidanceStepAt marks a step of the incident's active guidance as
+// done at the given time, which is when a step completed offline was done.
+// Steps of replaced guidance, and steps skipped by an earlier answer, can no
+// longer be completed. The answer decides which step comes next, and the
+// evidence the step requires is checked and linked to it.
+func (s *Service) CompleteGuidanceStepAt(ctx context.Context, incidentID string, stepID string, actorID string, completeDto *dto.CompleteGuidanceStepDto, completedAt time.Time) (*models.IncidentGuidanceStep, error) {
+	if _, err := uuid.Parse(stepID); err != nil {
+		return nil, errors.NewBadRequestError("Invalid step ID format")
+	}
+	incident, err := s.incidentRepo.GetIncidentByID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("incident")
+	}
+	var step *models.IncidentGuidanceStep
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.lockActiveGuidance(ctx, incidentID)
+		if err != nil {
+			return err
+		}
+		step, err = s.incidentGuidanceStepRepo.GetIncidentGuidanceStep(ctx, incident.ID, stepID)
+		if err != nil {
+			return errors.NewNotFoundError("guidance step")
+		}
+		if step.IncidentGuidanceID != current.ID {
+			return errors.NewConflictError("Guidance step belongs to guidance that was replaced")
+		}
+		if step.IsCompleted {
+			return errors.NewConflictError("Guidance step is already completed")
+		}
+		if step.Skipped {
+			return errors.NewConflictError("Guidance step was skipped by an earlier answer")
+		}
+		stepAnswer, err := checkAnswer(step, completeDto.Answer)
+		if err != nil {
+			return err
+		}
+		evidence, err := collectEvidence(step, completeDto, stepAnswer)
+		if err != nil {
+			return err
+		}
+		if err := s.attachEvidence(ctx, incident, step, evidence); err != nil {
+			return err
+		}
+		step.IsCompleted, step.CompletedAt, step.Answer = true, &completedAt, stepAnswer
+		step.Note = evidence.note
+		step.Latitude, step.Longitude = evidence.position.Coordinates()
+		if err := s.incidentGuidanceStepRepo.CompleteIncidentGuidanceStep(ctx, step); err != nil {
+			return errors.NewDatabaseError("complete guidance step", err)
+		}
+		nextStepID, err := s.advanceGuidance(ctx, current.ID, step)
+		if err != nil {
+			return err
+		}
+		details := map[string]interface{}{
+			"step_id":     step.ID.String(),
+			"step_number": step.StepNumber,
+		}
+		if stepAnswer != nil {
+			details["answer"] = *stepAnswer
+		}
+		if len(step.Media) > 0 {
+			mediaIDs := make([]string, 0, len(step.Media))
+			for _, media := range step.Media {
+				mediaIDs = append(mediaIDs, media.ID.String())
+			}
+			details["media_ids"] = mediaIDs
+		}
+		if nextStepID != nil {
+			details["next_step_id"] = nextStepID.String()
+		}
+		return s.recordActivity(ctx, incident.ID, parseActorID(actorID), ActivityStepCompleted, "Completed step "+step.Title, details)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publishIncidentChange(ctx, "incident.step_completed", incident, step)
+	return step, nil
+}
+
+// advanceGuidance follows the steps of a guidance after one was completed. It
+// skips the steps that can no longer be reached and records the next step.
+func (s *Service) advanceGuidance(ctx context.Context, incidentGuidanceID uuid.UUID, completed *models.IncidentGuidanceStep) (*uuid.UUID, error) {
+	steps, err := s.incidentGuidanceStepRepo.GetIncidentGuidanceSteps(ctx, incidentGuidanceID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get guidance steps", err)
+	}
+	for i := range steps {
+		if steps[i].ID == completed.ID {
+			steps[i] = *completed
+		}
+	}
+	nextStepID, skipped := stepFlow(steps)
+	if err := s.incidentGuidanceStepRepo.SkipIncidentGuidanceSteps(ctx, skipped); err != nil {
+		return nil, errors.NewDatabaseError("skip guidance steps", err)
+	}
+	if err := s.incidentGuidanceRepo.UpdateIncidentGuidanceNextStep(ctx, incidentGuidanceID, nextStepID); err != nil {
+		return nil, errors.NewDatabaseError("update next guidance step", err)
+	}
+	return nextStepID, nil
+}
+
+// RegisterMedia records media captured offline as pending. Its file is
+// uploaded later with the media ID.
+func (s *Service) RegisterMedia(ctx context.Context, incidentID string, registerMediaDto *dto.RegisterMediaDto) (*models.IncidentMedia, error) {
+	incident, err := s.incidentRepo.GetIncidentByID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("incident")
+	}
+	media, err := s.incidentMediaRepo.CreateIncidentMedia(ctx, &models.IncidentMedia{
+		IncidentID: incident.ID,
+		MediaType:  registerMediaDto.MediaType,
+		FileSize:   registerMediaDto.FileSize,
+		FileType:   registerMediaDto.FileType,
+		FileName:   registerMediaDto.FileName,
+		Status:     "pending",
+		CapturedAt: registerMediaDto.CapturedAt,
+	})
+	if err != nil {
+		return nil, errors.NewDatabaseError("register incident media", err)
+	}
+	return media, nil
+}
+
+// UploadMedia stores a photo or video of the incident. Given the ID of media
+// registered offline, the file is attached to it. The file is removed again
+// when the upload cannot be recorded.
+func (s *Service) UploadMedia(ctx context.Context, incidentID string, actorID string, mediaID string, file *multipart.FileHeader) (*models.IncidentMedia, error) {
+	incident, err := s.incidentRepo.GetIncidentByID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("incident")
+	}
+	var pending *models.IncidentMedia
+	if mediaID != "" {
+		if _, err := uuid.Parse(mediaID); err != nil {
+			return nil, errors.NewBadRequestError("Invalid media ID format")
+		}
+		pending, err = s.incidentMediaRepo.GetPendingIncidentMedia(ctx, incident.ID, mediaID)
+		if err != nil {
+			return nil, errors.NewNotFoundError("pending incident media")
+		}
+	}
+	mediaType := "image"
+	if strings.HasPrefix(file.Header.Get("Content-Type"), "video/") {
+		mediaType = "video"
+	}
+	if mediaType == "video" {
+		err = utils.ValidateVideoFile(file, s.maxMediaSize)
+	} else {
+		err = utils.ValidateImageFile(file, s.maxMediaSize)
+	}
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	fileInfo, err := utils.SaveUploadedFile(file, filepath.Join(s.mediaDir, incident.ID.String()))
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to save media", err)
+	}
+
+	media := &models.IncidentMedia{
+		IncidentID: incident.ID,
+		MediaType:  mediaType,
+		FileUrl:    "/api/v1/incidents/media/" + incident.ID.String() + "/" + fileInfo.SavedName,
+		FileSize:   fileInfo.Size,
+		FileType:   fileInfo.ContentType,
+		FileName:   fileInfo.OriginalName,
+		Status:     "uploaded",
+	}
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if pending != nil {
+			media.Base, media.CapturedAt = pending.Base, pending.CapturedAt
+			completed, err := s.incidentMediaRepo.CompleteIncidentMedia(ctx, media)
+			if err != nil {
+				return errors.NewDatabaseError("complete incident media", err)
+			}
+			if !completed {
+				return errors.NewConflictError("Incident media is already uploaded")
+			}
+		} else if _, err := s.incidentMediaRepo.CreateIncidentMedia(ctx, media); err != nil {
+			return errors.NewDatabaseError("create incident media", err)
+		}
+		return s.recordActivity(ctx, incident.ID, parseActorID(actorID), ActivityMediaUploaded, "Uploaded "+mediaType+" "+fileInfo.OriginalName, map[string]interface{}{
+			"media_id":   media.ID.String(),
+			"media_type": mediaType,
+		})
+	})
+	if err != nil {
+		_ = utils.DeleteFile(fileInfo.Path)
+		return nil, err
+	}
+	s.publishIncidentChange(ctx, "incident.media_uploaded", incident, media)
+	return media, nil
+}
+
+// recordActivity adds a system entry to the incident's activity feed. Callers
+// run it inside the transaction of the change it describes.
+func (s *Service) recordActivity(ctx context.Context, incidentID uuid.UUID, actorID *uuid.UUID, activityType string, message string, details map[string]interface{}) error {
+	activity := &models.IncidentActivity{
+		IncidentID: incidentID,
+		ActorID:    actorID,
+		Type:       activityType,
+		Message:    message,
+	}
+	if details != nil {
+		detailBytes, _ := json.Marshal(details)
+		activity.Details = models.JSONB(detailBytes)
+	}
+	if _, err := s.incidentActivityRepo.CreateIncidentActivity(ctx, activity); err != nil {
+		return errors.NewDatabaseError("record incident activity", err)
+	}
+	return nil
+}
+
+func (s *Service) getMentionedUsers(ctx context.Context, rawIDs []string) ([]models.User, error) {
+	if len(rawIDs) == 0 {
+		return nil, nil
+	}
+	ids := make([]uuid.UUID, 0, len(rawIDs))
+	for _, rawID := range rawIDs {
+		id, err := uuid.Parse(rawID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid mention ID format")
+		}
+		ids = append(ids, id)
+	}
+	users, err := s.userRepo.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get mentioned users", err)
+	}
+	found := make(map[uuid.UUID]bool, len(users))
+	for _, user := range users {
+		found[user.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, errors.NewNotFoundError("mentioned user")
+		}
+	}
+	return users, nil
+}
+
+// notifyMentions publishes one notification per mentioned user, keyed by the
+// user so a consumer sees each user's notifications in order.
+func (s *Service) notifyMentions(ctx context.Context, activity *models.IncidentActivity, actorID string) {
+	messages := make([]kafka.Message, 0, len(activity.Mentions))
+	for _, user := range activity.Mentions {
+		messageBytes, err := json.Marshal(types.Message[types.IncidentMentionEvent]{
+			Type: "incident.mention",
+			Payload: types.IncidentMentionEvent{
+				IncidentID:      activity.IncidentID.String(),
+				ActivityID:      activity.ID.String(),
+				MentionedUserID: user.ID.String(),
+				ActorID:         actorID,
+				Message:         activity.Message,
+			},
+		})
+		if err != nil {
+			continue
+		}
+		messages = append(messages, kafka.Message{Key: []byte(user.ID.String()), Value: messageBytes})
+	}
+	if len(messages) == 0 {
+		return
+	}
+	// A failed notification must not fail the request, a rolled back comment must not notify
+	database.AfterCommit(ctx, func() { _ = s.producer.WriteMessages(ctx, messages...) })
+}
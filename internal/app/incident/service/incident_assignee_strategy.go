@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	guardRepositories "scs-operator/internal/app/guard/repository"
+	qualificationRepositories "scs-operator/internal/app/qualification/repository"
+	"scs-operator/internal/models"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/geo"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AssigneeStrategy picks who guidance is assigned to when an incident is
+// created without an assignee. Candidates are the premise's guards qualified
+// for the guidance, by name. It reports false when none of them fits.
+type AssigneeStrategy interface {
+	PickAssignee(incident *models.Incident, candidates []guardRepositories.AssigneeCandidate) (AssigneeChoice, bool)
+}
+
+// AssigneeChoice is the picked guard and why they were picked, which is
+// recorded on the guidance.
+type AssigneeChoice struct {
+	UserID uuid.UUID
+	Reason string
+}
+
+// pickAssignee lets the assignee strategy choose among the guards of the
+// incident's premise qualified for the guidance. It returns the reason of the
+// choice along with the assignee.
+func (s *Service) pickAssignee(ctx context.Context, incident *models.Incident, guidanceTemplate *models.GuidanceTemplate) (*models.User, string, error) {
+	if incident.PremiseID == nil {
+		return nil, "", errors.NewBadRequestError("assignee_id is required for incidents without a premise")
+	}
+	now := time.Now()
+	candidates, err := s.guardRepo.GetAssigneeCandidates(ctx, *incident.PremiseID, now.Add(-s.positionMaxAge),
+		qualificationRepositories.QualifiedFor(guidanceTemplate.ID, now))
+	if err != nil {
+		return nil, "", errors.NewDatabaseError("get assignee candidates", err)
+	}
+	choice, ok := s.assigneeStrategy.PickAssignee(incident, candidates)
+	if !ok {
+		return nil, "", errors.NewBadRequestError("No guard of the premise is qualified for this guidance, assignee_id is required")
+	}
+	assignee, err := s.getAssignee(ctx, choice.UserID.String())
+	if err != nil {
+		return nil, "", err
+	}
+	return assignee, "Picked automatically, " + choice.Reason, nil
+}
+
+// WeightedAssigneeStrategy picks the guard with the lowest cost. Every open
+// incident costs OpenIncidentCost, being off shift costs OffDutyCost and every
+// kilometre between the guard and the incident costs DistanceCost. A distance
+// that cannot be measured counts as UnknownDistance kilometres. Ties go to
+// the first candidate.
+type WeightedAssigneeStrategy struct {
+	OpenIncidentCost float64
+	OffDutyCost      float64
+	DistanceCost     float64
+	UnknownDistance  float64
+}
+
+func (w WeightedAssigneeStrategy) PickAssignee(incident *models.Incident, candidates []guardRepositories.AssigneeCandidate) (AssigneeChoice, bool) {
+	incidentPosition, _ := geo.NewPoint(incident.Latitude, incident.Longitude)
+	best, bestCost, bestDistance := -1, 0.0, (*float64)(nil)
+	for i, candidate := range candidates {
+		cost := float64(candidate.OpenIncidents) * w.OpenIncidentCost
+		if !candidate.OnDuty {
+			cost += w.OffDutyCost
+		}
+		var distance *float64
+		candidatePosition, _ := geo.NewPoint(candidate.Latitude, candidate.Longitude)
+		if incidentPosition != nil && candidatePosition != nil {
+			kilometres := geo.Distance(*incidentPosition, *candidatePosition) / 1000
+			distance = &kilometres
+			cost += kilometres * w.DistanceCost
+		} else {
+			cost += w.UnknownDistance * w.DistanceCost
+		}
+		if best < 0 || cost < bestCost {
+			best, bestCost, bestDistance = i, cost, distance
+		}
+	}
+	if best < 0 {
+		return AssigneeChoice{}, false
+	}
+	picked := candidates[best]
+	reasons := []string{pluralize(picked.OpenIncidents, "open incident")}
+	if picked.OnDuty {
+		reasons = append(reasons, "on shift")
+	} else {
+		reasons = append(reasons, "off shift")
+	}
+	if bestDistance != nil {
+		reasons = append(reasons, fmt.Sprintf("%.1f km away", *bestDistance))
+	} else {
+		reasons = append(reasons, "distance unknown")
+	}
+	return AssigneeChoice{
+		UserID: picked.UserID,
+		Reason: fmt.Sprintf("lowest cost %.1f of %s: %s", bestCost, pluralize(len(candidates), "candidate"), strings.Join(reasons, ", ")),
+	}, true
+}
+
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
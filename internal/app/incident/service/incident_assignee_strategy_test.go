@@ -0,0 +1,84 @@
+package services
+
+import (
+	guardRepositories "scs-operator/internal/app/guard/repository"
+	"scs-operator/internal/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWeightedAssigneeStrategy(t *testing.T) {
+	strategy := WeightedAssigneeStrategy{OpenIncidentCost: 10, OffDutyCost: 25, DistanceCost: 1, UnknownDistance: 20}
+	latitude, longitude := 52.37, 4.89
+	near, far := 52.371, 52.55
+	incident := &models.Incident{Latitude: &latitude, Longitude: &longitude}
+	candidate := func(name string, openIncidents int, onDuty bool, latitude *float64) guardRepositories.AssigneeCandidate {
+		return guardRepositories.AssigneeCandidate{UserID: uuid.New(), Name: name, OpenIncidents: openIncidents, OnDuty: onDuty, Latitude: latitude, Longitude: &longitude}
+	}
+
+	tests := []struct {
+		name       string
+		incident   *models.Incident
+		candidates []guardRepositories.AssigneeCandidate
+		expected   string
+	}{
+		{
+			name:       "Fewer open incidents win",
+			incident:   incident,
+			candidates: []guardRepositories.AssigneeCandidate{candidate("Busy", 2, true, &near), candidate("Idle", 0, true, &near)},
+			expected:   "Idle",
+		},
+		{
+			name:       "Guards on shift win",
+			incident:   incident,
+			candidates: []guardRepositories.AssigneeCandidate{candidate("Off", 0, false, &near), candidate("On", 1, true, &near)},
+			expected:   "On",
+		},
+		{
+			name:       "Nearer guards win",
+			incident:   incident,
+			candidates: []guardRepositories.AssigneeCandidate{candidate("Far", 0, true, &far), candidate("Near", 0, true, &near)},
+			expected:   "Near",
+		},
+		{
+			name:       "Unknown positions count as far away",
+			incident:   incident,
+			candidates: []guardRepositories.AssigneeCandidate{candidate("Unknown", 0, true, nil), candidate("Near", 0, true, &near)},
+			expected:   "Near",
+		},
+		{
+			name:       "Ties go to the first candidate",
+			incident:   &models.Incident{},
+			candidates: []guardRepositories.AssigneeCandidate{candidate("Anna", 0, true, &near), candidate("Bob", 0, true, &far)},
+			expected:   "Anna",
+		},
+		{
+			name:     "No candidates",
+			incident: incident,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choice, ok := strategy.PickAssignee(tt.incident, tt.candidates)
+			if tt.expected == "" {
+				if ok {
+					t.Fatalf("expected no choice, got %+v", choice)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("expected a choice")
+			}
+			for _, c := range tt.candidates {
+				if c.UserID == choice.UserID && c.Name != tt.expected {
+					t.Errorf("expected %s, got %s (%s)", tt.expected, c.Name, choice.Reason)
+				}
+			}
+			if choice.Reason == "" {
+				t.Error("expected a reason")
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"scs-operator/internal/app/incident/dto"
+	repo "scs-operator/internal/app/incident/repository"
+	"scs-operator/internal/types"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/geo"
+	"time"
+)
+
+// GetIncidentsGeo returns the incidents within a bounding box or a radius as a
+// GeoJSON feature collection.
+func (s *Service) GetIncidentsGeo(ctx context.Context, geoDto *dto.GetIncidentsGeoDto) (*geo.FeatureCollection, error) {
+	filter := repo.IncidentAreaFilter{
+		IncidentFilter: repo.IncidentFilter{Status: geoDto.Status, Severity: geoDto.Severity},
+	}
+	center, err := geo.NewPoint(geoDto.Latitude, geoDto.Longitude)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	switch {
+	case center != nil && geoDto.BBox != "":
+		return nil, errors.NewBadRequestError("Give either bbox or latitude and longitude, not both")
+	case center != nil:
+		if geoDto.Radius == 0 {
+			return nil, errors.NewBadRequestError("radius is required with latitude and longitude")
+		}
+		filter.Center, filter.Radius = center, geoDto.Radius
+	case geoDto.BBox != "":
+		box, err := geo.ParseBoundingBox(geoDto.BBox)
+		if err != nil {
+			return nil, errors.NewBadRequestError(err.Error())
+		}
+		filter.Box = &box
+	default:
+		return nil, errors.NewBadRequestError("Either bbox or latitude and longitude is required")
+	}
+
+	positions, err := s.incidentRepo.GetIncidentsInArea(ctx, filter, geoDto.Limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get incidents in area", err)
+	}
+	features := make([]geo.Feature, 0, len(positions))
+	for _, position := range positions {
+		properties := types.IncidentFeatureProperties{
+			ID:        position.ID.String(),
+			Name:      position.Name,
+			Status:    position.Status,
+			Severity:  position.Severity,
+			Distance:  position.Distance,
+			CreatedAt: position.CreatedAt.UTC().Format(time.RFC3339),
+		}
+		if position.PremiseID != nil {
+			properties.PremiseID = position.PremiseID.String()
+		}
+		features = append(features, geo.NewFeature(geo.Point{Latitude: position.Latitude, Longitude: position.Longitude}, properties))
+	}
+	collection := geo.NewFeatureCollection(features)
+	return &collection, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"scs-operator/internal/app/incident/dto"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	"scs-operator/pkg/errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReassignGuidance hands the active guidance of an incident to another assignee.
+// Progress on the steps is kept.
+func (s *Service) ReassignGuidance(ctx context.Context, incidentID string, actorID string, reassignGuidanceDto *dto.ReassignGuidanceDto) (*models.IncidentGuidance, error) {
+	incident, err := s.incidentRepo.GetIncidentByID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("incident")
+	}
+	assignee, err := s.getAssignee(ctx, reassignGuidanceDto.Assignee)
+	if err != nil {
+		return nil, err
+	}
+	assignerID := parseActorID(actorID)
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.lockActiveGuidance(ctx, incidentID)
+		if err != nil {
+			return err
+		}
+		if current.AssigneeID != nil && *current.AssigneeID == assignee.ID {
+			return errors.NewBadRequestError("Guidance is already assigned to this user")
+		}
+		if current.GuidanceTemplateID != nil {
+			guidanceTemplate, err := s.getGuidanceTemplate(ctx, current.GuidanceTemplateID.String())
+			if err != nil {
+				return err
+			}
+			if err := s.checkQualified(ctx, guidanceTemplate, assignee); err != nil {
+				return err
+			}
+		}
+		if err := s.incidentGuidanceRepo.UpdateIncidentGuidanceAssignee(ctx, current.ID, assignee.ID, assignerID); err != nil {
+			return errors.NewDatabaseError("reassign guidance", err)
+		}
+		return s.recordAssignment(ctx, &models.IncidentGuidanceAssignment{
+			IncidentID:         *current.IncidentID,
+			IncidentGuidanceID: current.ID,
+			Action:             "reassigned",
+			AssigneeID:         &assignee.ID,
+			PreviousAssigneeID: current.AssigneeID,
+			AssignerID:         assignerID,
+			Reason:             reassignGuidanceDto.Reason,
+		}, assignee)
+	})
+	if err != nil {
+		return nil, err
+	}
+	incidentGuidance, err := s.incidentGuidanceRepo.GetIncidentGuidanceByIncidentID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get incident guidance", err)
+	}
+	s.notifyGuidanceAssigned(ctx, incident.ID)
+	s.publishIncidentChange(ctx, "incident.guidance_updated", incident, incidentGuidance)
+	return incidentGuidance, nil
+}
+
+// ReplaceGuidance archives the active guidance of an incident, completed steps
+// included, and assigns a new template in its place. The current assignee keeps
+// the incident unless another one is given.
+func (s *Service) ReplaceGuidance(ctx context.Context, incidentID string, actorID string, replaceGuidanceDto *dto.ReplaceGuidanceDto) (*models.IncidentGuidance, error) {
+	incident, err := s.incidentRepo.GetIncidentByID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("incident")
+	}
+	guidanceTemplate, err := s.getPublishedGuidanceTemplate(ctx, replaceGuidanceDto.GuidanceTemplateID)
+	if err != nil {
+		return nil, err
+	}
+	var assignee *models.User
+	if replaceGuidanceDto.Assignee != "" {
+		assignee, err = s.getAssignee(ctx, replaceGuidanceDto.Assignee)
+		if err != nil {
+			return nil, err
+		}
+	}
+	assignerID := parseActorID(actorID)
+
+	var createdIncidentGuidance *models.IncidentGuidance
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.lockActiveGuidance(ctx, incidentID)
+		if err != nil {
+			return err
+		}
+		if assignee == nil {
+			if current.AssigneeID == nil {
+				return errors.NewBadRequestError("assignee_id is required")
+			}
+			assignee, err = s.getAssignee(ctx, current.AssigneeID.String())
+			if err != nil {
+				return err
+			}
+		}
+		if err := s.checkQualified(ctx, guidanceTemplate, assignee); err != nil {
+			return err
+		}
+		// Archive first, an incident holds a single active guidance
+		if err := s.incidentGuidanceRepo.ArchiveIncidentGuidance(ctx, current.ID, time.Now()); err != nil {
+			return errors.NewDatabaseError("archive guidance", err)
+		}
+		incident.IncidentGuidance = nil
+		createdIncidentGuidance, err = s.createGuidance(ctx, incident, guidanceTemplate, assignee, assignerID, "")
+		if err != nil {
+			return err
+		}
+		return s.recordAssignment(ctx, &models.IncidentGuidanceAssignment{
+			IncidentID:         incident.ID,
+			IncidentGuidanceID: createdIncidentGuidance.ID,
+			PreviousGuidanceID: &current.ID,
+			Action:             "replaced",
+			AssigneeID:         &assignee.ID,
+			PreviousAssigneeID: current.AssigneeID,
+			AssignerID:         assignerID,
+			Reason:             replaceGuidanceDto.Reason,
+		}, assignee)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyGuidanceAssigned(ctx, incident.ID)
+	s.publishIncidentChange(ctx, "incident.guidance_updated", incident, createdIncidentGuidance)
+	return createdIncidentGuidance, nil
+}
+
+// GetGuidanceHistory returns every guidance an incident has had, archived ones
+// with the steps completed before they were replaced, and every assignment.
+func (s *Service) GetGuidanceHistory(ctx context.Context, incidentID string) (*types.GuidanceHistory, error) {
+	if _, err := s.incidentRepo.GetIncidentByID(ctx, incidentID); err != nil {
+		return nil, errors.NewNotFoundError("incident")
+	}
+	guidances, err := s.incidentGuidanceRepo.GetIncidentGuidancesByIncidentID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get incident guidances", err)
+	}
+	assignments, err := s.incidentGuidanceAssignmentRepo.GetIncidentGuidanceAssignmentsByIncidentID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get guidance assignments", err)
+	}
+	return &types.GuidanceHistory{Guidances: guidances, Assignments: assignments}, nil
+}
+
+func (s *Service) lockActiveGuidance(ctx context.Context, incidentID string) (*models.IncidentGuidance, error) {
+	current, err := s.incidentGuidanceRepo.LockActiveIncidentGuidance(ctx, incidentID)
+	if stdErrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewNotFoundError("incident guidance")
+	}
+	if err != nil {
+		return nil, errors.NewDatabaseError("get incident guidance", err)
+	}
+	return current, nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	"scs-operator/pkg/errors"
+	"strings"
+	"time"
+)
+
+// GetEligibleAssignees returns the guards holding every qualification the
+// guidance template requires, unexpired. Without a template the incident's
+// active guidance is used.
+func (s *Service) GetEligibleAssignees(ctx context.Context, incidentID string, rawTemplateID string) (*types.EligibleAssignees, error) {
+	incident, err := s.incidentRepo.GetIncidentByID(ctx, incidentID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("incident")
+	}
+	if rawTemplateID == "" {
+		if incident.IncidentGuidance == nil || incident.IncidentGuidance.GuidanceTemplateID == nil {
+			return nil, errors.NewBadRequestError("guidance_template_id is required when the incident has no guidance")
+		}
+		rawTemplateID = incident.IncidentGuidance.GuidanceTemplateID.String()
+	}
+	guidanceTemplate, err := s.getGuidanceTemplate(ctx, rawTemplateID)
+	if err != nil {
+		return nil, err
+	}
+	guards, err := s.qualificationRepo.GetQualifiedGuards(ctx, guidanceTemplate.ID, time.Now())
+	if err != nil {
+		return nil, errors.NewDatabaseError("get eligible assignees", err)
+	}
+	return &types.EligibleAssignees{
+		GuidanceTemplateID:     guidanceTemplate.ID.String(),
+		RequiredQualifications: guidanceTemplate.RequiredQualifications,
+		Assignees:              guards,
+	}, nil
+}
+
+// checkQualified refuses an assignee who lacks a qualification the guidance
+// template requires or whose certificate has expired.
+func (s *Service) checkQualified(ctx context.Context, guidanceTemplate *models.GuidanceTemplate, assignee *models.User) error {
+	if len(guidanceTemplate.RequiredQualifications) == 0 {
+		return nil
+	}
+	missing, err := s.qualificationRepo.GetMissingQualifications(ctx, guidanceTemplate.ID, assignee.ID, time.Now())
+	if err != nil {
+		return errors.NewDatabaseError("check assignee qualifications", err)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	reasons := make([]string, 0, len(missing))
+	for _, qualification := range missing {
+		if qualification.ExpiresAt != nil {
+			reasons = append(reasons, fmt.Sprintf("%s expired on %s", qualification.Name, qualification.ExpiresAt.Format("2006-01-02")))
+		} else {
+			reasons = append(reasons, qualification.Name+" missing")
+		}
+	}
+	return errors.NewBadRequestError(fmt.Sprintf("%s is not qualified for this guidance: %s", assignee.Name, strings.Join(reasons, ", ")))
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"scs-operator/internal/app/incident/dto"
+	repo "scs-operator/internal/app/incident/repository"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	"scs-operator/pkg/errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var searchFacets = []string{repo.FacetStatus, repo.FacetSeverity, repo.FacetPremise, repo.FacetAssignee, repo.FacetCreated}
+
+// SearchIncidents runs a full-text search over incidents, their comments and
+// linked alarms, and counts the matches per facet.
+func (s *Service) SearchIncidents(ctx context.Context, searchDto *dto.SearchIncidentsDto) (*types.IncidentSearchResponse, error) {
+	incidentFilter, err := s.buildIncidentFilter(ctx, searchDto.IncidentFilterDto)
+	if err != nil {
+		return nil, err
+	}
+	filter := repo.IncidentSearchFilter{IncidentFilter: incidentFilter, Query: strings.TrimSpace(searchDto.Query)}
+	hits, err := s.incidentRepo.SearchIncidents(ctx, filter, searchDto.Page, searchDto.Limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("search incidents", err)
+	}
+	total, err := s.incidentRepo.CountSearchIncidents(ctx, filter)
+	if err != nil {
+		return nil, errors.NewDatabaseError("count incidents", err)
+	}
+	facets := make(map[string][]types.FacetCount, len(searchFacets))
+	for _, facet := range searchFacets {
+		counts, err := s.incidentRepo.GetIncidentFacet(ctx, filter, facet, searchDto.Bucket)
+		if err != nil {
+			return nil, errors.NewDatabaseError("get incident facets", err)
+		}
+		facets[facet] = make([]types.FacetCount, 0, len(counts))
+		for _, count := range counts {
+			facets[facet] = append(facets[facet], types.FacetCount{Value: count.Value, Label: count.Label, Count: count.Count})
+		}
+	}
+
+	data := make([]types.IncidentSearchHit, 0, len(hits))
+	if len(hits) > 0 {
+		ids := make([]uuid.UUID, 0, len(hits))
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		incidents, err := s.incidentRepo.GetIncidentsByIDs(ctx, ids)
+		if err != nil {
+			return nil, errors.NewDatabaseError("get incidents", err)
+		}
+		incidentsByID := make(map[uuid.UUID]models.Incident, len(incidents))
+		for _, incident := range incidents {
+			incidentsByID[incident.ID] = incident
+		}
+		// Keep the ranking of the search
+		for _, hit := range hits {
+			incident, ok := incidentsByID[hit.ID]
+			if !ok {
+				continue
+			}
+			data = append(data, types.IncidentSearchHit{Incident: incident, Rank: hit.Rank, Highlights: searchHighlights(hit)})
+		}
+	}
+
+	totalPages := int(total) / searchDto.Limit
+	if total%int64(searchDto.Limit) != 0 {
+		totalPages++
+	}
+	return &types.IncidentSearchResponse{
+		Data: data,
+		Pagination: types.Pagination{
+			TotalPages: totalPages,
+			Page:       searchDto.Page,
+			Limit:      searchDto.Limit,
+		},
+		Total:  total,
+		Facets: facets,
+	}, nil
+}
+
+// searchHighlights keeps the snippets of the fields that actually matched.
+func searchHighlights(hit repo.IncidentSearchHit) map[string]string {
+	highlights := map[string]string{}
+	for field, snippet := range map[string]string{
+		"name":        hit.NameHighlight,
+		"description": hit.DescriptionHighlight,
+		"location":    hit.LocationHighlight,
+		"comment":     hit.CommentHighlight,
+		"alarm":       hit.AlarmHighlight,
+	} {
+		if strings.Contains(snippet, "<mark>") {
+			highlights[field] = snippet
+		}
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+	return highlights
+}
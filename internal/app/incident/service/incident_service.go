@@ -2,14 +2,24 @@ package services
 
 import (
 	"context"
+	alarmRepositories "scs-operator/internal/app/alarm/repository"
+	guardRepositories "scs-operator/internal/app/guard/repository"
 	guidanceTemplateRepository "scs-operator/internal/app/guidance-template/repository"
 	"scs-operator/internal/app/incident/dto"
 	repo "scs-operator/internal/app/incident/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	qualificationRepositories "scs-operator/internal/app/qualification/repository"
 	userRepositories "scs-operator/internal/app/user/repository"
 	"scs-operator/internal/models"
 	"scs-operator/internal/types"
+	database "scs-operator/pkg/db"
 	"scs-operator/pkg/errors"
+	"scs-operator/pkg/geo"
 	kafka_client "scs-operator/pkg/kafka"
+	"scs-operator/pkg/query"
+	"scs-operator/pkg/stream"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
@@ -19,116 +29,242 @@ type Service struct {
 	incidentRepo             repo.IncidentRepository
 	incidentGuidanceRepo     repo.IncidentGuidanceRepository
 	incidentGuidanceStepRepo repo.IncidentGuidanceStepRepository
-	userRepo                 userRepositories.UserRepository
-	guidanceTemplateRepo     guidanceTemplateRepository.GuidanceTemplateRepository
-	producer                 kafka_client.Producer
+	// incidentGuidanceAssignmentRepo keeps the assignment history of incident guidance
+	incidentGuidanceAssignmentRepo repo.IncidentGuidanceAssignmentRepository
+	incidentActivityRepo           repo.IncidentActivityRepository
+	incidentMediaRepo              repo.IncidentMediaRepository
+	userRepo                       userRepositories.UserRepository
+	guidanceTemplateRepo           guidanceTemplateRepository.GuidanceTemplateRepository
+	alarmRepo                      alarmRepositories.AlarmRepository
+	premiseRepo                    premiseRepositories.PremiseRepository
+	qualificationRepo              qualificationRepositories.QualificationRepository
+	guardRepo                      guardRepositories.GuardRepository
+	transactor                     database.Transactor
+	producer                       kafka_client.Producer
+	broker                         stream.Broker
+	// mediaDir is where uploaded incident media is stored, one directory per incident
+	mediaDir     string
+	maxMediaSize int64
+	// geofenceRadius bounds, in meters, where steps requiring a location may be
+	// completed around premises without a radius of their own
+	geofenceRadius float64
+	// assigneeStrategy picks the assignee of guidance created without one,
+	// weighing guard positions no older than positionMaxAge
+	assigneeStrategy AssigneeStrategy
+	positionMaxAge   time.Duration
 }
 
-func NewIncidentService(incidentRepo repo.IncidentRepository, incidentGuidanceRepo repo.IncidentGuidanceRepository, userRepo userRepositories.UserRepository, guidanceTemplateRepo guidanceTemplateRepository.GuidanceTemplateRepository, incidentGuidanceStepRepo repo.IncidentGuidanceStepRepository, producer kafka_client.Producer) *Service {
-	return &Service{incidentRepo: incidentRepo, incidentGuidanceRepo: incidentGuidanceRepo, userRepo: userRepo, guidanceTemplateRepo: guidanceTemplateRepo, incidentGuidanceStepRepo: incidentGuidanceStepRepo, producer: producer}
+func NewIncidentService(incidentRepo repo.IncidentRepository, incidentGuidanceRepo repo.IncidentGuidanceRepository, userRepo userRepositories.UserRepository, guidanceTemplateRepo guidanceTemplateRepository.GuidanceTemplateRepository, incidentGuidanceStepRepo repo.IncidentGuidanceStepRepository, incidentGuidanceAssignmentRepo repo.IncidentGuidanceAssignmentRepository, incidentActivityRepo repo.IncidentActivityRepository, incidentMediaRepo repo.IncidentMediaRepository, alarmRepo alarmRepositories.AlarmRepository, premiseRepo premiseRepositories.PremiseRepository, qualificationRepo qualificationRepositories.QualificationRepository, guardRepo guardRepositories.GuardRepository, transactor database.Transactor, producer kafka_client.Producer, broker stream.Broker, mediaDir string, maxMediaSize int64, geofenceRadius float64, assigneeStrategy AssigneeStrategy, positionMaxAge time.Duration) *Service {
+	return &Service{incidentRepo: incidentRepo, incidentGuidanceRepo: incidentGuidanceRepo, userRepo: userRepo, guidanceTemplateRepo: guidanceTemplateRepo, incidentGuidanceStepRepo: incidentGuidanceStepRepo, incidentGuidanceAssignmentRepo: incidentGuidanceAssignmentRepo, incidentActivityRepo: incidentActivityRepo, incidentMediaRepo: incidentMediaRepo, alarmRepo: alarmRepo, premiseRepo: premiseRepo, qualificationRepo: qualificationRepo, guardRepo: guardRepo, transactor: transactor, producer: producer, broker: broker, mediaDir: mediaDir, maxMediaSize: maxMediaSize, geofenceRadius: geofenceRadius, assigneeStrategy: assigneeStrategy, positionMaxAge: positionMaxAge}
 }
 
-func (s *Service) CreateIncident(ctx context.Context, createIncidentDto *dto.CreateIncidentDto) (*models.Incident, error) {
+// CreateIncident validates every reference first and then creates the incident,
+// links and dispatches its alarms and materialises its guidance in a single
+// transaction, so a failure at any point leaves nothing behind.
+func (s *Service) CreateIncident(ctx context.Context, actorID string, createIncidentDto *dto.CreateIncidentDto) (*models.Incident, error) {
 	incident := &models.Incident{
 		Name:        createIncidentDto.Name,
 		Description: createIncidentDto.Description,
 		Status:      "new",
 		Severity:    createIncidentDto.Severity,
 		Location:    createIncidentDto.Location,
-		Alarm:       nil,
 	}
-	alarmID, err := uuid.Parse(createIncidentDto.AlarmId)
-
+	position, err := geo.Resolve(createIncidentDto.Latitude, createIncidentDto.Longitude, createIncidentDto.Geometry)
 	if err != nil {
-		return nil, errors.NewBadRequestError("Invalid asset ID format")
+		return nil, errors.NewBadRequestError(err.Error())
 	}
-	incident.AlarmID = alarmID
-
-	createdIncident, err := s.incidentRepo.CreateIncident(ctx, incident)
+	incident.Latitude, incident.Longitude = position.Coordinates()
+	alarms, err := s.getAlarmsToLink(ctx, createIncidentDto.AlarmIDs)
 	if err != nil {
-		return nil, errors.NewDatabaseError("create incident", err)
+		return nil, err
+	}
+	var premise *models.Premise
+	if createIncidentDto.PremiseID != "" {
+		premise, err = s.premiseRepo.GetPremiseByID(ctx, createIncidentDto.PremiseID)
+		if err != nil {
+			return nil, errors.NewNotFoundError("premise")
+		}
+		incident.PremiseID = &premise.ID
+	} else if len(alarms) > 0 && alarms[0].PremiseID != uuid.Nil {
+		// Incidents raised from alarms happen where the first alarm went off
+		incident.PremiseID = &alarms[0].PremiseID
+		if position == nil {
+			premise, _ = s.premiseRepo.GetPremiseByID(ctx, alarms[0].PremiseID.String())
+		}
+	}
+	if position == nil && premise != nil {
+		incident.Latitude, incident.Longitude = premise.Latitude, premise.Longitude
+	}
+	var guidanceTemplate *models.GuidanceTemplate
+	var assignee *models.User
+	var assignmentReason string
+	if createIncidentDto.GuidanceTemplateID != "" && createIncidentDto.Assignee != "" {
+		guidanceTemplate, assignee, err = s.resolveGuidance(ctx, createIncidentDto.GuidanceTemplateID, createIncidentDto.Assignee)
+		if err != nil {
+			return nil, err
+		}
+	} else if createIncidentDto.GuidanceTemplateID != "" {
+		guidanceTemplate, err = s.getPublishedGuidanceTemplate(ctx, createIncidentDto.GuidanceTemplateID)
+		if err != nil {
+			return nil, err
+		}
+		assignee, assignmentReason, err = s.pickAssignee(ctx, incident, guidanceTemplate)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Validate guidance template ID
-	guidanceTemplateID, err := uuid.Parse(createIncidentDto.GuidanceTemplateID)
+	var createdIncident *models.Incident
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		createdIncident, err = s.incidentRepo.CreateIncident(ctx, incident)
+		if err != nil {
+			return errors.NewDatabaseError("create incident", err)
+		}
+		if len(alarms) > 0 {
+			if err := s.linkAlarms(ctx, createdIncident.ID, actorID, alarms); err != nil {
+				return err
+			}
+		}
+		if guidanceTemplate != nil {
+			incidentGuidance, err := s.createGuidance(ctx, createdIncident, guidanceTemplate, assignee, parseActorID(actorID), assignmentReason)
+			if err != nil {
+				return err
+			}
+			if err := s.recordAssignment(ctx, &models.IncidentGuidanceAssignment{
+				IncidentID:         createdIncident.ID,
+				IncidentGuidanceID: incidentGuidance.ID,
+				Action:             "assigned",
+				AssigneeID:         incidentGuidance.AssigneeID,
+				AssignerID:         incidentGuidance.AssignerID,
+				Reason:             assignmentReason,
+			}, assignee); err != nil {
+				return err
+			}
+			// Avoid a cycle when serialising the incident
+			incidentGuidance.Incident = nil
+			createdIncident.IncidentGuidance = incidentGuidance
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.NewBadRequestError("Invalid guidance template ID format")
+		return nil, err
 	}
-	guidanceTemplate, err := s.guidanceTemplateRepo.GetGuidanceTemplateByID(ctx, guidanceTemplateID.String())
-	if err != nil {
-		return nil, errors.NewNotFoundError("guidance template not found")
+	for i := range alarms {
+		alarms[i].Status = "dispatched"
+		alarms[i].LeasedByID, alarms[i].LeaseExpiresAt = nil, nil
 	}
+	createdIncident.Alarms = alarms
+	s.publishIncidentChange(ctx, "incident.created", createdIncident, createdIncident)
+	// Linked alarms were dispatched along with the incident
+	database.AfterCommit(ctx, func() {
+		for i := range alarms {
+			premiseID := ""
+			if alarms[i].PremiseID != uuid.Nil {
+				premiseID = alarms[i].PremiseID.String()
+			}
+			s.broker.Publish("alarm.updated", premiseID, &alarms[i])
+		}
+	})
 
-	incidentGuidance := &models.IncidentGuidance{
-		IncidentID: &incident.ID,
-		Incident:   incident,
+	return createdIncident, nil
+}
+
+// getAlarmsToLink loads the alarms an incident is created for.
+func (s *Service) getAlarmsToLink(ctx context.Context, rawIDs []string) ([]models.Alarm, error) {
+	if len(rawIDs) == 0 {
+		return nil, nil
 	}
-	incidentGuidance.GuidanceTemplateID = &guidanceTemplate.ID
-	incidentGuidance.GuidanceTemplate = guidanceTemplate
-	// Validate guidance assignee
-	assigneeId, err := uuid.Parse(createIncidentDto.Assignee)
-	if err != nil {
-		return nil, errors.NewBadRequestError("Invalid assignee ID format")
+	ids := make([]uuid.UUID, 0, len(rawIDs))
+	for _, rawID := range rawIDs {
+		id, err := uuid.Parse(rawID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid alarm ID format")
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
 	}
-	assigneeInfo, err := s.userRepo.GetUserByID(ctx, assigneeId.String())
+	alarms, err := s.alarmRepo.GetAlarmsByIDs(ctx, ids)
 	if err != nil {
-		return nil, errors.NewNotFoundError("assignee not found")
+		return nil, errors.NewDatabaseError("get alarms", err)
 	}
-	incidentGuidance.AssigneeID = &assigneeInfo.ID
-	incidentGuidance.Assignee = assigneeInfo
-
-	createdIncidentGuidance, err := s.incidentGuidanceRepo.CreateIncidentGuidance(ctx, incidentGuidance)
-	if err != nil {
-		return nil, errors.NewDatabaseError("assign guidance", err)
+	if len(alarms) != len(ids) {
+		return nil, errors.NewNotFoundError("alarm")
 	}
-	steps := []models.IncidentGuidanceStep{}
-	for _, step := range guidanceTemplate.GuidanceSteps {
-		steps = append(steps, models.IncidentGuidanceStep{
-			IncidentGuidanceID: createdIncidentGuidance.ID,
-			StepNumber:         int64(step.StepNumber),
-			Title:              step.Title,
-			Description:        step.Description,
-			IsCompleted:        false,
-		})
-	}
-
-	_, _ = s.incidentGuidanceStepRepo.CreateIncidentGuidanceSteps(ctx, steps)
-	// Send Kafka message
-	// producerMessage := kafka.Message{
-	// 	Key:   []byte(incident.ID.String()),
-	// 	Value: []byte("Incident guidance assigned"),
-	// }
-	// if err := s.producer.WriteMessages(ctx, producerMessage); err != nil {
-	// 	return nil, errors.NewAppError(errors.ErrorTypeInternal, "Failed to send Kafka message", err)
-	// }
-
-	return createdIncident, nil
+	return alarms, nil
 }
 
-func (s *Service) GetIncidents(ctx context.Context, page int, limit int) (*types.PaginateResponse[models.Incident], error) {
-	incidents, err := s.incidentRepo.GetIncidents(ctx, page, limit)
+// linkAlarms links the alarms to the incident and dispatches them, releasing
+// any operator lease since the alarms leave the queue.
+func (s *Service) linkAlarms(ctx context.Context, incidentID uuid.UUID, actorID string, alarms []models.Alarm) error {
+	linkedBy := parseActorID(actorID)
+	ids := make([]uuid.UUID, 0, len(alarms))
+	links := make([]models.IncidentAlarm, 0, len(alarms))
+	for _, alarm := range alarms {
+		ids = append(ids, alarm.ID)
+		links = append(links, models.IncidentAlarm{IncidentID: incidentID, AlarmID: alarm.ID, LinkedByID: linkedBy})
+	}
+	if err := s.alarmRepo.LinkAlarmsToIncident(ctx, links); err != nil {
+		return errors.NewDatabaseError("link alarms", err)
+	}
+	if err := s.alarmRepo.UpdateAlarmsFields(ctx, ids, map[string]interface{}{
+		"status":           "dispatched",
+		"leased_by_id":     nil,
+		"lease_expires_at": nil,
+	}); err != nil {
+		return errors.NewDatabaseError("dispatch alarms", err)
+	}
+	return nil
+}
 
+func (s *Service) GetIncidents(ctx context.Context, getIncidentsDto *dto.GetIncidentsDto) (*types.PaginateResponse[models.Incident], error) {
+	params, err := query.New(repo.IncidentSchema, getIncidentsDto.Request)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	filter, err := s.buildIncidentFilter(ctx, getIncidentsDto.IncidentFilterDto)
+	if err != nil {
+		return nil, err
+	}
+	incidents, page, err := s.incidentRepo.GetIncidents(ctx, filter, params)
 	if err != nil {
 		return nil, errors.NewDatabaseError("get incidents", err)
 	}
-	total, err := s.incidentRepo.GetIncidentsCount(ctx)
-	totalPages := int(total) / limit
-	if total%int64(limit) != 0 {
-		totalPages++
+	if params.IncludeTotal {
+		total, err := s.incidentRepo.GetIncidentsCount(ctx, filter)
+		if err != nil {
+			return nil, errors.NewDatabaseError("get incidents count", err)
+		}
+		page.Total = &total
 	}
+	return types.NewPaginateResponse(incidents, page), nil
+}
 
-	if err != nil {
-		return nil, errors.NewDatabaseError("get incidents count", err)
+// buildIncidentFilter resolves the sub-premises and parses the date range.
+func (s *Service) buildIncidentFilter(ctx context.Context, filterDto dto.IncidentFilterDto) (repo.IncidentFilter, error) {
+	filter := repo.IncidentFilter{
+		Status:     filterDto.Status,
+		Severity:   filterDto.Severity,
+		AssigneeID: filterDto.AssigneeID,
 	}
-	paginateResponse := types.PaginateResponse[models.Incident]{
-		Pagination: types.Pagination{
-			TotalPages: int(totalPages),
-			Page:       page,
-			Limit:      limit,
-		},
-		Data: incidents,
+	if filterDto.PremiseID != "" {
+		filter.PremiseIDs = []uuid.UUID{uuid.MustParse(filterDto.PremiseID)}
+		if filterDto.IncludeSubPremises {
+			ids, err := s.premiseRepo.GetDescendantIDs(ctx, filterDto.PremiseID)
+			if err != nil {
+				return filter, errors.NewDatabaseError("get sub-premises", err)
+			}
+			filter.PremiseIDs = ids
+		}
 	}
-	return &paginateResponse, nil
+	var err error
+	if filter.CreatedFrom, err = query.ParseTime("created_from", filterDto.CreatedFrom); err != nil {
+		return filter, errors.NewBadRequestError(err.Error())
+	}
+	if filter.CreatedTo, err = query.ParseTime("created_to", filterDto.CreatedTo); err != nil {
+		return filter, errors.NewBadRequestError(err.Error())
+	}
+	return filter, nil
 }
 
 func (s *Service) GetIncidentByID(ctx context.Context, id string) (*models.Incident, error) {
@@ -140,7 +276,7 @@ func (s *Service) GetIncidentByID(ctx context.Context, id string) (*models.Incid
 	return incident, nil
 }
 
-func (s *Service) AssignGuidance(ctx context.Context, incidentID string, assignGuidanceDto *dto.AssignGuidance) (*models.IncidentGuidance, error) {
+func (s *Service) AssignGuidance(ctx context.Context, incidentID string, actorID string, assignGuidanceDto *dto.AssignGuidance) (*models.IncidentGuidance, error) {
 	incident, err := s.incidentRepo.GetIncidentByID(ctx, incidentID)
 	if err != nil {
 		return nil, errors.NewNotFoundError("incident not found")
@@ -148,59 +284,176 @@ func (s *Service) AssignGuidance(ctx context.Context, incidentID string, assignG
 	if incident == nil {
 		return nil, errors.NewNotFoundError("incident not found")
 	}
-	incidentGuidance := &models.IncidentGuidance{
-		IncidentID: &incident.ID,
-		Incident:   incident,
+	if incident.IncidentGuidance != nil {
+		return nil, errors.NewConflictError("Incident already has guidance, reassign or replace it instead")
+	}
+	guidanceTemplate, assignee, err := s.resolveGuidance(ctx, assignGuidanceDto.GuidanceTemplateID, assignGuidanceDto.Assignee)
+	if err != nil {
+		return nil, err
+	}
+
+	var createdIncidentGuidance *models.IncidentGuidance
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		createdIncidentGuidance, err = s.createGuidance(ctx, incident, guidanceTemplate, assignee, parseActorID(actorID), "")
+		if err != nil {
+			return err
+		}
+		return s.recordAssignment(ctx, &models.IncidentGuidanceAssignment{
+			IncidentID:         incident.ID,
+			IncidentGuidanceID: createdIncidentGuidance.ID,
+			Action:             "assigned",
+			AssigneeID:         createdIncidentGuidance.AssigneeID,
+			AssignerID:         createdIncidentGuidance.AssignerID,
+		}, assignee)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyGuidanceAssigned(ctx, incident.ID)
+	s.publishIncidentChange(ctx, "incident.guidance_updated", incident, createdIncidentGuidance)
+
+	return createdIncidentGuidance, nil
+}
+
+// resolveGuidance validates the guidance template and assignee of a guidance
+// assignment. The assignee must be qualified for the template.
+func (s *Service) resolveGuidance(ctx context.Context, rawTemplateID string, rawAssigneeID string) (*models.GuidanceTemplate, *models.User, error) {
+	guidanceTemplate, err := s.getPublishedGuidanceTemplate(ctx, rawTemplateID)
+	if err != nil {
+		return nil, nil, err
+	}
+	assignee, err := s.getAssignee(ctx, rawAssigneeID)
+	if err != nil {
+		return nil, nil, err
 	}
+	if err := s.checkQualified(ctx, guidanceTemplate, assignee); err != nil {
+		return nil, nil, err
+	}
+	return guidanceTemplate, assignee, nil
+}
+
+func (s *Service) getGuidanceTemplate(ctx context.Context, rawTemplateID string) (*models.GuidanceTemplate, error) {
 	// Validate guidance template ID
-	guidanceTemplateID, err := uuid.Parse(assignGuidanceDto.GuidanceTemplateID)
+	guidanceTemplateID, err := uuid.Parse(rawTemplateID)
 	if err != nil {
 		return nil, errors.NewBadRequestError("Invalid guidance template ID format")
 	}
 	guidanceTemplate, err := s.guidanceTemplateRepo.GetGuidanceTemplateByID(ctx, guidanceTemplateID.String())
 	if err != nil {
-		return nil, errors.NewNotFoundError("guidance template not found")
+		return nil, errors.NewNotFoundError("guidance template")
 	}
-	incidentGuidance.GuidanceTemplateID = &guidanceTemplate.ID
-	incidentGuidance.GuidanceTemplate = guidanceTemplate
+	return guidanceTemplate, nil
+}
+
+// getPublishedGuidanceTemplate returns a template guidance can be created
+// from, which is one that was published.
+func (s *Service) getPublishedGuidanceTemplate(ctx context.Context, rawTemplateID string) (*models.GuidanceTemplate, error) {
+	guidanceTemplate, err := s.getGuidanceTemplate(ctx, rawTemplateID)
+	if err != nil {
+		return nil, err
+	}
+	if guidanceTemplate.PublishedVersion == nil {
+		return nil, errors.NewBadRequestError("Guidance template " + guidanceTemplate.Name + " has no published version")
+	}
+	return guidanceTemplate, nil
+}
+
+func (s *Service) getAssignee(ctx context.Context, rawAssigneeID string) (*models.User, error) {
 	// Validate guidance assignee
-	assigneeId, err := uuid.Parse(assignGuidanceDto.Assignee)
+	assigneeID, err := uuid.Parse(rawAssigneeID)
 	if err != nil {
 		return nil, errors.NewBadRequestError("Invalid assignee ID format")
 	}
-	assigneeInfo, err := s.userRepo.GetUserByID(ctx, assigneeId.String())
+	assignee, err := s.userRepo.GetUserByID(ctx, assigneeID.String())
 	if err != nil {
-		return nil, errors.NewNotFoundError("assignee not found")
+		return nil, errors.NewNotFoundError("assignee")
 	}
-	incidentGuidance.AssigneeID = &assigneeInfo.ID
-	incidentGuidance.Assignee = assigneeInfo
+	return assignee, nil
+}
 
+// createGuidance assigns the template to the incident and copies the steps of
+// its published version onto it, recording the version. Callers run it inside
+// a transaction so the guidance never exists without its steps.
+func (s *Service) createGuidance(ctx context.Context, incident *models.Incident, guidanceTemplate *models.GuidanceTemplate, assignee *models.User, assignerID *uuid.UUID, assignmentReason string) (*models.IncidentGuidance, error) {
+	incidentGuidance := &models.IncidentGuidance{
+		IncidentID:                &incident.ID,
+		GuidanceTemplateID:        &guidanceTemplate.ID,
+		GuidanceTemplateVersionID: &guidanceTemplate.PublishedVersion.ID,
+		TemplateVersion:           guidanceTemplate.PublishedVersion.Version,
+		AssigneeID:                &assignee.ID,
+		AssignerID:                assignerID,
+		AssignmentReason:          assignmentReason,
+		Status:                    "active",
+	}
+	incidentGuidance.ID = uuid.New()
+	steps := copyGuidanceSteps(incidentGuidance.ID, guidanceTemplate.PublishedVersion.Steps)
+	incidentGuidance.NextStepID, _ = stepFlow(steps)
 	createdIncidentGuidance, err := s.incidentGuidanceRepo.CreateIncidentGuidance(ctx, incidentGuidance)
 	if err != nil {
 		return nil, errors.NewDatabaseError("assign guidance", err)
 	}
-	steps := []models.IncidentGuidanceStep{}
-	for _, step := range guidanceTemplate.GuidanceSteps {
-		steps = append(steps, models.IncidentGuidanceStep{
-			IncidentGuidanceID: createdIncidentGuidance.ID,
-			StepNumber:         int64(step.StepNumber),
-			Title:              step.Title,
-			Description:        step.Description,
-			IsCompleted:        false,
-		})
+	createdSteps, err := s.incidentGuidanceStepRepo.CreateIncidentGuidanceSteps(ctx, steps)
+	if err != nil {
+		return nil, errors.NewDatabaseError("create guidance steps", err)
 	}
+	createdIncidentGuidance.Incident = incident
+	createdIncidentGuidance.GuidanceTemplate = guidanceTemplate
+	createdIncidentGuidance.Assignee = assignee
+	createdIncidentGuidance.IncidentGuidanceSteps = createdSteps
+	return createdIncidentGuidance, nil
+}
 
-	// _, _ = s.incidentGuidanceStepRepo.CreateIncidentGuidanceSteps(ctx, steps)
-	// Send Kafka message
+// recordAssignment adds the assignment to the guidance history and the
+// incident's activity feed.
+func (s *Service) recordAssignment(ctx context.Context, assignment *models.IncidentGuidanceAssignment, assignee *models.User) error {
+	if _, err := s.incidentGuidanceAssignmentRepo.CreateIncidentGuidanceAssignment(ctx, assignment); err != nil {
+		return errors.NewDatabaseError("record guidance assignment", err)
+	}
+	message := "Guidance " + assignment.Action + ", assigned to " + assignee.Name
+	if assignment.Reason != "" {
+		message += ": " + assignment.Reason
+	}
+	details := map[string]interface{}{
+		"incident_guidance_id": assignment.IncidentGuidanceID.String(),
+		"assignee_id":          assignee.ID.String(),
+	}
+	if assignment.PreviousAssigneeID != nil {
+		details["previous_assignee_id"] = assignment.PreviousAssigneeID.String()
+	}
+	if assignment.PreviousGuidanceID != nil {
+		details["previous_guidance_id"] = assignment.PreviousGuidanceID.String()
+	}
+	return s.recordActivity(ctx, assignment.IncidentID, assignment.AssignerID, "guidance_"+assignment.Action, message, details)
+}
+
+// notifyGuidanceAssigned announces a guidance assignment once it is committed.
+// A failed notification must not fail the assignment.
+func (s *Service) notifyGuidanceAssigned(ctx context.Context, incidentID uuid.UUID) {
 	producerMessage := kafka.Message{
-		Key:   []byte(incident.ID.String()),
+		Key:   []byte(incidentID.String()),
 		Value: []byte("Incident guidance assigned"),
 	}
-	if err := s.producer.WriteMessages(ctx, producerMessage); err != nil {
-		return nil, errors.NewAppError(errors.ErrorTypeInternal, "Failed to send Kafka message", err)
+	database.AfterCommit(ctx, func() { _ = s.producer.WriteMessages(ctx, producerMessage) })
+}
+
+// publishIncidentChange pushes a change of the incident to streaming clients
+// once it is committed, data being the changed entity. Escalations and synced
+// operations change incidents inside their own transaction.
+func (s *Service) publishIncidentChange(ctx context.Context, eventType string, incident *models.Incident, data interface{}) {
+	premiseID := ""
+	if incident.PremiseID != nil {
+		premiseID = incident.PremiseID.String()
 	}
+	database.AfterCommit(ctx, func() { s.broker.Publish(eventType, premiseID, data) })
+}
 
-	return createdIncidentGuidance, nil
+// parseActorID returns the caller's user ID, or nil when the caller is unknown.
+func parseActorID(actorID string) *uuid.UUID {
+	parsedActorID, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil
+	}
+	return &parsedActorID
 }
 func (s *Service) GetIncidentGuidance(ctx context.Context, incidentID string) (*models.IncidentGuidance, error) {
 	incidentGuidance, err := s.incidentGuidanceRepo.GetIncidentGuidanceByIncidentID(ctx, incidentID)
@@ -210,28 +463,56 @@ func (s *Service) GetIncidentGuidance(ctx context.Context, incidentID string) (*
 	return incidentGuidance, nil
 }
 
-func (s *Service) UpdateIncident(ctx context.Context, id string, updateIncidentDto *dto.UpdateIncidentDto) (*models.Incident, error) {
+func (s *Service) UpdateIncident(ctx context.Context, id string, actorID string, updateIncidentDto *dto.UpdateIncidentDto) (*models.Incident, error) {
 	incident, err := s.incidentRepo.GetIncidentByID(ctx, id)
 	if err != nil {
 		return nil, errors.NewNotFoundError("incident not found")
 	}
-	incident.Status = updateIncidentDto.Status
-	updatedIncident, err := s.incidentRepo.UpdateIncident(ctx, id, incident)
+	var updatedIncident *models.Incident
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		updatedIncident, err = s.changeStatus(ctx, incident, actorID, updateIncidentDto.Status)
+		return err
+	})
 	if err != nil {
-		return nil, errors.NewDatabaseError("update incident", err)
+		return nil, err
 	}
+	s.publishIncidentChange(ctx, "incident.updated", updatedIncident, updatedIncident)
 	return updatedIncident, nil
 }
 
-func (s *Service) CompleteIncident(ctx context.Context, incidentID string) error {
+func (s *Service) CompleteIncident(ctx context.Context, incidentID string, actorID string) error {
 	incident, err := s.incidentRepo.GetIncidentByID(ctx, incidentID)
 	if err != nil {
 		return errors.NewNotFoundError("incident not found")
 	}
-	incident.Status = "resolved"
-	_, err = s.incidentRepo.UpdateIncident(ctx, incidentID, incident)
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := s.changeStatus(ctx, incident, actorID, "resolved")
+		return err
+	})
 	if err != nil {
-		return errors.NewDatabaseError("update incident", err)
+		return err
 	}
+	s.publishIncidentChange(ctx, "incident.updated", incident, incident)
 	return nil
 }
+
+// changeStatus updates the incident status and records the change in the
+// activity feed when the status actually changes.
+func (s *Service) changeStatus(ctx context.Context, incident *models.Incident, actorID string, status string) (*models.Incident, error) {
+	previousStatus := incident.Status
+	incident.Status = status
+	updatedIncident, err := s.incidentRepo.UpdateIncident(ctx, incident.ID.String(), incident)
+	if err != nil {
+		return nil, errors.NewDatabaseError("update incident", err)
+	}
+	if previousStatus == status {
+		return updatedIncident, nil
+	}
+	if err := s.recordActivity(ctx, incident.ID, parseActorID(actorID), ActivityStatusChanged, "Status changed from "+previousStatus+" to "+status, map[string]interface{}{
+		"from": previousStatus,
+		"to":   status,
+	}); err != nil {
+		return nil, err
+	}
+	return updatedIncident, nil
+}
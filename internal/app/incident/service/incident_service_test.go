@@ -0,0 +1,842 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	alarmRepositories "scs-operator/internal/app/alarm/repository"
+	guardRepositories "scs-operator/internal/app/guard/repository"
+	guidanceTemplateRepository "scs-operator/internal/app/guidance-template/repository"
+	"scs-operator/internal/app/incident/dto"
+	repo "scs-operator/internal/app/incident/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	qualificationRepositories "scs-operator/internal/app/qualification/repository"
+	userRepositories "scs-operator/internal/app/user/repository"
+	"scs-operator/internal/testsupport"
+	"scs-operator/internal/types"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/query"
+	"scs-operator/pkg/stream"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/gorm"
+)
+
+const (
+	testIncidentID      = "0b6c2a4e-55f1-4f1e-9f55-3f0a7f3b2c01"
+	testTemplateID      = "1c7d3b5f-66a2-4a2f-8a66-4a1b8a4c3d02"
+	testAssigneeID      = "2d8e4c6a-77b3-4b3a-9b77-5b2c9b5d4e03"
+	testAlarmID         = "3e9f5d7b-88c4-4c4b-8c88-6c3d0c6e5f04"
+	testGuidanceID      = "4f0a6e8c-99d5-4d5c-9d99-7d4e1d7f6a05"
+	testAssignmentID    = "9e5f1d3b-ee5a-4cab-8cee-2c9d6c2e1f10"
+	testActivityID      = "af6a2e4c-ff6b-4dbc-9dff-3d0e7d3f2a11"
+	testQualificationID = "b07b3f5d-007c-4ecd-8e00-4e1f8e4a3b12"
+	testPremiseID       = "c18c4a6e-118d-4fde-9f11-5f2a9f5b4c13"
+	testVersionID       = "d29d5b7f-229e-4aef-8a22-6a3bab6c5d14"
+)
+
+// The incident service cannot use incidenttest, which imports this package
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := testsupport.NewMockDB(t)
+	svc := NewIncidentService(
+		*repo.NewIncidentRepository(db),
+		*repo.NewIncidentGuidanceRepository(db),
+		*userRepositories.NewUserRepository(db),
+		*guidanceTemplateRepository.NewGuidanceTemplateRepository(db),
+		*repo.NewIncidentGuidanceStepRepository(db),
+		*repo.NewIncidentGuidanceAssignmentRepository(db),
+		*repo.NewIncidentActivityRepository(db),
+		*repo.NewIncidentMediaRepository(db),
+		*alarmRepositories.NewAlarmRepository(db),
+		*premiseRepositories.NewPremiseRepository(db),
+		*qualificationRepositories.NewQualificationRepository(db),
+		*guardRepositories.NewGuardRepository(db),
+		*database.NewTransactor(db),
+		testsupport.NewProducer(t),
+		*stream.NewBroker(10),
+		t.TempDir(),
+		1<<20,
+		200,
+		WeightedAssigneeStrategy{OpenIncidentCost: 10, OffDutyCost: 25, DistanceCost: 1, UnknownDistance: 20},
+		15*time.Minute,
+	)
+	return svc, mock
+}
+
+// expectTemplateLookup looks up a template whose draft has a step more than
+// its published version 2.
+func expectTemplateLookup(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_templates" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "published_version_id"}).AddRow(testTemplateID, "Fire", testVersionID))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_steps" WHERE "guidance_steps"."guidance_template_id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "guidance_template_id", "step_number", "title"}).
+			AddRow("5a1b7f9d-aa16-4e6d-8eaa-8e5f2e8a7b06", testTemplateID, 1, "Call fire brigade").
+			AddRow("6b2c8a0e-bb27-4f7e-9fbb-9f6a3f9b8c07", testTemplateID, 2, "Evacuate").
+			AddRow("7c3d9b1f-cc38-4a8f-8acc-0a7b4a0c9d08", testTemplateID, 3, "Unpublished draft step"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_template_versions" WHERE "guidance_template_versions"."id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "guidance_template_id", "version", "name", "steps"}).
+			AddRow(testVersionID, testTemplateID, 2, "Fire", `[
+				{"id": "5a1b7f9d-aa16-4e6d-8eaa-8e5f2e8a7b06", "step_number": 1, "title": "Call fire brigade"},
+				{"id": "6b2c8a0e-bb27-4f7e-9fbb-9f6a3f9b8c07", "step_number": 2, "title": "Evacuate"}
+			]`))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_template_qualifications" WHERE "guidance_template_qualifications"."guidance_template_id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"guidance_template_id", "qualification_id"}))
+}
+
+// expectQualifiedTemplateLookup looks up a template requiring one qualification.
+func expectQualifiedTemplateLookup(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_templates" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "published_version_id"}).AddRow(testTemplateID, "Fire", testVersionID))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_steps" WHERE "guidance_steps"."guidance_template_id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "guidance_template_id", "step_number", "title"}).
+			AddRow("5a1b7f9d-aa16-4e6d-8eaa-8e5f2e8a7b06", testTemplateID, 1, "Call fire brigade").
+			AddRow("6b2c8a0e-bb27-4f7e-9fbb-9f6a3f9b8c07", testTemplateID, 2, "Evacuate").
+			AddRow("7c3d9b1f-cc38-4a8f-8acc-0a7b4a0c9d08", testTemplateID, 3, "Unpublished draft step"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_template_versions" WHERE "guidance_template_versions"."id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "guidance_template_id", "version", "name", "steps"}).
+			AddRow(testVersionID, testTemplateID, 2, "Fire", `[
+				{"id": "5a1b7f9d-aa16-4e6d-8eaa-8e5f2e8a7b06", "step_number": 1, "title": "Call fire brigade"},
+				{"id": "6b2c8a0e-bb27-4f7e-9fbb-9f6a3f9b8c07", "step_number": 2, "title": "Evacuate"}
+			]`))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_template_qualifications" WHERE "guidance_template_qualifications"."guidance_template_id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"guidance_template_id", "qualification_id"}).AddRow(testTemplateID, testQualificationID))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "qualifications" WHERE "qualifications"."id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testQualificationID, "Fire marshal"))
+}
+
+func expectAssigneeLookup(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "users" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testAssigneeID, "Guard"))
+}
+
+func expectAlarmLookup(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "alarms" WHERE id IN ($1)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "severity"}).AddRow(testAlarmID, "new", "high"))
+}
+
+func expectIncidentLookup(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incidents" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).AddRow(testIncidentID, "Fire", "new"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_alarms" WHERE "incident_alarms"."incident_id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"incident_id", "alarm_id"}))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_guidances" WHERE "incident_guidances"."incident_id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_media" WHERE "incident_media"."incident_id" = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func expectInsert(mock sqlmock.Sqlmock, table string, id string, err error) {
+	expectation := mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "` + table + `"`))
+	if err != nil {
+		expectation.WillReturnError(err)
+		return
+	}
+	expectation.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+}
+
+func expectStepsInsert(mock sqlmock.Sqlmock, err error) {
+	expectation := mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "incident_guidance_steps"`))
+	if err != nil {
+		expectation.WillReturnError(err)
+		return
+	}
+	expectation.WillReturnRows(sqlmock.NewRows([]string{"id"}).
+		AddRow("7c3d9b1f-cc38-4a8f-8acc-0a7b4a0c9d08").
+		AddRow("8d4e0c2a-dd49-4b9a-9bdd-1b8c5b1d0e09"))
+}
+
+func TestCreateIncident(t *testing.T) {
+	validDto := func() *dto.CreateIncidentDto {
+		return &dto.CreateIncidentDto{
+			Name:               "Fire in warehouse",
+			Description:        "Smoke detected",
+			AlarmIDs:           []string{testAlarmID},
+			Severity:           "high",
+			Location:           "Warehouse 4",
+			GuidanceTemplateID: testTemplateID,
+			Assignee:           testAssigneeID,
+		}
+	}
+	// expectValidation queues the lookups made before the transaction starts
+	expectValidation := func(mock sqlmock.Sqlmock) {
+		expectAlarmLookup(mock)
+		expectTemplateLookup(mock)
+		expectAssigneeLookup(mock)
+	}
+
+	tests := []struct {
+		name           string
+		modify         func(*dto.CreateIncidentDto)
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name:   "Success commits every write",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectValidation(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", testIncidentID, nil)
+				testsupport.ExpectExec(mock, `INSERT INTO "incident_alarms"`, nil)
+				testsupport.ExpectExec(mock, `UPDATE "alarms" SET`, nil)
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, nil)
+				expectInsert(mock, "incident_guidance_assignments", testAssignmentID, nil)
+				expectInsert(mock, "incident_activities", testActivityID, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Success without alarms or guidance",
+			modify: func(d *dto.CreateIncidentDto) {
+				d.AlarmIDs, d.GuidanceTemplateID, d.Assignee = nil, "", ""
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", testIncidentID, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Without an assignee picks a guard of the premise",
+			modify: func(d *dto.CreateIncidentDto) {
+				d.AlarmIDs, d.PremiseID, d.Assignee = nil, testPremiseID, ""
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "latitude", "longitude"}).AddRow(testPremiseID, "Warehouse", 52.37, 4.89))
+				expectTemplateLookup(mock)
+				mock.ExpectQuery(`(?s)SELECT users.id AS user_id.*FROM "users" LEFT JOIN guard_positions`).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "open_incidents", "on_duty", "latitude", "longitude"}).
+						AddRow("d29d5b7f-229e-4aef-8a22-6a3b0a6c5d14", "Busy", 2, true, 52.37, 4.89).
+						AddRow(testAssigneeID, "Guard", 0, true, 52.38, 4.89))
+				expectAssigneeLookup(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", testIncidentID, nil)
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, nil)
+				expectInsert(mock, "incident_guidance_assignments", testAssignmentID, nil)
+				expectInsert(mock, "incident_activities", testActivityID, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Without an assignee and a qualified guard writes nothing",
+			modify: func(d *dto.CreateIncidentDto) {
+				d.AlarmIDs, d.PremiseID, d.Assignee = nil, testPremiseID, ""
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testPremiseID, "Warehouse"))
+				expectTemplateLookup(mock)
+				mock.ExpectQuery(`(?s)SELECT users.id AS user_id.*FROM "users" LEFT JOIN guard_positions`).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "name", "open_incidents", "on_duty"}))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Without an assignee or a premise writes nothing",
+			modify: func(d *dto.CreateIncidentDto) { d.Assignee = "" },
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAlarmLookup(mock)
+				expectTemplateLookup(mock)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid guidance template ID writes nothing",
+			modify:         func(d *dto.CreateIncidentDto) { d.GuidanceTemplateID = "not-a-uuid" },
+			expect:         expectAlarmLookup,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Unknown guidance template writes nothing",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAlarmLookup(mock)
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_templates"`)).WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Unknown assignee writes nothing",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAlarmLookup(mock)
+				expectTemplateLookup(mock)
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "users"`)).WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Unknown alarm writes nothing",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "alarms"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Incident insert failure rolls back",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectValidation(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", "", testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "Alarm link failure rolls back",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectValidation(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", testIncidentID, nil)
+				testsupport.ExpectExec(mock, `INSERT INTO "incident_alarms"`, testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "Alarm dispatch failure rolls back",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectValidation(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", testIncidentID, nil)
+				testsupport.ExpectExec(mock, `INSERT INTO "incident_alarms"`, nil)
+				testsupport.ExpectExec(mock, `UPDATE "alarms" SET`, testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "Guidance insert failure rolls back",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectValidation(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", testIncidentID, nil)
+				testsupport.ExpectExec(mock, `INSERT INTO "incident_alarms"`, nil)
+				testsupport.ExpectExec(mock, `UPDATE "alarms" SET`, nil)
+				expectInsert(mock, "incident_guidances", "", testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "Step copy failure rolls back",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectValidation(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", testIncidentID, nil)
+				testsupport.ExpectExec(mock, `INSERT INTO "incident_alarms"`, nil)
+				testsupport.ExpectExec(mock, `UPDATE "alarms" SET`, nil)
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "Assignment history failure rolls back",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectValidation(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", testIncidentID, nil)
+				testsupport.ExpectExec(mock, `INSERT INTO "incident_alarms"`, nil)
+				testsupport.ExpectExec(mock, `UPDATE "alarms" SET`, nil)
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, nil)
+				expectInsert(mock, "incident_guidance_assignments", "", testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:   "Activity feed failure rolls back",
+			modify: func(*dto.CreateIncidentDto) {},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectValidation(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incidents", testIncidentID, nil)
+				testsupport.ExpectExec(mock, `INSERT INTO "incident_alarms"`, nil)
+				testsupport.ExpectExec(mock, `UPDATE "alarms" SET`, nil)
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, nil)
+				expectInsert(mock, "incident_guidance_assignments", testAssignmentID, nil)
+				expectInsert(mock, "incident_activities", "", testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			createIncidentDto := validDto()
+			tt.modify(createIncidentDto)
+
+			incident, err := svc.CreateIncident(context.Background(), testAssigneeID, createIncidentDto)
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				if incident != nil {
+					t.Errorf("expected no incident on failure, got %+v", incident)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if incident.ID.String() != testIncidentID {
+				t.Errorf("expected incident %s, got %s", testIncidentID, incident.ID)
+			}
+			if createIncidentDto.GuidanceTemplateID != "" && len(incident.IncidentGuidance.IncidentGuidanceSteps) != 2 {
+				t.Errorf("expected 2 guidance steps, got %d", len(incident.IncidentGuidance.IncidentGuidanceSteps))
+			}
+			if createIncidentDto.GuidanceTemplateID != "" && createIncidentDto.Assignee == "" {
+				guidance := incident.IncidentGuidance
+				if guidance.AssigneeID.String() != testAssigneeID {
+					t.Errorf("expected the idle guard %s to be picked, got %s", testAssigneeID, guidance.AssigneeID)
+				}
+				if !strings.Contains(guidance.AssignmentReason, "0 open incidents") {
+					t.Errorf("expected the reason of the choice, got %q", guidance.AssignmentReason)
+				}
+			}
+			for _, alarm := range incident.Alarms {
+				if alarm.Status != "dispatched" {
+					t.Errorf("expected linked alarm to be dispatched, got %s", alarm.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestAssignGuidance(t *testing.T) {
+	tests := []struct {
+		name           string
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name: "Success copies the steps of the published version",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				expectTemplateLookup(mock)
+				expectAssigneeLookup(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, nil)
+				expectInsert(mock, "incident_guidance_assignments", testAssignmentID, nil)
+				expectInsert(mock, "incident_activities", testActivityID, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Unpublished template writes nothing",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_templates" WHERE id = $1`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testTemplateID, "Fire"))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_steps"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "guidance_template_id", "step_number", "title"}))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_template_qualifications"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"guidance_template_id", "qualification_id"}))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Unknown assignee writes nothing",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				expectTemplateLookup(mock)
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "users"`)).WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "Qualified assignee is assigned",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				expectQualifiedTemplateLookup(mock)
+				expectAssigneeLookup(mock)
+				mock.ExpectQuery(`(?s)FROM "qualifications" JOIN guidance_template_qualifications`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "expires_at"}))
+				mock.ExpectBegin()
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, nil)
+				expectInsert(mock, "incident_guidance_assignments", testAssignmentID, nil)
+				expectInsert(mock, "incident_activities", testActivityID, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Unqualified assignee writes nothing",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				expectQualifiedTemplateLookup(mock)
+				expectAssigneeLookup(mock)
+				mock.ExpectQuery(`(?s)FROM "qualifications" JOIN guidance_template_qualifications`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "expires_at"}).AddRow(testQualificationID, "Fire marshal", nil))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Expired qualification writes nothing",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				expectQualifiedTemplateLookup(mock)
+				expectAssigneeLookup(mock)
+				mock.ExpectQuery(`(?s)FROM "qualifications" JOIN guidance_template_qualifications`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "expires_at"}).
+						AddRow(testQualificationID, "Fire marshal", time.Now().Add(-24*time.Hour)))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Guidance insert failure rolls back",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				expectTemplateLookup(mock)
+				expectAssigneeLookup(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incident_guidances", "", testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "Step copy failure rolls back",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				expectTemplateLookup(mock)
+				expectAssigneeLookup(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "Assignment history failure rolls back",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				expectTemplateLookup(mock)
+				expectAssigneeLookup(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, nil)
+				expectInsert(mock, "incident_guidance_assignments", "", testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "Activity feed failure rolls back",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				expectTemplateLookup(mock)
+				expectAssigneeLookup(mock)
+				mock.ExpectBegin()
+				expectInsert(mock, "incident_guidances", testGuidanceID, nil)
+				expectStepsInsert(mock, nil)
+				expectInsert(mock, "incident_guidance_assignments", testAssignmentID, nil)
+				expectInsert(mock, "incident_activities", "", testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			guidance, err := svc.AssignGuidance(ctx, testIncidentID, testAssigneeID, &dto.AssignGuidance{
+				GuidanceTemplateID: testTemplateID,
+				Assignee:           testAssigneeID,
+			})
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(guidance.IncidentGuidanceSteps) != 2 {
+				t.Errorf("expected 2 guidance steps, got %d", len(guidance.IncidentGuidanceSteps))
+			}
+			if guidance.TemplateVersion != 2 || guidance.GuidanceTemplateVersionID == nil || guidance.GuidanceTemplateVersionID.String() != testVersionID {
+				t.Errorf("expected guidance to record version 2, got %d", guidance.TemplateVersion)
+			}
+		})
+	}
+}
+
+func TestUpdateIncident(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         string
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name:   "Status change is added to the activity feed",
+			status: "in_progress",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				mock.ExpectBegin()
+				testsupport.ExpectExec(mock, `UPDATE "incidents" SET`, nil)
+				expectInsert(mock, "incident_activities", testActivityID, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:   "Unchanged status adds no activity",
+			status: "new",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				mock.ExpectBegin()
+				testsupport.ExpectExec(mock, `UPDATE "incidents" SET`, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:   "Activity feed failure rolls back",
+			status: "in_progress",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectIncidentLookup(mock)
+				mock.ExpectBegin()
+				testsupport.ExpectExec(mock, `UPDATE "incidents" SET`, nil)
+				expectInsert(mock, "incident_activities", "", testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+
+			incident, err := svc.UpdateIncident(context.Background(), testIncidentID, testAssigneeID, &dto.UpdateIncidentDto{Status: tt.status})
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if incident.Status != tt.status {
+				t.Errorf("expected status %s, got %s", tt.status, incident.Status)
+			}
+		})
+	}
+}
+
+func TestSearchIncidents(t *testing.T) {
+	const otherIncidentID = "b07b3f5d-007c-4ecd-8e00-4e1f8e4a3b12"
+
+	t.Run("Invalid date is rejected", func(t *testing.T) {
+		svc, _ := newTestService(t)
+		_, err := svc.SearchIncidents(context.Background(), &dto.SearchIncidentsDto{Page: 1, Limit: 10, IncidentFilterDto: dto.IncidentFilterDto{CreatedFrom: "yesterday"}})
+		testsupport.AssertAppError(t, err, http.StatusBadRequest)
+	})
+
+	t.Run("Hits keep their rank and only matching highlights", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		mock.ExpectQuery(`SELECT incidents.id,\s+ts_rank`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "rank", "name_highlight", "description_highlight", "location_highlight", "comment_highlight", "alarm_highlight"}).
+				AddRow(otherIncidentID, 0.9, "Smoke", "<mark>Fire</mark> in the kitchen", "", nil, nil).
+				AddRow(testIncidentID, 0.4, "Intrusion", "", "", "Saw <mark>fire</mark>", nil))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT count(*) FROM "incidents" CROSS JOIN websearch_to_tsquery`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		for range searchFacets {
+			mock.ExpectQuery(testsupport.QuoteSQL(`FROM (SELECT incidents.id, incidents.status`)).
+				WillReturnRows(sqlmock.NewRows([]string{"value", "label", "count"}))
+		}
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incidents" WHERE id IN ($1,$2)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testIncidentID, "Intrusion").AddRow(otherIncidentID, "Smoke"))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_guidances"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		result, err := svc.SearchIncidents(context.Background(), &dto.SearchIncidentsDto{Page: 1, Limit: 10, Query: "fire", Bucket: "day"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Data) != 2 || result.Data[0].Incident.ID.String() != otherIncidentID {
+			t.Fatalf("expected the best match first, got %+v", result.Data)
+		}
+		if _, ok := result.Data[0].Highlights["name"]; ok || result.Data[0].Highlights["description"] == "" {
+			t.Errorf("expected only the description highlight, got %v", result.Data[0].Highlights)
+		}
+		if result.Data[1].Highlights["comment"] != "Saw <mark>fire</mark>" {
+			t.Errorf("expected the comment highlight, got %v", result.Data[1].Highlights)
+		}
+		if result.Total != 2 || len(result.Facets) != len(searchFacets) {
+			t.Errorf("expected total 2 and %d facets, got %d and %d", len(searchFacets), result.Total, len(result.Facets))
+		}
+	})
+
+	t.Run("Markup in the matched text is escaped before highlighting", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		escaped := func(text string) string {
+			return `ts_headline\('english', replace\(replace\(replace\(replace\(replace\(` + text + `, '&', '&amp;'\), '<', '&lt;'\)`
+		}
+		mock.ExpectQuery(`(?s)` + escaped(`coalesce\(incidents\.name, ''\)`) + `.+` + escaped(`coalesce\(incidents\.description, ''\)`) +
+			`.+` + escaped(`coalesce\(incidents\.location, ''\)`) + `.+` + escaped(`a\.message`) + `.+` + escaped(`al\.description`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "rank", "name_highlight", "description_highlight", "location_highlight", "comment_highlight", "alarm_highlight"}).
+				AddRow(testIncidentID, 0.9, "&lt;script&gt;alert(1)&lt;/script&gt; <mark>fire</mark>", "", "", nil, nil))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT count(*) FROM "incidents"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		for range searchFacets {
+			mock.ExpectQuery(testsupport.QuoteSQL(`FROM (SELECT incidents.id, incidents.status`)).
+				WillReturnRows(sqlmock.NewRows([]string{"value", "label", "count"}))
+		}
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incidents" WHERE id IN ($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testIncidentID, "<script>alert(1)</script> fire"))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_guidances"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		result, err := svc.SearchIncidents(context.Background(), &dto.SearchIncidentsDto{Page: 1, Limit: 10, Query: "fire", Bucket: "day"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if highlight := result.Data[0].Highlights["name"]; strings.Contains(highlight, "<script>") || !strings.Contains(highlight, "<mark>fire</mark>") {
+			t.Errorf("expected an escaped name highlight, got %q", highlight)
+		}
+	})
+}
+
+func TestGetIncidents(t *testing.T) {
+	tests := []struct {
+		name           string
+		request        query.Request
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name:    "Filters and sort are applied",
+			request: query.Request{Page: 1, Limit: 10, Sort: "-severity"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incidents" WHERE incidents.status = $1 ORDER BY CASE "incidents"."severity" WHEN 'high' THEN 3 WHEN 'medium' THEN 2 WHEN 'low' THEN 1 ELSE 0 END DESC, "incidents"."id" DESC LIMIT $2`)).
+					WithArgs("new", 11).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT count(*) FROM "incidents" WHERE incidents.status = $1`)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			},
+		},
+		{
+			name:           "Unknown sort field is rejected",
+			request:        query.Request{Page: 1, Limit: 10, Sort: "location"},
+			expect:         func(sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Count failure is reported",
+			request: query.Request{Page: 1, Limit: 10},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incidents"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT count(*) FROM "incidents"`)).WillReturnError(testsupport.ErrInjected)
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+
+			result, err := svc.GetIncidents(context.Background(), &dto.GetIncidentsDto{
+				Request:           tt.request,
+				IncidentFilterDto: dto.IncidentFilterDto{Status: "new"},
+			})
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Total == nil || *result.Total != 0 || result.HasMore {
+				t.Errorf("expected an empty counted page, got %+v", result.Pagination)
+			}
+		})
+	}
+}
+
+func TestGetIncidentsGeo(t *testing.T) {
+	latitude, longitude := 52.3676, 4.9041
+	tests := []struct {
+		name           string
+		request        dto.GetIncidentsGeoDto
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+		expectedCount  int
+	}{
+		{name: "Area is required", request: dto.GetIncidentsGeoDto{Limit: 100}, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid bbox", request: dto.GetIncidentsGeoDto{BBox: "5.1,52.2,4.7,52.5", Limit: 100}, expectedStatus: http.StatusBadRequest},
+		{name: "Radius is required", request: dto.GetIncidentsGeoDto{Latitude: &latitude, Longitude: &longitude, Limit: 100}, expectedStatus: http.StatusBadRequest},
+		{name: "Bbox and center", request: dto.GetIncidentsGeoDto{BBox: "4.7,52.2,5.1,52.5", Latitude: &latitude, Longitude: &longitude, Radius: 1000, Limit: 100}, expectedStatus: http.StatusBadRequest},
+		{
+			name:    "Bbox newest first",
+			request: dto.GetIncidentsGeoDto{BBox: "4.7,52.2,5.1,52.5", Status: "new", Limit: 100},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(testsupport.QuoteSQL(`FROM "incidents" WHERE (incidents.latitude IS NOT NULL AND incidents.longitude IS NOT NULL) AND incidents.status = $1 AND (incidents.latitude BETWEEN $2 AND $3) AND (incidents.longitude BETWEEN $4 AND $5) ORDER BY incidents.created_at DESC LIMIT $6`)).
+					WithArgs("new", 52.2, 52.5, 4.7, 5.1, 100).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status", "severity", "latitude", "longitude"}).
+						AddRow(testIncidentID, "Intrusion", "new", "high", 52.37, 4.9))
+			},
+			expectedCount: 1,
+		},
+		{
+			name:    "Radius nearest first",
+			request: dto.GetIncidentsGeoDto{Latitude: &latitude, Longitude: &longitude, Radius: 1000, Limit: 10},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`AS distance FROM "incidents" WHERE .+ \(incidents.longitude BETWEEN \$\d+ AND \$\d+\) AND \(2 \* 6371000 .+\) <= \$\d+ ORDER BY distance LIMIT`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status", "severity", "latitude", "longitude", "distance"}).
+						AddRow(testIncidentID, "Intrusion", "new", "high", 52.37, 4.9, 320.5))
+			},
+			expectedCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			collection, err := svc.GetIncidentsGeo(context.Background(), &tt.request)
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(collection.Features) != tt.expectedCount {
+				t.Fatalf("expected %d features, got %d", tt.expectedCount, len(collection.Features))
+			}
+			if properties := collection.Features[0].Properties.(types.IncidentFeatureProperties); properties.ID != testIncidentID {
+				t.Errorf("unexpected feature properties %+v", properties)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/app/incident/dto"
+	"scs-operator/internal/models"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/geo"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// stepEvidence is what a step is completed with that its evidence
+// requirements are checked against.
+type stepEvidence struct {
+	photoIDs    []uuid.UUID
+	signatureID *uuid.UUID
+	note        string
+	position    *geo.Point
+}
+
+// collectEvidence gathers the evidence given with a completion. The answer to
+// a photo step counts as one of its photos, the answer to a signature step is
+// its signature.
+func collectEvidence(step *models.IncidentGuidanceStep, completeDto *dto.CompleteGuidanceStepDto, answer *string) (*stepEvidence, error) {
+	evidence := &stepEvidence{note: strings.TrimSpace(completeDto.Note)}
+	seen := map[uuid.UUID]bool{}
+	rawPhotoIDs := completeDto.PhotoIDs
+	if step.Type == models.GuidanceStepTypePhoto && answer != nil {
+		rawPhotoIDs = append([]string{*answer}, rawPhotoIDs...)
+	}
+	for _, rawID := range rawPhotoIDs {
+		photoID, err := uuid.Parse(rawID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid photo ID format")
+		}
+		if !seen[photoID] {
+			seen[photoID] = true
+			evidence.photoIDs = append(evidence.photoIDs, photoID)
+		}
+	}
+	rawSignatureID := completeDto.SignatureID
+	if step.Type == models.GuidanceStepTypeSignature && answer != nil {
+		if rawSignatureID != "" && rawSignatureID != *answer {
+			return nil, errors.NewBadRequestError("The answer to a signature step is its signature")
+		}
+		rawSignatureID = *answer
+	}
+	if rawSignatureID != "" {
+		signatureID, err := uuid.Parse(rawSignatureID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid signature ID format")
+		}
+		if seen[signatureID] {
+			return nil, errors.NewBadRequestError("The signature cannot also be a photo")
+		}
+		evidence.signatureID = &signatureID
+	}
+	position, err := geo.NewPoint(completeDto.Latitude, completeDto.Longitude)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	evidence.position = position
+	return evidence, nil
+}
+
+// checkEvidence checks the evidence against what the step requires. The
+// position is checked against the geofence separately.
+func checkEvidence(step *models.IncidentGuidanceStep, evidence *stepEvidence) error {
+	required := step.Evidence
+	if len(evidence.photoIDs) < required.MinPhotos {
+		return errors.NewBadRequestError(fmt.Sprintf("Step %d needs at least %d photos", step.StepNumber, required.MinPhotos))
+	}
+	if required.Note && evidence.note == "" {
+		return errors.NewBadRequestError(fmt.Sprintf("Step %d needs a note", step.StepNumber))
+	}
+	if required.Signature && evidence.signatureID == nil {
+		return errors.NewBadRequestError(fmt.Sprintf("Step %d needs a signature", step.StepNumber))
+	}
+	if required.Location && evidence.position == nil {
+		return errors.NewBadRequestError(fmt.Sprintf("Step %d needs the position it was completed at", step.StepNumber))
+	}
+	return nil
+}
+
+// attachEvidence checks the evidence a step is completed with and links its
+// photos and signature to the step. They must be uploaded images of the
+// incident not yet linked to another step; media registered offline counts
+// once its file is uploaded.
+func (s *Service) attachEvidence(ctx context.Context, incident *models.Incident, step *models.IncidentGuidanceStep, evidence *stepEvidence) error {
+	if err := checkEvidence(step, evidence); err != nil {
+		return err
+	}
+	if step.Evidence.Location {
+		if err := s.checkGeofence(ctx, incident, step, *evidence.position); err != nil {
+			return err
+		}
+	}
+	ids := evidence.photoIDs
+	if evidence.signatureID != nil {
+		ids = append(append([]uuid.UUID{}, ids...), *evidence.signatureID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	media, err := s.incidentMediaRepo.GetIncidentMediaByIDs(ctx, incident.ID, ids)
+	if err != nil {
+		return errors.NewDatabaseError("get incident media", err)
+	}
+	if len(media) != len(ids) {
+		return errors.NewBadRequestError("Photos and signatures must be media of the incident")
+	}
+	for _, m := range media {
+		if m.MediaType != "image" {
+			return errors.NewBadRequestError(fmt.Sprintf("Media %s is not an image", m.ID))
+		}
+		if m.Status != "uploaded" {
+			return errors.NewBadRequestError(fmt.Sprintf("Media %s is not uploaded yet", m.ID))
+		}
+		if m.IncidentGuidanceStepID != nil {
+			return errors.NewConflictError(fmt.Sprintf("Media %s is already evidence of a step", m.ID))
+		}
+	}
+	if len(evidence.photoIDs) > 0 {
+		if err := s.linkEvidence(ctx, evidence.photoIDs, step.ID, "photo"); err != nil {
+			return err
+		}
+	}
+	if evidence.signatureID != nil {
+		if err := s.linkEvidence(ctx, []uuid.UUID{*evidence.signatureID}, step.ID, "signature"); err != nil {
+			return err
+		}
+	}
+	for i := range media {
+		media[i].IncidentGuidanceStepID = &step.ID
+		media[i].Evidence = "photo"
+		if evidence.signatureID != nil && media[i].ID == *evidence.signatureID {
+			media[i].Evidence = "signature"
+		}
+	}
+	step.Media = media
+	return nil
+}
+
+// linkEvidence links media to a step as its photos or signature.
+func (s *Service) linkEvidence(ctx context.Context, ids []uuid.UUID, stepID uuid.UUID, evidence string) error {
+	linked, err := s.incidentMediaRepo.LinkIncidentMedia(ctx, ids, stepID, evidence)
+	if err != nil {
+		return errors.NewDatabaseError("link step evidence", err)
+	}
+	if !linked {
+		return errors.NewConflictError("Media is already evidence of a step")
+	}
+	return nil
+}
+
+// checkGeofence checks that a step was completed within the geofence of the
+// incident's premise, or around the incident when its premise has no position.
+func (s *Service) checkGeofence(ctx context.Context, incident *models.Incident, step *models.IncidentGuidanceStep, position geo.Point) error {
+	radius := s.geofenceRadius
+	center, _ := geo.NewPoint(incident.Latitude, incident.Longitude)
+	if incident.PremiseID != nil {
+		premise, err := s.premiseRepo.GetPremiseByID(ctx, incident.PremiseID.String())
+		if err != nil {
+			return errors.NewNotFoundError("premise")
+		}
+		if premisePosition, _ := geo.NewPoint(premise.Latitude, premise.Longitude); premisePosition != nil {
+			center = premisePosition
+		}
+		if premise.GeofenceRadius != nil {
+			radius = *premise.GeofenceRadius
+		}
+	}
+	if center == nil {
+		return errors.NewBadRequestError("The incident has no premise position to check the step's position against")
+	}
+	if distance := geo.Distance(*center, position); distance > radius {
+		return errors.NewBadRequestError(fmt.Sprintf("Step %d must be completed within %.0f m of the premise, the position is %.0f m away", step.StepNumber, radius, distance))
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"scs-operator/internal/app/incident/dto"
+	"scs-operator/internal/models"
+	"scs-operator/internal/testsupport"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/geo"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestCheckEvidence(t *testing.T) {
+	photo := uuid.New().String()
+	signature := uuid.New().String()
+	latitude, longitude := 52.37, 4.89
+
+	tests := []struct {
+		name           string
+		step           models.IncidentGuidanceStep
+		complete       dto.CompleteGuidanceStepDto
+		expectedPhotos int
+		expectedStatus int
+	}{
+		{
+			name: "Steps without requirements need no evidence",
+			step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypeCheckbox},
+		},
+		{
+			name:           "Too few photos are rejected",
+			step:           models.IncidentGuidanceStep{Type: models.GuidanceStepTypeCheckbox, Evidence: models.EvidenceRequirements{MinPhotos: 2}},
+			complete:       dto.CompleteGuidanceStepDto{PhotoIDs: []string{photo, photo}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "The answer to a photo step counts as a photo",
+			step:           models.IncidentGuidanceStep{Type: models.GuidanceStepTypePhoto, Evidence: models.EvidenceRequirements{MinPhotos: 2}},
+			complete:       dto.CompleteGuidanceStepDto{Answer: uuid.New().String(), PhotoIDs: []string{photo}},
+			expectedPhotos: 2,
+		},
+		{
+			name:           "A blank note is no note",
+			step:           models.IncidentGuidanceStep{Type: models.GuidanceStepTypeCheckbox, Evidence: models.EvidenceRequirements{Note: true}},
+			complete:       dto.CompleteGuidanceStepDto{Note: "  "},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "The answer to a signature step is its signature",
+			step:     models.IncidentGuidanceStep{Type: models.GuidanceStepTypeSignature, Evidence: models.EvidenceRequirements{Signature: true}},
+			complete: dto.CompleteGuidanceStepDto{Answer: signature},
+		},
+		{
+			name:           "A missing signature is rejected",
+			step:           models.IncidentGuidanceStep{Type: models.GuidanceStepTypeCheckbox, Evidence: models.EvidenceRequirements{Signature: true}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "A signature cannot also be a photo",
+			step:           models.IncidentGuidanceStep{Type: models.GuidanceStepTypeCheckbox},
+			complete:       dto.CompleteGuidanceStepDto{PhotoIDs: []string{signature}, SignatureID: signature},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "A missing position is rejected",
+			step:           models.IncidentGuidanceStep{Type: models.GuidanceStepTypeCheckbox, Evidence: models.EvidenceRequirements{Location: true}},
+			complete:       dto.CompleteGuidanceStepDto{Latitude: &latitude},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "A position satisfies the location requirement",
+			step:     models.IncidentGuidanceStep{Type: models.GuidanceStepTypeCheckbox, Evidence: models.EvidenceRequirements{Location: true}},
+			complete: dto.CompleteGuidanceStepDto{Latitude: &latitude, Longitude: &longitude},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := checkAnswer(&tt.step, tt.complete.Answer)
+			if err != nil {
+				t.Fatalf("unexpected answer error: %v", err)
+			}
+			evidence, err := collectEvidence(&tt.step, &tt.complete, answer)
+			if err == nil {
+				err = checkEvidence(&tt.step, evidence)
+			}
+			if tt.expectedStatus != 0 {
+				appErr, ok := errors.IsAppError(err)
+				if !ok || appErr.StatusCode != tt.expectedStatus {
+					t.Fatalf("expected status %d, got %v", tt.expectedStatus, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(evidence.photoIDs) != tt.expectedPhotos {
+				t.Errorf("expected %d photos, got %d", tt.expectedPhotos, len(evidence.photoIDs))
+			}
+		})
+	}
+}
+
+func TestCheckGeofence(t *testing.T) {
+	svc, _ := newTestService(t)
+	latitude, longitude := 52.37, 4.89
+	incident := &models.Incident{Latitude: &latitude, Longitude: &longitude}
+	step := &models.IncidentGuidanceStep{StepNumber: 1}
+
+	if err := svc.checkGeofence(context.Background(), incident, step, geo.Point{Latitude: 52.3705, Longitude: 4.89}); err != nil {
+		t.Errorf("expected a position 55 m away to be inside, got %v", err)
+	}
+	err := svc.checkGeofence(context.Background(), incident, step, geo.Point{Latitude: 52.38, Longitude: 4.89})
+	if appErr, ok := errors.IsAppError(err); !ok || appErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a position 1 km away to be rejected, got %v", err)
+	}
+}
+
+func TestAttachEvidence(t *testing.T) {
+	photo := uuid.New()
+	incident := &models.Incident{Base: models.Base{ID: uuid.MustParse(testIncidentID)}}
+	step := &models.IncidentGuidanceStep{Base: models.Base{ID: uuid.New()}, StepNumber: 1, Evidence: models.EvidenceRequirements{MinPhotos: 1}}
+	getMedia := testsupport.QuoteSQL(`SELECT * FROM "incident_media" WHERE id IN ($1) AND incident_id = $2`)
+	mediaRows := func(mediaType string, status string, stepID interface{}) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "incident_id", "media_type", "status", "incident_guidance_step_id"}).
+			AddRow(photo, testIncidentID, mediaType, status, stepID)
+	}
+	tests := []struct {
+		name           string
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name: "Uploaded photos are linked to the step",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(getMedia).WithArgs(photo, testIncidentID).WillReturnRows(mediaRows("image", "uploaded", nil))
+				mock.ExpectBegin()
+				mock.ExpectExec(testsupport.QuoteSQL(`UPDATE "incident_media" SET "evidence"=$1,"incident_guidance_step_id"=$2,"updated_at"=$3 WHERE id IN ($4) AND incident_guidance_step_id IS NULL`)).
+					WithArgs("photo", step.ID, sqlmock.AnyArg(), photo).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Media registered offline counts only once uploaded",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(getMedia).WillReturnRows(mediaRows("image", "pending", nil))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Videos are no photos",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(getMedia).WillReturnRows(mediaRows("video", "uploaded", nil))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Media of another incident",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(getMedia).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Evidence of another step",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(getMedia).WillReturnRows(mediaRows("image", "uploaded", uuid.New()))
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			err := svc.attachEvidence(context.Background(), incident, step, &stepEvidence{photoIDs: []uuid.UUID{photo}})
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"scs-operator/internal/models"
+	"scs-operator/pkg/errors"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// copyGuidanceSteps copies the steps of a published template version onto a
+// guidance, pointing their branches at the copied steps.
+func copyGuidanceSteps(incidentGuidanceID uuid.UUID, templateSteps []models.GuidanceStep) []models.IncidentGuidanceStep {
+	ids := make(map[uuid.UUID]uuid.UUID, len(templateSteps))
+	for _, step := range templateSteps {
+		ids[step.ID] = uuid.New()
+	}
+	steps := make([]models.IncidentGuidanceStep, 0, len(templateSteps))
+	for _, step := range templateSteps {
+		stepType := step.Type
+		if stepType == "" {
+			stepType = models.GuidanceStepTypeCheckbox
+		}
+		var branches models.StepBranches
+		for _, branch := range step.Branches {
+			if branch.GoToStepID != nil {
+				target, ok := ids[*branch.GoToStepID]
+				if !ok {
+					continue
+				}
+				branch.GoToStepID = &target
+			}
+			branches = append(branches, branch)
+		}
+		steps = append(steps, models.IncidentGuidanceStep{
+			Base:               models.Base{ID: ids[step.ID]},
+			IncidentGuidanceID: incidentGuidanceID,
+			StepNumber:         int64(step.StepNumber),
+			Title:              step.Title,
+			Description:        step.Description,
+			Type:               stepType,
+			Options:            step.Options,
+			Branches:           branches,
+			Evidence:           step.Evidence,
+		})
+	}
+	return steps
+}
+
+// stepFlow follows ordered steps from the first one along the answers given.
+// It returns the first step on that path still to be done, nil once the path
+// ended, and the open steps the path jumped over, which can no longer be
+// reached.
+func stepFlow(steps []models.IncidentGuidanceStep) (*uuid.UUID, []uuid.UUID) {
+	index := make(map[uuid.UUID]int, len(steps))
+	for i, step := range steps {
+		index[step.ID] = i
+	}
+	visited := make([]bool, len(steps))
+	var next *uuid.UUID
+	end := len(steps)
+	for i := 0; i < len(steps); {
+		visited[i] = true
+		if !steps[i].IsCompleted {
+			next, end = &steps[i].ID, i
+			break
+		}
+		branch := takenBranch(steps[i])
+		switch {
+		case branch == nil:
+			i++
+		case branch.End:
+			i = len(steps)
+		default:
+			if target, ok := index[*branch.GoToStepID]; ok && target > i {
+				i = target
+			} else {
+				i++
+			}
+		}
+	}
+	skipped := []uuid.UUID{}
+	for i := 0; i < end; i++ {
+		if !visited[i] && !steps[i].IsCompleted && !steps[i].Skipped {
+			skipped = append(skipped, steps[i].ID)
+		}
+	}
+	return next, skipped
+}
+
+// takenBranch returns the branch a completed step leads on with: the one for
+// its answer, otherwise the one without an answer.
+func takenBranch(step models.IncidentGuidanceStep) *models.StepBranch {
+	var fallback *models.StepBranch
+	for i, branch := range step.Branches {
+		if branch.Answer == "" {
+			fallback = &step.Branches[i]
+		} else if step.Answer != nil && branch.Answer == *step.Answer {
+			return &step.Branches[i]
+		}
+	}
+	return fallback
+}
+
+// checkAnswer checks an answer against the type of the step and returns it as
+// stored. Photos and signatures are answered with a media ID, which is checked
+// as evidence of the step.
+func checkAnswer(step *models.IncidentGuidanceStep, answer string) (*string, error) {
+	answer = strings.TrimSpace(answer)
+	if step.Type == "" || step.Type == models.GuidanceStepTypeCheckbox {
+		if answer != "" {
+			return nil, errors.NewBadRequestError("Checkbox steps take no answer")
+		}
+		return nil, nil
+	}
+	if answer == "" {
+		return nil, errors.NewBadRequestError(fmt.Sprintf("Step %d needs an answer", step.StepNumber))
+	}
+	switch step.Type {
+	case models.GuidanceStepTypeYesNo:
+		answer = strings.ToLower(answer)
+		if answer != "yes" && answer != "no" {
+			return nil, errors.NewBadRequestError("Answer yes or no")
+		}
+	case models.GuidanceStepTypeSingleChoice:
+		valid := false
+		for _, option := range step.Options {
+			valid = valid || option == answer
+		}
+		if !valid {
+			return nil, errors.NewBadRequestError("Answer one of the options of the step")
+		}
+	case models.GuidanceStepTypeNumber:
+		if _, err := strconv.ParseFloat(answer, 64); err != nil {
+			return nil, errors.NewBadRequestError("Answer with a number")
+		}
+	case models.GuidanceStepTypePhoto, models.GuidanceStepTypeSignature:
+		if _, err := uuid.Parse(answer); err != nil {
+			return nil, errors.NewBadRequestError("Answer with the ID of an image uploaded to the incident")
+		}
+	}
+	return &answer, nil
+}
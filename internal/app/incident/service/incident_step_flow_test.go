@@ -0,0 +1,123 @@
+package services
+
+import (
+	"net/http"
+	"scs-operator/internal/models"
+	"scs-operator/pkg/errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestStepFlow(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	answer := func(a string) *string { return &a }
+	newSteps := func() []models.IncidentGuidanceStep {
+		steps := make([]models.IncidentGuidanceStep, len(ids))
+		for i, id := range ids {
+			steps[i] = models.IncidentGuidanceStep{Base: models.Base{ID: id}, StepNumber: int64(i + 1), Type: models.GuidanceStepTypeCheckbox}
+		}
+		steps[0].Type = models.GuidanceStepTypeYesNo
+		steps[0].Branches = models.StepBranches{
+			{Answer: "yes", GoToStepID: &ids[2]},
+			{Answer: "no", End: true},
+		}
+		return steps
+	}
+
+	tests := []struct {
+		name            string
+		complete        map[int]*string
+		expectedNext    *uuid.UUID
+		expectedSkipped []uuid.UUID
+	}{
+		{
+			name:            "Nothing done starts at the first step",
+			expectedNext:    &ids[0],
+			expectedSkipped: []uuid.UUID{},
+		},
+		{
+			name:            "A branch jumps over steps and skips them",
+			complete:        map[int]*string{0: answer("yes")},
+			expectedNext:    &ids[2],
+			expectedSkipped: []uuid.UUID{ids[1]},
+		},
+		{
+			name:            "Ending the guidance skips every open step",
+			complete:        map[int]*string{0: answer("no")},
+			expectedSkipped: []uuid.UUID{ids[1], ids[2], ids[3]},
+		},
+		{
+			name:            "Steps done ahead of the path are not skipped",
+			complete:        map[int]*string{0: answer("yes"), 1: nil, 2: nil},
+			expectedNext:    &ids[3],
+			expectedSkipped: []uuid.UUID{},
+		},
+		{
+			name:            "The path ends after the last step",
+			complete:        map[int]*string{0: answer("yes"), 2: nil, 3: nil},
+			expectedSkipped: []uuid.UUID{ids[1]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := newSteps()
+			for i, a := range tt.complete {
+				steps[i].IsCompleted, steps[i].Answer = true, a
+			}
+			next, skipped := stepFlow(steps)
+			if (next == nil) != (tt.expectedNext == nil) || (next != nil && *next != *tt.expectedNext) {
+				t.Errorf("expected next step %v, got %v", tt.expectedNext, next)
+			}
+			if len(skipped) != len(tt.expectedSkipped) {
+				t.Fatalf("expected skipped %v, got %v", tt.expectedSkipped, skipped)
+			}
+			for i := range skipped {
+				if skipped[i] != tt.expectedSkipped[i] {
+					t.Errorf("expected skipped %v, got %v", tt.expectedSkipped, skipped)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckAnswer(t *testing.T) {
+	ptr := func(a string) *string { return &a }
+	tests := []struct {
+		name           string
+		step           models.IncidentGuidanceStep
+		answer         string
+		expectedAnswer *string
+		expectedStatus int
+	}{
+		{name: "Checkbox takes no answer", step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypeCheckbox}},
+		{name: "Checkbox rejects an answer", step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypeCheckbox}, answer: "yes", expectedStatus: http.StatusBadRequest},
+		{name: "Yes/no is normalised", step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypeYesNo}, answer: " Yes ", expectedAnswer: ptr("yes")},
+		{name: "Yes/no rejects other answers", step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypeYesNo}, answer: "maybe", expectedStatus: http.StatusBadRequest},
+		{name: "Single choice takes an option", step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypeSingleChoice, Options: models.StringList{"Fire", "Flood"}}, answer: "Flood", expectedAnswer: ptr("Flood")},
+		{name: "Single choice rejects other answers", step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypeSingleChoice, Options: models.StringList{"Fire", "Flood"}}, answer: "Storm", expectedStatus: http.StatusBadRequest},
+		{name: "Number must parse", step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypeNumber}, answer: "three", expectedStatus: http.StatusBadRequest},
+		{name: "Text needs an answer", step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypeText}, expectedStatus: http.StatusBadRequest},
+		{name: "Photo needs a media ID", step: models.IncidentGuidanceStep{Type: models.GuidanceStepTypePhoto}, answer: "photo.jpg", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := checkAnswer(&tt.step, tt.answer)
+			if tt.expectedStatus != 0 {
+				appErr, ok := errors.IsAppError(err)
+				if !ok || appErr.StatusCode != tt.expectedStatus {
+					t.Fatalf("expected status %d, got %v", tt.expectedStatus, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (answer == nil) != (tt.expectedAnswer == nil) || (answer != nil && *answer != *tt.expectedAnswer) {
+				t.Errorf("expected answer %v, got %v", tt.expectedAnswer, answer)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"scs-operator/internal/app/maintenance-window/dto"
+	services "scs-operator/internal/app/maintenance-window/service"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler
+type Handler struct {
+	svc services.Service
+}
+
+// NewHandler constructor
+func NewHandler(svc services.Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// CreateMaintenanceWindow schedules a new maintenance window
+// @Summary Create a maintenance window
+// @Description Schedule a maintenance window for a premise (optionally with its sub-premises) or a single device. Alarms raised during the window are stored as suppressed.
+// @Tags maintenance-windows
+// @Accept json
+// @Produce json
+// @Param window body dto.CreateMaintenanceWindowDto true "Maintenance window data"
+// @Success 201 {object} models.MaintenanceWindow
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /maintenance-windows [post]
+func (h *Handler) CreateMaintenanceWindow() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		createDto := &dto.CreateMaintenanceWindowDto{}
+		if err := c.Bind(createDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		// Validate the DTO
+		if err := validation.ValidateStruct(createDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		window, err := h.svc.CreateMaintenanceWindow(c.Request().Context(), userID, createDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, window)
+	}
+}
+
+// GetMaintenanceWindows lists maintenance windows
+// @Summary Get maintenance windows
+// @Description Get maintenance windows with optional premise or device filtering
+// @Tags maintenance-windows
+// @Accept json
+// @Produce json
+// @Param premise_id query string false "Filter by premise ID"
+// @Param device_id query string false "Filter by device ID"
+// @Success 200 {array} models.MaintenanceWindow
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /maintenance-windows [get]
+func (h *Handler) GetMaintenanceWindows() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		windows, err := h.svc.GetMaintenanceWindows(c.Request().Context(), c.QueryParam("premise_id"), c.QueryParam("device_id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, windows)
+	}
+}
+
+// GetMaintenanceWindow retrieves a maintenance window by ID
+// @Summary Get maintenance window by ID
+// @Description Get a specific maintenance window by its ID
+// @Tags maintenance-windows
+// @Accept json
+// @Produce json
+// @Param id path string true "Maintenance Window ID"
+// @Success 200 {object} models.MaintenanceWindow
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /maintenance-windows/{id} [get]
+func (h *Handler) GetMaintenanceWindow() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		window, err := h.svc.GetMaintenanceWindowByID(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, window)
+	}
+}
+
+// DeleteMaintenanceWindow cancels a maintenance window
+// @Summary Cancel maintenance window
+// @Description Delete a window that has not started yet, or close a started window immediately
+// @Tags maintenance-windows
+// @Accept json
+// @Produce json
+// @Param id path string true "Maintenance Window ID"
+// @Success 200 {string} string "success"
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /maintenance-windows/{id} [delete]
+func (h *Handler) DeleteMaintenanceWindow() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := h.svc.DeleteMaintenanceWindow(c.Request().Context(), c.Param("id")); err != nil {
+			return err
+		}
+		return c.JSON(200, "success")
+	}
+}
+
+// GetSuppressedAlarms lists alarms suppressed by a maintenance window
+// @Summary Get suppressed alarms
+// @Description Get the alarms that were suppressed by a maintenance window
+// @Tags maintenance-windows
+// @Accept json
+// @Produce json
+// @Param id path string true "Maintenance Window ID"
+// @Success 200 {array} models.Alarm
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /maintenance-windows/{id}/alarms [get]
+func (h *Handler) GetSuppressedAlarms() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		alarms, err := h.svc.GetSuppressedAlarms(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, alarms)
+	}
+}
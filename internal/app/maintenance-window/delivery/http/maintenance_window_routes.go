@@ -0,0 +1,13 @@
+package http
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handler) RegisterRoutes(g *echo.Group) {
+	g.POST("", h.CreateMaintenanceWindow())
+	g.GET("", h.GetMaintenanceWindows())
+	g.GET("/:id", h.GetMaintenanceWindow())
+	g.DELETE("/:id", h.DeleteMaintenanceWindow())
+	g.GET("/:id/alarms", h.GetSuppressedAlarms())
+}
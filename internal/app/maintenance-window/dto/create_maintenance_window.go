@@ -0,0 +1,14 @@
+package dto
+
+type CreateMaintenanceWindowDto struct {
+	Name               string `json:"name" validate:"required,min=2,max=100"`
+	Reason             string `json:"reason" validate:"omitempty,max=255"`
+	PremiseID          string `json:"premise_id" validate:"omitempty,uuid"`
+	IncludeSubPremises bool   `json:"include_sub_premises"`
+	DeviceID           string `json:"device_id" validate:"omitempty,uuid"`
+	StartsAt           string `json:"starts_at" validate:"required"`
+	EndsAt             string `json:"ends_at" validate:"required"`
+	Recurrence         string `json:"recurrence" validate:"omitempty,oneof=none daily weekly monthly"`
+	RecurrenceUntil    string `json:"recurrence_until" validate:"omitempty"`
+	Timezone           string `json:"timezone" validate:"omitempty"`
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MaintenanceWindowRepository struct {
+	db *gorm.DB
+}
+
+func NewMaintenanceWindowRepository(db *gorm.DB) *MaintenanceWindowRepository {
+	return &MaintenanceWindowRepository{db: db}
+}
+
+func (r *MaintenanceWindowRepository) CreateMaintenanceWindow(ctx context.Context, window *models.MaintenanceWindow) (*models.MaintenanceWindow, error) {
+	if err := r.db.WithContext(ctx).Create(window).Error; err != nil {
+		return nil, fmt.Errorf("failed to create maintenance window: %w", err)
+	}
+	return window, nil
+}
+
+func (r *MaintenanceWindowRepository) GetMaintenanceWindows(ctx context.Context, premiseID string, deviceID string) ([]models.MaintenanceWindow, error) {
+	var windows []models.MaintenanceWindow
+	query := r.db.WithContext(ctx).Preload("Premise").Preload("Device")
+	if premiseID != "" {
+		query = query.Where("premise_id = ?", premiseID)
+	}
+	if deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	if err := query.Order("starts_at desc").Find(&windows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get maintenance windows: %w", err)
+	}
+	return windows, nil
+}
+
+func (r *MaintenanceWindowRepository) GetMaintenanceWindowByID(ctx context.Context, id string) (*models.MaintenanceWindow, error) {
+	var window models.MaintenanceWindow
+	if err := r.db.WithContext(ctx).Preload("Premise").Preload("Device").First(&window, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get maintenance window: %w", err)
+	}
+	return &window, nil
+}
+
+func (r *MaintenanceWindowRepository) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Delete(&models.MaintenanceWindow{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete maintenance window: %w", err)
+	}
+	return nil
+}
+
+// CloseMaintenanceWindow stops the window and all its recurrences at t.
+func (r *MaintenanceWindowRepository) CloseMaintenanceWindow(ctx context.Context, id string, t time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.MaintenanceWindow{}).Where("id = ?", id).Update("recurrence_until", t)
+	if result.Error != nil {
+		return fmt.Errorf("failed to close maintenance window: %w", result.Error)
+	}
+	return nil
+}
+
+// GetCandidateWindows returns the windows covering the given device or premise
+// that may be active at t. ancestorIDs are the premise's parents, which match
+// only windows that include sub-premises. Recurrences are resolved by the caller.
+func (r *MaintenanceWindowRepository) GetCandidateWindows(ctx context.Context, premiseID *uuid.UUID, ancestorIDs []uuid.UUID, deviceID *uuid.UUID, t time.Time) ([]models.MaintenanceWindow, error) {
+	var windows []models.MaintenanceWindow
+	scope := r.db.WithContext(ctx)
+	hasScope := false
+	if deviceID != nil {
+		scope = scope.Or("device_id = ?", *deviceID)
+		hasScope = true
+	}
+	if premiseID != nil {
+		scope = scope.Or("premise_id = ?", *premiseID)
+		hasScope = true
+	}
+	if len(ancestorIDs) > 0 {
+		scope = scope.Or("include_sub_premises AND premise_id IN ?", ancestorIDs)
+		hasScope = true
+	}
+	if !hasScope {
+		return nil, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where(scope).
+		Where("starts_at <= ?", t).
+		Where("recurrence <> 'none' OR ends_at > ?", t).
+		Where("recurrence_until IS NULL OR recurrence_until >= ?", t).
+		Find(&windows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get maintenance windows: %w", err)
+	}
+	return windows, nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	alarmRepositories "scs-operator/internal/app/alarm/repository"
+	deviceRepositories "scs-operator/internal/app/device/repository"
+	"scs-operator/internal/app/maintenance-window/dto"
+	repositories "scs-operator/internal/app/maintenance-window/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	"scs-operator/internal/models"
+	"scs-operator/pkg/errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service struct {
+	maintenanceWindowRepo repositories.MaintenanceWindowRepository
+	premiseRepo           premiseRepositories.PremiseRepository
+	deviceRepo            deviceRepositories.DeviceRepository
+	alarmRepo             alarmRepositories.AlarmRepository
+}
+
+func NewMaintenanceWindowService(maintenanceWindowRepo repositories.MaintenanceWindowRepository, premiseRepo premiseRepositories.PremiseRepository, deviceRepo deviceRepositories.DeviceRepository, alarmRepo alarmRepositories.AlarmRepository) *Service {
+	return &Service{maintenanceWindowRepo: maintenanceWindowRepo, premiseRepo: premiseRepo, deviceRepo: deviceRepo, alarmRepo: alarmRepo}
+}
+
+func (s *Service) CreateMaintenanceWindow(ctx context.Context, userID string, createDto *dto.CreateMaintenanceWindowDto) (*models.MaintenanceWindow, error) {
+	if (createDto.PremiseID == "") == (createDto.DeviceID == "") {
+		return nil, errors.NewBadRequestError("exactly one of premise_id or device_id is required")
+	}
+	window := &models.MaintenanceWindow{
+		Name:               createDto.Name,
+		Reason:             createDto.Reason,
+		IncludeSubPremises: createDto.IncludeSubPremises,
+		Recurrence:         createDto.Recurrence,
+	}
+	if window.Recurrence == "" {
+		window.Recurrence = "none"
+	}
+	window.Timezone = createDto.Timezone
+	if window.Timezone == "" {
+		window.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(window.Timezone); err != nil {
+		return nil, errors.NewBadRequestError("Invalid timezone, expected an IANA name such as Europe/Berlin")
+	}
+	if createDto.PremiseID != "" {
+		premiseID, err := uuid.Parse(createDto.PremiseID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid premise ID format")
+		}
+		if _, err := s.premiseRepo.GetPremiseByID(ctx, premiseID.String()); err != nil {
+			return nil, errors.NewNotFoundError("premise")
+		}
+		window.PremiseID = &premiseID
+	}
+	if createDto.DeviceID != "" {
+		deviceID, err := uuid.Parse(createDto.DeviceID)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid device ID format")
+		}
+		if _, err := s.deviceRepo.GetDeviceByID(ctx, deviceID.String()); err != nil {
+			return nil, errors.NewNotFoundError("device")
+		}
+		window.DeviceID = &deviceID
+		window.IncludeSubPremises = false
+	}
+
+	startsAt, err := time.Parse(time.RFC3339, createDto.StartsAt)
+	if err != nil {
+		return nil, errors.NewBadRequestError("Invalid starts_at format, expected RFC3339")
+	}
+	endsAt, err := time.Parse(time.RFC3339, createDto.EndsAt)
+	if err != nil {
+		return nil, errors.NewBadRequestError("Invalid ends_at format, expected RFC3339")
+	}
+	if !endsAt.After(startsAt) {
+		return nil, errors.NewBadRequestError("ends_at must be after starts_at")
+	}
+	window.StartsAt = startsAt
+	window.EndsAt = endsAt
+	if createDto.RecurrenceUntil != "" {
+		if window.Recurrence == "none" {
+			return nil, errors.NewBadRequestError("recurrence_until requires a recurrence")
+		}
+		recurrenceUntil, err := time.Parse(time.RFC3339, createDto.RecurrenceUntil)
+		if err != nil {
+			return nil, errors.NewBadRequestError("Invalid recurrence_until format, expected RFC3339")
+		}
+		window.RecurrenceUntil = &recurrenceUntil
+	}
+	if userID != "" {
+		createdByID, err := uuid.Parse(userID)
+		if err == nil {
+			window.CreatedByID = &createdByID
+		}
+	}
+
+	createdWindow, err := s.maintenanceWindowRepo.CreateMaintenanceWindow(ctx, window)
+	if err != nil {
+		return nil, errors.NewDatabaseError("create maintenance window", err)
+	}
+	return createdWindow, nil
+}
+
+func (s *Service) GetMaintenanceWindows(ctx context.Context, premiseID string, deviceID string) ([]models.MaintenanceWindow, error) {
+	windows, err := s.maintenanceWindowRepo.GetMaintenanceWindows(ctx, premiseID, deviceID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get maintenance windows", err)
+	}
+	return windows, nil
+}
+
+func (s *Service) GetMaintenanceWindowByID(ctx context.Context, id string) (*models.MaintenanceWindow, error) {
+	window, err := s.maintenanceWindowRepo.GetMaintenanceWindowByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewNotFoundError("maintenance window")
+	}
+	return window, nil
+}
+
+// DeleteMaintenanceWindow removes a window that has not started yet. Windows
+// that already started are closed instead so suppressed alarms keep their reference.
+func (s *Service) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	window, err := s.maintenanceWindowRepo.GetMaintenanceWindowByID(ctx, id)
+	if err != nil {
+		return errors.NewNotFoundError("maintenance window")
+	}
+	now := time.Now()
+	if window.StartsAt.After(now) {
+		if err := s.maintenanceWindowRepo.DeleteMaintenanceWindow(ctx, id); err != nil {
+			return errors.NewDatabaseError("delete maintenance window", err)
+		}
+		return nil
+	}
+	if err := s.maintenanceWindowRepo.CloseMaintenanceWindow(ctx, id, now); err != nil {
+		return errors.NewDatabaseError("close maintenance window", err)
+	}
+	return nil
+}
+
+// GetSuppressedAlarms lists the alarms a maintenance window suppressed.
+func (s *Service) GetSuppressedAlarms(ctx context.Context, id string) ([]models.Alarm, error) {
+	if _, err := s.maintenanceWindowRepo.GetMaintenanceWindowByID(ctx, id); err != nil {
+		return nil, errors.NewNotFoundError("maintenance window")
+	}
+	alarms, err := s.alarmRepo.GetAlarmsByMaintenanceWindowID(ctx, id)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get suppressed alarms", err)
+	}
+	return alarms, nil
+}
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"scs-operator/internal/app/patrol/dto"
+	services "scs-operator/internal/app/patrol/service"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler
+type Handler struct {
+	svc services.Service
+}
+
+// NewHandler constructor
+func NewHandler(svc services.Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// CreatePatrolRoute creates a patrol route
+// @Summary Create a patrol route
+// @Description Create a patrol route on a premise with its checkpoints in walking order. Each checkpoint is identified by the code of its QR or NFC tag, which must be unique, and is due expected_after_minutes after the patrol starts.
+// @Tags patrols
+// @Accept json
+// @Produce json
+// @Param route body dto.CreatePatrolRouteDto true "Patrol route"
+// @Success 201 {object} models.PatrolRoute
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /patrols/routes [post]
+func (h *Handler) CreatePatrolRoute() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		createRouteDto := &dto.CreatePatrolRouteDto{}
+		if err := c.Bind(createRouteDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(createRouteDto); err != nil {
+			return err
+		}
+		route, err := h.svc.CreatePatrolRoute(c.Request().Context(), createRouteDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, route)
+	}
+}
+
+// GetPatrolRoutes lists patrol routes
+// @Summary Get patrol routes
+// @Description Get the patrol routes with their checkpoints, optionally of one premise
+// @Tags patrols
+// @Produce json
+// @Param premise_id query string false "Premise ID"
+// @Success 200 {array} models.PatrolRoute
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /patrols/routes [get]
+func (h *Handler) GetPatrolRoutes() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		routes, err := h.svc.GetPatrolRoutes(c.Request().Context(), c.QueryParam("premise_id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, routes)
+	}
+}
+
+// GetPatrolRoute gets a patrol route
+// @Summary Get patrol route
+// @Description Get a patrol route with its premise and checkpoints
+// @Tags patrols
+// @Produce json
+// @Param id path string true "Patrol route ID"
+// @Success 200 {object} models.PatrolRoute
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /patrols/routes/{id} [get]
+func (h *Handler) GetPatrolRoute() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		route, err := h.svc.GetPatrolRoute(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, route)
+	}
+}
+
+// SchedulePatrolRun schedules a patrol
+// @Summary Schedule a patrol run
+// @Description Assign a patrol of a route to a guard. The route's checkpoints are copied onto the run, so later route changes do not affect it.
+// @Tags patrols
+// @Accept json
+// @Produce json
+// @Param run body dto.SchedulePatrolRunDto true "Patrol run"
+// @Success 201 {object} models.PatrolRun
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /patrols/runs [post]
+func (h *Handler) SchedulePatrolRun() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		scheduleDto := &dto.SchedulePatrolRunDto{}
+		if err := c.Bind(scheduleDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(scheduleDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		run, err := h.svc.SchedulePatrolRun(c.Request().Context(), userID, scheduleDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, run)
+	}
+}
+
+// GetPatrolRuns lists patrol runs
+// @Summary Get patrol runs
+// @Description Get patrol runs, most recently scheduled first
+// @Tags patrols
+// @Produce json
+// @Param premise_id query string false "Premise ID"
+// @Param patrol_route_id query string false "Patrol route ID"
+// @Param guard_id query string false "Guard ID"
+// @Param status query string false "Status" Enums(scheduled, in_progress, completed, overdue, cancelled)
+// @Param scheduled_from query string false "Scheduled at or after, RFC3339"
+// @Param scheduled_to query string false "Scheduled before, RFC3339"
+// @Success 200 {array} models.PatrolRun
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /patrols/runs [get]
+func (h *Handler) GetPatrolRuns() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		getRunsDto := &dto.GetPatrolRunsDto{}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, getRunsDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		if err := validation.ValidateStruct(getRunsDto); err != nil {
+			return err
+		}
+		runs, err := h.svc.GetPatrolRuns(c.Request().Context(), getRunsDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, runs)
+	}
+}
+
+// GetPatrolRun gets a patrol run
+// @Summary Get patrol run
+// @Description Get a patrol run with the status of its checkpoints
+// @Tags patrols
+// @Produce json
+// @Param id path string true "Patrol run ID"
+// @Success 200 {object} models.PatrolRun
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /patrols/runs/{id} [get]
+func (h *Handler) GetPatrolRun() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		run, err := h.svc.GetPatrolRun(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, run)
+	}
+}
+
+// CancelPatrolRun cancels a patrol run
+// @Summary Cancel patrol run
+// @Description Cancel a patrol run that has not ended. Cancelled runs raise no alarms.
+// @Tags patrols
+// @Produce json
+// @Param id path string true "Patrol run ID"
+// @Success 200 {object} models.PatrolRun
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /patrols/runs/{id}/cancel [patch]
+func (h *Handler) CancelPatrolRun() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		run, err := h.svc.CancelPatrolRun(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, run)
+	}
+}
+
+// ScanCheckpoint records a checkpoint scan
+// @Summary Scan a checkpoint
+// @Description Submit the code read from a checkpoint's QR or NFC tag during a patrol run. Only the guard assigned to the run can scan. The run starts with its first scan and completes once every checkpoint was scanned; checkpoints scanned after their due time and the route's tolerance are marked late.
+// @Tags patrols
+// @Accept json
+// @Produce json
+// @Param id path string true "Patrol run ID"
+// @Param scan body dto.ScanCheckpointDto true "Checkpoint scan"
+// @Success 200 {object} models.PatrolRun
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /patrols/runs/{id}/scans [post]
+func (h *Handler) ScanCheckpoint() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		scanDto := &dto.ScanCheckpointDto{}
+		if err := c.Bind(scanDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(scanDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		run, err := h.svc.ScanCheckpoint(c.Request().Context(), c.Param("id"), userID, scanDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, run)
+	}
+}
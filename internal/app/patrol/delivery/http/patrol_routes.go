@@ -0,0 +1,16 @@
+package http
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handler) RegisterRoutes(g *echo.Group) {
+	g.POST("/routes", h.CreatePatrolRoute())
+	g.GET("/routes", h.GetPatrolRoutes())
+	g.GET("/routes/:id", h.GetPatrolRoute())
+	g.POST("/runs", h.SchedulePatrolRun())
+	g.GET("/runs", h.GetPatrolRuns())
+	g.GET("/runs/:id", h.GetPatrolRun())
+	g.PATCH("/runs/:id/cancel", h.CancelPatrolRun())
+	g.POST("/runs/:id/scans", h.ScanCheckpoint())
+}
@@ -0,0 +1,21 @@
+package dto
+
+type CreatePatrolRouteDto struct {
+	PremiseID        string `json:"premise_id" validate:"required,uuid"`
+	Name             string `json:"name" validate:"required,min=2,max=100"`
+	Description      string `json:"description" validate:"omitempty,max=255"`
+	DurationMinutes  int    `json:"duration_minutes" validate:"required,gte=1,lte=1440"`
+	ToleranceMinutes int    `json:"tolerance_minutes" validate:"gte=0,lte=120"`
+	// Checkpoints are walked in the given order
+	Checkpoints []CreatePatrolCheckpointDto `json:"checkpoints" validate:"required,min=1,max=100,dive"`
+}
+
+type CreatePatrolCheckpointDto struct {
+	Name     string `json:"name" validate:"required,min=1,max=100"`
+	Code     string `json:"code" validate:"required,max=255"`
+	CodeType string `json:"code_type" validate:"required,oneof=qr nfc"`
+	// ExpectedAfterMinutes is counted from the patrol's scheduled start
+	ExpectedAfterMinutes int      `json:"expected_after_minutes" validate:"gte=0"`
+	Latitude             *float64 `json:"latitude,omitempty"`
+	Longitude            *float64 `json:"longitude,omitempty"`
+}
@@ -0,0 +1,10 @@
+package dto
+
+// ScanCheckpointDto is a checkpoint tag read by the guard walking a patrol.
+type ScanCheckpointDto struct {
+	Code string `json:"code" validate:"required,max=255"`
+	// ScannedAt is when the tag was read, RFC3339, defaults to now
+	ScannedAt string   `json:"scanned_at,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
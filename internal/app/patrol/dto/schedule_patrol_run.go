@@ -0,0 +1,18 @@
+package dto
+
+type SchedulePatrolRunDto struct {
+	PatrolRouteID string `json:"patrol_route_id" validate:"required,uuid"`
+	GuardID       string `json:"guard_id" validate:"required,uuid"`
+	// ScheduledAt is the patrol start, RFC3339
+	ScheduledAt string `json:"scheduled_at" validate:"required"`
+}
+
+// GetPatrolRunsDto holds the query parameters of GET /patrols/runs.
+type GetPatrolRunsDto struct {
+	PremiseID     string `query:"premise_id" validate:"omitempty,uuid"`
+	PatrolRouteID string `query:"patrol_route_id" validate:"omitempty,uuid"`
+	GuardID       string `query:"guard_id" validate:"omitempty,uuid"`
+	Status        string `query:"status" validate:"omitempty,oneof=scheduled in_progress completed overdue cancelled"`
+	ScheduledFrom string `query:"scheduled_from"`
+	ScheduledTo   string `query:"scheduled_to"`
+}
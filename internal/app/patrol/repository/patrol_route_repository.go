@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+
+	"gorm.io/gorm"
+)
+
+type PatrolRouteRepository struct {
+	db *gorm.DB
+}
+
+func NewPatrolRouteRepository(db *gorm.DB) *PatrolRouteRepository {
+	return &PatrolRouteRepository{db: db}
+}
+
+// CreatePatrolRoute creates the route together with its checkpoints.
+func (r *PatrolRouteRepository) CreatePatrolRoute(ctx context.Context, route *models.PatrolRoute) (*models.PatrolRoute, error) {
+	if err := database.Conn(ctx, r.db).Create(route).Error; err != nil {
+		return nil, fmt.Errorf("failed to create patrol route: %w", err)
+	}
+	return route, nil
+}
+
+func orderedCheckpoints(db *gorm.DB) *gorm.DB {
+	return db.Order("sequence")
+}
+
+func (r *PatrolRouteRepository) GetPatrolRoutes(ctx context.Context, premiseID string) ([]models.PatrolRoute, error) {
+	var routes []models.PatrolRoute
+	db := database.Conn(ctx, r.db).Preload("Checkpoints", orderedCheckpoints)
+	if premiseID != "" {
+		db = db.Where("premise_id = ?", premiseID)
+	}
+	if err := db.Order("name").Find(&routes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patrol routes: %w", err)
+	}
+	return routes, nil
+}
+
+func (r *PatrolRouteRepository) GetPatrolRouteByID(ctx context.Context, id string) (*models.PatrolRoute, error) {
+	var route models.PatrolRoute
+	if err := database.Conn(ctx, r.db).Preload("Premise").Preload("Checkpoints", orderedCheckpoints).
+		First(&route, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patrol route: %w", err)
+	}
+	return &route, nil
+}
+
+// GetCheckpointsByCodes returns the checkpoints whose tag has one of the codes.
+func (r *PatrolRouteRepository) GetCheckpointsByCodes(ctx context.Context, codes []string) ([]models.PatrolCheckpoint, error) {
+	var checkpoints []models.PatrolCheckpoint
+	if err := database.Conn(ctx, r.db).Where("code IN ?", codes).Find(&checkpoints).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patrol checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
@@ -0,0 +1,199 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/query"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PatrolRunRepository struct {
+	db *gorm.DB
+}
+
+func NewPatrolRunRepository(db *gorm.DB) *PatrolRunRepository {
+	return &PatrolRunRepository{db: db}
+}
+
+// CreatePatrolRun creates the run together with its checkpoints.
+func (r *PatrolRunRepository) CreatePatrolRun(ctx context.Context, run *models.PatrolRun) (*models.PatrolRun, error) {
+	if err := database.Conn(ctx, r.db).Create(run).Error; err != nil {
+		return nil, fmt.Errorf("failed to create patrol run: %w", err)
+	}
+	return run, nil
+}
+
+// PatrolRunFilter narrows down GetPatrolRuns. Empty fields are ignored.
+type PatrolRunFilter struct {
+	PremiseID     string
+	PatrolRouteID string
+	GuardID       string
+	Status        string
+	ScheduledFrom *time.Time
+	ScheduledTo   *time.Time
+}
+
+func (r *PatrolRunRepository) GetPatrolRuns(ctx context.Context, filter PatrolRunFilter) ([]models.PatrolRun, error) {
+	db := database.Conn(ctx, r.db).Preload("PatrolRoute").Preload("Guard").Scopes(
+		query.Equal("patrol_runs.patrol_route_id", filter.PatrolRouteID),
+		query.Equal("patrol_runs.guard_id", filter.GuardID),
+		query.Equal("patrol_runs.status", filter.Status),
+		query.Between("patrol_runs.scheduled_at", filter.ScheduledFrom, filter.ScheduledTo),
+	)
+	if filter.PremiseID != "" {
+		db = db.Where("patrol_runs.patrol_route_id IN (SELECT id FROM patrol_routes WHERE premise_id = ?)", filter.PremiseID)
+	}
+	var runs []models.PatrolRun
+	if err := db.Order("patrol_runs.scheduled_at DESC").Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patrol runs: %w", err)
+	}
+	return runs, nil
+}
+
+func (r *PatrolRunRepository) GetPatrolRunByID(ctx context.Context, id string) (*models.PatrolRun, error) {
+	var run models.PatrolRun
+	if err := database.Conn(ctx, r.db).Preload("PatrolRoute").Preload("Guard").Preload("Checkpoints", orderedCheckpoints).
+		First(&run, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patrol run: %w", err)
+	}
+	return &run, nil
+}
+
+// GetPatrolRunForUpdate locks the run until the transaction ends, so scans
+// of the same run are applied one after the other. The run comes with its
+// route and checkpoints.
+func (r *PatrolRunRepository) GetPatrolRunForUpdate(ctx context.Context, id string) (*models.PatrolRun, error) {
+	var run models.PatrolRun
+	if err := database.Conn(ctx, r.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&run, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patrol run: %w", err)
+	}
+	run.PatrolRoute = &models.PatrolRoute{}
+	if err := database.Conn(ctx, r.db).First(run.PatrolRoute, "id = ?", run.PatrolRouteID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patrol route: %w", err)
+	}
+	if err := database.Conn(ctx, r.db).Where("patrol_run_id = ?", run.ID).Order("sequence").Find(&run.Checkpoints).Error; err != nil {
+		return nil, fmt.Errorf("failed to get patrol run checkpoints: %w", err)
+	}
+	return &run, nil
+}
+
+func (r *PatrolRunRepository) UpdatePatrolRunFields(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	if err := database.Conn(ctx, r.db).Model(&models.PatrolRun{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+		return fmt.Errorf("failed to update patrol run: %w", err)
+	}
+	return nil
+}
+
+func (r *PatrolRunRepository) UpdateRunCheckpointFields(ctx context.Context, id uuid.UUID, fields map[string]interface{}) error {
+	if err := database.Conn(ctx, r.db).Model(&models.PatrolRunCheckpoint{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+		return fmt.Errorf("failed to update patrol run checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (r *PatrolRunRepository) CreatePatrolScan(ctx context.Context, scan *models.PatrolScan) error {
+	if err := database.Conn(ctx, r.db).Create(scan).Error; err != nil {
+		return fmt.Errorf("failed to create patrol scan: %w", err)
+	}
+	return nil
+}
+
+// CancelPatrolRun cancels the run unless it already ended. It reports whether
+// the run was cancelled.
+func (r *PatrolRunRepository) CancelPatrolRun(ctx context.Context, id string) (bool, error) {
+	result := database.Conn(ctx, r.db).Model(&models.PatrolRun{}).
+		Where("id = ? AND status NOT IN ('completed', 'cancelled')", id).
+		Update("status", "cancelled")
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to cancel patrol run: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// activeRuns are the runs still being walked or waited for.
+const activeRuns = "patrol_runs.status IN ('scheduled', 'in_progress', 'overdue')"
+
+// MissedCheckpoint is a pending checkpoint past its due time and the route's
+// tolerance, with what an alarm about it needs.
+type MissedCheckpoint struct {
+	ID          uuid.UUID
+	Name        string
+	DueAt       time.Time
+	PatrolRunID uuid.UUID
+	RouteName   string
+	PremiseID   uuid.UUID
+	GuardName   string
+}
+
+func (r *PatrolRunRepository) GetMissedCheckpoints(ctx context.Context, now time.Time) ([]MissedCheckpoint, error) {
+	var checkpoints []MissedCheckpoint
+	if err := database.Conn(ctx, r.db).Table("patrol_run_checkpoints").
+		Select("patrol_run_checkpoints.id, patrol_run_checkpoints.name, patrol_run_checkpoints.due_at, patrol_run_checkpoints.patrol_run_id, patrol_routes.name AS route_name, patrol_routes.premise_id, users.name AS guard_name").
+		Joins("JOIN patrol_runs ON patrol_runs.id = patrol_run_checkpoints.patrol_run_id").
+		Joins("JOIN patrol_routes ON patrol_routes.id = patrol_runs.patrol_route_id").
+		Joins("JOIN users ON users.id = patrol_runs.guard_id").
+		Where("patrol_run_checkpoints.status = 'pending' AND "+activeRuns).
+		Where("patrol_run_checkpoints.due_at + make_interval(mins => patrol_routes.tolerance_minutes) < ?", now).
+		Order("patrol_run_checkpoints.due_at").
+		Scan(&checkpoints).Error; err != nil {
+		return nil, fmt.Errorf("failed to get missed patrol checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// MarkCheckpointMissed marks a pending checkpoint as missed. It reports
+// whether the checkpoint was still pending, so a miss is only reported once.
+func (r *PatrolRunRepository) MarkCheckpointMissed(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := database.Conn(ctx, r.db).Model(&models.PatrolRunCheckpoint{}).
+		Where("id = ? AND status = 'pending'", id).
+		Update("status", "missed")
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark patrol checkpoint missed: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// OverdueRun is a patrol run past its due time and the route's tolerance.
+type OverdueRun struct {
+	ID          uuid.UUID
+	DueAt       time.Time
+	RouteName   string
+	PremiseID   uuid.UUID
+	GuardName   string
+	Outstanding int
+}
+
+func (r *PatrolRunRepository) GetOverdueRuns(ctx context.Context, now time.Time) ([]OverdueRun, error) {
+	var runs []OverdueRun
+	if err := database.Conn(ctx, r.db).Table("patrol_runs").
+		Select(`patrol_runs.id, patrol_runs.due_at, patrol_routes.name AS route_name, patrol_routes.premise_id, users.name AS guard_name,
+			(SELECT count(*) FROM patrol_run_checkpoints c WHERE c.patrol_run_id = patrol_runs.id AND c.scanned_at IS NULL) AS outstanding`).
+		Joins("JOIN patrol_routes ON patrol_routes.id = patrol_runs.patrol_route_id").
+		Joins("JOIN users ON users.id = patrol_runs.guard_id").
+		Where("patrol_runs.status IN ('scheduled', 'in_progress')").
+		Where("patrol_runs.due_at + make_interval(mins => patrol_routes.tolerance_minutes) < ?", now).
+		Order("patrol_runs.due_at").
+		Scan(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get overdue patrol runs: %w", err)
+	}
+	return runs, nil
+}
+
+// MarkRunOverdue marks a scheduled or started run as overdue. It reports
+// whether the run was still open, so an overdue run is only reported once.
+func (r *PatrolRunRepository) MarkRunOverdue(ctx context.Context, id uuid.UUID) (bool, error) {
+	result := database.Conn(ctx, r.db).Model(&models.PatrolRun{}).
+		Where("id = ? AND status IN ('scheduled', 'in_progress')", id).
+		Update("status", "overdue")
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark patrol run overdue: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
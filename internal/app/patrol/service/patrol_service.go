@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	stdErrors "errors"
+	"fmt"
+	alarmDto "scs-operator/internal/app/alarm/dto"
+	alarmServices "scs-operator/internal/app/alarm/service"
+	guardRepositories "scs-operator/internal/app/guard/repository"
+	"scs-operator/internal/app/patrol/dto"
+	repositories "scs-operator/internal/app/patrol/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/geo"
+	"scs-operator/pkg/query"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Service struct {
+	routeRepo    repositories.PatrolRouteRepository
+	runRepo      repositories.PatrolRunRepository
+	premiseRepo  premiseRepositories.PremiseRepository
+	guardRepo    guardRepositories.GuardRepository
+	alarmService alarmServices.Service
+	transactor   database.Transactor
+}
+
+func NewPatrolService(routeRepo repositories.PatrolRouteRepository, runRepo repositories.PatrolRunRepository, premiseRepo premiseRepositories.PremiseRepository, guardRepo guardRepositories.GuardRepository, alarmService alarmServices.Service, transactor database.Transactor) *Service {
+	return &Service{routeRepo: routeRepo, runRepo: runRepo, premiseRepo: premiseRepo, guardRepo: guardRepo, alarmService: alarmService, transactor: transactor}
+}
+
+func (s *Service) CreatePatrolRoute(ctx context.Context, createRouteDto *dto.CreatePatrolRouteDto) (*models.PatrolRoute, error) {
+	premise, err := s.premiseRepo.GetPremiseByID(ctx, createRouteDto.PremiseID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("premise")
+	}
+	route := &models.PatrolRoute{
+		PremiseID:        premise.ID,
+		Name:             createRouteDto.Name,
+		Description:      createRouteDto.Description,
+		DurationMinutes:  createRouteDto.DurationMinutes,
+		ToleranceMinutes: createRouteDto.ToleranceMinutes,
+	}
+	codes := make([]string, 0, len(createRouteDto.Checkpoints))
+	seen := make(map[string]bool, len(createRouteDto.Checkpoints))
+	for i, checkpointDto := range createRouteDto.Checkpoints {
+		if seen[checkpointDto.Code] {
+			return nil, errors.NewBadRequestError(fmt.Sprintf("Checkpoint code %s is used more than once", checkpointDto.Code))
+		}
+		seen[checkpointDto.Code] = true
+		codes = append(codes, checkpointDto.Code)
+		if checkpointDto.ExpectedAfterMinutes > createRouteDto.DurationMinutes {
+			return nil, errors.NewBadRequestError(fmt.Sprintf("Checkpoint %s is expected after the patrol ends", checkpointDto.Name))
+		}
+		point, err := geo.NewPoint(checkpointDto.Latitude, checkpointDto.Longitude)
+		if err != nil {
+			return nil, errors.NewBadRequestError(err.Error())
+		}
+		latitude, longitude := point.Coordinates()
+		route.Checkpoints = append(route.Checkpoints, models.PatrolCheckpoint{
+			Sequence:             i + 1,
+			Name:                 checkpointDto.Name,
+			Code:                 checkpointDto.Code,
+			CodeType:             checkpointDto.CodeType,
+			ExpectedAfterMinutes: checkpointDto.ExpectedAfterMinutes,
+			Latitude:             latitude,
+			Longitude:            longitude,
+		})
+	}
+	existing, err := s.routeRepo.GetCheckpointsByCodes(ctx, codes)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get patrol checkpoints", err)
+	}
+	if len(existing) > 0 {
+		return nil, errors.NewConflictError(fmt.Sprintf("Checkpoint code %s is already in use", existing[0].Code))
+	}
+	createdRoute, err := s.routeRepo.CreatePatrolRoute(ctx, route)
+	if err != nil {
+		return nil, errors.NewDatabaseError("create patrol route", err)
+	}
+	return createdRoute, nil
+}
+
+func (s *Service) GetPatrolRoutes(ctx context.Context, premiseID string) ([]models.PatrolRoute, error) {
+	routes, err := s.routeRepo.GetPatrolRoutes(ctx, premiseID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get patrol routes", err)
+	}
+	return routes, nil
+}
+
+func (s *Service) GetPatrolRoute(ctx context.Context, id string) (*models.PatrolRoute, error) {
+	route, err := s.routeRepo.GetPatrolRouteByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewNotFoundError("patrol route")
+	}
+	return route, nil
+}
+
+// SchedulePatrolRun assigns a patrol of a route to a guard. The route's
+// checkpoints are copied onto the run with their due times.
+func (s *Service) SchedulePatrolRun(ctx context.Context, actorID string, scheduleDto *dto.SchedulePatrolRunDto) (*models.PatrolRun, error) {
+	scheduledAt, err := query.ParseTime("scheduled_at", scheduleDto.ScheduledAt)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	route, err := s.routeRepo.GetPatrolRouteByID(ctx, scheduleDto.PatrolRouteID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("patrol route")
+	}
+	guard, err := s.guardRepo.GetGuardByID(ctx, scheduleDto.GuardID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guard")
+	}
+	dueAt := scheduledAt.Add(time.Duration(route.DurationMinutes) * time.Minute)
+	if dueAt.Add(time.Duration(route.ToleranceMinutes) * time.Minute).Before(time.Now()) {
+		return nil, errors.NewBadRequestError("Patrol would already be overdue")
+	}
+	run := &models.PatrolRun{
+		PatrolRouteID: route.ID,
+		GuardID:       guard.ID,
+		Status:        "scheduled",
+		ScheduledAt:   *scheduledAt,
+		DueAt:         dueAt,
+	}
+	if actor, err := uuid.Parse(actorID); err == nil {
+		run.AssignedByID = &actor
+	}
+	for _, checkpoint := range route.Checkpoints {
+		run.Checkpoints = append(run.Checkpoints, models.PatrolRunCheckpoint{
+			PatrolCheckpointID: checkpoint.ID,
+			Sequence:           checkpoint.Sequence,
+			Name:               checkpoint.Name,
+			Code:               checkpoint.Code,
+			Status:             "pending",
+			DueAt:              scheduledAt.Add(time.Duration(checkpoint.ExpectedAfterMinutes) * time.Minute),
+		})
+	}
+	createdRun, err := s.runRepo.CreatePatrolRun(ctx, run)
+	if err != nil {
+		return nil, errors.NewDatabaseError("schedule patrol run", err)
+	}
+	return createdRun, nil
+}
+
+func (s *Service) GetPatrolRuns(ctx context.Context, getRunsDto *dto.GetPatrolRunsDto) ([]models.PatrolRun, error) {
+	scheduledFrom, err := query.ParseTime("scheduled_from", getRunsDto.ScheduledFrom)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	scheduledTo, err := query.ParseTime("scheduled_to", getRunsDto.ScheduledTo)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	runs, err := s.runRepo.GetPatrolRuns(ctx, repositories.PatrolRunFilter{
+		PremiseID:     getRunsDto.PremiseID,
+		PatrolRouteID: getRunsDto.PatrolRouteID,
+		GuardID:       getRunsDto.GuardID,
+		Status:        getRunsDto.Status,
+		ScheduledFrom: scheduledFrom,
+		ScheduledTo:   scheduledTo,
+	})
+	if err != nil {
+		return nil, errors.NewDatabaseError("get patrol runs", err)
+	}
+	return runs, nil
+}
+
+func (s *Service) GetPatrolRun(ctx context.Context, id string) (*models.PatrolRun, error) {
+	run, err := s.runRepo.GetPatrolRunByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewNotFoundError("patrol run")
+	}
+	return run, nil
+}
+
+func (s *Service) CancelPatrolRun(ctx context.Context, id string) (*models.PatrolRun, error) {
+	if _, err := s.runRepo.GetPatrolRunByID(ctx, id); err != nil {
+		return nil, errors.NewNotFoundError("patrol run")
+	}
+	cancelled, err := s.runRepo.CancelPatrolRun(ctx, id)
+	if err != nil {
+		return nil, errors.NewDatabaseError("cancel patrol run", err)
+	}
+	if !cancelled {
+		return nil, errors.NewConflictError("Patrol run has already ended")
+	}
+	return s.GetPatrolRun(ctx, id)
+}
+
+// ScanCheckpoint records a checkpoint tag read by the guard walking the run.
+// The run starts with its first scan and completes once every checkpoint was
+// scanned. Checkpoints scanned after their tolerance are marked late.
+func (s *Service) ScanCheckpoint(ctx context.Context, runID string, guardID string, scanDto *dto.ScanCheckpointDto) (*models.PatrolRun, error) {
+	scannedAt, err := query.ParseTime("scanned_at", scanDto.ScannedAt)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	point, err := geo.NewPoint(scanDto.Latitude, scanDto.Longitude)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	now := time.Now()
+	// Device clocks drift, a tag is never read in the future
+	if scannedAt == nil || scannedAt.After(now) {
+		scannedAt = &now
+	}
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		run, err := s.runRepo.GetPatrolRunForUpdate(ctx, runID)
+		if err != nil {
+			return errors.NewNotFoundError("patrol run")
+		}
+		if run.GuardID.String() != guardID {
+			return errors.NewBadRequestError("Patrol run is assigned to another guard")
+		}
+		if run.Status == "completed" || run.Status == "cancelled" {
+			return errors.NewConflictError("Patrol run has already ended")
+		}
+		tolerance := time.Duration(run.PatrolRoute.ToleranceMinutes) * time.Minute
+
+		var checkpoint *models.PatrolRunCheckpoint
+		remaining := 0
+		for i := range run.Checkpoints {
+			if run.Checkpoints[i].Code == scanDto.Code {
+				checkpoint = &run.Checkpoints[i]
+			} else if run.Checkpoints[i].ScannedAt == nil {
+				remaining++
+			}
+		}
+		if checkpoint == nil {
+			return errors.NewNotFoundError("checkpoint")
+		}
+		if checkpoint.ScannedAt != nil {
+			return errors.NewConflictError("Checkpoint was already scanned")
+		}
+		status := "scanned"
+		if checkpoint.Status == "missed" || scannedAt.After(checkpoint.DueAt.Add(tolerance)) {
+			status = "late"
+		}
+		latitude, longitude := point.Coordinates()
+		if err := s.runRepo.CreatePatrolScan(ctx, &models.PatrolScan{
+			PatrolRunID:           run.ID,
+			PatrolRunCheckpointID: checkpoint.ID,
+			GuardID:               run.GuardID,
+			Code:                  scanDto.Code,
+			ScannedAt:             *scannedAt,
+			Latitude:              latitude,
+			Longitude:             longitude,
+		}); err != nil {
+			return errors.NewDatabaseError("record patrol scan", err)
+		}
+		if err := s.runRepo.UpdateRunCheckpointFields(ctx, checkpoint.ID, map[string]interface{}{
+			"status":     status,
+			"scanned_at": *scannedAt,
+		}); err != nil {
+			return errors.NewDatabaseError("update patrol checkpoint", err)
+		}
+
+		fields := map[string]interface{}{}
+		if run.StartedAt == nil {
+			fields["started_at"] = *scannedAt
+			if run.Status == "scheduled" {
+				fields["status"] = "in_progress"
+			}
+		}
+		if remaining == 0 {
+			fields["status"] = "completed"
+			fields["completed_at"] = *scannedAt
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+		if err := s.runRepo.UpdatePatrolRunFields(ctx, run.ID, fields); err != nil {
+			return errors.NewDatabaseError("update patrol run", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPatrolRun(ctx, runID)
+}
+
+// CheckPatrols marks checkpoints that were not scanned in time as missed and
+// patrols that were not completed in time as overdue, raising an alarm for
+// each of them. Each is marked with its alarm in its own transaction, so one
+// failing does not hold up the others.
+func (s *Service) CheckPatrols(ctx context.Context) error {
+	now := time.Now()
+	checkpoints, err := s.runRepo.GetMissedCheckpoints(ctx, now)
+	if err != nil {
+		return errors.NewDatabaseError("get missed patrol checkpoints", err)
+	}
+	var failures []error
+	for _, checkpoint := range checkpoints {
+		if err := s.markCheckpointMissed(ctx, checkpoint); err != nil {
+			failures = append(failures, fmt.Errorf("checkpoint %s of patrol %s: %w", checkpoint.Name, checkpoint.RouteName, err))
+		}
+	}
+
+	runs, err := s.runRepo.GetOverdueRuns(ctx, now)
+	if err != nil {
+		failures = append(failures, errors.NewDatabaseError("get overdue patrol runs", err))
+		return stdErrors.Join(failures...)
+	}
+	for _, run := range runs {
+		if err := s.markRunOverdue(ctx, run); err != nil {
+			failures = append(failures, fmt.Errorf("patrol run %s: %w", run.ID, err))
+		}
+	}
+	return stdErrors.Join(failures...)
+}
+
+// markCheckpointMissed flags the checkpoint missed and raises its alarm in one
+// transaction, so a checkpoint is never missed without an alarm.
+func (s *Service) markCheckpointMissed(ctx context.Context, checkpoint repositories.MissedCheckpoint) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		marked, err := s.runRepo.MarkCheckpointMissed(ctx, checkpoint.ID)
+		if err != nil {
+			return errors.NewDatabaseError("mark patrol checkpoint missed", err)
+		}
+		if !marked {
+			return nil
+		}
+		alarm, err := s.alarmService.CreateAlarm(ctx, &alarmDto.CreateAlarmDto{
+			PremiseID:   checkpoint.PremiseID.String(),
+			Type:        "patrol_checkpoint_missed",
+			Description: fmt.Sprintf("%s missed checkpoint %s of patrol %s, due %s", checkpoint.GuardName, checkpoint.Name, checkpoint.RouteName, checkpoint.DueAt.Format(time.RFC3339)),
+			Severity:    "medium",
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.runRepo.UpdateRunCheckpointFields(ctx, checkpoint.ID, map[string]interface{}{"alarm_id": alarm.ID}); err != nil {
+			return errors.NewDatabaseError("update patrol checkpoint", err)
+		}
+		return nil
+	})
+}
+
+// markRunOverdue flags the run overdue and raises its alarm in one transaction.
+func (s *Service) markRunOverdue(ctx context.Context, run repositories.OverdueRun) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		marked, err := s.runRepo.MarkRunOverdue(ctx, run.ID)
+		if err != nil {
+			return errors.NewDatabaseError("mark patrol run overdue", err)
+		}
+		if !marked {
+			return nil
+		}
+		alarm, err := s.alarmService.CreateAlarm(ctx, &alarmDto.CreateAlarmDto{
+			PremiseID:   run.PremiseID.String(),
+			Type:        "patrol_overdue",
+			Description: fmt.Sprintf("Patrol %s by %s was due %s with %d checkpoints not scanned", run.RouteName, run.GuardName, run.DueAt.Format(time.RFC3339), run.Outstanding),
+			Severity:    "high",
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.runRepo.UpdatePatrolRunFields(ctx, run.ID, map[string]interface{}{"overdue_alarm_id": alarm.ID}); err != nil {
+			return errors.NewDatabaseError("update patrol run", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	guardRepositories "scs-operator/internal/app/guard/repository"
+	"scs-operator/internal/app/patrol/dto"
+	repositories "scs-operator/internal/app/patrol/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	"scs-operator/internal/testsupport"
+	"scs-operator/internal/testsupport/alarmtest"
+	database "scs-operator/pkg/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	testRunID        = "0f8b2c52-5d43-4a8e-9d6c-3b1a7e0c9a11"
+	testRouteID      = "7c4e1d2a-9b3f-4e6a-8d1c-2f5b6a7e8d90"
+	testGuardID      = "5a1b7f9d-aa6e-4e6d-8eaa-8e5f2e8a7b06"
+	testPremiseID    = "b2d4f6a8-1c3e-4a5b-9d7f-0e2c4a6b8d1f"
+	testCheckpointID = "3e5a7c9b-2d4f-4b6a-8c1e-9f0a2b4c6d8e"
+	testAlarmID      = "3e9f5d7b-88c4-4c4b-8c88-6c3d0c6e5f04"
+)
+
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := testsupport.NewMockDB(t)
+	svc := NewPatrolService(
+		*repositories.NewPatrolRouteRepository(db),
+		*repositories.NewPatrolRunRepository(db),
+		*premiseRepositories.NewPremiseRepository(db),
+		*guardRepositories.NewGuardRepository(db),
+		*alarmtest.NewService(t, db),
+		*database.NewTransactor(db),
+	)
+	return svc, mock
+}
+
+func TestCreatePatrolRoute(t *testing.T) {
+	expectPremise := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testPremiseID, "Head office"))
+	}
+	route := func(checkpoints ...dto.CreatePatrolCheckpointDto) dto.CreatePatrolRouteDto {
+		return dto.CreatePatrolRouteDto{PremiseID: testPremiseID, Name: "Night round", DurationMinutes: 30, Checkpoints: checkpoints}
+	}
+	tests := []struct {
+		name           string
+		route          dto.CreatePatrolRouteDto
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name: "Codes are unique within the route",
+			route: route(
+				dto.CreatePatrolCheckpointDto{Name: "Gate", Code: "A1", CodeType: "qr"},
+				dto.CreatePatrolCheckpointDto{Name: "Dock", Code: "A1", CodeType: "qr"},
+			),
+			expect:         expectPremise,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Checkpoints are due within the patrol",
+			route:          route(dto.CreatePatrolCheckpointDto{Name: "Gate", Code: "A1", CodeType: "qr", ExpectedAfterMinutes: 45}),
+			expect:         expectPremise,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Codes are unique across routes",
+			route: route(dto.CreatePatrolCheckpointDto{Name: "Gate", Code: "A1", CodeType: "qr"}),
+			expect: func(mock sqlmock.Sqlmock) {
+				expectPremise(mock)
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "patrol_checkpoints" WHERE code IN ($1)`)).
+					WithArgs("A1").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(testCheckpointID, "A1"))
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			_, err := svc.CreatePatrolRoute(context.Background(), &tt.route)
+			testsupport.AssertAppError(t, err, tt.expectedStatus)
+		})
+	}
+}
+
+func TestScanCheckpoint(t *testing.T) {
+	scheduledAt := time.Now().Add(-20 * time.Minute)
+	expectRun := func(mock sqlmock.Sqlmock, guardID string, status string) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "patrol_runs" WHERE id = $1 ORDER BY "patrol_runs"."id" LIMIT $2 FOR UPDATE`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "patrol_route_id", "guard_id", "status", "scheduled_at", "due_at"}).
+				AddRow(testRunID, testRouteID, guardID, status, scheduledAt, scheduledAt.Add(30*time.Minute)))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "patrol_routes" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "duration_minutes", "tolerance_minutes"}).AddRow(testRouteID, 30, 5))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "patrol_run_checkpoints" WHERE patrol_run_id = $1 ORDER BY sequence`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "sequence", "code", "status", "due_at"}).
+				AddRow(testCheckpointID, 1, "A1", "pending", scheduledAt.Add(10*time.Minute)))
+	}
+	tests := []struct {
+		name           string
+		guardID        string
+		code           string
+		expect         func(sqlmock.Sqlmock)
+		expectedStatus int
+	}{
+		{
+			name:    "Only the assigned guard can scan",
+			guardID: "9d8c7b6a-5f4e-4d3c-8b2a-1f0e9d8c7b6a",
+			code:    "A1",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectRun(mock, testGuardID, "scheduled")
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Unknown codes are rejected",
+			guardID: testGuardID,
+			code:    "B7",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectRun(mock, testGuardID, "scheduled")
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:    "Ended runs cannot be scanned",
+			guardID: testGuardID,
+			code:    "A1",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectRun(mock, testGuardID, "cancelled")
+				mock.ExpectRollback()
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:    "Scanning the last checkpoint late completes the run",
+			guardID: testGuardID,
+			code:    "A1",
+			expect: func(mock sqlmock.Sqlmock) {
+				expectRun(mock, testGuardID, "scheduled")
+				mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "patrol_scans"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testCheckpointID))
+				mock.ExpectExec(testsupport.QuoteSQL(`UPDATE "patrol_run_checkpoints" SET "scanned_at"=$1,"status"=$2,"updated_at"=$3 WHERE id = $4`)).
+					WithArgs(sqlmock.AnyArg(), "late", sqlmock.AnyArg(), testCheckpointID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(testsupport.QuoteSQL(`UPDATE "patrol_runs" SET "completed_at"=$1,"started_at"=$2,"status"=$3,"updated_at"=$4 WHERE id = $5`)).
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "completed", sqlmock.AnyArg(), testRunID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "patrol_runs" WHERE id = $1`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(testRunID, "completed"))
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "patrol_run_checkpoints"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			run, err := svc.ScanCheckpoint(context.Background(), testRunID, tt.guardID, &dto.ScanCheckpointDto{Code: tt.code})
+			if tt.expectedStatus != 0 {
+				testsupport.AssertAppError(t, err, tt.expectedStatus)
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if run.Status != "completed" {
+				t.Errorf("expected a completed run, got %s", run.Status)
+			}
+		})
+	}
+}
+
+// expectPremiseAlarm expects the alarm service to raise an alarm on the test
+// premise, failing the insert with err when given.
+func expectPremiseAlarm(mock sqlmock.Sqlmock, err error) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(testPremiseID, "Head office"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`WITH RECURSIVE ancestors`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testPremiseID))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "maintenance_windows"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "premises"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	insert := mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "alarms"`))
+	if err != nil {
+		insert.WillReturnError(err)
+		return
+	}
+	insert.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testAlarmID))
+}
+
+func TestCheckPatrols(t *testing.T) {
+	const otherCheckpointID = "4f6b8d0c-3e5a-4c7b-9d2f-0a1b3c5d7e9f"
+	const handledCheckpointID = "5a7c9e1d-4f6b-4d8c-8e3a-1b2c4d6e8f0a"
+	svc, mock := newTestService(t)
+	due := time.Now().Add(-time.Hour)
+	markMissed := `UPDATE "patrol_run_checkpoints" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = 'pending'`
+	mock.ExpectQuery(testsupport.QuoteSQL(`FROM "patrol_run_checkpoints" JOIN patrol_runs`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "due_at", "patrol_run_id", "route_name", "premise_id", "guard_name"}).
+			AddRow(testCheckpointID, "Gate", due, testRunID, "Night round", testPremiseID, "Sam").
+			AddRow(otherCheckpointID, "Dock", due, testRunID, "Night round", testPremiseID, "Sam").
+			AddRow(handledCheckpointID, "Roof", due, testRunID, "Night round", testPremiseID, "Sam"))
+	// The first checkpoint's alarm fails, it is rolled back to be retried and
+	// the other checkpoints and the overdue run are still checked
+	mock.ExpectBegin()
+	testsupport.ExpectExec(mock, markMissed, nil)
+	expectPremiseAlarm(mock, testsupport.ErrInjected)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	testsupport.ExpectExec(mock, markMissed, nil)
+	expectPremiseAlarm(mock, nil)
+	mock.ExpectExec(testsupport.QuoteSQL(`UPDATE "patrol_run_checkpoints" SET "alarm_id"=$1,"updated_at"=$2 WHERE id = $3`)).
+		WithArgs(testAlarmID, sqlmock.AnyArg(), otherCheckpointID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// A checkpoint scanned or marked meanwhile raises no alarm
+	mock.ExpectBegin()
+	mock.ExpectExec(testsupport.QuoteSQL(markMissed)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(testsupport.QuoteSQL(`FROM "patrol_runs" JOIN patrol_routes`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "due_at", "route_name", "premise_id", "guard_name", "outstanding"}).
+			AddRow(testRunID, due, "Night round", testPremiseID, "Sam", 2))
+	mock.ExpectBegin()
+	testsupport.ExpectExec(mock, `UPDATE "patrol_runs" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status IN ('scheduled', 'in_progress')`, nil)
+	expectPremiseAlarm(mock, nil)
+	mock.ExpectExec(testsupport.QuoteSQL(`UPDATE "patrol_runs" SET "overdue_alarm_id"=$1,"updated_at"=$2 WHERE id = $3`)).
+		WithArgs(testAlarmID, sqlmock.AnyArg(), testRunID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := svc.CheckPatrols(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "checkpoint Gate of patrol Night round") {
+		t.Fatalf("expected the failure of checkpoint Gate, got %v", err)
+	}
+	if strings.Contains(err.Error(), "Dock") || strings.Contains(err.Error(), "patrol run") {
+		t.Errorf("expected only checkpoint Gate to fail, got %v", err)
+	}
+}
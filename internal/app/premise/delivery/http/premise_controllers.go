@@ -4,8 +4,8 @@ import (
 	"scs-operator/internal/app/premise/dto"
 	services "scs-operator/internal/app/premise/service"
 	"scs-operator/pkg/errors"
+	"scs-operator/pkg/query"
 	"scs-operator/pkg/validation"
-	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
@@ -53,35 +53,30 @@ func (h *Handler) CreatePremise() echo.HandlerFunc {
 
 // GetPremises retrieves a paginated list of premises
 // @Summary Get premises with pagination
-// @Description Get a paginated list of all premises
+// @Description Get a paginated list of all premises. Follow next_cursor for stable keyset pagination; the total is counted unless a cursor is given.
 // @Tags premises
 // @Accept json
 // @Produce json
-// @Param page query int false "Page number" default(1)
-// @Param limit query int false "Number of items per page" default(10)
+// @Param page query int false "Page number, ignored with a cursor" default(1)
+// @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param include_total query bool false "Count the premises, defaults to true without a cursor"
+// @Param sort query string false "Comma separated sort fields (name, created_at), prefix with - for descending" default(name)
 // @Success 200 {object} types.PremiseListResponse
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Router /premises [get]
 func (h *Handler) GetPremises() echo.HandlerFunc {
 	return func(c echo.Context) error {
-		page := c.QueryParam("page")
-		limit := c.QueryParam("limit")
-		if page == "" {
-			page = "1"
+		request := query.NewRequest()
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, &request); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
 		}
-		if limit == "" {
-			limit = "10"
-		}
-		pageInt, err := strconv.Atoi(page)
-		if err != nil {
-			return errors.NewBadRequestError("Invalid page number")
-		}
-		limitInt, err := strconv.Atoi(limit)
-		if err != nil {
-			return errors.NewBadRequestError("Invalid limit number")
+
+		if err := validation.ValidateStruct(request); err != nil {
+			return err
 		}
-		premises, err := h.svc.GetPremises(c.Request().Context(), pageInt, limitInt)
+		premises, err := h.svc.GetPremises(c.Request().Context(), request)
 		if err != nil {
 			return err
 		}
@@ -1,7 +1,16 @@
 package dto
 
+import "scs-operator/pkg/geo"
+
 type CreatePremiseDto struct {
 	Name            string `json:"name" validate:"required,min=2,max=100"`
 	Address         string `json:"address" validate:"required,min=2,max=255"`
 	ParentPremiseID string `json:"parent_premise_id,omitempty" validate:"omitempty,uuid"`
+	// The position is given either as latitude and longitude or as a GeoJSON point
+	Latitude  *float64   `json:"latitude,omitempty"`
+	Longitude *float64   `json:"longitude,omitempty"`
+	Geometry  *geo.Point `json:"geometry,omitempty"`
+	// GeofenceRadius in meters bounds where steps requiring a location may be
+	// completed
+	GeofenceRadius *float64 `json:"geofence_radius,omitempty" validate:"omitempty,gt=0"`
 }
@@ -1,6 +1,16 @@
 package dto
 
+import "scs-operator/pkg/geo"
+
 type UpdatePremiseDto struct {
 	Name    string `json:"name" validate:"required,min=2,max=100"`
 	Address string `json:"address" validate:"required,min=2,max=255"`
+	// The position is given either as latitude and longitude or as a GeoJSON
+	// point, leaving both out clears it
+	Latitude  *float64   `json:"latitude,omitempty"`
+	Longitude *float64   `json:"longitude,omitempty"`
+	Geometry  *geo.Point `json:"geometry,omitempty"`
+	// GeofenceRadius in meters bounds where steps requiring a location may be
+	// completed, leaving it out restores the default
+	GeofenceRadius *float64 `json:"geofence_radius,omitempty" validate:"omitempty,gt=0"`
 }
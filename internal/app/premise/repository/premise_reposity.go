@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"scs-operator/internal/models"
+	"scs-operator/pkg/query"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -23,12 +25,21 @@ func (r *PremiseRepository) CreatePremise(ctx context.Context, Premise *models.P
 	return Premise, nil
 }
 
-func (r *PremiseRepository) GetPremises(ctx context.Context, page int, limit int) ([]models.Premise, error) {
-	var Premises []models.Premise
-	if err := r.db.WithContext(ctx).Limit(limit).Offset((page - 1) * limit).Find(&Premises).Error; err != nil {
-		return nil, fmt.Errorf("failed to get Premises: %w", err)
+// PremiseSchema lists the fields premises can be sorted by.
+var PremiseSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"name":       {Column: "name"},
+		"created_at": {Column: "created_at"},
+	},
+	DefaultSort: "name",
+}
+
+func (r *PremiseRepository) GetPremises(ctx context.Context, params query.Params) ([]models.Premise, query.PageInfo, error) {
+	Premises, page, err := query.Find[models.Premise](r.db.WithContext(ctx).Model(&models.Premise{}), params)
+	if err != nil {
+		return nil, page, fmt.Errorf("failed to get Premises: %w", err)
 	}
-	return Premises, nil
+	return Premises, page, nil
 }
 
 func (r *PremiseRepository) GetPremisesCount(ctx context.Context) (int64, error) {
@@ -59,9 +70,39 @@ func (r *PremiseRepository) GetAvailableUsers(ctx context.Context, premiseID str
 }
 
 func (r *PremiseRepository) UpdatePremise(ctx context.Context, id string, premise *models.Premise) (*models.Premise, error) {
-	result := r.db.WithContext(ctx).Model(&models.Premise{}).Where("id = ?", id).Updates(premise)
+	// Select the columns so a cleared position or radius is written as well
+	result := r.db.WithContext(ctx).Model(&models.Premise{}).Where("id = ?", id).
+		Select("name", "address", "latitude", "longitude", "geofence_radius").Updates(premise)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to update premise: %w", result.Error)
 	}
 	return premise, nil
 }
+
+// GetAncestorIDs returns the premise ID followed by the IDs of all its parents.
+// UNION drops rows already seen, so a cycle in the hierarchy ends the walk.
+func (r *PremiseRepository) GetAncestorIDs(ctx context.Context, id string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := r.db.WithContext(ctx).Raw(`WITH RECURSIVE ancestors AS (
+		SELECT id, parent_premise_id FROM premises WHERE id = ?
+		UNION
+		SELECT p.id, p.parent_premise_id FROM premises p JOIN ancestors a ON p.id = a.parent_premise_id
+	) SELECT id FROM ancestors`, id).Scan(&ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get premise ancestors: %w", err)
+	}
+	return ids, nil
+}
+
+// GetDescendantIDs returns the premise ID followed by the IDs of all its sub-premises.
+// UNION drops rows already seen, so a cycle in the hierarchy ends the walk.
+func (r *PremiseRepository) GetDescendantIDs(ctx context.Context, id string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := r.db.WithContext(ctx).Raw(`WITH RECURSIVE descendants AS (
+		SELECT id FROM premises WHERE id = ?
+		UNION
+		SELECT p.id FROM premises p JOIN descendants d ON p.parent_premise_id = d.id
+	) SELECT id FROM descendants`, id).Scan(&ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get premise descendants: %w", err)
+	}
+	return ids, nil
+}
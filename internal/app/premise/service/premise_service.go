@@ -7,6 +7,8 @@ import (
 	"scs-operator/internal/models"
 	"scs-operator/internal/types"
 	"scs-operator/pkg/errors"
+	"scs-operator/pkg/geo"
+	"scs-operator/pkg/query"
 
 	"github.com/google/uuid"
 )
@@ -22,9 +24,15 @@ func NewPremiseService(premiseRepo repositories.PremiseRepository, premiseUsersR
 
 func (s *Service) CreatePremise(ctx context.Context, createPremiseDto *dto.CreatePremiseDto) (*models.Premise, error) {
 	premise := &models.Premise{
-		Name:    createPremiseDto.Name,
-		Address: createPremiseDto.Address,
+		Name:           createPremiseDto.Name,
+		Address:        createPremiseDto.Address,
+		GeofenceRadius: createPremiseDto.GeofenceRadius,
 	}
+	position, err := geo.Resolve(createPremiseDto.Latitude, createPremiseDto.Longitude, createPremiseDto.Geometry)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	premise.Latitude, premise.Longitude = position.Coordinates()
 	if createPremiseDto.ParentPremiseID != "" {
 		parentID, err := uuid.Parse(createPremiseDto.ParentPremiseID)
 		if err != nil {
@@ -46,30 +54,25 @@ func (s *Service) CreatePremise(ctx context.Context, createPremiseDto *dto.Creat
 	return createdPremise, nil
 }
 
-func (s *Service) GetPremises(ctx context.Context, page int, limit int) (*types.PaginateResponse[models.Premise], error) {
-	premises, err := s.premiseRepo.GetPremises(ctx, page, limit)
+func (s *Service) GetPremises(ctx context.Context, request query.Request) (*types.PaginateResponse[models.Premise], error) {
+	params, err := query.New(repositories.PremiseSchema, request)
 	if err != nil {
-		return nil, errors.NewDatabaseError("get premises", err)
+		return nil, errors.NewBadRequestError(err.Error())
 	}
-	total, err := s.premiseRepo.GetPremisesCount(ctx)
-	totalPages := int(total) / limit
-	if total%int64(limit) != 0 {
-		totalPages++
-	}
-
+	premises, page, err := s.premiseRepo.GetPremises(ctx, params)
 	if err != nil {
-		return nil, errors.NewDatabaseError("get premises count", err)
+		return nil, errors.NewDatabaseError("get premises", err)
 	}
-	paginateResponse := &types.PaginateResponse[models.Premise]{
-		Pagination: types.Pagination{
-			TotalPages: int(totalPages),
-			Page:       page,
-			Limit:      limit,
-		},
-		Data: premises,
+	if params.IncludeTotal {
+		total, err := s.premiseRepo.GetPremisesCount(ctx)
+		if err != nil {
+			return nil, errors.NewDatabaseError("get premises count", err)
+		}
+		page.Total = &total
 	}
-	return paginateResponse, nil
+	return types.NewPaginateResponse(premises, page), nil
 }
+
 func (s *Service) GetPremiseByID(ctx context.Context, id string) (*models.Premise, error) {
 	premise, err := s.premiseRepo.GetPremiseByID(ctx, id)
 	if err != nil {
@@ -92,6 +95,12 @@ func (s *Service) UpdatePremise(ctx context.Context, id string, updatePremiseDto
 	}
 	premise.Name = updatePremiseDto.Name
 	premise.Address = updatePremiseDto.Address
+	premise.GeofenceRadius = updatePremiseDto.GeofenceRadius
+	position, err := geo.Resolve(updatePremiseDto.Latitude, updatePremiseDto.Longitude, updatePremiseDto.Geometry)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	premise.Latitude, premise.Longitude = position.Coordinates()
 	updatedPremise, err := s.premiseRepo.UpdatePremise(ctx, id, premise)
 	if err != nil {
 		return nil, errors.NewDatabaseError("update premise", err)
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"scs-operator/internal/app/qualification/dto"
+	services "scs-operator/internal/app/qualification/service"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler
+type Handler struct {
+	svc services.Service
+}
+
+// NewHandler constructor
+func NewHandler(svc services.Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// CreateQualification creates a qualification
+// @Summary Create a qualification
+// @Description Create a skill or certificate, such as first aid or fire safety, that guidance templates can require of their assignee. Names are unique regardless of case.
+// @Tags qualifications
+// @Accept json
+// @Produce json
+// @Param qualification body dto.CreateQualificationDto true "Qualification"
+// @Success 201 {object} models.Qualification
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /qualifications [post]
+func (h *Handler) CreateQualification() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		createQualificationDto := &dto.CreateQualificationDto{}
+		if err := c.Bind(createQualificationDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(createQualificationDto); err != nil {
+			return err
+		}
+		qualification, err := h.svc.CreateQualification(c.Request().Context(), createQualificationDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(201, qualification)
+	}
+}
+
+// GetQualifications lists qualifications
+// @Summary Get qualifications
+// @Description Get every qualification by name
+// @Tags qualifications
+// @Produce json
+// @Success 200 {array} models.Qualification
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /qualifications [get]
+func (h *Handler) GetQualifications() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		qualifications, err := h.svc.GetQualifications(c.Request().Context())
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, qualifications)
+	}
+}
+
+// GetGuardQualifications lists a guard's qualifications
+// @Summary Get guard qualifications
+// @Description Get the qualifications a guard holds with their expiry, expired ones included
+// @Tags qualifications
+// @Produce json
+// @Param guard_id path string true "Guard ID"
+// @Success 200 {array} models.UserQualification
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /qualifications/guards/{guard_id} [get]
+func (h *Handler) GetGuardQualifications() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userQualifications, err := h.svc.GetGuardQualifications(c.Request().Context(), c.Param("guard_id"))
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, userQualifications)
+	}
+}
+
+// GrantQualification grants a qualification to a guard
+// @Summary Grant qualification
+// @Description Grant a qualification to a guard, or renew it with a new certificate and expiry when they hold it already
+// @Tags qualifications
+// @Accept json
+// @Produce json
+// @Param id path string true "Qualification ID"
+// @Param guard_id path string true "Guard ID"
+// @Param grant body dto.GrantQualificationDto true "Certificate"
+// @Success 200 {object} models.UserQualification
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /qualifications/{id}/guards/{guard_id} [put]
+func (h *Handler) GrantQualification() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		grantDto := &dto.GrantQualificationDto{}
+		if err := c.Bind(grantDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(grantDto); err != nil {
+			return err
+		}
+		userQualification, err := h.svc.GrantQualification(c.Request().Context(), c.Param("guard_id"), c.Param("id"), grantDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, userQualification)
+	}
+}
+
+// RevokeQualification revokes a guard's qualification
+// @Summary Revoke qualification
+// @Description Remove a qualification from a guard
+// @Tags qualifications
+// @Produce json
+// @Param id path string true "Qualification ID"
+// @Param guard_id path string true "Guard ID"
+// @Success 200 {string} string "success"
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /qualifications/{id}/guards/{guard_id} [delete]
+func (h *Handler) RevokeQualification() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := h.svc.RevokeQualification(c.Request().Context(), c.Param("guard_id"), c.Param("id")); err != nil {
+			return err
+		}
+		return c.JSON(200, "success")
+	}
+}
@@ -0,0 +1,13 @@
+package http
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handler) RegisterRoutes(g *echo.Group) {
+	g.POST("", h.CreateQualification())
+	g.GET("", h.GetQualifications())
+	g.GET("/guards/:guard_id", h.GetGuardQualifications())
+	g.PUT("/:id/guards/:guard_id", h.GrantQualification())
+	g.DELETE("/:id/guards/:guard_id", h.RevokeQualification())
+}
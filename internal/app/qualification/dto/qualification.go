@@ -0,0 +1,14 @@
+package dto
+
+type CreateQualificationDto struct {
+	Name        string `json:"name" validate:"required,min=2,max=100"`
+	Description string `json:"description" validate:"omitempty,max=255"`
+}
+
+// GrantQualificationDto grants a qualification to a guard or renews it.
+type GrantQualificationDto struct {
+	CertificateNumber string `json:"certificate_number,omitempty" validate:"omitempty,max=100"`
+	// IssuedAt and ExpiresAt are RFC3339, a qualification without ExpiresAt does not expire
+	IssuedAt  string `json:"issued_at,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
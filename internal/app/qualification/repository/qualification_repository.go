@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type QualificationRepository struct {
+	db *gorm.DB
+}
+
+func NewQualificationRepository(db *gorm.DB) *QualificationRepository {
+	return &QualificationRepository{db: db}
+}
+
+func (r *QualificationRepository) CreateQualification(ctx context.Context, qualification *models.Qualification) (*models.Qualification, error) {
+	if err := database.Conn(ctx, r.db).Create(qualification).Error; err != nil {
+		return nil, fmt.Errorf("failed to create qualification: %w", err)
+	}
+	return qualification, nil
+}
+
+func (r *QualificationRepository) GetQualifications(ctx context.Context) ([]models.Qualification, error) {
+	var qualifications []models.Qualification
+	if err := database.Conn(ctx, r.db).Order("name").Find(&qualifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to get qualifications: %w", err)
+	}
+	return qualifications, nil
+}
+
+func (r *QualificationRepository) GetQualificationByID(ctx context.Context, id string) (*models.Qualification, error) {
+	var qualification models.Qualification
+	if err := database.Conn(ctx, r.db).First(&qualification, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get qualification: %w", err)
+	}
+	return &qualification, nil
+}
+
+func (r *QualificationRepository) GetQualificationByName(ctx context.Context, name string) (*models.Qualification, error) {
+	var qualification models.Qualification
+	if err := database.Conn(ctx, r.db).First(&qualification, "lower(name) = lower(?)", name).Error; err != nil {
+		return nil, fmt.Errorf("failed to get qualification: %w", err)
+	}
+	return &qualification, nil
+}
+
+func (r *QualificationRepository) GetQualificationsByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Qualification, error) {
+	var qualifications []models.Qualification
+	if err := database.Conn(ctx, r.db).Where("id IN ?", ids).Order("name").Find(&qualifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to get qualifications: %w", err)
+	}
+	return qualifications, nil
+}
+
+// GetUserQualifications returns the qualifications the user holds, expired
+// ones included.
+func (r *QualificationRepository) GetUserQualifications(ctx context.Context, userID string) ([]models.UserQualification, error) {
+	var userQualifications []models.UserQualification
+	if err := database.Conn(ctx, r.db).Preload("Qualification").Where("user_id = ?", userID).
+		Order("expires_at NULLS LAST").Find(&userQualifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user qualifications: %w", err)
+	}
+	return userQualifications, nil
+}
+
+// UpsertUserQualification grants the qualification to the user, or renews it
+// when they hold it already.
+func (r *QualificationRepository) UpsertUserQualification(ctx context.Context, userQualification *models.UserQualification) error {
+	if err := database.Conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "qualification_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"certificate_number", "issued_at", "expires_at", "updated_at"}),
+	}).Create(userQualification).Error; err != nil {
+		return fmt.Errorf("failed to upsert user qualification: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserQualification revokes the qualification. It reports whether the
+// user held it.
+func (r *QualificationRepository) DeleteUserQualification(ctx context.Context, userID string, qualificationID string) (bool, error) {
+	result := database.Conn(ctx, r.db).Where("user_id = ? AND qualification_id = ?", userID, qualificationID).
+		Delete(&models.UserQualification{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete user qualification: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// MissingQualification is a qualification a template requires that the user
+// does not hold, or held until ExpiresAt.
+type MissingQualification struct {
+	ID        uuid.UUID
+	Name      string
+	ExpiresAt *time.Time
+}
+
+// GetMissingQualifications returns the qualifications the template requires
+// that the user does not hold valid at the given time.
+func (r *QualificationRepository) GetMissingQualifications(ctx context.Context, templateID uuid.UUID, userID uuid.UUID, at time.Time) ([]MissingQualification, error) {
+	var missing []MissingQualification
+	if err := database.Conn(ctx, r.db).Table("qualifications").
+		Select("qualifications.id, qualifications.name, user_qualifications.expires_at").
+		Joins("JOIN guidance_template_qualifications ON guidance_template_qualifications.qualification_id = qualifications.id").
+		Joins("LEFT JOIN user_qualifications ON user_qualifications.qualification_id = qualifications.id AND user_qualifications.user_id = ?", userID).
+		Where("guidance_template_qualifications.guidance_template_id = ?", templateID).
+		Where("(user_qualifications.id IS NULL OR user_qualifications.expires_at <= ?)", at).
+		Order("qualifications.name").
+		Scan(&missing).Error; err != nil {
+		return nil, fmt.Errorf("failed to get missing qualifications: %w", err)
+	}
+	return missing, nil
+}
+
+// QualifiedFor keeps the users holding every qualification the template
+// requires, valid at the given time.
+func QualifiedFor(templateID uuid.UUID, at time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`NOT EXISTS (SELECT 1 FROM guidance_template_qualifications gtq WHERE gtq.guidance_template_id = ?
+			AND NOT EXISTS (SELECT 1 FROM user_qualifications uq WHERE uq.user_id = users.id AND uq.qualification_id = gtq.qualification_id
+				AND (uq.expires_at IS NULL OR uq.expires_at > ?)))`, templateID, at)
+	}
+}
+
+// GetQualifiedGuards returns the guards qualified for the template, by name.
+func (r *QualificationRepository) GetQualifiedGuards(ctx context.Context, templateID uuid.UUID, at time.Time) ([]models.User, error) {
+	var guards []models.User
+	if err := database.Conn(ctx, r.db).Where("users.role = 'guard'").Scopes(QualifiedFor(templateID, at)).
+		Order("users.name").Find(&guards).Error; err != nil {
+		return nil, fmt.Errorf("failed to get qualified guards: %w", err)
+	}
+	return guards, nil
+}
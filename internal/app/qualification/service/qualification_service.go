@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	guardRepositories "scs-operator/internal/app/guard/repository"
+	"scs-operator/internal/app/qualification/dto"
+	repositories "scs-operator/internal/app/qualification/repository"
+	"scs-operator/internal/models"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/query"
+)
+
+type Service struct {
+	qualificationRepo repositories.QualificationRepository
+	guardRepo         guardRepositories.GuardRepository
+}
+
+func NewQualificationService(qualificationRepo repositories.QualificationRepository, guardRepo guardRepositories.GuardRepository) *Service {
+	return &Service{qualificationRepo: qualificationRepo, guardRepo: guardRepo}
+}
+
+func (s *Service) CreateQualification(ctx context.Context, createQualificationDto *dto.CreateQualificationDto) (*models.Qualification, error) {
+	if _, err := s.qualificationRepo.GetQualificationByName(ctx, createQualificationDto.Name); err == nil {
+		return nil, errors.NewConflictError("Qualification already exists")
+	}
+	qualification, err := s.qualificationRepo.CreateQualification(ctx, &models.Qualification{
+		Name:        createQualificationDto.Name,
+		Description: createQualificationDto.Description,
+	})
+	if err != nil {
+		return nil, errors.NewDatabaseError("create qualification", err)
+	}
+	return qualification, nil
+}
+
+func (s *Service) GetQualifications(ctx context.Context) ([]models.Qualification, error) {
+	qualifications, err := s.qualificationRepo.GetQualifications(ctx)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get qualifications", err)
+	}
+	return qualifications, nil
+}
+
+// GetGuardQualifications returns the qualifications a guard holds, expired
+// ones included.
+func (s *Service) GetGuardQualifications(ctx context.Context, guardID string) ([]models.UserQualification, error) {
+	if _, err := s.guardRepo.GetGuardByID(ctx, guardID); err != nil {
+		return nil, errors.NewNotFoundError("guard")
+	}
+	userQualifications, err := s.qualificationRepo.GetUserQualifications(ctx, guardID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("get guard qualifications", err)
+	}
+	return userQualifications, nil
+}
+
+// GrantQualification grants the qualification to a guard, replacing the
+// certificate and expiry when they hold it already.
+func (s *Service) GrantQualification(ctx context.Context, guardID string, qualificationID string, grantDto *dto.GrantQualificationDto) (*models.UserQualification, error) {
+	issuedAt, err := query.ParseTime("issued_at", grantDto.IssuedAt)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	expiresAt, err := query.ParseTime("expires_at", grantDto.ExpiresAt)
+	if err != nil {
+		return nil, errors.NewBadRequestError(err.Error())
+	}
+	if issuedAt != nil && expiresAt != nil && !expiresAt.After(*issuedAt) {
+		return nil, errors.NewBadRequestError("expires_at must be after issued_at")
+	}
+	guard, err := s.guardRepo.GetGuardByID(ctx, guardID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("guard")
+	}
+	qualification, err := s.qualificationRepo.GetQualificationByID(ctx, qualificationID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("qualification")
+	}
+	userQualification := &models.UserQualification{
+		UserID:            guard.ID,
+		QualificationID:   qualification.ID,
+		CertificateNumber: grantDto.CertificateNumber,
+		IssuedAt:          issuedAt,
+		ExpiresAt:         expiresAt,
+	}
+	if err := s.qualificationRepo.UpsertUserQualification(ctx, userQualification); err != nil {
+		return nil, errors.NewDatabaseError("grant qualification", err)
+	}
+	userQualification.Qualification = qualification
+	return userQualification, nil
+}
+
+func (s *Service) RevokeQualification(ctx context.Context, guardID string, qualificationID string) error {
+	revoked, err := s.qualificationRepo.DeleteUserQualification(ctx, guardID, qualificationID)
+	if err != nil {
+		return errors.NewDatabaseError("revoke qualification", err)
+	}
+	if !revoked {
+		return errors.NewNotFoundError("guard qualification")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"scs-operator/internal/app/stream/dto"
+	services "scs-operator/internal/app/stream/service"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/stream"
+	"scs-operator/pkg/validation"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler
+type Handler struct {
+	svc services.Service
+}
+
+// NewHandler constructor
+func NewHandler(svc services.Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Stream pushes alarm and incident changes as server-sent events
+// @Summary Stream alarm and incident changes
+// @Description Server-sent event stream of alarm and incident changes. Each event carries its type, premise and the changed entity. Reconnecting clients resume with the Last-Event-ID header or last_event_id; a "reset" event means the events since then are gone and the client must reload. Browsers that cannot set headers may pass the JWT as access_token.
+// @Tags stream
+// @Produce text/event-stream
+// @Param topics query string false "Comma separated topics: alarms, incidents"
+// @Param premise_id query string false "Only changes at this premise"
+// @Param include_sub_premises query bool false "Include the sub-premises of premise_id"
+// @Param last_event_id query string false "Resume after this event ID"
+// @Param Last-Event-ID header string false "Resume after this event ID"
+// @Success 200 {object} stream.Event
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /stream [get]
+func (h *Handler) Stream() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		subscribeDto := &dto.SubscribeDto{}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, subscribeDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+
+		if err := validation.ValidateStruct(subscribeDto); err != nil {
+			return err
+		}
+		lastEventID := c.Request().Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = subscribeDto.LastEventID
+		}
+		sub, err := h.svc.Subscribe(c.Request().Context(), lastEventID, subscribeDto)
+		if err != nil {
+			return err
+		}
+		defer h.svc.Unsubscribe(sub)
+
+		response := c.Response()
+		response.Header().Set(echo.HeaderContentType, "text/event-stream")
+		response.Header().Set(echo.HeaderCacheControl, "no-cache")
+		response.Header().Set(echo.HeaderConnection, "keep-alive")
+		// Stop reverse proxies from buffering the stream
+		response.Header().Set("X-Accel-Buffering", "no")
+		response.WriteHeader(http.StatusOK)
+		// The server write timeout would otherwise cut every stream short
+		_ = http.NewResponseController(response).SetWriteDeadline(time.Time{})
+
+		if _, err := fmt.Fprint(response, "retry: 3000\n\n"); err != nil {
+			return nil
+		}
+		if sub.Reset {
+			if _, err := fmt.Fprint(response, "event: reset\ndata: {}\n\n"); err != nil {
+				return nil
+			}
+		}
+		for _, event := range sub.Replay {
+			if err := writeEvent(response, event); err != nil {
+				return nil
+			}
+		}
+		response.Flush()
+
+		keepAlive := time.NewTicker(h.svc.KeepAliveInterval())
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-c.Request().Context().Done():
+				return nil
+			case event, ok := <-sub.Events:
+				if !ok {
+					// Dropped for falling behind, the client reconnects and resumes
+					return nil
+				}
+				if err := writeEvent(response, event); err != nil {
+					return nil
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(response, ": keep-alive\n\n"); err != nil {
+					return nil
+				}
+			}
+			response.Flush()
+		}
+	}
+}
+
+func writeEvent(response *echo.Response, event stream.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(response, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
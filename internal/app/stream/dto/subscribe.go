@@ -0,0 +1,11 @@
+package dto
+
+// SubscribeDto holds the query parameters of GET /stream.
+type SubscribeDto struct {
+	// Topics is a comma separated list of alarms and incidents, all when empty
+	Topics             string `query:"topics"`
+	PremiseID          string `query:"premise_id" validate:"omitempty,uuid"`
+	IncludeSubPremises bool   `query:"include_sub_premises"`
+	// LastEventID resumes the stream for clients that cannot send the Last-Event-ID header
+	LastEventID string `query:"last_event_id"`
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	"scs-operator/internal/app/stream/dto"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/stream"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// topicPrefixes maps the subscribable topics to the event types they cover.
+var topicPrefixes = map[string]string{
+	"alarms":    "alarm.",
+	"incidents": "incident.",
+}
+
+type Service struct {
+	premiseRepo premiseRepositories.PremiseRepository
+	broker      stream.Broker
+	// keepAliveInterval is how often idle streams get a comment so proxies keep them open
+	keepAliveInterval time.Duration
+}
+
+func NewStreamService(premiseRepo premiseRepositories.PremiseRepository, broker stream.Broker, keepAliveInterval time.Duration) *Service {
+	return &Service{premiseRepo: premiseRepo, broker: broker, keepAliveInterval: keepAliveInterval}
+}
+
+// Subscribe starts a subscription to the alarm and incident changes selected by
+// the topics and premise filter, resuming after lastEventID when given.
+func (s *Service) Subscribe(ctx context.Context, lastEventID string, subscribeDto *dto.SubscribeDto) (*stream.Subscription, error) {
+	var prefixes []string
+	for _, topic := range strings.Split(subscribeDto.Topics, ",") {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+		prefix, ok := topicPrefixes[topic]
+		if !ok {
+			return nil, errors.NewBadRequestError("Unknown topic " + topic + ", expected alarms or incidents")
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	var premiseIDs map[string]bool
+	if subscribeDto.PremiseID != "" {
+		ids := []uuid.UUID{uuid.MustParse(subscribeDto.PremiseID)}
+		if subscribeDto.IncludeSubPremises {
+			var err error
+			ids, err = s.premiseRepo.GetDescendantIDs(ctx, subscribeDto.PremiseID)
+			if err != nil {
+				return nil, errors.NewDatabaseError("get sub-premises", err)
+			}
+		}
+		premiseIDs = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			premiseIDs[id.String()] = true
+		}
+	}
+	match := func(event stream.Event) bool {
+		if premiseIDs != nil && !premiseIDs[event.PremiseID] {
+			return false
+		}
+		if len(prefixes) == 0 {
+			return true
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(event.Type, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return s.broker.Subscribe(lastEventID, match), nil
+}
+
+func (s *Service) Unsubscribe(sub *stream.Subscription) {
+	s.broker.Unsubscribe(sub)
+}
+
+func (s *Service) KeepAliveInterval() time.Duration {
+	return s.keepAliveInterval
+}
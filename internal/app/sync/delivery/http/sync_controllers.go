@@ -0,0 +1,78 @@
+package http
+
+import (
+	"scs-operator/internal/app/sync/dto"
+	services "scs-operator/internal/app/sync/service"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Handler
+type Handler struct {
+	svc services.Service
+}
+
+// NewHandler constructor
+func NewHandler(svc services.Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GetChanges returns what changed for the calling guard
+// @Summary Get changes since a sync token
+// @Description Get the incidents with active guidance assigned to the calling guard, their guidance steps, the template versions that guidance follows, and the guard's premises, that changed since the sync token. Without a token everything is returned and full is true. Incidents whose guidance was taken from the guard are listed in unassigned_incident_ids. Pass the returned sync_token on the next call.
+// @Tags sync
+// @Produce json
+// @Param sync_token query string false "Sync token returned by the previous sync"
+// @Success 200 {object} types.SyncChanges
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /sync [get]
+func (h *Handler) GetChanges() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		getChangesDto := &dto.GetChangesDto{}
+		if err := (&echo.DefaultBinder{}).BindQueryParams(c, getChangesDto); err != nil {
+			return errors.NewBadRequestError("Invalid query parameters")
+		}
+		userID, _ := c.Get("user_id").(string)
+		changes, err := h.svc.GetChanges(c.Request().Context(), userID, getChangesDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, changes)
+	}
+}
+
+// UploadOperations applies operations made offline
+// @Summary Upload offline operations
+// @Description Apply step completions, comments and media registrations the calling guard queued offline, in order. Each operation has its own result: applied, conflict when the server state wins (the step is already completed or its guidance was replaced), rejected when it can never apply, or failed when it may be retried. Operations are identified by their id, uploading one again returns its first result with replayed set. Register media here, then upload the file to /incidents/{id}/media with its media_id.
+// @Tags sync
+// @Accept json
+// @Produce json
+// @Param operations body dto.UploadOperationsDto true "Offline operations"
+// @Success 200 {object} types.SyncUploadResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /sync/operations [post]
+func (h *Handler) UploadOperations() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		uploadDto := &dto.UploadOperationsDto{}
+		if err := c.Bind(uploadDto); err != nil {
+			return errors.NewBadRequestError("Invalid request body")
+		}
+
+		if err := validation.ValidateStruct(uploadDto); err != nil {
+			return err
+		}
+		userID, _ := c.Get("user_id").(string)
+		response, err := h.svc.UploadOperations(c.Request().Context(), userID, uploadDto)
+		if err != nil {
+			return err
+		}
+		return c.JSON(200, response)
+	}
+}
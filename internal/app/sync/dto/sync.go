@@ -0,0 +1,42 @@
+package dto
+
+// GetChangesDto asks for what changed for the calling guard since a sync
+// token. Without a token everything is returned.
+type GetChangesDto struct {
+	SyncToken string `query:"sync_token"`
+}
+
+// UploadOperationsDto is a batch of operations a device queued offline,
+// applied in order.
+type UploadOperationsDto struct {
+	Operations []OperationDto `json:"operations" validate:"required,min=1,max=100,dive"`
+}
+
+// OperationDto is an operation made offline. The ID is generated by the
+// device, uploading an operation again returns its first result.
+type OperationDto struct {
+	ID         string `json:"id" validate:"required,uuid"`
+	Type       string `json:"type" validate:"required,oneof=complete_step comment register_media"`
+	IncidentID string `json:"incident_id" validate:"required,uuid"`
+	// PerformedAt is when the operation was made on the device, RFC3339
+	PerformedAt string `json:"performed_at" validate:"required"`
+	// StepID is the guidance step a complete_step operation completes
+	StepID string `json:"step_id,omitempty" validate:"required_if=Type complete_step,omitempty,uuid"`
+	// Answer and the evidence fields complete the step as online. Photos and
+	// signature may be media registered offline, once their files are uploaded
+	Answer      string   `json:"answer,omitempty" validate:"max=4000"`
+	Note        string   `json:"note,omitempty" validate:"max=4000"`
+	PhotoIDs    []string `json:"photo_ids,omitempty" validate:"omitempty,max=20,dive,uuid"`
+	SignatureID string   `json:"signature_id,omitempty" validate:"omitempty,uuid"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+	// Message and MentionIDs make up a comment operation
+	Message    string   `json:"message,omitempty" validate:"required_if=Type comment,max=4000"`
+	MentionIDs []string `json:"mention_ids,omitempty" validate:"omitempty,max=50,dive,uuid"`
+	// The media fields describe a photo or video taken offline, its file is
+	// uploaded later with the returned media ID
+	MediaType string `json:"media_type,omitempty" validate:"required_if=Type register_media,omitempty,oneof=image video"`
+	FileName  string `json:"file_name,omitempty" validate:"required_if=Type register_media,max=255"`
+	FileType  string `json:"file_type,omitempty" validate:"max=255"`
+	FileSize  int64  `json:"file_size,omitempty" validate:"gte=0"`
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"scs-operator/internal/models"
+	database "scs-operator/pkg/db"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SyncRepository struct {
+	db *gorm.DB
+}
+
+func NewSyncRepository(db *gorm.DB) *SyncRepository {
+	return &SyncRepository{db: db}
+}
+
+// activeGuidance is the active guidance assigned to a user. Guidance updated
+// after a time was created, reassigned or replaced since.
+const (
+	activeGuidance  = " FROM incident_guidances WHERE assignee_id = ? AND status = 'active'"
+	changedGuidance = activeGuidance + " AND updated_at > ?"
+)
+
+// GetAssignedIncidents returns the incidents whose active guidance is assigned
+// to the user, with that guidance. Without since only unresolved incidents are
+// returned, otherwise those that changed or were assigned since, resolved ones
+// included so devices can drop them.
+func (r *SyncRepository) GetAssignedIncidents(ctx context.Context, userID uuid.UUID, since *time.Time) ([]models.Incident, error) {
+	db := database.Conn(ctx, r.db).Preload("IncidentGuidance", "status = 'active'").
+		Where("incidents.id IN (SELECT incident_id"+activeGuidance+")", userID)
+	if since == nil {
+		db = db.Where("incidents.status <> 'resolved'")
+	} else {
+		db = db.Where("(incidents.updated_at > ? OR incidents.id IN (SELECT incident_id"+changedGuidance+"))",
+			*since, userID, *since)
+	}
+	var incidents []models.Incident
+	if err := db.Order("incidents.created_at").Find(&incidents).Error; err != nil {
+		return nil, fmt.Errorf("failed to get assigned incidents: %w", err)
+	}
+	return incidents, nil
+}
+
+// GetUnassignedIncidentIDs returns the incidents whose guidance was taken
+// from the user since, by reassignment or replacement, and not handed back.
+func (r *SyncRepository) GetUnassignedIncidentIDs(ctx context.Context, userID uuid.UUID, since time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := database.Conn(ctx, r.db).Model(&models.IncidentGuidanceAssignment{}).Distinct("incident_id").
+		Where("previous_assignee_id = ? AND created_at > ?", userID, since).
+		Where("incident_id NOT IN (SELECT incident_id"+activeGuidance+")", userID).
+		Pluck("incident_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get unassigned incidents: %w", err)
+	}
+	return ids, nil
+}
+
+// GetGuidanceSteps returns the steps of the active guidance assigned to the
+// user, optionally only those that changed or were assigned since.
+func (r *SyncRepository) GetGuidanceSteps(ctx context.Context, userID uuid.UUID, since *time.Time) ([]models.IncidentGuidanceStep, error) {
+	db := database.Conn(ctx, r.db).Where("incident_guidance_id IN (SELECT id"+activeGuidance+")", userID)
+	if since != nil {
+		db = db.Where("(updated_at > ? OR incident_guidance_id IN (SELECT id"+changedGuidance+"))", *since, userID, *since)
+	}
+	var steps []models.IncidentGuidanceStep
+	if err := db.Order("incident_guidance_id, step_number").Find(&steps).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guidance steps: %w", err)
+	}
+	return steps, nil
+}
+
+// GetGuidanceTemplates returns the template versions the active guidance
+// assigned to the user follows, optionally only for guidance assigned or
+// changed since. Versions never change, so there is nothing else to send.
+func (r *SyncRepository) GetGuidanceTemplates(ctx context.Context, userID uuid.UUID, since *time.Time) ([]models.GuidanceTemplateVersion, error) {
+	subquery := "SELECT guidance_template_version_id" + activeGuidance
+	args := []interface{}{userID}
+	if since != nil {
+		subquery = "SELECT guidance_template_version_id" + changedGuidance
+		args = append(args, *since)
+	}
+	var versions []models.GuidanceTemplateVersion
+	if err := database.Conn(ctx, r.db).Where("id IN ("+subquery+")", args...).
+		Order("name, version").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get guidance template versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetPremises returns the premises the user is assigned to, optionally only
+// those that changed or were assigned since.
+func (r *SyncRepository) GetPremises(ctx context.Context, userID uuid.UUID, since *time.Time) ([]models.Premise, error) {
+	db := database.Conn(ctx, r.db).Where("premises.id IN (SELECT premise_id FROM user_premises WHERE user_id = ?)", userID)
+	if since != nil {
+		db = db.Where("(premises.updated_at > ? OR premises.id IN (SELECT premise_id FROM user_premises WHERE user_id = ? AND created_at > ?))",
+			*since, userID, *since)
+	}
+	var premises []models.Premise
+	if err := db.Order("premises.name").Find(&premises).Error; err != nil {
+		return nil, fmt.Errorf("failed to get premises: %w", err)
+	}
+	return premises, nil
+}
+
+// WasAssigned reports whether the user holds or held guidance of the incident.
+func (r *SyncRepository) WasAssigned(ctx context.Context, incidentID uuid.UUID, userID uuid.UUID) (bool, error) {
+	var assigned bool
+	if err := database.Conn(ctx, r.db).Raw(`SELECT EXISTS (SELECT 1 FROM incident_guidances WHERE incident_id = ? AND assignee_id = ?)
+		OR EXISTS (SELECT 1 FROM incident_guidance_assignments WHERE incident_id = ? AND (assignee_id = ? OR previous_assignee_id = ?))`,
+		incidentID, userID, incidentID, userID, userID).Scan(&assigned).Error; err != nil {
+		return false, fmt.Errorf("failed to check incident assignment: %w", err)
+	}
+	return assigned, nil
+}
+
+// ClaimSyncOperation stores the operation unless the user uploaded it before.
+// It reports whether the operation is new and must be applied.
+func (r *SyncRepository) ClaimSyncOperation(ctx context.Context, operation *models.SyncOperation) (bool, error) {
+	result := database.Conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "operation_id"}},
+		DoNothing: true,
+	}).Create(operation)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim sync operation: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *SyncRepository) GetSyncOperation(ctx context.Context, userID uuid.UUID, operationID uuid.UUID) (*models.SyncOperation, error) {
+	var operation models.SyncOperation
+	if err := database.Conn(ctx, r.db).First(&operation, "user_id = ? AND operation_id = ?", userID, operationID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sync operation: %w", err)
+	}
+	return &operation, nil
+}
+
+func (r *SyncRepository) UpdateSyncOperationResult(ctx context.Context, id uuid.UUID, status string, result models.JSONB) error {
+	if err := database.Conn(ctx, r.db).Model(&models.SyncOperation{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "result": result}).Error; err != nil {
+		return fmt.Errorf("failed to update sync operation: %w", err)
+	}
+	return nil
+}
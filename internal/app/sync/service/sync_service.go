@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	stdErrors "errors"
+	"net/http"
+	incidentDto "scs-operator/internal/app/incident/dto"
+	incidentServices "scs-operator/internal/app/incident/service"
+	"scs-operator/internal/app/sync/dto"
+	repositories "scs-operator/internal/app/sync/repository"
+	"scs-operator/internal/models"
+	"scs-operator/internal/types"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes of an operation made offline.
+const (
+	OperationApplied  = "applied"
+	OperationConflict = "conflict"
+	OperationRejected = "rejected"
+	OperationFailed   = "failed"
+	// operationPending marks an operation claimed by the transaction applying
+	// it, it is never committed
+	operationPending = "pending"
+)
+
+type Service struct {
+	syncRepo        repositories.SyncRepository
+	incidentService incidentServices.Service
+	transactor      database.Transactor
+	// overlap is how far back a sync token reaches before the sync it was
+	// issued by, so changes committed while that sync ran are not missed
+	overlap time.Duration
+}
+
+func NewSyncService(syncRepo repositories.SyncRepository, incidentService incidentServices.Service, transactor database.Transactor, overlap time.Duration) *Service {
+	return &Service{syncRepo: syncRepo, incidentService: incidentService, transactor: transactor, overlap: overlap}
+}
+
+// GetChanges returns the incidents, guidance steps, templates and premises of
+// the guard that changed since the sync token, or all of them without one.
+func (s *Service) GetChanges(ctx context.Context, actorID string, getChangesDto *dto.GetChangesDto) (*types.SyncChanges, error) {
+	userID, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid user")
+	}
+	since, err := decodeSyncToken(getChangesDto.SyncToken)
+	if err != nil {
+		return nil, errors.NewBadRequestError("Invalid sync token")
+	}
+	next := time.Now().Add(-s.overlap)
+	if since != nil && next.Before(*since) {
+		next = *since
+	}
+	changes := &types.SyncChanges{SyncToken: encodeSyncToken(next), Full: since == nil}
+	if changes.Incidents, err = s.syncRepo.GetAssignedIncidents(ctx, userID, since); err != nil {
+		return nil, errors.NewDatabaseError("get assigned incidents", err)
+	}
+	if since != nil {
+		if changes.UnassignedIncidentIDs, err = s.syncRepo.GetUnassignedIncidentIDs(ctx, userID, *since); err != nil {
+			return nil, errors.NewDatabaseError("get unassigned incidents", err)
+		}
+	}
+	if changes.GuidanceSteps, err = s.syncRepo.GetGuidanceSteps(ctx, userID, since); err != nil {
+		return nil, errors.NewDatabaseError("get guidance steps", err)
+	}
+	if changes.GuidanceTemplates, err = s.syncRepo.GetGuidanceTemplates(ctx, userID, since); err != nil {
+		return nil, errors.NewDatabaseError("get guidance templates", err)
+	}
+	if changes.Premises, err = s.syncRepo.GetPremises(ctx, userID, since); err != nil {
+		return nil, errors.NewDatabaseError("get premises", err)
+	}
+	return changes, nil
+}
+
+// UploadOperations applies operations the guard made offline, in order and
+// each on its own, so one failing operation does not hold back the others.
+// Conflicts are resolved on the server:
+//   - a step that is already completed, or belongs to guidance that was
+//     replaced meanwhile, keeps its server state
+//   - comments and media are appended and never conflict
+//   - incidents the guard never held guidance of are rejected
+//
+// Times in the future are taken as now, device clocks drift.
+func (s *Service) UploadOperations(ctx context.Context, actorID string, uploadDto *dto.UploadOperationsDto) (*types.SyncUploadResponse, error) {
+	userID, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid user")
+	}
+	response := &types.SyncUploadResponse{Results: make([]types.SyncOperationResult, 0, len(uploadDto.Operations))}
+	for _, operation := range uploadDto.Operations {
+		result := s.applyOnce(ctx, userID, operation)
+		switch result.Status {
+		case OperationApplied:
+			response.Applied++
+		case OperationConflict:
+			response.Conflicts++
+		case OperationRejected:
+			response.Rejected++
+		default:
+			response.Failed++
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response, nil
+}
+
+// errNotApplied rolls back an operation that did not apply, its changes and
+// its claim with it.
+var errNotApplied = stdErrors.New("operation was not applied")
+
+// applyOnce applies an operation unless the guard uploaded it before, in
+// which case the first result is returned. The claim, the changes and the
+// result are committed together, so an upload running at the same time waits
+// for the result instead of applying the operation again. Conflicts and
+// rejections are kept without their changes, failed operations are
+// forgotten so they can be retried.
+func (s *Service) applyOnce(ctx context.Context, userID uuid.UUID, operation dto.OperationDto) types.SyncOperationResult {
+	var result types.SyncOperationResult
+	replayed := false
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		stored := &models.SyncOperation{
+			UserID:      userID,
+			OperationID: uuid.MustParse(operation.ID),
+			Type:        operation.Type,
+			Status:      operationPending,
+		}
+		claimed, err := s.syncRepo.ClaimSyncOperation(ctx, stored)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			result, replayed = s.replay(ctx, userID, operation), true
+			return nil
+		}
+		result = s.apply(ctx, userID, operation)
+		if result.Status != OperationApplied {
+			return errNotApplied
+		}
+		resultBytes, _ := json.Marshal(result)
+		return s.syncRepo.UpdateSyncOperationResult(ctx, stored.ID, result.Status, models.JSONB(resultBytes))
+	})
+	switch {
+	case err == nil || replayed:
+		return result
+	case !stdErrors.Is(err, errNotApplied):
+		return newResult(operation, OperationFailed, err.Error())
+	case result.Status == OperationFailed:
+		return result
+	}
+	// The outcome is final, keep it so the operation is not tried again. If
+	// that fails the operation is tried again and most likely ends the same.
+	resultBytes, _ := json.Marshal(result)
+	_, _ = s.syncRepo.ClaimSyncOperation(ctx, &models.SyncOperation{
+		UserID:      userID,
+		OperationID: uuid.MustParse(operation.ID),
+		Type:        operation.Type,
+		Status:      result.Status,
+		Result:      models.JSONB(resultBytes),
+	})
+	return result
+}
+
+func (s *Service) replay(ctx context.Context, userID uuid.UUID, operation dto.OperationDto) types.SyncOperationResult {
+	stored, err := s.syncRepo.GetSyncOperation(ctx, userID, uuid.MustParse(operation.ID))
+	if err != nil {
+		return newResult(operation, OperationFailed, err.Error())
+	}
+	var result types.SyncOperationResult
+	if err := json.Unmarshal(stored.Result, &result); err != nil {
+		return newResult(operation, OperationFailed, "Stored result is unreadable")
+	}
+	result.Replayed = true
+	return result
+}
+
+func (s *Service) apply(ctx context.Context, userID uuid.UUID, operation dto.OperationDto) types.SyncOperationResult {
+	performedAt, err := time.Parse(time.RFC3339, operation.PerformedAt)
+	if err != nil {
+		return newResult(operation, OperationRejected, "performed_at must be an RFC3339 time")
+	}
+	if now := time.Now(); performedAt.After(now) {
+		performedAt = now
+	}
+	assigned, err := s.syncRepo.WasAssigned(ctx, uuid.MustParse(operation.IncidentID), userID)
+	if err != nil {
+		return newResult(operation, OperationFailed, err.Error())
+	}
+	if !assigned {
+		return newResult(operation, OperationRejected, "Incident is not assigned to you")
+	}
+	var data interface{}
+	switch operation.Type {
+	case "complete_step":
+		data, err = s.incidentService.CompleteGuidanceStepAt(ctx, operation.IncidentID, operation.StepID, userID.String(), &incidentDto.CompleteGuidanceStepDto{
+			Answer:      operation.Answer,
+			Note:        operation.Note,
+			PhotoIDs:    operation.PhotoIDs,
+			SignatureID: operation.SignatureID,
+			Latitude:    operation.Latitude,
+			Longitude:   operation.Longitude,
+		}, performedAt)
+	case "comment":
+		data, err = s.incidentService.AddComment(ctx, operation.IncidentID, userID.String(), &incidentDto.CreateCommentDto{
+			Message:    operation.Message,
+			MentionIDs: operation.MentionIDs,
+		})
+	case "register_media":
+		data, err = s.incidentService.RegisterMedia(ctx, operation.IncidentID, &incidentDto.RegisterMediaDto{
+			MediaType:  operation.MediaType,
+			FileName:   operation.FileName,
+			FileType:   operation.FileType,
+			FileSize:   operation.FileSize,
+			CapturedAt: &performedAt,
+		})
+	}
+	if err != nil {
+		return errorResult(operation, err)
+	}
+	result := newResult(operation, OperationApplied, "")
+	result.Data, _ = json.Marshal(data)
+	return result
+}
+
+func newResult(operation dto.OperationDto, status string, message string) types.SyncOperationResult {
+	return types.SyncOperationResult{ID: operation.ID, Type: operation.Type, Status: status, Error: message}
+}
+
+// errorResult maps a service error to the outcome of the operation. Client
+// errors are final, server errors may be retried.
+func errorResult(operation dto.OperationDto, err error) types.SyncOperationResult {
+	appErr, ok := errors.IsAppError(err)
+	if !ok {
+		return newResult(operation, OperationFailed, err.Error())
+	}
+	switch {
+	case appErr.StatusCode == http.StatusConflict:
+		return newResult(operation, OperationConflict, appErr.Message)
+	case appErr.StatusCode < http.StatusInternalServerError:
+		return newResult(operation, OperationRejected, appErr.Message)
+	default:
+		return newResult(operation, OperationFailed, appErr.Message)
+	}
+}
+
+// syncToken is the opaque position a device syncs from.
+type syncToken struct {
+	Since time.Time `json:"since"`
+}
+
+func encodeSyncToken(since time.Time) string {
+	data, _ := json.Marshal(syncToken{Since: since.UTC()})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSyncToken returns the time to sync from, or nil for a full sync.
+func decodeSyncToken(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var token syncToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	if token.Since.IsZero() || token.Since.After(time.Now()) {
+		return nil, errors.NewBadRequestError("Invalid sync token")
+	}
+	return &token.Since, nil
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"regexp"
+	"scs-operator/internal/app/sync/dto"
+	repositories "scs-operator/internal/app/sync/repository"
+	"scs-operator/internal/testsupport"
+	"scs-operator/internal/testsupport/incidenttest"
+	database "scs-operator/pkg/db"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	testUserID      = "2d8e4c6a-77b3-4b3a-9b77-5b2c9b5d4e03"
+	testIncidentID  = "0b6c2a4e-55f1-4f1e-9f55-3f0a7f3b2c01"
+	testGuidanceID  = "4f0a6e8c-99d5-4d5c-9d99-7d4e1d7f6a05"
+	testStepID      = "7c3d9b1f-cc38-4a8f-8acc-0a7b4a0c9d08"
+	testOperationID = "5a1b7c9d-aa16-4e6a-9aaa-8e5f2e8a7b06"
+)
+
+func newTestService(t *testing.T) (*Service, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := testsupport.NewMockDB(t)
+	svc := NewSyncService(
+		*repositories.NewSyncRepository(db),
+		*incidenttest.NewService(t, db),
+		*database.NewTransactor(db),
+		time.Minute,
+	)
+	return svc, mock
+}
+
+// expectClaim claims the operation, or finds it uploaded before.
+func expectClaim(mock sqlmock.Sqlmock, claimed bool) {
+	rows := sqlmock.NewRows([]string{"id"})
+	if claimed {
+		rows.AddRow("6b2c8d0e-bb27-4f7b-8abb-9f6a3f9b8c07")
+	}
+	mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "sync_operations"`) + `.*` + regexp.QuoteMeta(`ON CONFLICT ("user_id","operation_id") DO NOTHING`)).
+		WillReturnRows(rows)
+}
+
+func expectAssigned(mock sqlmock.Sqlmock, assigned bool) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT EXISTS (SELECT 1 FROM incident_guidances WHERE incident_id = $1 AND assignee_id = $2)`)).
+		WithArgs(testIncidentID, testUserID, testIncidentID, testUserID, testUserID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(assigned))
+}
+
+// expectStepLookup finds the incident, its active guidance and the step,
+// which belongs to the given guidance.
+func expectStepLookup(mock sqlmock.Sqlmock, guidanceID string, completed bool) {
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incidents" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).AddRow(testIncidentID, "Fire", "in_progress"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_alarms"`)).WillReturnRows(sqlmock.NewRows([]string{"incident_id"}))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_guidances"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_media"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_guidances" WHERE incident_id = $1 AND status = 'active'`) + `.*FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "incident_id", "status"}).AddRow(testGuidanceID, testIncidentID, "active"))
+	mock.ExpectQuery(testsupport.QuoteSQL(`FROM "incident_guidance_steps" JOIN incident_guidances`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "incident_guidance_id", "step_number", "type", "is_completed"}).
+			AddRow(testStepID, guidanceID, 1, "checkbox", completed))
+}
+
+// expectOutcome keeps the final outcome of an operation that did not apply.
+func expectOutcome(mock sqlmock.Sqlmock, status string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "sync_operations"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), testUserID, testOperationID, "complete_step", status, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("6b2c8d0e-bb27-4f7b-8abb-9f6a3f9b8c07"))
+	mock.ExpectCommit()
+}
+
+func TestUploadOperations(t *testing.T) {
+	completeStep := dto.OperationDto{
+		ID:          testOperationID,
+		Type:        "complete_step",
+		IncidentID:  testIncidentID,
+		StepID:      testStepID,
+		PerformedAt: time.Now().Add(-time.Hour).Format(time.RFC3339),
+	}
+	tests := []struct {
+		name             string
+		operation        dto.OperationDto
+		expect           func(sqlmock.Sqlmock)
+		expectedStatus   string
+		expectedReplayed bool
+	}{
+		{
+			name:      "A completed step keeps its server state",
+			operation: completeStep,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectClaim(mock, true)
+				expectAssigned(mock, true)
+				expectStepLookup(mock, testGuidanceID, true)
+				mock.ExpectRollback()
+				expectOutcome(mock, OperationConflict)
+			},
+			expectedStatus: OperationConflict,
+		},
+		{
+			name:      "A step of replaced guidance keeps its server state",
+			operation: completeStep,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectClaim(mock, true)
+				expectAssigned(mock, true)
+				expectStepLookup(mock, "8d4e0c2a-dd49-4b9a-9bdd-1b8c5b1d0e09", false)
+				mock.ExpectRollback()
+				expectOutcome(mock, OperationConflict)
+			},
+			expectedStatus: OperationConflict,
+		},
+		{
+			name:      "Incidents never assigned to the guard are rejected",
+			operation: completeStep,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectClaim(mock, true)
+				expectAssigned(mock, false)
+				mock.ExpectRollback()
+				expectOutcome(mock, OperationRejected)
+			},
+			expectedStatus: OperationRejected,
+		},
+		{
+			name: "An unreadable time is rejected",
+			operation: func() dto.OperationDto {
+				operation := completeStep
+				operation.PerformedAt = "yesterday"
+				return operation
+			}(),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectClaim(mock, true)
+				mock.ExpectRollback()
+				expectOutcome(mock, OperationRejected)
+			},
+			expectedStatus: OperationRejected,
+		},
+		{
+			name:      "An operation uploaded before returns its first result",
+			operation: completeStep,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectClaim(mock, false)
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "sync_operations" WHERE user_id = $1 AND operation_id = $2`)).
+					WithArgs(testUserID, testOperationID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status", "result"}).
+						AddRow("6b2c8d0e-bb27-4f7b-8abb-9f6a3f9b8c07", OperationApplied,
+							`{"id":"`+testOperationID+`","type":"complete_step","status":"applied"}`))
+				mock.ExpectCommit()
+			},
+			expectedStatus:   OperationApplied,
+			expectedReplayed: true,
+		},
+		{
+			name:      "Server failures are forgotten so they can be retried",
+			operation: completeStep,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectClaim(mock, true)
+				mock.ExpectQuery(testsupport.QuoteSQL(`SELECT EXISTS`)).WillReturnError(testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: OperationFailed,
+		},
+		{
+			name:      "A failed claim fails the operation",
+			operation: completeStep,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(testsupport.QuoteSQL(`INSERT INTO "sync_operations"`)).WillReturnError(testsupport.ErrInjected)
+				mock.ExpectRollback()
+			},
+			expectedStatus: OperationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, mock := newTestService(t)
+			tt.expect(mock)
+			response, err := svc.UploadOperations(context.Background(), testUserID, &dto.UploadOperationsDto{
+				Operations: []dto.OperationDto{tt.operation},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			result := response.Results[0]
+			if result.Status != tt.expectedStatus || result.Replayed != tt.expectedReplayed {
+				t.Errorf("expected %s (replayed %v), got %s (replayed %v): %s",
+					tt.expectedStatus, tt.expectedReplayed, result.Status, result.Replayed, result.Error)
+			}
+		})
+	}
+}
+
+func TestGetChanges(t *testing.T) {
+	since := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	unassignedID := "9e5f1d3b-ee5a-4cab-8cee-2c9d6c2e1f10"
+
+	t.Run("Delta sync drops incidents handed to someone else", func(t *testing.T) {
+		svc, mock := newTestService(t)
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incidents"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT DISTINCT "incident_id" FROM "incident_guidance_assignments" WHERE (previous_assignee_id = $1 AND created_at > $2) AND (incident_id NOT IN (SELECT incident_id FROM incident_guidances WHERE assignee_id = $3 AND status = 'active'))`)).
+			WithArgs(testUserID, since, testUserID).
+			WillReturnRows(sqlmock.NewRows([]string{"incident_id"}).AddRow(unassignedID))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "incident_guidance_steps"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "guidance_template_versions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(testsupport.QuoteSQL(`SELECT * FROM "premises"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		changes, err := svc.GetChanges(context.Background(), testUserID, &dto.GetChangesDto{SyncToken: encodeSyncToken(since)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if changes.Full || len(changes.UnassignedIncidentIDs) != 1 || changes.UnassignedIncidentIDs[0].String() != unassignedID {
+			t.Errorf("expected a delta dropping %s, got full %v and %v", unassignedID, changes.Full, changes.UnassignedIncidentIDs)
+		}
+		next, err := decodeSyncToken(changes.SyncToken)
+		if err != nil || next.Before(since) {
+			t.Errorf("expected the next token not to go back before %s, got %v (%v)", since, next, err)
+		}
+	})
+
+	t.Run("Invalid sync token", func(t *testing.T) {
+		svc, _ := newTestService(t)
+		_, err := svc.GetChanges(context.Background(), testUserID, &dto.GetChangesDto{SyncToken: "not a token"})
+		testsupport.AssertAppError(t, err, http.StatusBadRequest)
+	})
+}
+
+func TestDecodeSyncToken(t *testing.T) {
+	since := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	token := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	tests := []struct {
+		name     string
+		token    string
+		expected *time.Time
+		invalid  bool
+	}{
+		{name: "No token is a full sync", token: ""},
+		{name: "Issued token", token: encodeSyncToken(since), expected: &since},
+		{name: "Not base64", token: "%%%", invalid: true},
+		{name: "Not JSON", token: token("since"), invalid: true},
+		{name: "Without a time", token: token(`{}`), invalid: true},
+		{name: "From the future", token: encodeSyncToken(time.Now().Add(time.Hour)), invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSyncToken(tt.token)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("expected an invalid token, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (got == nil) != (tt.expected == nil) || (got != nil && !got.Equal(*tt.expected)) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
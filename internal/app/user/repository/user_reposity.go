@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"scs-operator/internal/models"
+	"scs-operator/pkg/query"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -22,12 +24,23 @@ func (r *UserRepository) CreateUser(ctx context.Context, User *models.User) (*mo
 	}
 	return User, nil
 }
-func (r *UserRepository) GetUsers(ctx context.Context, page int, limit int) ([]models.User, error) {
-	var Users []models.User
-	if err := r.db.WithContext(ctx).Limit(limit).Offset((page - 1) * limit).Find(&Users).Error; err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+
+// UserSchema lists the fields users can be sorted by.
+var UserSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"name":       {Column: "name"},
+		"email":      {Column: "email"},
+		"created_at": {Column: "created_at"},
+	},
+	DefaultSort: "name",
+}
+
+func (r *UserRepository) GetUsers(ctx context.Context, params query.Params) ([]models.User, query.PageInfo, error) {
+	Users, page, err := query.Find[models.User](r.db.WithContext(ctx).Model(&models.User{}), params)
+	if err != nil {
+		return nil, page, fmt.Errorf("failed to get users: %w", err)
 	}
-	return Users, nil
+	return Users, page, nil
 }
 
 func (r *UserRepository) GetUsersCount(ctx context.Context) (int64, error) {
@@ -44,3 +57,11 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.Us
 	}
 	return &User, nil
 }
+
+func (r *UserRepository) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]models.User, error) {
+	var users []models.User
+	if err := r.db.WithContext(ctx).Find(&users, "id IN ?", ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	return users, nil
+}
@@ -1,8 +1,12 @@
 package container
 
 import (
+	"scs-operator/config"
 	alarm_repository "scs-operator/internal/app/alarm/repository"
 	alarm_service "scs-operator/internal/app/alarm/service"
+	audit_repository "scs-operator/internal/app/audit/repository"
+	device_repository "scs-operator/internal/app/device/repository"
+	device_service "scs-operator/internal/app/device/service"
 	guard_premise_repository "scs-operator/internal/app/guard/repository"
 	guard_repository "scs-operator/internal/app/guard/repository"
 	guard_service "scs-operator/internal/app/guard/service"
@@ -12,10 +16,21 @@ import (
 	guidance_template_service "scs-operator/internal/app/guidance-template/service"
 	incident_repository "scs-operator/internal/app/incident/repository"
 	incident_service "scs-operator/internal/app/incident/service"
+	maintenance_window_repository "scs-operator/internal/app/maintenance-window/repository"
+	maintenance_window_service "scs-operator/internal/app/maintenance-window/service"
+	patrol_repository "scs-operator/internal/app/patrol/repository"
+	patrol_service "scs-operator/internal/app/patrol/service"
 	premise_repository "scs-operator/internal/app/premise/repository"
 	premise_service "scs-operator/internal/app/premise/service"
+	qualification_repository "scs-operator/internal/app/qualification/repository"
+	qualification_service "scs-operator/internal/app/qualification/service"
+	stream_service "scs-operator/internal/app/stream/service"
+	sync_repository "scs-operator/internal/app/sync/repository"
+	sync_service "scs-operator/internal/app/sync/service"
 	user_repository "scs-operator/internal/app/user/repository"
+	database "scs-operator/pkg/db"
 	kafka_client "scs-operator/pkg/kafka"
+	"scs-operator/pkg/stream"
 
 	"gorm.io/gorm"
 )
@@ -23,27 +38,47 @@ import (
 // Container holds all the application dependencies
 type Container struct {
 	// Repositories
-	AlarmRepo                *alarm_repository.AlarmRepository
-	PremiseRepo              *premise_repository.PremiseRepository
-	IncidentRepo             *incident_repository.IncidentRepository
-	IncidentGuidanceRepo     *incident_repository.IncidentGuidanceRepository
-	IncidentGuidanceStepRepo *incident_repository.IncidentGuidanceStepRepository
-	UserRepo                 *user_repository.UserRepository
-	GuidanceTemplateRepo     *guidance_template_repository.GuidanceTemplateRepository
-	GuidanceStepRepo         *guidance_step_repository.GuidanceStepRepository
-	GuardRepo                *guard_repository.GuardRepository
-	GuardPremiseRepo         *guard_premise_repository.GuardPremiseRepository
+	AlarmRepo                      *alarm_repository.AlarmRepository
+	PremiseRepo                    *premise_repository.PremiseRepository
+	IncidentRepo                   *incident_repository.IncidentRepository
+	IncidentGuidanceRepo           *incident_repository.IncidentGuidanceRepository
+	IncidentGuidanceStepRepo       *incident_repository.IncidentGuidanceStepRepository
+	IncidentGuidanceAssignmentRepo *incident_repository.IncidentGuidanceAssignmentRepository
+	IncidentActivityRepo           *incident_repository.IncidentActivityRepository
+	IncidentMediaRepo              *incident_repository.IncidentMediaRepository
+	UserRepo                       *user_repository.UserRepository
+	GuidanceTemplateRepo           *guidance_template_repository.GuidanceTemplateRepository
+	GuidanceTemplateVersionRepo    *guidance_template_repository.GuidanceTemplateVersionRepository
+	GuidanceStepRepo               *guidance_step_repository.GuidanceStepRepository
+	GuardRepo                      *guard_repository.GuardRepository
+	GuardPremiseRepo               *guard_premise_repository.GuardPremiseRepository
+	GuardPositionRepo              *guard_repository.GuardPositionRepository
+	GuardLocationRepo              *guard_repository.GuardLocationRepository
+	GuardShiftRepo                 *guard_repository.GuardShiftRepository
+	DeviceRepo                     *device_repository.DeviceRepository
+	MaintenanceWindowRepo          *maintenance_window_repository.MaintenanceWindowRepository
+	AuditRepo                      *audit_repository.AuditRepository
+	PatrolRouteRepo                *patrol_repository.PatrolRouteRepository
+	PatrolRunRepo                  *patrol_repository.PatrolRunRepository
+	QualificationRepo              *qualification_repository.QualificationRepository
+	SyncRepo                       *sync_repository.SyncRepository
 
 	// Services
-	AlarmService            *alarm_service.Service
-	PremiseService          *premise_service.Service
-	IncidentService         *incident_service.Service
-	GuidanceTemplateService *guidance_template_service.Service
-	GuidanceStepService     *guidance_step_service.Service
-	GuardService            *guard_service.Service
+	AlarmService             *alarm_service.Service
+	PremiseService           *premise_service.Service
+	IncidentService          *incident_service.Service
+	GuidanceTemplateService  *guidance_template_service.Service
+	GuidanceStepService      *guidance_step_service.Service
+	GuardService             *guard_service.Service
+	DeviceService            *device_service.Service
+	MaintenanceWindowService *maintenance_window_service.Service
+	StreamService            *stream_service.Service
+	PatrolService            *patrol_service.Service
+	QualificationService     *qualification_service.Service
+	SyncService              *sync_service.Service
 }
 
-func NewContainer(db *gorm.DB, producer *kafka_client.Producer) *Container {
+func NewContainer(cfg *config.Config, db *gorm.DB, producer *kafka_client.Producer) *Container {
 	// Initialize repositories
 	alarmRepo := alarm_repository.NewAlarmRepository(db)
 	premiseRepo := premise_repository.NewPremiseRepository(db)
@@ -51,39 +86,90 @@ func NewContainer(db *gorm.DB, producer *kafka_client.Producer) *Container {
 	incidentRepo := incident_repository.NewIncidentRepository(db)
 	incidentGuidanceRepo := incident_repository.NewIncidentGuidanceRepository(db)
 	incidentGuidanceStepRepo := incident_repository.NewIncidentGuidanceStepRepository(db)
+	incidentGuidanceAssignmentRepo := incident_repository.NewIncidentGuidanceAssignmentRepository(db)
+	incidentActivityRepo := incident_repository.NewIncidentActivityRepository(db)
+	incidentMediaRepo := incident_repository.NewIncidentMediaRepository(db)
 	userRepo := user_repository.NewUserRepository(db)
 	guidanceTemplateRepo := guidance_template_repository.NewGuidanceTemplateRepository(db)
+	guidanceTemplateVersionRepo := guidance_template_repository.NewGuidanceTemplateVersionRepository(db)
 	guidanceStepRepo := guidance_step_repository.NewGuidanceStepRepository(db)
 	guardPremiseRepo := guard_premise_repository.NewGuardPremiseRepository(db)
 	guardRepo := guard_repository.NewGuardRepository(db)
+	guardPositionRepo := guard_repository.NewGuardPositionRepository(db)
+	guardLocationRepo := guard_repository.NewGuardLocationRepository(db)
+	guardShiftRepo := guard_repository.NewGuardShiftRepository(db)
+	deviceRepo := device_repository.NewDeviceRepository(db)
+	maintenanceWindowRepo := maintenance_window_repository.NewMaintenanceWindowRepository(db)
+	auditRepo := audit_repository.NewAuditRepository(db)
+	patrolRouteRepo := patrol_repository.NewPatrolRouteRepository(db)
+	patrolRunRepo := patrol_repository.NewPatrolRunRepository(db)
+	qualificationRepo := qualification_repository.NewQualificationRepository(db)
+	syncRepo := sync_repository.NewSyncRepository(db)
+	transactor := database.NewTransactor(db)
+	broker := stream.NewBroker(cfg.Stream.BufferSize)
 
 	// Initialize services
-	alarmService := alarm_service.NewAlarmService(*alarmRepo, *premiseRepo, *producer)
+	incidentService := incident_service.NewIncidentService(*incidentRepo, *incidentGuidanceRepo, *userRepo, *guidanceTemplateRepo, *incidentGuidanceStepRepo, *incidentGuidanceAssignmentRepo, *incidentActivityRepo, *incidentMediaRepo, *alarmRepo, *premiseRepo, *qualificationRepo, *guardRepo, *transactor, *producer, *broker, cfg.Incident.MediaDir, cfg.Incident.MediaMaxSize, cfg.Incident.GeofenceRadius, incident_service.WeightedAssigneeStrategy{
+		OpenIncidentCost: cfg.Assignment.OpenIncidentCost,
+		OffDutyCost:      cfg.Assignment.OffDutyCost,
+		DistanceCost:     cfg.Assignment.DistanceCost,
+		UnknownDistance:  cfg.Assignment.UnknownDistance,
+	}, cfg.Guard.PositionMaxAge)
+	alarmService := alarm_service.NewAlarmService(*alarmRepo, *premiseRepo, *deviceRepo, *maintenanceWindowRepo, *incidentRepo, *incidentService, *auditRepo, *userRepo, *transactor, *producer, *broker, cfg.Alarm.LeaseDuration)
 	premiseService := premise_service.NewPremiseService(*premiseRepo, *premiseUsersRepo)
-	incidentService := incident_service.NewIncidentService(*incidentRepo, *incidentGuidanceRepo, *userRepo, *guidanceTemplateRepo, *incidentGuidanceStepRepo, *producer)
-	guidanceTemplateService := guidance_template_service.NewGuidanceTemplateService(*guidanceTemplateRepo, *guidanceStepRepo)
+	guidanceTemplateService := guidance_template_service.NewGuidanceTemplateService(*guidanceTemplateRepo, *guidanceTemplateVersionRepo, *guidanceStepRepo, *qualificationRepo, *transactor)
 	guidanceStepService := guidance_step_service.NewGuidanceStepService(*guidanceStepRepo)
-	guardService := guard_service.NewGuardService(*guardRepo, *guardPremiseRepo)
+	guardService := guard_service.NewGuardService(*guardRepo, *guardPremiseRepo, *guardPositionRepo, *guardLocationRepo, *guardShiftRepo, *premiseRepo, *alarmService, *transactor, *producer, cfg.Guard.PositionMaxAge, guard_service.CheckInPolicy{
+		Interval:        cfg.LoneWorker.CheckInInterval,
+		AlarmDelay:      cfg.LoneWorker.AlarmDelay,
+		SupervisorDelay: cfg.LoneWorker.SupervisorDelay,
+	})
+	deviceService := device_service.NewDeviceService(*deviceRepo, *premiseRepo, *alarmRepo, *alarmService, *transactor)
+	maintenanceWindowService := maintenance_window_service.NewMaintenanceWindowService(*maintenanceWindowRepo, *premiseRepo, *deviceRepo, *alarmRepo)
+	streamService := stream_service.NewStreamService(*premiseRepo, *broker, cfg.Stream.KeepAliveInterval)
+	patrolService := patrol_service.NewPatrolService(*patrolRouteRepo, *patrolRunRepo, *premiseRepo, *guardRepo, *alarmService, *transactor)
+	qualificationService := qualification_service.NewQualificationService(*qualificationRepo, *guardRepo)
+	syncService := sync_service.NewSyncService(*syncRepo, *incidentService, *transactor, cfg.Sync.Overlap)
 
 	return &Container{
 		// Repositories
-		AlarmRepo:                alarmRepo,
-		PremiseRepo:              premiseRepo,
-		IncidentRepo:             incidentRepo,
-		IncidentGuidanceRepo:     incidentGuidanceRepo,
-		IncidentGuidanceStepRepo: incidentGuidanceStepRepo,
-		UserRepo:                 userRepo,
-		GuidanceTemplateRepo:     guidanceTemplateRepo,
-		GuidanceStepRepo:         guidanceStepRepo,
-		GuardRepo:                guardRepo,
-		GuardPremiseRepo:         guardPremiseRepo,
+		AlarmRepo:                      alarmRepo,
+		PremiseRepo:                    premiseRepo,
+		IncidentRepo:                   incidentRepo,
+		IncidentGuidanceRepo:           incidentGuidanceRepo,
+		IncidentGuidanceStepRepo:       incidentGuidanceStepRepo,
+		IncidentGuidanceAssignmentRepo: incidentGuidanceAssignmentRepo,
+		IncidentActivityRepo:           incidentActivityRepo,
+		IncidentMediaRepo:              incidentMediaRepo,
+		UserRepo:                       userRepo,
+		GuidanceTemplateRepo:           guidanceTemplateRepo,
+		GuidanceTemplateVersionRepo:    guidanceTemplateVersionRepo,
+		GuidanceStepRepo:               guidanceStepRepo,
+		GuardRepo:                      guardRepo,
+		GuardPremiseRepo:               guardPremiseRepo,
+		GuardPositionRepo:              guardPositionRepo,
+		GuardLocationRepo:              guardLocationRepo,
+		GuardShiftRepo:                 guardShiftRepo,
+		DeviceRepo:                     deviceRepo,
+		MaintenanceWindowRepo:          maintenanceWindowRepo,
+		AuditRepo:                      auditRepo,
+		PatrolRouteRepo:                patrolRouteRepo,
+		PatrolRunRepo:                  patrolRunRepo,
+		QualificationRepo:              qualificationRepo,
+		SyncRepo:                       syncRepo,
 
 		// Services
-		AlarmService:            alarmService,
-		PremiseService:          premiseService,
-		IncidentService:         incidentService,
-		GuidanceTemplateService: guidanceTemplateService,
-		GuidanceStepService:     guidanceStepService,
-		GuardService:            guardService,
+		AlarmService:             alarmService,
+		PremiseService:           premiseService,
+		IncidentService:          incidentService,
+		GuidanceTemplateService:  guidanceTemplateService,
+		GuidanceStepService:      guidanceStepService,
+		GuardService:             guardService,
+		DeviceService:            deviceService,
+		MaintenanceWindowService: maintenanceWindowService,
+		StreamService:            streamService,
+		PatrolService:            patrolService,
+		QualificationService:     qualificationService,
+		SyncService:              syncService,
 	}
 }
@@ -32,3 +32,22 @@ func (mw *MiddlewareManager) JWTAuth(next echo.HandlerFunc) echo.HandlerFunc {
 		return next(c)
 	}
 }
+
+// StreamAuth authenticates like JWTAuth but also accepts the token as the
+// access_token query parameter, since browser EventSource cannot set headers.
+func (mw *MiddlewareManager) StreamAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	authenticated := mw.JWTAuth(next)
+	return func(c echo.Context) error {
+		req := c.Request()
+		if token := c.QueryParam("access_token"); token != "" {
+			if req.Header.Get("Authorization") == "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			// Keep the token out of the request log
+			query := req.URL.Query()
+			query.Del("access_token")
+			req.URL.RawQuery = query.Encode()
+		}
+		return authenticated(c)
+	}
+}
@@ -9,12 +9,25 @@ import (
 // Alarm represents an alarm in the SCS system.
 type Alarm struct {
 	Base
-	PremiseID   uuid.UUID `json:"premise_id"`
-	Premise     *Premise  `json:"premise,omitempty" gorm:"foreignKey:PremiseID"`
-	Type        string    `json:"type"`
-	Description string    `json:"description"`
-	Severity    string    `json:"severity" gorm:"check:severity IN ('low', 'medium', 'high')"`
-	TriggeredAt time.Time `json:"triggered_at" gorm:"type:timestamptz;default:CURRENT_TIMESTAMP"`
-	Device      string    `json:"device"`
-	Status      string    `json:"status" gorm:"check:status IN ('new', 'ignored', 'dispatched')"`
+	PremiseID   uuid.UUID  `json:"premise_id" gorm:"index:idx_alarms_premise_triggered_at,priority:1"`
+	Premise     *Premise   `json:"premise,omitempty" gorm:"foreignKey:PremiseID"`
+	Type        string     `json:"type" gorm:"index"`
+	Description string     `json:"description"`
+	Severity    string     `json:"severity" gorm:"check:severity IN ('low', 'medium', 'high')"`
+	TriggeredAt time.Time  `json:"triggered_at" gorm:"type:timestamptz;default:CURRENT_TIMESTAMP;index;index:idx_alarms_premise_triggered_at,priority:2;index:idx_alarms_device_triggered_at,priority:2;index:idx_alarms_status_triggered_at,priority:2"`
+	DeviceID    *uuid.UUID `json:"device_id,omitempty" gorm:"index:idx_alarms_device_triggered_at,priority:1"`
+	Device      *Device    `json:"device,omitempty" gorm:"foreignKey:DeviceID"`
+	// DeviceSerial keeps the serial reported for a device missing from the registry
+	DeviceSerial     string     `json:"device_serial,omitempty"`
+	Status           string     `json:"status" gorm:"check:status IN ('new', 'acknowledged', 'ignored', 'dispatched', 'suppressed');index:idx_alarms_status_triggered_at,priority:1"`
+	AcknowledgedByID *uuid.UUID `json:"acknowledged_by_id,omitempty"`
+	AcknowledgedAt   *time.Time `json:"acknowledged_at,omitempty" gorm:"type:timestamptz"`
+	// LeasedByID is the operator currently working the alarm from the queue.
+	// The lease lapses at LeaseExpiresAt and the alarm returns to the queue.
+	LeasedByID     *uuid.UUID `json:"leased_by_id,omitempty" gorm:"index"`
+	LeasedBy       *User      `json:"leased_by,omitempty" gorm:"foreignKey:LeasedByID"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" gorm:"type:timestamptz;index"`
+	// MaintenanceWindowID is set on alarms suppressed by a maintenance window
+	MaintenanceWindowID *uuid.UUID         `json:"maintenance_window_id,omitempty" gorm:"index"`
+	MaintenanceWindow   *MaintenanceWindow `json:"maintenance_window,omitempty" gorm:"foreignKey:MaintenanceWindowID"`
 }
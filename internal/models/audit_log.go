@@ -0,0 +1,14 @@
+package models
+
+import "github.com/google/uuid"
+
+// AuditLog records who did what to which entity.
+type AuditLog struct {
+	Base
+	ActorID    *uuid.UUID `json:"actor_id,omitempty" gorm:"index"`
+	Actor      *User      `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+	Action     string     `json:"action"`
+	EntityType string     `json:"entity_type" gorm:"index:idx_audit_logs_entity,priority:1"`
+	EntityID   uuid.UUID  `json:"entity_id" gorm:"index:idx_audit_logs_entity,priority:2"`
+	Details    JSONB      `json:"details,omitempty"`
+}
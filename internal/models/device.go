@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Device represents a sensor or other alarm source installed on a premise.
+type Device struct {
+	Base
+	DeviceType  string     `json:"device_type"`
+	Vendor      string     `json:"vendor"`
+	Serial      string     `json:"serial" gorm:"unique"`
+	PremiseID   uuid.UUID  `json:"premise_id"`
+	Premise     *Premise   `json:"premise,omitempty" gorm:"foreignKey:PremiseID"`
+	Location    string     `json:"location"`
+	InstalledAt *time.Time `json:"installed_at,omitempty" gorm:"type:timestamptz"`
+	Status      string     `json:"status" gorm:"default:active;check:status IN ('active', 'inactive', 'faulty', 'decommissioned')"`
+	// HeartbeatInterval is the number of seconds the device may stay silent
+	// before it is considered offline. Zero disables monitoring.
+	HeartbeatInterval int        `json:"heartbeat_interval"`
+	LastSeenAt        *time.Time `json:"last_seen_at,omitempty" gorm:"type:timestamptz"`
+	Connectivity      string     `json:"connectivity" gorm:"default:unknown;check:connectivity IN ('unknown', 'online', 'offline')"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GuardLocation is a position in a guard's location history. The table is
+// partitioned by month of ReportedAt, so it is created with raw SQL instead of
+// AutoMigrate.
+type GuardLocation struct {
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	// Accuracy is the reported accuracy radius in meters
+	Accuracy   *float64  `json:"accuracy,omitempty"`
+	ReportedAt time.Time `json:"reported_at" gorm:"type:timestamptz"`
+	ReceivedAt time.Time `json:"received_at" gorm:"type:timestamptz"`
+}
+
+func (GuardLocation) TableName() string {
+	return "guard_location_history"
+}
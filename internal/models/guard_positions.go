@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GuardPosition is the last position reported by a guard.
+type GuardPosition struct {
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey"`
+	User      *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Latitude  float64   `json:"latitude" gorm:"index:idx_guard_positions_position,priority:1;check:latitude BETWEEN -90 AND 90"`
+	Longitude float64   `json:"longitude" gorm:"index:idx_guard_positions_position,priority:2;check:longitude BETWEEN -180 AND 180"`
+	// Accuracy is the reported accuracy radius in meters
+	Accuracy   *float64  `json:"accuracy,omitempty"`
+	ReportedAt time.Time `json:"reported_at" gorm:"type:timestamptz;index"`
+}
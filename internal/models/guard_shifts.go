@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GuardShift is a lone-worker shift of a guard on a premise. The guard checks
+// in every CheckInIntervalMinutes, a missed check-in is escalated.
+type GuardShift struct {
+	Base
+	// A guard has at most one shift that has not ended
+	UserID                 uuid.UUID  `json:"user_id" gorm:"uniqueIndex:idx_guard_shifts_active,where:ended_at IS NULL"`
+	User                   *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	PremiseID              uuid.UUID  `json:"premise_id" gorm:"index"`
+	Premise                *Premise   `json:"premise,omitempty" gorm:"foreignKey:PremiseID"`
+	CheckInIntervalMinutes int        `json:"check_in_interval_minutes" gorm:"check:check_in_interval_minutes > 0"`
+	StartedAt              time.Time  `json:"started_at" gorm:"type:timestamptz"`
+	EndedAt                *time.Time `json:"ended_at,omitempty" gorm:"type:timestamptz"`
+	LastCheckInAt          time.Time  `json:"last_check_in_at" gorm:"type:timestamptz"`
+	NextCheckInAt          time.Time  `json:"next_check_in_at" gorm:"type:timestamptz;index"`
+	// Escalation is how far the missed check-in was escalated, none after a check-in
+	Escalation string `json:"escalation" gorm:"check:escalation IN ('none', 'warning', 'alarm', 'supervisors')"`
+	// AlarmID is the man-down alarm raised for the last missed check-in
+	AlarmID *uuid.UUID `json:"alarm_id,omitempty"`
+}
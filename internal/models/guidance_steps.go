@@ -1,6 +1,10 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"database/sql/driver"
+
+	"github.com/google/uuid"
+)
 
 type GuidanceStep struct {
 	Base
@@ -9,4 +13,77 @@ type GuidanceStep struct {
 	StepNumber         int               `json:"step_number"`
 	Title              string            `json:"title"`
 	Description        string            `json:"description"`
+	// Type is how the step is done: checkbox steps are ticked, the others are
+	// answered with yes or no, one of Options, text, a number, or the ID of a
+	// photo or signature uploaded as incident media
+	Type     string               `json:"type" gorm:"default:checkbox;check:type IN ('checkbox', 'yes_no', 'single_choice', 'text', 'number', 'photo', 'signature')"`
+	Options  StringList           `json:"options,omitempty"`
+	Branches StepBranches         `json:"branches,omitempty"`
+	Evidence EvidenceRequirements `json:"evidence" gorm:"embedded;embeddedPrefix:evidence_"`
+}
+
+// EvidenceRequirements is the proof a step must be completed with: at least
+// MinPhotos photos, a note, a signature, and a position within the geofence of
+// the incident's premise.
+type EvidenceRequirements struct {
+	MinPhotos int  `json:"min_photos" gorm:"default:0"`
+	Note      bool `json:"note" gorm:"default:false"`
+	Signature bool `json:"signature" gorm:"default:false"`
+	Location  bool `json:"location" gorm:"default:false"`
+}
+
+// Types of guidance steps
+const (
+	GuidanceStepTypeCheckbox     = "checkbox"
+	GuidanceStepTypeYesNo        = "yes_no"
+	GuidanceStepTypeSingleChoice = "single_choice"
+	GuidanceStepTypeText         = "text"
+	GuidanceStepTypeNumber       = "number"
+	GuidanceStepTypePhoto        = "photo"
+	GuidanceStepTypeSignature    = "signature"
+)
+
+// StepBranch leads from a step to a later one, or ends the guidance, when the
+// step is answered with Answer. A branch without an answer is taken for any
+// answer no other branch matches; steps without one go on with the next step.
+type StepBranch struct {
+	Answer     string     `json:"answer,omitempty"`
+	GoToStepID *uuid.UUID `json:"go_to_step_id,omitempty"`
+	End        bool       `json:"end,omitempty"`
+}
+
+// StepBranches stores the branches of a step in a jsonb column.
+type StepBranches []StepBranch
+
+func (b StepBranches) Value() (driver.Value, error) {
+	if b == nil {
+		return nil, nil
+	}
+	return jsonValue([]StepBranch(b))
+}
+
+func (b *StepBranches) Scan(value interface{}) error {
+	return jsonScan(value, (*[]StepBranch)(b))
+}
+
+func (StepBranches) GormDataType() string {
+	return "jsonb"
+}
+
+// StringList stores a list of strings in a jsonb column.
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return jsonValue([]string(l))
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	return jsonScan(value, (*[]string)(l))
+}
+
+func (StringList) GormDataType() string {
+	return "jsonb"
 }
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"database/sql/driver"
+
+	"github.com/google/uuid"
+)
+
+// GuidanceTemplateVersion is a published guidance template, frozen together
+// with its steps. Versions are never changed once published; incidents are
+// given the steps of a version, never those of the draft.
+type GuidanceTemplateVersion struct {
+	Base
+	GuidanceTemplateID uuid.UUID     `json:"guidance_template_id" gorm:"uniqueIndex:idx_guidance_template_version"`
+	Version            int           `json:"version" gorm:"uniqueIndex:idx_guidance_template_version"`
+	Name               string        `json:"name"`
+	Description        string        `json:"description"`
+	Category           string        `json:"category"`
+	Steps              GuidanceSteps `json:"steps"`
+	// RestoredFrom is the version a rollback copied
+	RestoredFrom  *int       `json:"restored_from,omitempty"`
+	Note          string     `json:"note,omitempty"`
+	PublishedByID *uuid.UUID `json:"published_by_id"`
+	PublishedBy   *User      `json:"published_by,omitempty" gorm:"foreignKey:PublishedByID"`
+}
+
+// GuidanceSteps stores the steps of a template version in a jsonb column.
+type GuidanceSteps []GuidanceStep
+
+func (s GuidanceSteps) Value() (driver.Value, error) {
+	if s == nil {
+		s = GuidanceSteps{}
+	}
+	return jsonValue([]GuidanceStep(s))
+}
+
+func (s *GuidanceSteps) Scan(value interface{}) error {
+	return jsonScan(value, (*[]GuidanceStep)(s))
+}
+
+func (GuidanceSteps) GormDataType() string {
+	return "jsonb"
+}
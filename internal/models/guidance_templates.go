@@ -1,9 +1,18 @@
 package models
 
+import "github.com/google/uuid"
+
+// GuidanceTemplate is a procedure guards follow on incidents. Its fields and
+// steps are the draft of the next version; incidents follow PublishedVersion.
 type GuidanceTemplate struct {
 	Base
 	Name          string         `json:"name"`
 	Description   string         `json:"description"`
 	Category      string         `json:"category"`
 	GuidanceSteps []GuidanceStep `json:"guidance_steps" gorm:"foreignKey:GuidanceTemplateID"`
+	// RequiredQualifications must be held, unexpired, by the guidance assignee.
+	// They apply to every version.
+	RequiredQualifications []Qualification          `json:"required_qualifications" gorm:"many2many:guidance_template_qualifications"`
+	PublishedVersionID     *uuid.UUID               `json:"published_version_id"`
+	PublishedVersion       *GuidanceTemplateVersion `json:"published_version,omitempty" gorm:"foreignKey:PublishedVersionID"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "github.com/google/uuid"
+
+// IncidentActivity is an entry in an incident's activity feed: a comment left by
+// a user or a system entry recorded when the incident changes.
+type IncidentActivity struct {
+	Base
+	IncidentID uuid.UUID  `json:"incident_id" gorm:"index"`
+	ActorID    *uuid.UUID `json:"actor_id,omitempty"`
+	Actor      *User      `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+	Type       string     `json:"type" gorm:"check:type IN ('comment', 'status_changed', 'step_completed', 'media_uploaded', 'guidance_assigned', 'guidance_reassigned', 'guidance_replaced')"`
+	Message    string     `json:"message"`
+	Details    JSONB      `json:"details,omitempty"`
+	// Mentions are the users notified about a comment
+	Mentions []User `json:"mentions,omitempty" gorm:"many2many:incident_activity_mentions"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IncidentAlarm links an alarm to an incident.
+type IncidentAlarm struct {
+	IncidentID uuid.UUID  `json:"incident_id" gorm:"primaryKey"`
+	AlarmID    uuid.UUID  `json:"alarm_id" gorm:"primaryKey;index"`
+	LinkedByID *uuid.UUID `json:"linked_by_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
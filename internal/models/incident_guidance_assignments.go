@@ -0,0 +1,23 @@
+package models
+
+import "github.com/google/uuid"
+
+// IncidentGuidanceAssignment records an assignment, reassignment or replacement
+// of an incident's guidance.
+type IncidentGuidanceAssignment struct {
+	Base
+	IncidentID         uuid.UUID         `json:"incident_id" gorm:"index"`
+	IncidentGuidanceID uuid.UUID         `json:"incident_guidance_id"`
+	IncidentGuidance   *IncidentGuidance `json:"incident_guidance,omitempty" gorm:"foreignKey:IncidentGuidanceID"`
+	// PreviousGuidanceID is the archived guidance when the template was replaced
+	PreviousGuidanceID *uuid.UUID        `json:"previous_guidance_id,omitempty"`
+	PreviousGuidance   *IncidentGuidance `json:"previous_guidance,omitempty" gorm:"foreignKey:PreviousGuidanceID"`
+	Action             string            `json:"action" gorm:"check:action IN ('assigned', 'reassigned', 'replaced')"`
+	AssigneeID         *uuid.UUID        `json:"assignee_id"`
+	Assignee           *User             `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`
+	PreviousAssigneeID *uuid.UUID        `json:"previous_assignee_id,omitempty"`
+	PreviousAssignee   *User             `json:"previous_assignee,omitempty" gorm:"foreignKey:PreviousAssigneeID"`
+	AssignerID         *uuid.UUID        `json:"assigner_id"`
+	Assigner           *User             `json:"assigner,omitempty" gorm:"foreignKey:AssignerID"`
+	Reason             string            `json:"reason,omitempty"`
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/google/uuid"
 )
 
+// IncidentGuidanceStep is a template step copied onto an incident's guidance.
+// Branches lead to other steps of the same guidance.
 type IncidentGuidanceStep struct {
 	Base
 	IncidentGuidanceID uuid.UUID         `json:"incident_guidance_id"`
@@ -13,6 +15,19 @@ type IncidentGuidanceStep struct {
 	StepNumber         int64             `json:"step_number"`
 	Title              string            `json:"title"`
 	Description        string            `json:"description"`
+	Type               string            `json:"type" gorm:"default:checkbox"`
+	Options            StringList        `json:"options,omitempty"`
+	Branches           StepBranches      `json:"branches,omitempty"`
 	IsCompleted        bool              `json:"is_completed" gorm:"default:false"`
 	CompletedAt        *time.Time        `json:"completed_at,omitempty"`
+	Answer             *string           `json:"answer,omitempty"`
+	// Skipped steps were jumped over by a branch and can no longer be done
+	Skipped  bool                 `json:"skipped" gorm:"default:false"`
+	Evidence EvidenceRequirements `json:"evidence" gorm:"embedded;embeddedPrefix:evidence_"`
+	// Note and the position the step was completed at are given with the
+	// completion, its photos and signature are linked as Media
+	Note      string          `json:"note,omitempty"`
+	Latitude  *float64        `json:"latitude,omitempty" gorm:"check:latitude BETWEEN -90 AND 90"`
+	Longitude *float64        `json:"longitude,omitempty" gorm:"check:longitude BETWEEN -180 AND 180"`
+	Media     []IncidentMedia `json:"media,omitempty" gorm:"foreignKey:IncidentGuidanceStepID"`
 }
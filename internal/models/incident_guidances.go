@@ -1,16 +1,34 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
 
+	"github.com/google/uuid"
+)
+
+// IncidentGuidance is a guidance template assigned to an incident. An incident
+// has at most one active guidance; replaced guidance is archived with its steps.
 type IncidentGuidance struct {
 	Base
-	IncidentID            *uuid.UUID             `json:"incident_id"  gorm:"uniqueIndex:idx_incident_guidance"`
-	Incident              *Incident              `json:"incident,omitempty" gorm:"foreignKey:IncidentID"`
-	GuidanceTemplateID    *uuid.UUID             `json:"guidance_template_id" gorm:"uniqueIndex:idx_incident_guidance"`
-	GuidanceTemplate      *GuidanceTemplate      `json:"guidance_template,omitempty" gorm:"foreignKey:GuidanceTemplateID"`
-	AssignerID            *uuid.UUID             `json:"assigner_id"`
-	Assigner              *User                  `json:"assigner,omitempty" gorm:"foreignKey:AssignerID"`
-	AssigneeID            *uuid.UUID             `json:"assignee_id"`
-	Assignee              *User                  `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`
+	IncidentID         *uuid.UUID        `json:"incident_id" gorm:"index;uniqueIndex:idx_incident_guidance_active,where:status = 'active'"`
+	Incident           *Incident         `json:"incident,omitempty" gorm:"foreignKey:IncidentID"`
+	GuidanceTemplateID *uuid.UUID        `json:"guidance_template_id"`
+	GuidanceTemplate   *GuidanceTemplate `json:"guidance_template,omitempty" gorm:"foreignKey:GuidanceTemplateID"`
+	// GuidanceTemplateVersionID is the template version the steps were copied
+	// from, unset for guidance assigned before templates were versioned
+	GuidanceTemplateVersionID *uuid.UUID               `json:"guidance_template_version_id"`
+	GuidanceTemplateVersion   *GuidanceTemplateVersion `json:"guidance_template_version,omitempty" gorm:"foreignKey:GuidanceTemplateVersionID"`
+	TemplateVersion           int                      `json:"template_version,omitempty"`
+	AssignerID                *uuid.UUID               `json:"assigner_id"`
+	Assigner                  *User                    `json:"assigner,omitempty" gorm:"foreignKey:AssignerID"`
+	AssigneeID                *uuid.UUID               `json:"assignee_id"`
+	Assignee                  *User                    `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`
+	// AssignmentReason explains why the assignee was picked automatically
+	AssignmentReason string     `json:"assignment_reason,omitempty"`
+	Status           string     `json:"status" gorm:"default:active;check:status IN ('active', 'archived')"`
+	ArchivedAt       *time.Time `json:"archived_at,omitempty" gorm:"type:timestamptz"`
+	// NextStepID is the step to do next along the answers given so far, unset
+	// once the guidance is done
+	NextStepID            *uuid.UUID             `json:"next_step_id"`
 	IncidentGuidanceSteps []IncidentGuidanceStep `json:"incident_guidance_steps" gorm:"foreignKey:IncidentGuidanceID"`
 }
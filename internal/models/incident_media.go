@@ -1,7 +1,13 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
 
+	"github.com/google/uuid"
+)
+
+// IncidentMedia is a photo or video of an incident. Media captured offline is
+// registered as pending by the sync API and uploaded once the device is online.
 type IncidentMedia struct {
 	Base
 	IncidentID uuid.UUID `json:"incident_id"`
@@ -11,4 +17,10 @@ type IncidentMedia struct {
 	FileSize   int64     `json:"file_size"`
 	FileType   string    `json:"file_type"`
 	FileName   string    `json:"file_name"`
+	Status     string    `json:"status" gorm:"default:uploaded;check:status IN ('pending', 'uploaded')"`
+	// CapturedAt is when the media was taken, when the device reported it
+	CapturedAt *time.Time `json:"captured_at,omitempty" gorm:"type:timestamptz"`
+	// IncidentGuidanceStepID is the step the media is a photo or signature of
+	IncidentGuidanceStepID *uuid.UUID `json:"incident_guidance_step_id,omitempty" gorm:"index"`
+	Evidence               string     `json:"evidence,omitempty" gorm:"check:evidence IN ('', 'photo', 'signature')"`
 }
@@ -6,13 +6,18 @@ import (
 
 type Incident struct {
 	Base
-	Name             string            `json:"name"`
-	Description      string            `json:"description"`
-	AlarmID          uuid.UUID         `json:"alarm_id,omitempty"`
-	Alarm            *Alarm            `json:"alarm,omitempty" gorm:"foreignKey:AlarmID"`
-	Status           string            `json:"status" gorm:"check:status IN ('new', 'in_progress', 'resolved')"`
-	Severity         string            `json:"severity" gorm:"check:severity IN ('low', 'medium', 'high')"`
-	Location         string            `json:"location"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	PremiseID   *uuid.UUID `json:"premise_id,omitempty" gorm:"index"`
+	Premise     *Premise   `json:"premise,omitempty" gorm:"foreignKey:PremiseID"`
+	// Alarms are linked through incident_alarms; incidents reported by phone have none
+	Alarms   []Alarm `json:"alarms,omitempty" gorm:"many2many:incident_alarms"`
+	Status   string  `json:"status" gorm:"check:status IN ('new', 'in_progress', 'resolved')"`
+	Severity string  `json:"severity" gorm:"check:severity IN ('low', 'medium', 'high')"`
+	Location string  `json:"location"`
+	// Latitude and Longitude pin the incident on a map, inherited from the premise when not reported
+	Latitude         *float64          `json:"latitude,omitempty" gorm:"index:idx_incidents_position,priority:1;check:latitude BETWEEN -90 AND 90"`
+	Longitude        *float64          `json:"longitude,omitempty" gorm:"index:idx_incidents_position,priority:2;check:longitude BETWEEN -180 AND 180"`
 	IncidentGuidance *IncidentGuidance `json:"incident_guidance,omitempty" gorm:"foreignKey:IncidentID"`
 	IncidentMedia    []IncidentMedia   `json:"incident_media,omitempty" gorm:"foreignKey:IncidentID"`
 }
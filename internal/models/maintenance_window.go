@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaintenanceWindow suppresses alarms raised during planned works. It is scoped
+// to a single device or to a premise, optionally including its sub-premises.
+type MaintenanceWindow struct {
+	Base
+	Name               string     `json:"name"`
+	Reason             string     `json:"reason"`
+	PremiseID          *uuid.UUID `json:"premise_id,omitempty" gorm:"index"`
+	Premise            *Premise   `json:"premise,omitempty" gorm:"foreignKey:PremiseID"`
+	IncludeSubPremises bool       `json:"include_sub_premises"`
+	DeviceID           *uuid.UUID `json:"device_id,omitempty" gorm:"index"`
+	Device             *Device    `json:"device,omitempty" gorm:"foreignKey:DeviceID"`
+	StartsAt           time.Time  `json:"starts_at" gorm:"type:timestamptz"`
+	EndsAt             time.Time  `json:"ends_at" gorm:"type:timestamptz"`
+	Recurrence         string     `json:"recurrence" gorm:"default:none;check:recurrence IN ('none', 'daily', 'weekly', 'monthly')"`
+	RecurrenceUntil    *time.Time `json:"recurrence_until,omitempty" gorm:"type:timestamptz"`
+	Timezone           string     `json:"timezone" gorm:"default:UTC"`
+	CreatedByID        *uuid.UUID `json:"created_by_id,omitempty"`
+	CreatedBy          *User      `json:"created_by,omitempty" gorm:"foreignKey:CreatedByID"`
+}
+
+// IsActiveAt reports whether t falls into the window or into one of its recurrences.
+// Recurrences keep the wall clock time of the first window in its timezone, so
+// they do not drift across daylight saving changes. Monthly recurrences fall on
+// the last day of shorter months.
+func (w *MaintenanceWindow) IsActiveAt(t time.Time) bool {
+	if t.Before(w.StartsAt) {
+		return false
+	}
+	if w.RecurrenceUntil != nil && t.After(*w.RecurrenceUntil) {
+		return false
+	}
+	duration := w.EndsAt.Sub(w.StartsAt)
+	first := w.StartsAt.In(w.location())
+	start := first
+	switch w.Recurrence {
+	case "daily", "weekly":
+		period := 1
+		if w.Recurrence == "weekly" {
+			period = 7
+		}
+		periods := daysBetween(first, t.In(first.Location())) / period
+		start = addDays(first, periods*period)
+		if start.After(t) {
+			start = addDays(first, (periods-1)*period)
+		}
+	case "monthly":
+		local := t.In(first.Location())
+		months := (local.Year()-first.Year())*12 + int(local.Month()-first.Month())
+		start = addMonths(first, months)
+		if start.After(t) {
+			start = addMonths(first, months-1)
+		}
+	}
+	return t.Before(start.Add(duration))
+}
+
+// location returns the timezone the recurrences are computed in, UTC when it
+// is unset or unknown.
+func (w *MaintenanceWindow) location() *time.Location {
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// daysBetween returns the number of calendar days from from to to.
+func daysBetween(from, to time.Time) int {
+	fromDate := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
+
+// addDays moves t by days calendar days, keeping its wall clock time.
+func addDays(t time.Time, days int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+days, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// addMonths moves t by months calendar months, keeping its wall clock time and
+// clamping the day to the end of the target month.
+func addMonths(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	day := t.Day()
+	if lastDay := firstOfMonth.AddDate(0, 1, -1).Day(); day > lastDay {
+		day = lastDay
+	}
+	return addDays(firstOfMonth, day-1)
+}
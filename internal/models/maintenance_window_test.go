@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowIsActiveAt(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("invalid time %q: %v", value, err)
+		}
+		return parsed
+	}
+	until := at("2026-04-10T00:00:00Z")
+	// 08:00-09:00 in Berlin, which moves from UTC+1 to UTC+2 on 2026-03-29
+	berlin := func(recurrence string) MaintenanceWindow {
+		return MaintenanceWindow{
+			StartsAt:   at("2026-03-20T07:00:00Z"),
+			EndsAt:     at("2026-03-20T08:00:00Z"),
+			Recurrence: recurrence,
+			Timezone:   "Europe/Berlin",
+		}
+	}
+	monthly := MaintenanceWindow{
+		StartsAt:   at("2026-01-31T10:00:00Z"),
+		EndsAt:     at("2026-01-31T11:00:00Z"),
+		Recurrence: "monthly",
+	}
+	tests := []struct {
+		name     string
+		window   MaintenanceWindow
+		at       string
+		expected bool
+	}{
+		{name: "Before the first window", window: berlin("daily"), at: "2026-03-20T06:30:00Z", expected: false},
+		{name: "One-off window", window: berlin("none"), at: "2026-03-20T07:30:00Z", expected: true},
+		{name: "One-off window is not repeated", window: berlin("none"), at: "2026-03-21T07:30:00Z", expected: false},
+		{name: "Daily before the clock change", window: berlin("daily"), at: "2026-03-28T07:30:00Z", expected: true},
+		{name: "Daily keeps 08:00 after the clock change", window: berlin("daily"), at: "2026-03-30T06:30:00Z", expected: true},
+		{name: "Daily does not drift to 09:00", window: berlin("daily"), at: "2026-03-30T07:30:00Z", expected: false},
+		{name: "Weekly keeps 08:00 after the clock change", window: berlin("weekly"), at: "2026-04-03T06:30:00Z", expected: true},
+		{name: "Weekly skips other days", window: berlin("weekly"), at: "2026-04-02T06:30:00Z", expected: false},
+		{name: "Recurrence ends", window: func() MaintenanceWindow {
+			w := berlin("daily")
+			w.RecurrenceUntil = &until
+			return w
+		}(), at: "2026-04-11T06:30:00Z", expected: false},
+		{name: "Monthly falls on the end of a shorter month", window: monthly, at: "2026-02-28T10:30:00Z", expected: true},
+		{name: "Monthly does not overflow into the next month", window: monthly, at: "2026-03-03T10:30:00Z", expected: false},
+		{name: "Monthly returns to the original day", window: monthly, at: "2026-03-31T10:30:00Z", expected: true},
+		{name: "Monthly is not pulled forward by clamping", window: monthly, at: "2026-03-28T10:30:00Z", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.IsActiveAt(at(tt.at)); got != tt.expected {
+				t.Errorf("expected active %v at %s, got %v", tt.expected, tt.at, got)
+			}
+		})
+	}
+}
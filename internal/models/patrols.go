@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PatrolRoute is a round guards walk on a premise, passing its checkpoints in
+// order within DurationMinutes.
+type PatrolRoute struct {
+	Base
+	PremiseID   uuid.UUID `json:"premise_id" gorm:"index"`
+	Premise     *Premise  `json:"premise,omitempty" gorm:"foreignKey:PremiseID"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	// DurationMinutes is how long a patrol may take from its scheduled start
+	DurationMinutes int `json:"duration_minutes" gorm:"check:duration_minutes > 0"`
+	// ToleranceMinutes is how late a checkpoint or patrol may be before it is missed
+	ToleranceMinutes int                `json:"tolerance_minutes" gorm:"check:tolerance_minutes >= 0"`
+	Checkpoints      []PatrolCheckpoint `json:"checkpoints,omitempty" gorm:"foreignKey:PatrolRouteID"`
+}
+
+// PatrolCheckpoint is a QR or NFC tag on a patrol route. Code is the value
+// read from the tag and identifies the checkpoint.
+type PatrolCheckpoint struct {
+	Base
+	PatrolRouteID uuid.UUID `json:"patrol_route_id" gorm:"index"`
+	Sequence      int       `json:"sequence"`
+	Name          string    `json:"name"`
+	Code          string    `json:"code" gorm:"uniqueIndex"`
+	CodeType      string    `json:"code_type" gorm:"check:code_type IN ('qr', 'nfc')"`
+	// ExpectedAfterMinutes is when the checkpoint is due, counted from the patrol's scheduled start
+	ExpectedAfterMinutes int      `json:"expected_after_minutes" gorm:"check:expected_after_minutes >= 0"`
+	Latitude             *float64 `json:"latitude,omitempty" gorm:"check:latitude BETWEEN -90 AND 90"`
+	Longitude            *float64 `json:"longitude,omitempty" gorm:"check:longitude BETWEEN -180 AND 180"`
+}
+
+// PatrolRun is a scheduled patrol of a route by a guard.
+type PatrolRun struct {
+	Base
+	PatrolRouteID uuid.UUID    `json:"patrol_route_id" gorm:"index"`
+	PatrolRoute   *PatrolRoute `json:"patrol_route,omitempty" gorm:"foreignKey:PatrolRouteID"`
+	GuardID       uuid.UUID    `json:"guard_id" gorm:"index"`
+	Guard         *User        `json:"guard,omitempty" gorm:"foreignKey:GuardID"`
+	Status        string       `json:"status" gorm:"index;check:status IN ('scheduled', 'in_progress', 'completed', 'overdue', 'cancelled')"`
+	ScheduledAt   time.Time    `json:"scheduled_at" gorm:"type:timestamptz;index"`
+	// DueAt is when the patrol should be completed, it is overdue after the route's tolerance
+	DueAt       time.Time  `json:"due_at" gorm:"type:timestamptz;index"`
+	StartedAt   *time.Time `json:"started_at,omitempty" gorm:"type:timestamptz"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" gorm:"type:timestamptz"`
+	// OverdueAlarmID is the alarm raised when the patrol was not completed in time
+	OverdueAlarmID *uuid.UUID            `json:"overdue_alarm_id,omitempty"`
+	AssignedByID   *uuid.UUID            `json:"assigned_by_id,omitempty"`
+	Checkpoints    []PatrolRunCheckpoint `json:"checkpoints,omitempty" gorm:"foreignKey:PatrolRunID"`
+}
+
+// PatrolRunCheckpoint is a checkpoint of a patrol run, copied from the route
+// when the run is scheduled so later route changes leave it alone.
+type PatrolRunCheckpoint struct {
+	Base
+	PatrolRunID        uuid.UUID `json:"patrol_run_id" gorm:"index"`
+	PatrolCheckpointID uuid.UUID `json:"patrol_checkpoint_id"`
+	Sequence           int       `json:"sequence"`
+	Name               string    `json:"name"`
+	// Code is kept from guards so a checkpoint cannot be scanned without visiting it
+	Code      string     `json:"-"`
+	Status    string     `json:"status" gorm:"index;check:status IN ('pending', 'scanned', 'late', 'missed')"`
+	DueAt     time.Time  `json:"due_at" gorm:"type:timestamptz;index"`
+	ScannedAt *time.Time `json:"scanned_at,omitempty" gorm:"type:timestamptz"`
+	// AlarmID is the alarm raised when the checkpoint was missed
+	AlarmID *uuid.UUID `json:"alarm_id,omitempty"`
+}
+
+// PatrolScan is a checkpoint tag read by a guard during a patrol run.
+type PatrolScan struct {
+	Base
+	PatrolRunID           uuid.UUID `json:"patrol_run_id" gorm:"index"`
+	PatrolRunCheckpointID uuid.UUID `json:"patrol_run_checkpoint_id" gorm:"index"`
+	GuardID               uuid.UUID `json:"guard_id"`
+	Code                  string    `json:"code"`
+	ScannedAt             time.Time `json:"scanned_at" gorm:"type:timestamptz"`
+	Latitude              *float64  `json:"latitude,omitempty"`
+	Longitude             *float64  `json:"longitude,omitempty"`
+}
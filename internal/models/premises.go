@@ -6,6 +6,11 @@ type Premise struct {
 	Base
 	Name            string     `json:"name"`
 	Address         string     `json:"address"`
+	Latitude        *float64   `json:"latitude,omitempty" gorm:"check:latitude BETWEEN -90 AND 90"`
+	Longitude       *float64   `json:"longitude,omitempty" gorm:"check:longitude BETWEEN -180 AND 180"`
 	ParentPremiseID *uuid.UUID `json:"parent_premise_id,omitempty"`
 	ParentPremise   *Premise   `json:"parent_premise,omitempty" gorm:"foreignKey:ParentPremiseID"`
+	// GeofenceRadius is how far from the premise, in meters, steps requiring a
+	// location may be completed. The configured default applies when unset
+	GeofenceRadius *float64 `json:"geofence_radius,omitempty" gorm:"check:geofence_radius > 0"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Qualification is a skill or certificate, such as first aid or fire safety,
+// that guidance templates can require of their assignee.
+type Qualification struct {
+	Base
+	Name        string `json:"name" gorm:"uniqueIndex"`
+	Description string `json:"description"`
+}
+
+// UserQualification is a qualification held by a user. It is valid until
+// ExpiresAt, or indefinitely when that is not set.
+type UserQualification struct {
+	Base
+	UserID            uuid.UUID      `json:"user_id" gorm:"uniqueIndex:idx_user_qualification"`
+	User              *User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	QualificationID   uuid.UUID      `json:"qualification_id" gorm:"uniqueIndex:idx_user_qualification"`
+	Qualification     *Qualification `json:"qualification,omitempty" gorm:"foreignKey:QualificationID"`
+	CertificateNumber string         `json:"certificate_number,omitempty"`
+	IssuedAt          *time.Time     `json:"issued_at,omitempty" gorm:"type:timestamptz"`
+	ExpiresAt         *time.Time     `json:"expires_at,omitempty" gorm:"type:timestamptz;index"`
+}
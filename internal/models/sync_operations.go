@@ -0,0 +1,15 @@
+package models
+
+import "github.com/google/uuid"
+
+// SyncOperation is an operation a device queued offline and uploaded through
+// the sync API. Its result is kept so a batch sent again after a lost response
+// is not applied twice.
+type SyncOperation struct {
+	Base
+	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid;uniqueIndex:idx_sync_operation"`
+	OperationID uuid.UUID `json:"operation_id" gorm:"type:uuid;uniqueIndex:idx_sync_operation"`
+	Type        string    `json:"type"`
+	Status      string    `json:"status"`
+	Result      JSONB     `json:"result,omitempty"`
+}
@@ -33,12 +33,17 @@ func (ap AlarmProcessor) Process(msg kafka.Message) error {
 		ap.logger.Errorf("Failed to unmarshal message: %v", err)
 		return err
 	}
-	_, err = ap.alarmService.CreateAlarm(context.Background(), &createAlarmDto)
+	return ap.createAlarm(context.Background(), &createAlarmDto)
+}
+
+// createAlarm is the processing path shared by every alarm source.
+func (ap AlarmProcessor) createAlarm(ctx context.Context, createAlarmDto *dto.CreateAlarmDto) error {
+	_, err := ap.alarmService.CreateAlarm(ctx, createAlarmDto)
 	if err != nil {
 		ap.logger.Errorf("Failed to create alarm: %v", err)
 		return err
 	}
 	ap.logger.Info("Alarm created")
-	
+
 	return nil // or return an actual error if something goes wrong
 }
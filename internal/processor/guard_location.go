@@ -0,0 +1,33 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"scs-operator/internal/app/guard/dto"
+	services "scs-operator/internal/app/guard/service"
+	"scs-operator/pkg/logger"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type GuardLocationProcessor struct {
+	guardService services.Service
+	logger       logger.Logger
+}
+
+func NewGuardLocationProcessor(guardService services.Service, logger logger.Logger) Processor {
+	return &GuardLocationProcessor{guardService: guardService, logger: logger}
+}
+
+func (gp GuardLocationProcessor) Process(msg kafka.Message) error {
+	var guardLocationDto dto.GuardLocationDto
+	if err := json.Unmarshal(msg.Value, &guardLocationDto); err != nil {
+		gp.logger.Errorf("Failed to unmarshal guard location: %v", err)
+		return err
+	}
+	if _, err := gp.guardService.RecordLocation(context.Background(), &guardLocationDto); err != nil {
+		gp.logger.Errorf("Failed to record guard location: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"scs-operator/internal/app/device/dto"
+	services "scs-operator/internal/app/device/service"
+	"scs-operator/pkg/logger"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type HeartbeatProcessor struct {
+	deviceService services.Service
+	logger        logger.Logger
+}
+
+func NewHeartbeatProcessor(deviceService services.Service, logger logger.Logger) Processor {
+	return &HeartbeatProcessor{deviceService: deviceService, logger: logger}
+}
+
+func (hp HeartbeatProcessor) Process(msg kafka.Message) error {
+	var heartbeatDto dto.HeartbeatDto
+	if err := json.Unmarshal(msg.Value, &heartbeatDto); err != nil {
+		hp.logger.Errorf("Failed to unmarshal heartbeat: %v", err)
+		return err
+	}
+	if err := hp.deviceService.RecordHeartbeat(context.Background(), &heartbeatDto); err != nil {
+		hp.logger.Errorf("Failed to record heartbeat: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"scs-operator/internal/app/alarm/dto"
+	services "scs-operator/internal/app/alarm/service"
+	"scs-operator/pkg/errors"
+	"scs-operator/pkg/logger"
+	mqtt_client "scs-operator/pkg/mqtt"
+)
+
+// MqttAlarmProcessor turns IoT sensor messages into alarms. The premise and
+// device serial are taken from the {premise_id} and {device} topic segments
+// when the payload does not carry them.
+type MqttAlarmProcessor struct {
+	alarmProcessor AlarmProcessor
+	patterns       []*mqtt_client.TopicPattern
+	logger         logger.Logger
+}
+
+func NewMqttAlarmProcessor(alarmService services.Service, patterns []*mqtt_client.TopicPattern, logger logger.Logger) *MqttAlarmProcessor {
+	return &MqttAlarmProcessor{
+		alarmProcessor: AlarmProcessor{alarmService: alarmService, logger: logger},
+		patterns:       patterns,
+		logger:         logger,
+	}
+}
+
+// Process creates the alarm for a message. Only failures that may succeed on
+// redelivery are returned; malformed or rejected messages are logged and
+// dropped, since redelivering them would fail the same way.
+func (p *MqttAlarmProcessor) Process(topic string, payload []byte) error {
+	var createAlarmDto dto.CreateAlarmDto
+	if err := json.Unmarshal(payload, &createAlarmDto); err != nil {
+		p.logger.Errorf("Dropping malformed MQTT message on %s: %v", topic, err)
+		return nil
+	}
+	for _, pattern := range p.patterns {
+		values, ok := pattern.Match(topic)
+		if !ok {
+			continue
+		}
+		if createAlarmDto.PremiseID == "" {
+			createAlarmDto.PremiseID = values["premise_id"]
+		}
+		if createAlarmDto.DeviceID == "" && createAlarmDto.Serial() == "" {
+			createAlarmDto.DeviceSerial = values["device"]
+		}
+		break
+	}
+	err := p.alarmProcessor.createAlarm(context.Background(), &createAlarmDto)
+	if appErr, ok := errors.IsAppError(err); ok && appErr.StatusCode < http.StatusInternalServerError {
+		p.logger.Errorf("Dropping rejected MQTT message on %s: %v", topic, err)
+		return nil
+	}
+	return err
+}
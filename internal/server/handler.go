@@ -15,6 +15,18 @@ import (
 
 	guardsHttp "scs-operator/internal/app/guard/delivery/http"
 
+	devicesHttp "scs-operator/internal/app/device/delivery/http"
+
+	maintenanceWindowsHttp "scs-operator/internal/app/maintenance-window/delivery/http"
+
+	streamHttp "scs-operator/internal/app/stream/delivery/http"
+
+	patrolsHttp "scs-operator/internal/app/patrol/delivery/http"
+
+	qualificationsHttp "scs-operator/internal/app/qualification/delivery/http"
+
+	syncHttp "scs-operator/internal/app/sync/delivery/http"
+
 	myMiddleware "scs-operator/internal/middlewares"
 
 	"github.com/labstack/echo/v4"
@@ -31,6 +43,12 @@ func (s *Server) MapHandlers(e *echo.Echo) error {
 	guidanceStepsHandlers := guidanceStepsHttp.NewHandler(*s.container.GuidanceStepService)
 	alarmsHandlers := alarmsHttp.NewHandler(*s.container.AlarmService)
 	guardsHandlers := guardsHttp.NewHandler(*s.container.GuardService)
+	devicesHandlers := devicesHttp.NewHandler(*s.container.DeviceService)
+	maintenanceWindowsHandlers := maintenanceWindowsHttp.NewHandler(*s.container.MaintenanceWindowService)
+	streamHandlers := streamHttp.NewHandler(*s.container.StreamService)
+	patrolsHandlers := patrolsHttp.NewHandler(*s.container.PatrolService)
+	qualificationsHandlers := qualificationsHttp.NewHandler(*s.container.QualificationService)
+	syncHandlers := syncHttp.NewHandler(*s.container.SyncService)
 
 	// Enable CORS for all origins
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
@@ -57,6 +75,12 @@ func (s *Server) MapHandlers(e *echo.Echo) error {
 	guidanceStepsGroup := v1.Group("/guidance-steps", mw.JWTAuth)
 	alarmsGroup := v1.Group("/alarms", mw.JWTAuth)
 	guardsGroup := v1.Group("/guards", mw.JWTAuth)
+	devicesGroup := v1.Group("/devices", mw.JWTAuth)
+	maintenanceWindowsGroup := v1.Group("/maintenance-windows", mw.JWTAuth)
+	streamGroup := v1.Group("/stream", mw.StreamAuth)
+	patrolsGroup := v1.Group("/patrols", mw.JWTAuth)
+	qualificationsGroup := v1.Group("/qualifications", mw.JWTAuth)
+	syncGroup := v1.Group("/sync", mw.JWTAuth)
 
 	// Health check endpoint
 	// @Summary Health Check
@@ -71,10 +95,18 @@ func (s *Server) MapHandlers(e *echo.Echo) error {
 	})
 	premisesHandlers.RegisterRoutes(premisesGroup)
 	incidentsHandlers.RegisterRoutes(incidentsGroup)
+	// Uploaded incident media, served to authenticated users only
+	incidentsGroup.Static("/media", s.cfg.Incident.MediaDir)
 	guidanceTemplatesHandlers.RegisterRoutes(guidanceTemplatesGroup)
 	guidanceStepsHandlers.RegisterRoutes(guidanceStepsGroup)
 	alarmsHandlers.RegisterRoutes(alarmsGroup)
 	guardsHandlers.RegisterRoutes(guardsGroup)
+	devicesHandlers.RegisterRoutes(devicesGroup)
+	maintenanceWindowsHandlers.RegisterRoutes(maintenanceWindowsGroup)
+	streamHandlers.RegisterRoutes(streamGroup)
+	patrolsHandlers.RegisterRoutes(patrolsGroup)
+	qualificationsHandlers.RegisterRoutes(qualificationsGroup)
+	syncHandlers.RegisterRoutes(syncGroup)
 	return nil
 
 }
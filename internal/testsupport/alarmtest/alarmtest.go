@@ -0,0 +1,42 @@
+// Package alarmtest builds the alarm service the tests of its callers run
+// against.
+package alarmtest
+
+import (
+	alarmRepositories "scs-operator/internal/app/alarm/repository"
+	alarmServices "scs-operator/internal/app/alarm/service"
+	auditRepositories "scs-operator/internal/app/audit/repository"
+	deviceRepositories "scs-operator/internal/app/device/repository"
+	incidentRepositories "scs-operator/internal/app/incident/repository"
+	maintenanceWindowRepositories "scs-operator/internal/app/maintenance-window/repository"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	userRepositories "scs-operator/internal/app/user/repository"
+	"scs-operator/internal/testsupport"
+	"scs-operator/internal/testsupport/incidenttest"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/stream"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NewService wires the alarm service and its incident service to the real
+// repositories on db.
+func NewService(t *testing.T, db *gorm.DB) *alarmServices.Service {
+	t.Helper()
+	return alarmServices.NewAlarmService(
+		*alarmRepositories.NewAlarmRepository(db),
+		*premiseRepositories.NewPremiseRepository(db),
+		*deviceRepositories.NewDeviceRepository(db),
+		*maintenanceWindowRepositories.NewMaintenanceWindowRepository(db),
+		*incidentRepositories.NewIncidentRepository(db),
+		*incidenttest.NewService(t, db),
+		*auditRepositories.NewAuditRepository(db),
+		*userRepositories.NewUserRepository(db),
+		*database.NewTransactor(db),
+		testsupport.NewProducer(t),
+		*stream.NewBroker(10),
+		5*time.Minute,
+	)
+}
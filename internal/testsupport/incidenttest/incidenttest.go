@@ -0,0 +1,49 @@
+// Package incidenttest builds the incident service the tests of its callers
+// run against. It lives apart from testsupport so that the incident service
+// tests can use testsupport without an import cycle.
+package incidenttest
+
+import (
+	alarmRepositories "scs-operator/internal/app/alarm/repository"
+	guardRepositories "scs-operator/internal/app/guard/repository"
+	guidanceTemplateRepository "scs-operator/internal/app/guidance-template/repository"
+	incidentRepositories "scs-operator/internal/app/incident/repository"
+	incidentServices "scs-operator/internal/app/incident/service"
+	premiseRepositories "scs-operator/internal/app/premise/repository"
+	qualificationRepositories "scs-operator/internal/app/qualification/repository"
+	userRepositories "scs-operator/internal/app/user/repository"
+	"scs-operator/internal/testsupport"
+	database "scs-operator/pkg/db"
+	"scs-operator/pkg/stream"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NewService wires the incident service to the real repositories on db.
+func NewService(t *testing.T, db *gorm.DB) *incidentServices.Service {
+	t.Helper()
+	return incidentServices.NewIncidentService(
+		*incidentRepositories.NewIncidentRepository(db),
+		*incidentRepositories.NewIncidentGuidanceRepository(db),
+		*userRepositories.NewUserRepository(db),
+		*guidanceTemplateRepository.NewGuidanceTemplateRepository(db),
+		*incidentRepositories.NewIncidentGuidanceStepRepository(db),
+		*incidentRepositories.NewIncidentGuidanceAssignmentRepository(db),
+		*incidentRepositories.NewIncidentActivityRepository(db),
+		*incidentRepositories.NewIncidentMediaRepository(db),
+		*alarmRepositories.NewAlarmRepository(db),
+		*premiseRepositories.NewPremiseRepository(db),
+		*qualificationRepositories.NewQualificationRepository(db),
+		*guardRepositories.NewGuardRepository(db),
+		*database.NewTransactor(db),
+		testsupport.NewProducer(t),
+		*stream.NewBroker(10),
+		t.TempDir(),
+		1<<20,
+		200,
+		incidentServices.WeightedAssigneeStrategy{OpenIncidentCost: 10, OffDutyCost: 25, DistanceCost: 1, UnknownDistance: 20},
+		15*time.Minute,
+	)
+}
@@ -0,0 +1,21 @@
+package testsupport
+
+import (
+	stdErrors "errors"
+	kafka_client "scs-operator/pkg/kafka"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrInjected is the failure the tests make the database return.
+var ErrInjected = stdErrors.New("injected failure")
+
+// NewProducer returns a producer on an unreachable broker. Async writes return
+// immediately, notifications are not under test.
+func NewProducer(t *testing.T) kafka_client.Producer {
+	t.Helper()
+	writer := &kafka.Writer{Addr: kafka.TCP("127.0.0.1:0"), Async: true}
+	t.Cleanup(func() { _ = writer.Close() })
+	return kafka_client.Producer{Writer: writer}
+}
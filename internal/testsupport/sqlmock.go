@@ -0,0 +1,61 @@
+// Package testsupport holds the helpers shared by the service tests, which run
+// the real repositories against a sqlmock database.
+package testsupport
+
+import (
+	"regexp"
+	"scs-operator/pkg/errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NewMockDB opens a gorm connection on sqlmock. Expectations left unmet when
+// the test ends fail it.
+func NewMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open gorm: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet database expectations: %v", err)
+		}
+	})
+	return db, mock
+}
+
+// QuoteSQL escapes sql for the regexp matching of sqlmock expectations.
+func QuoteSQL(sql string) string {
+	return regexp.QuoteMeta(sql)
+}
+
+// ExpectExec expects sql to run, affecting one row, or to fail with err.
+func ExpectExec(mock sqlmock.Sqlmock, sql string, err error) {
+	expectation := mock.ExpectExec(QuoteSQL(sql))
+	if err != nil {
+		expectation.WillReturnError(err)
+		return
+	}
+	expectation.WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// AssertAppError fails the test unless err is an AppError with the status.
+func AssertAppError(t *testing.T, err error, status int) {
+	t.Helper()
+	appErr, ok := errors.IsAppError(err)
+	if !ok {
+		t.Fatalf("expected AppError, got %v", err)
+	}
+	if appErr.StatusCode != status {
+		t.Errorf("expected status %d, got %d (%v)", status, appErr.StatusCode, err)
+	}
+}
@@ -0,0 +1,24 @@
+package types
+
+// BulkItemResult is the outcome of a bulk action for a single entity.
+type BulkItemResult struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkResponse summarises a bulk action.
+type BulkResponse struct {
+	Action    string           `json:"action"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// AlarmBatchEvent is published once per bulk alarm action.
+type AlarmBatchEvent struct {
+	Action     string   `json:"action"`
+	AlarmIDs   []string `json:"alarm_ids"`
+	ActorID    string   `json:"actor_id,omitempty"`
+	IncidentID string   `json:"incident_id,omitempty"`
+}
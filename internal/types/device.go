@@ -0,0 +1,13 @@
+package types
+
+import "scs-operator/internal/models"
+
+// DeviceDetail is a device together with its alarm history. The false alarm
+// rate is the share of the device's alarms that operators ignored.
+type DeviceDetail struct {
+	models.Device
+	AlarmCount      int64          `json:"alarm_count"`
+	FalseAlarmCount int64          `json:"false_alarm_count"`
+	FalseAlarmRate  float64        `json:"false_alarm_rate"`
+	Alarms          []models.Alarm `json:"alarms"`
+}
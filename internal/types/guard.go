@@ -0,0 +1,57 @@
+package types
+
+import (
+	"scs-operator/pkg/geo"
+	"time"
+)
+
+// GuardLocation is a guard with their last reported position. Stale positions
+// are older than GUARD_POSITION_MAX_AGE.
+type GuardLocation struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Position   geo.Point `json:"position"`
+	Accuracy   *float64  `json:"accuracy,omitempty"`
+	ReportedAt time.Time `json:"reported_at"`
+	Stale      bool      `json:"stale"`
+}
+
+// NearestGuard is an available guard and their distance in meters.
+type NearestGuard struct {
+	GuardLocation
+	Distance float64 `json:"distance"`
+}
+
+// GuardTrack is the route a guard reported between From and To, oldest
+// position first. Truncated is set when the route had more positions than
+// were returned.
+type GuardTrack struct {
+	GuardID   string       `json:"guard_id"`
+	From      time.Time    `json:"from"`
+	To        time.Time    `json:"to"`
+	Points    []TrackPoint `json:"points"`
+	Truncated bool         `json:"truncated"`
+}
+
+// TrackPoint is a position of a guard track.
+type TrackPoint struct {
+	Position   geo.Point `json:"position"`
+	Accuracy   *float64  `json:"accuracy,omitempty"`
+	ReportedAt time.Time `json:"reported_at"`
+}
+
+// LoneWorkerEvent is published on Kafka when a lone-worker check-in is missed
+// or a guard pressed the panic button. RecipientID is the user to notify: the
+// guard for a check-in warning, a supervisor otherwise.
+type LoneWorkerEvent struct {
+	RecipientID string     `json:"recipient_id"`
+	GuardID     string     `json:"guard_id"`
+	GuardName   string     `json:"guard_name"`
+	PremiseID   string     `json:"premise_id,omitempty"`
+	ShiftID     string     `json:"shift_id,omitempty"`
+	AlarmID     string     `json:"alarm_id,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Position    *geo.Point `json:"position,omitempty"`
+	Message     string     `json:"message,omitempty"`
+}
@@ -0,0 +1,39 @@
+package types
+
+import (
+	"scs-operator/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// GuidanceTemplateDiff is what changed between two versions of a guidance
+// template, From and To being version numbers or "draft". Steps are matched by
+// ID, so a step that was moved shows as changed rather than removed and added.
+type GuidanceTemplateDiff struct {
+	GuidanceTemplateID string                `json:"guidance_template_id"`
+	From               string                `json:"from"`
+	To                 string                `json:"to"`
+	Fields             []FieldChange         `json:"fields"`
+	AddedSteps         []models.GuidanceStep `json:"added_steps"`
+	RemovedSteps       []models.GuidanceStep `json:"removed_steps"`
+	ChangedSteps       []GuidanceStepChange  `json:"changed_steps"`
+}
+
+// Empty reports whether both sides are the same.
+func (d *GuidanceTemplateDiff) Empty() bool {
+	return len(d.Fields) == 0 && len(d.AddedSteps) == 0 && len(d.RemovedSteps) == 0 && len(d.ChangedSteps) == 0
+}
+
+// FieldChange is a field whose value differs between two versions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// GuidanceStepChange is a step present on both sides with different fields.
+type GuidanceStepChange struct {
+	StepID uuid.UUID     `json:"step_id"`
+	Title  string        `json:"title"`
+	Fields []FieldChange `json:"fields"`
+}
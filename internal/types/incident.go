@@ -0,0 +1,61 @@
+package types
+
+import "scs-operator/internal/models"
+
+// GuidanceHistory lists every guidance of an incident, archived ones included,
+// and the assignments that led to them, oldest first.
+type GuidanceHistory struct {
+	Guidances   []models.IncidentGuidance           `json:"guidances"`
+	Assignments []models.IncidentGuidanceAssignment `json:"assignments"`
+}
+
+// IncidentMentionEvent notifies a user they were mentioned in an incident comment.
+type IncidentMentionEvent struct {
+	IncidentID      string `json:"incident_id"`
+	ActivityID      string `json:"activity_id"`
+	MentionedUserID string `json:"mentioned_user_id"`
+	ActorID         string `json:"actor_id,omitempty"`
+	Message         string `json:"message"`
+}
+
+// IncidentSearchResponse is a page of incident search results. Facets count
+// every match, ignoring the facet's own filter.
+type IncidentSearchResponse struct {
+	Data       []IncidentSearchHit     `json:"data"`
+	Pagination Pagination              `json:"pagination"`
+	Total      int64                   `json:"total"`
+	Facets     map[string][]FacetCount `json:"facets"`
+}
+
+// IncidentSearchHit is a matching incident. Highlights holds snippets of the
+// matching fields with the matches wrapped in <mark>.
+type IncidentSearchHit struct {
+	Incident   models.Incident   `json:"incident"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// FacetCount is the number of results sharing a facet value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// IncidentFeatureProperties are the properties of an incident on the map.
+type IncidentFeatureProperties struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	Severity  string   `json:"severity"`
+	PremiseID string   `json:"premise_id,omitempty"`
+	Distance  *float64 `json:"distance,omitempty"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// EligibleAssignees are the guards qualified for a guidance template.
+type EligibleAssignees struct {
+	GuidanceTemplateID     string                 `json:"guidance_template_id"`
+	RequiredQualifications []models.Qualification `json:"required_qualifications"`
+	Assignees              []models.User          `json:"assignees"`
+}
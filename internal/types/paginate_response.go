@@ -1,11 +1,34 @@
 package types
 
+import "scs-operator/pkg/query"
+
 type PaginateResponse[T any] struct {
 	Data       []T `json:"data"`
 	Pagination `json:"pagination"`
 }
 type Pagination struct {
-	TotalPages int `json:"total_pages"`
-	Page  int `json:"page"`
+	// TotalPages and Total are only set when the total was counted
+	TotalPages int    `json:"total_pages,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+	// Page is omitted when paginating by cursor
+	Page  int `json:"page,omitempty"`
 	Limit int `json:"limit"`
+	// NextCursor fetches the following page, it is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// NewPaginateResponse wraps a page read with the query package.
+func NewPaginateResponse[T any](data []T, page query.PageInfo) *PaginateResponse[T] {
+	pagination := Pagination{
+		Total:      page.Total,
+		Page:       page.Page,
+		Limit:      page.Limit,
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+	if page.Total != nil && page.Limit > 0 {
+		pagination.TotalPages = int((*page.Total + int64(page.Limit) - 1) / int64(page.Limit))
+	}
+	return &PaginateResponse[T]{Data: data, Pagination: pagination}
 }
@@ -14,8 +14,17 @@ type IncidentListResponse struct {
 	Pagination Pagination        `json:"pagination"`
 }
 
-// AlarmListResponse represents a response for alarms list
-type AlarmListResponse []models.Alarm
+// IncidentActivityListResponse represents a paginated response for an incident activity feed
+type IncidentActivityListResponse struct {
+	Data       []models.IncidentActivity `json:"data"`
+	Pagination Pagination                `json:"pagination"`
+}
+
+// AlarmListResponse represents a paginated response for alarms
+type AlarmListResponse struct {
+	Data       []models.Alarm `json:"data"`
+	Pagination Pagination     `json:"pagination"`
+}
 
 // GuidanceTemplateListResponse represents a response for guidance templates list
 type GuidanceTemplateListResponse []models.GuidanceTemplate
@@ -25,3 +34,15 @@ type GuidanceStepListResponse []models.GuidanceStep
 
 // UserListResponse represents a response for users list
 type UserListResponse []models.User
+
+// GuardListResponse represents a paginated response for guards
+type GuardListResponse struct {
+	Data       []models.User `json:"data"`
+	Pagination Pagination    `json:"pagination"`
+}
+
+// DeviceListResponse represents a paginated response for devices
+type DeviceListResponse struct {
+	Data       []models.Device `json:"data"`
+	Pagination Pagination      `json:"pagination"`
+}
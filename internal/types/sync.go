@@ -0,0 +1,43 @@
+package types
+
+import (
+	"scs-operator/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SyncChanges is what changed for a guard since their last sync. Entities are
+// returned whole, devices replace their copies. Pass SyncToken to the next
+// sync; a full sync replaces everything the device holds.
+type SyncChanges struct {
+	SyncToken string            `json:"sync_token"`
+	Full      bool              `json:"full"`
+	Incidents []models.Incident `json:"incidents"`
+	// UnassignedIncidentIDs were handed to someone else since and should be dropped
+	UnassignedIncidentIDs []uuid.UUID                      `json:"unassigned_incident_ids"`
+	GuidanceSteps         []models.IncidentGuidanceStep    `json:"guidance_steps"`
+	GuidanceTemplates     []models.GuidanceTemplateVersion `json:"guidance_templates"`
+	Premises              []models.Premise                 `json:"premises"`
+}
+
+// SyncOperationResult is the outcome of an operation made offline. Status is
+// applied, conflict or rejected, which are final, or failed, which may be
+// retried. Data holds the entity an applied operation created or changed.
+type SyncOperationResult struct {
+	ID     string       `json:"id"`
+	Type   string       `json:"type"`
+	Status string       `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Data   models.JSONB `json:"data,omitempty"`
+	// Replayed is set when the operation was uploaded before
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+// SyncUploadResponse holds one result per uploaded operation, in order.
+type SyncUploadResponse struct {
+	Applied   int                   `json:"applied"`
+	Conflicts int                   `json:"conflicts"`
+	Rejected  int                   `json:"rejected"`
+	Failed    int                   `json:"failed"`
+	Results   []SyncOperationResult `json:"results"`
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// txState is the transaction bound to a context and the work waiting for it
+// to commit.
+type txState struct {
+	tx          *gorm.DB
+	afterCommit []func()
+}
+
+// Transactor runs units of work in a database transaction. The transaction is
+// carried in the context so every repository resolving its connection through
+// Conn joins it without changing method signatures.
+type Transactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+// WithinTransaction commits when fn returns nil and rolls back otherwise.
+// Nested calls join the outer transaction. Work registered with AfterCommit
+// runs once the outer transaction has committed and is dropped on rollback.
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx)
+	}
+	state := &txState{}
+	err := t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		state.tx = tx
+		return fn(context.WithValue(ctx, txKey{}, state))
+	})
+	if err != nil {
+		return err
+	}
+	for _, run := range state.afterCommit {
+		run()
+	}
+	return nil
+}
+
+// AfterCommit defers fn until the transaction bound to ctx has committed, so
+// nothing is announced that a rollback takes back. Without a transaction fn
+// runs immediately.
+func AfterCommit(ctx context.Context, fn func()) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+// Conn returns the transaction bound to ctx, or db when there is none.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	stdErrors "errors"
+	"scs-operator/internal/testsupport"
+	"testing"
+)
+
+func TestAfterCommit(t *testing.T) {
+	t.Run("Work runs once the outer transaction commits", func(t *testing.T) {
+		db, mock := testsupport.NewMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+		transactor := NewTransactor(db)
+		var ran []string
+		err := transactor.WithinTransaction(context.Background(), func(ctx context.Context) error {
+			AfterCommit(ctx, func() { ran = append(ran, "outer") })
+			err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+				AfterCommit(ctx, func() { ran = append(ran, "nested") })
+				return nil
+			})
+			if len(ran) != 0 {
+				t.Errorf("expected nothing to run before the commit, got %v", ran)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(ran) != 2 || ran[0] != "outer" || ran[1] != "nested" {
+			t.Errorf("expected outer and nested work in order, got %v", ran)
+		}
+	})
+
+	t.Run("Work is dropped on rollback", func(t *testing.T) {
+		db, mock := testsupport.NewMockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		ran := false
+		err := NewTransactor(db).WithinTransaction(context.Background(), func(ctx context.Context) error {
+			AfterCommit(ctx, func() { ran = true })
+			return testsupport.ErrInjected
+		})
+		if !stdErrors.Is(err, testsupport.ErrInjected) {
+			t.Fatalf("expected the injected error, got %v", err)
+		}
+		if ran {
+			t.Error("expected no work to run after a rollback")
+		}
+	})
+
+	t.Run("Work runs at once without a transaction", func(t *testing.T) {
+		ran := false
+		AfterCommit(context.Background(), func() { ran = true })
+		if !ran {
+			t.Error("expected the work to run immediately")
+		}
+	})
+}
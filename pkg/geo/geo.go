@@ -0,0 +1,204 @@
+// Package geo handles WGS84 positions: GeoJSON encoding, bounding boxes and
+// great-circle distances, both in Go and as SQL over latitude/longitude columns.
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EarthRadius is the mean earth radius in meters.
+const EarthRadius = 6371000.0
+
+// Point is a WGS84 position. It is read and written as a GeoJSON Point.
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// NewPoint returns the point of the coordinates, or nil when both are missing.
+func NewPoint(latitude *float64, longitude *float64) (*Point, error) {
+	if latitude == nil && longitude == nil {
+		return nil, nil
+	}
+	if latitude == nil || longitude == nil {
+		return nil, fmt.Errorf("latitude and longitude must be given together")
+	}
+	point := &Point{Latitude: *latitude, Longitude: *longitude}
+	if err := point.Validate(); err != nil {
+		return nil, err
+	}
+	return point, nil
+}
+
+// Resolve returns the position given either as latitude and longitude or as a
+// GeoJSON geometry, or nil when neither is given.
+func Resolve(latitude *float64, longitude *float64, geometry *Point) (*Point, error) {
+	if geometry != nil {
+		if latitude != nil || longitude != nil {
+			return nil, fmt.Errorf("give either latitude and longitude or a geometry, not both")
+		}
+		return geometry, nil
+	}
+	return NewPoint(latitude, longitude)
+}
+
+// Coordinates returns the latitude and longitude columns of an optional point.
+func (p *Point) Coordinates() (*float64, *float64) {
+	if p == nil {
+		return nil, nil
+	}
+	latitude, longitude := p.Latitude, p.Longitude
+	return &latitude, &longitude
+}
+
+func (p Point) Validate() error {
+	if math.IsNaN(p.Latitude) || p.Latitude < -90 || p.Latitude > 90 {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if math.IsNaN(p.Longitude) || p.Longitude < -180 || p.Longitude > 180 {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+type geoJSONPoint struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// MarshalJSON writes the point as GeoJSON, longitude first.
+func (p Point) MarshalJSON() ([]byte, error) {
+	return json.Marshal(geoJSONPoint{Type: "Point", Coordinates: []float64{p.Longitude, p.Latitude}})
+}
+
+// UnmarshalJSON reads a GeoJSON Point. An altitude is accepted and ignored.
+func (p *Point) UnmarshalJSON(data []byte) error {
+	var point geoJSONPoint
+	if err := json.Unmarshal(data, &point); err != nil {
+		return fmt.Errorf("invalid GeoJSON point: %w", err)
+	}
+	if point.Type != "Point" {
+		return fmt.Errorf("unsupported GeoJSON geometry %q, expected Point", point.Type)
+	}
+	if len(point.Coordinates) < 2 || len(point.Coordinates) > 3 {
+		return fmt.Errorf("GeoJSON point needs [longitude, latitude] coordinates")
+	}
+	parsed := Point{Latitude: point.Coordinates[1], Longitude: point.Coordinates[0]}
+	if err := parsed.Validate(); err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// Distance returns the great-circle distance between two points in meters.
+func Distance(a Point, b Point) float64 {
+	dLat := radians(b.Latitude - a.Latitude)
+	dLng := radians(b.Longitude - a.Longitude)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(radians(a.Latitude))*math.Cos(radians(b.Latitude))*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// DistanceSQL is the SQL counterpart of Distance between the columns and a
+// point. Its placeholders take the point's latitude, latitude and longitude.
+func DistanceSQL(latitudeColumn string, longitudeColumn string) string {
+	return fmt.Sprintf("(2 * %.0[3]f * asin(least(1, sqrt("+
+		"power(sin(radians(%[1]s - ?) / 2), 2) + "+
+		"cos(radians(?)) * cos(radians(%[1]s)) * power(sin(radians(%[2]s - ?) / 2), 2)))))",
+		latitudeColumn, longitudeColumn, EarthRadius)
+}
+
+// DistanceArgs are the placeholder values of DistanceSQL.
+func (p Point) DistanceArgs() []interface{} {
+	return []interface{}{p.Latitude, p.Latitude, p.Longitude}
+}
+
+// BoundingBox is an area between two latitudes and two longitudes. Boxes
+// crossing the antimeridian are not supported.
+type BoundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+// ParseBoundingBox parses a GeoJSON style "minLng,minLat,maxLng,maxLat" box.
+func ParseBoundingBox(value string) (BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return BoundingBox{}, fmt.Errorf("bbox must be min_longitude,min_latitude,max_longitude,max_latitude")
+	}
+	var numbers [4]float64
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return BoundingBox{}, fmt.Errorf("bbox must be min_longitude,min_latitude,max_longitude,max_latitude")
+		}
+		numbers[i] = number
+	}
+	box := BoundingBox{MinLongitude: numbers[0], MinLatitude: numbers[1], MaxLongitude: numbers[2], MaxLatitude: numbers[3]}
+	for _, corner := range []Point{{box.MinLatitude, box.MinLongitude}, {box.MaxLatitude, box.MaxLongitude}} {
+		if err := corner.Validate(); err != nil {
+			return BoundingBox{}, err
+		}
+	}
+	if box.MinLatitude > box.MaxLatitude || box.MinLongitude > box.MaxLongitude {
+		return BoundingBox{}, fmt.Errorf("bbox minimum must not exceed its maximum")
+	}
+	return box, nil
+}
+
+// Around returns a box containing every point within radius meters of
+// center, used to narrow down a distance query with an index. A radius
+// reaching across the antimeridian spans every longitude, since a box cannot
+// wrap around it.
+func Around(center Point, radius float64) BoundingBox {
+	dLat := radius / EarthRadius * 180 / math.Pi
+	box := BoundingBox{
+		MinLatitude:  math.Max(-90, center.Latitude-dLat),
+		MaxLatitude:  math.Min(90, center.Latitude+dLat),
+		MinLongitude: -180,
+		MaxLongitude: 180,
+	}
+	// Near the poles every longitude may be within reach
+	if cos := math.Cos(radians(center.Latitude)); box.MinLatitude > -90 && box.MaxLatitude < 90 && cos > 0 {
+		dLng := dLat / cos
+		if center.Longitude-dLng >= -180 && center.Longitude+dLng <= 180 {
+			box.MinLongitude = center.Longitude - dLng
+			box.MaxLongitude = center.Longitude + dLng
+		}
+	}
+	return box
+}
+
+// Feature is a GeoJSON feature with a point geometry.
+type Feature struct {
+	Type       string      `json:"type"`
+	Geometry   Point       `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON feature collection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+func NewFeature(point Point, properties interface{}) Feature {
+	return Feature{Type: "Feature", Geometry: point, Properties: properties}
+}
+
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
@@ -0,0 +1,114 @@
+package geo
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestPointGeoJSON(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      Point
+		expectedError bool
+	}{
+		{name: "Longitude comes first", input: `{"type":"Point","coordinates":[4.9041,52.3676]}`, expected: Point{Latitude: 52.3676, Longitude: 4.9041}},
+		{name: "Altitude is ignored", input: `{"type":"Point","coordinates":[4.9041,52.3676,12]}`, expected: Point{Latitude: 52.3676, Longitude: 4.9041}},
+		{name: "Other geometry", input: `{"type":"LineString","coordinates":[[0,0],[1,1]]}`, expectedError: true},
+		{name: "Missing coordinate", input: `{"type":"Point","coordinates":[4.9041]}`, expectedError: true},
+		{name: "Latitude out of range", input: `{"type":"Point","coordinates":[4.9041,91]}`, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var point Point
+			err := json.Unmarshal([]byte(tt.input), &point)
+			if tt.expectedError {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", point)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if point != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, point)
+			}
+			encoded, _ := json.Marshal(point)
+			if string(encoded) != `{"type":"Point","coordinates":[4.9041,52.3676]}` {
+				t.Errorf("unexpected GeoJSON %s", encoded)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	amsterdam := Point{Latitude: 52.3676, Longitude: 4.9041}
+	rotterdam := Point{Latitude: 51.9244, Longitude: 4.4777}
+	// Roughly 57 km apart
+	if distance := Distance(amsterdam, rotterdam); math.Abs(distance-57_400) > 500 {
+		t.Errorf("expected about 57.4 km, got %.0f m", distance)
+	}
+	if distance := Distance(amsterdam, amsterdam); distance != 0 {
+		t.Errorf("expected 0, got %f", distance)
+	}
+}
+
+func TestAroundContainsRadius(t *testing.T) {
+	center := Point{Latitude: 52.3676, Longitude: 4.9041}
+	box := Around(center, 10_000)
+	for _, bearing := range []Point{
+		{Latitude: box.MaxLatitude, Longitude: center.Longitude},
+		{Latitude: center.Latitude, Longitude: box.MaxLongitude},
+	} {
+		if distance := Distance(center, bearing); distance < 9_999 {
+			t.Errorf("box edge %+v is only %.0f m away", bearing, distance)
+		}
+	}
+	if polar := Around(Point{Latitude: 89.99, Longitude: 0}, 10_000); polar.MinLongitude != -180 || polar.MaxLongitude != 180 {
+		t.Errorf("expected every longitude near the pole, got %+v", polar)
+	}
+	// Points on either side of the antimeridian are close together
+	east, west := Point{Latitude: -16.8, Longitude: 179.9}, Point{Latitude: -16.8, Longitude: -179.95}
+	box = Around(east, 20_000)
+	if Distance(east, west) > 20_000 || west.Longitude < box.MinLongitude || west.Longitude > box.MaxLongitude {
+		t.Errorf("expected %+v across the antimeridian within %+v", west, box)
+	}
+}
+
+func TestParseBoundingBox(t *testing.T) {
+	box, err := ParseBoundingBox("4.7,52.2,5.1,52.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if box.MinLongitude != 4.7 || box.MinLatitude != 52.2 || box.MaxLongitude != 5.1 || box.MaxLatitude != 52.5 {
+		t.Errorf("unexpected box %+v", box)
+	}
+	for _, invalid := range []string{"4.7,52.2,5.1", "5.1,52.2,4.7,52.5", "a,b,c,d", "4.7,-95,5.1,52.5"} {
+		if _, err := ParseBoundingBox(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	latitude, longitude := 52.3676, 4.9041
+	geometry := &Point{Latitude: 51.9244, Longitude: 4.4777}
+	if point, err := Resolve(nil, nil, nil); point != nil || err != nil {
+		t.Errorf("expected no position, got %+v, %v", point, err)
+	}
+	if point, err := Resolve(&latitude, &longitude, nil); err != nil || *point != (Point{Latitude: latitude, Longitude: longitude}) {
+		t.Errorf("expected the coordinates, got %+v, %v", point, err)
+	}
+	if point, err := Resolve(nil, nil, geometry); err != nil || point != geometry {
+		t.Errorf("expected the geometry, got %+v, %v", point, err)
+	}
+	if _, err := Resolve(&latitude, nil, nil); err == nil {
+		t.Error("expected a lone latitude to be rejected")
+	}
+	if _, err := Resolve(&latitude, &longitude, geometry); err == nil {
+		t.Error("expected coordinates and a geometry to be rejected")
+	}
+}
@@ -0,0 +1,20 @@
+package geo
+
+import "gorm.io/gorm"
+
+// Within keeps the rows whose position lies in the box.
+func Within(latitudeColumn string, longitudeColumn string, box BoundingBox) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(latitudeColumn+" BETWEEN ? AND ?", box.MinLatitude, box.MaxLatitude).
+			Where(longitudeColumn+" BETWEEN ? AND ?", box.MinLongitude, box.MaxLongitude)
+	}
+}
+
+// Near keeps the rows whose position is within radius meters of center. The
+// surrounding box is checked first so an index on the columns can be used.
+func Near(latitudeColumn string, longitudeColumn string, center Point, radius float64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = Within(latitudeColumn, longitudeColumn, Around(center, radius))(db)
+		return db.Where(DistanceSQL(latitudeColumn, longitudeColumn)+" <= ?", append(center.DistanceArgs(), radius)...)
+	}
+}
@@ -0,0 +1,123 @@
+package mqtt_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// subscribeTimeout is how long the broker has to answer a subscription
+	subscribeTimeout = 10 * time.Second
+	// subscriptionRefused is the SUBACK return code of a refused topic filter
+	subscriptionRefused = 0x80
+)
+
+// MessageHandler is called once for every message received on a subscribed
+// topic. A message is only acknowledged when the handler returns nil, so a
+// failed message is redelivered by the broker when the session resumes.
+type MessageHandler func(topic string, payload []byte) error
+
+type Subscriber struct {
+	Client mqtt.Client
+}
+
+// NewSubscriber creates a subscriber that (re)subscribes to all configured
+// topics every time the connection is established. Lost connections are retried
+// with an exponential backoff capped at MaxReconnectInterval.
+func NewSubscriber(cfg *Config, sCfg *SubscriberConfig, handler MessageHandler) *Subscriber {
+	opts := mqtt.NewClientOptions()
+	for _, broker := range cfg.Brokers {
+		opts.AddBroker(broker)
+	}
+	opts.SetClientID(cfg.ClientID)
+	opts.SetUsername(cfg.Username)
+	opts.SetPassword(cfg.Password)
+	opts.SetCleanSession(sCfg.CleanSession)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(true)
+	opts.SetConnectRetryInterval(sCfg.ConnectRetryInterval)
+	opts.SetMaxReconnectInterval(sCfg.MaxReconnectInterval)
+	// Acknowledge QoS 1 messages only after the handler has finished so that a
+	// crash while processing leads to redelivery instead of a lost alarm.
+	opts.SetAutoAckDisabled(true)
+
+	filters := make(map[string]byte, len(sCfg.Topics))
+	for _, topic := range sCfg.Topics {
+		filters[topic] = sCfg.QoS
+	}
+	callback := func(_ mqtt.Client, msg mqtt.Message) {
+		if err := handler(msg.Topic(), msg.Payload()); err != nil {
+			return
+		}
+		msg.Ack()
+	}
+	// Without its subscriptions the connection receives nothing, so they are
+	// retried with the reconnect backoff for as long as the connection is open.
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		delay := sCfg.ConnectRetryInterval
+		for client.IsConnectionOpen() {
+			err := subscribe(client, filters, callback)
+			if err == nil {
+				return
+			}
+			if sCfg.OnSubscribeError != nil {
+				sCfg.OnSubscribeError(err)
+			}
+			time.Sleep(delay)
+			delay = min(2*delay, sCfg.MaxReconnectInterval)
+		}
+	})
+
+	return &Subscriber{Client: mqtt.NewClient(opts)}
+}
+
+// subscribe subscribes to all filters, failing when the broker does not
+// answer in time or refuses any of them.
+func subscribe(client mqtt.Client, filters map[string]byte, callback mqtt.MessageHandler) error {
+	token := client.SubscribeMultiple(filters, callback)
+	if !token.WaitTimeout(subscribeTimeout) {
+		return errors.New("subscribing timed out")
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("subscribing failed: %w", err)
+	}
+	subscribeToken, ok := token.(*mqtt.SubscribeToken)
+	if !ok {
+		return nil
+	}
+	var refused []string
+	for topic, code := range subscribeToken.Result() {
+		if code == subscriptionRefused {
+			refused = append(refused, topic)
+		}
+	}
+	if len(refused) > 0 {
+		sort.Strings(refused)
+		return fmt.Errorf("subscription to %v refused", refused)
+	}
+	return nil
+}
+
+// Run connects to the broker and blocks until ctx is canceled.
+func (s *Subscriber) Run(ctx context.Context) error {
+	token := s.Client.Connect()
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return err
+		}
+	case <-ctx.Done():
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// Close disconnects from the broker, waiting briefly for in-flight work.
+func (s *Subscriber) Close() {
+	s.Client.Disconnect(250)
+}
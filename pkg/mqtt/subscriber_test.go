@@ -0,0 +1,241 @@
+package mqtt_client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// refusingHook allows every connection, but refuses subscriptions until
+// allowed.
+type refusingHook struct {
+	auth.AllowHook
+	allowed atomic.Bool
+}
+
+func (h *refusingHook) ID() string {
+	return "refusing-auth"
+}
+
+func (h *refusingHook) OnACLCheck(_ *mochi.Client, _ string, write bool) bool {
+	return write || h.allowed.Load()
+}
+
+func (h *refusingHook) OnConnectAuthenticate(_ *mochi.Client, _ packets.Packet) bool {
+	return true
+}
+
+// startBroker starts an embedded MQTT broker on a random local port, allowing
+// what authHook allows.
+func startBroker(t *testing.T, authHook mochi.Hook) (*mochi.Server, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := mochi.New(&mochi.Options{InlineClient: true})
+	if err := server.AddHook(authHook, nil); err != nil {
+		t.Fatalf("Failed to add auth hook: %v", err)
+	}
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "t1", Address: address})); err != nil {
+		t.Fatalf("Failed to add listener: %v", err)
+	}
+	go func() {
+		_ = server.Serve()
+	}()
+	t.Cleanup(func() { server.Close() })
+	return server, "tcp://" + address
+}
+
+func TestSubscriberReceivesMessages(t *testing.T) {
+	broker, address := startBroker(t, new(auth.AllowHook))
+
+	type received struct {
+		topic   string
+		payload string
+	}
+	messages := make(chan received, 1)
+	subscriber := NewSubscriber(&Config{
+		Brokers:  []string{address},
+		ClientID: "scs-operator-test",
+	}, &SubscriberConfig{
+		Topics:               []string{"sensors/+/+/alarm"},
+		QoS:                  1,
+		ConnectRetryInterval: 100 * time.Millisecond,
+		MaxReconnectInterval: time.Second,
+	}, func(topic string, payload []byte) error {
+		select {
+		case messages <- received{topic: topic, payload: string(payload)}:
+		default:
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = subscriber.Run(ctx)
+	}()
+	defer subscriber.Close()
+
+	// Publish until the subscription is active; the broker drops messages
+	// published before the subscriber connects.
+	deadline := time.After(5 * time.Second)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-messages:
+			if msg.topic != "sensors/p-1/door-3/alarm" {
+				t.Fatalf("Unexpected topic %s", msg.topic)
+			}
+			if msg.payload != `{"type":"intrusion"}` {
+				t.Fatalf("Unexpected payload %s", msg.payload)
+			}
+			return
+		case <-ticker.C:
+			if err := broker.Publish("sensors/p-1/door-3/alarm", []byte(`{"type":"intrusion"}`), false, 1); err != nil {
+				t.Fatalf("Failed to publish: %v", err)
+			}
+		case <-deadline:
+			t.Fatal("Timed out waiting for message")
+		}
+	}
+}
+
+func TestSubscriberRedeliversFailedMessages(t *testing.T) {
+	broker, address := startBroker(t, new(auth.AllowHook))
+
+	cfg := &Config{Brokers: []string{address}, ClientID: "scs-operator-redeliver"}
+	subscriberCfg := &SubscriberConfig{
+		Topics:               []string{"sensors/+/+/alarm"},
+		QoS:                  1,
+		ConnectRetryInterval: 100 * time.Millisecond,
+		MaxReconnectInterval: time.Second,
+	}
+	failed := make(chan struct{}, 1)
+	first := NewSubscriber(cfg, subscriberCfg, func(topic string, payload []byte) error {
+		select {
+		case failed <- struct{}{}:
+		default:
+		}
+		return errors.New("database unavailable")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = first.Run(ctx)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	published := false
+	for !published {
+		select {
+		case <-failed:
+			published = true
+		case <-ticker.C:
+			if err := broker.Publish("sensors/p-1/door-3/alarm", []byte(`{"type":"intrusion"}`), false, 1); err != nil {
+				t.Fatalf("Failed to publish: %v", err)
+			}
+		case <-deadline:
+			t.Fatal("Timed out waiting for message")
+		}
+	}
+	first.Close()
+
+	// The unacknowledged message is resent once the session resumes
+	redelivered := make(chan string, 1)
+	second := NewSubscriber(cfg, subscriberCfg, func(topic string, payload []byte) error {
+		select {
+		case redelivered <- string(payload):
+		default:
+		}
+		return nil
+	})
+	go func() {
+		_ = second.Run(ctx)
+	}()
+	defer second.Close()
+	select {
+	case payload := <-redelivered:
+		if payload != `{"type":"intrusion"}` {
+			t.Fatalf("Unexpected payload %s", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for redelivery")
+	}
+}
+
+func TestSubscriberRetriesRefusedSubscriptions(t *testing.T) {
+	hook := &refusingHook{}
+	broker, address := startBroker(t, hook)
+
+	refused := make(chan error, 1)
+	received := make(chan struct{}, 1)
+	subscriber := NewSubscriber(&Config{
+		Brokers:  []string{address},
+		ClientID: "scs-operator-refused",
+	}, &SubscriberConfig{
+		Topics:               []string{"sensors/+/+/alarm"},
+		QoS:                  1,
+		ConnectRetryInterval: 100 * time.Millisecond,
+		MaxReconnectInterval: time.Second,
+		OnSubscribeError: func(err error) {
+			select {
+			case refused <- err:
+			default:
+			}
+		},
+	}, func(topic string, payload []byte) error {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = subscriber.Run(ctx)
+	}()
+	defer subscriber.Close()
+
+	select {
+	case err := <-refused:
+		if err == nil {
+			t.Fatal("Expected the refusal to be reported")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the refused subscription")
+	}
+	// The next attempt is accepted and messages arrive
+	hook.allowed.Store(true)
+	deadline := time.After(5 * time.Second)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-received:
+			return
+		case <-ticker.C:
+			if err := broker.Publish("sensors/p-1/door-3/alarm", []byte(`{"type":"intrusion"}`), false, 1); err != nil {
+				t.Fatalf("Failed to publish: %v", err)
+			}
+		case <-deadline:
+			t.Fatal("Timed out waiting for message")
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package mqtt_client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TopicPattern is an MQTT topic filter whose segments may be named, e.g.
+// "sensors/{premise_id}/{device}/alarm". Named segments subscribe as "+" and
+// are extracted from matching topics.
+type TopicPattern struct {
+	segments []string
+}
+
+// ParseTopicPattern parses a topic pattern. Besides named segments it accepts
+// the regular "+" and trailing "#" wildcards.
+func ParseTopicPattern(pattern string) (*TopicPattern, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("topic pattern cannot be empty")
+	}
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if segment == "#" && i != len(segments)-1 {
+			return nil, fmt.Errorf("invalid topic pattern %q: '#' must be the last segment", pattern)
+		}
+		if strings.HasPrefix(segment, "{") != strings.HasSuffix(segment, "}") {
+			return nil, fmt.Errorf("invalid topic pattern %q: unbalanced braces in %q", pattern, segment)
+		}
+	}
+	return &TopicPattern{segments: segments}, nil
+}
+
+// ParseTopicPatterns parses a comma separated list of topic patterns.
+func ParseTopicPatterns(patterns string) ([]*TopicPattern, error) {
+	var result []*TopicPattern
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		topicPattern, err := ParseTopicPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, topicPattern)
+	}
+	return result, nil
+}
+
+// Filter returns the MQTT subscription filter for the pattern.
+func (p *TopicPattern) Filter() string {
+	filter := make([]string, len(p.segments))
+	for i, segment := range p.segments {
+		if isNamedSegment(segment) {
+			filter[i] = "+"
+			continue
+		}
+		filter[i] = segment
+	}
+	return strings.Join(filter, "/")
+}
+
+// Match reports whether topic matches the pattern and returns the values of
+// its named segments.
+func (p *TopicPattern) Match(topic string) (map[string]string, bool) {
+	parts := strings.Split(topic, "/")
+	values := map[string]string{}
+	for i, segment := range p.segments {
+		if segment == "#" {
+			return values, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		switch {
+		case isNamedSegment(segment):
+			values[segment[1:len(segment)-1]] = parts[i]
+		case segment == "+":
+		case segment != parts[i]:
+			return nil, false
+		}
+	}
+	if len(parts) != len(p.segments) {
+		return nil, false
+	}
+	return values, true
+}
+
+func isNamedSegment(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
@@ -0,0 +1,77 @@
+package mqtt_client
+
+import "testing"
+
+func TestTopicPatternFilter(t *testing.T) {
+	pattern, err := ParseTopicPattern("sensors/{premise_id}/{device}/alarm")
+	if err != nil {
+		t.Fatalf("Failed to parse pattern: %v", err)
+	}
+	if filter := pattern.Filter(); filter != "sensors/+/+/alarm" {
+		t.Fatalf("Expected filter sensors/+/+/alarm, got %s", filter)
+	}
+}
+
+func TestTopicPatternMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		topic    string
+		match    bool
+		expected map[string]string
+	}{
+		{
+			name:     "Named segments",
+			pattern:  "sensors/{premise_id}/{device}/alarm",
+			topic:    "sensors/p-1/door-3/alarm",
+			match:    true,
+			expected: map[string]string{"premise_id": "p-1", "device": "door-3"},
+		},
+		{
+			name:    "Literal mismatch",
+			pattern: "sensors/{premise_id}/{device}/alarm",
+			topic:   "sensors/p-1/door-3/status",
+			match:   false,
+		},
+		{
+			name:    "Too many segments",
+			pattern: "sensors/{premise_id}/{device}/alarm",
+			topic:   "sensors/p-1/door-3/alarm/extra",
+			match:   false,
+		},
+		{
+			name:     "Multi level wildcard",
+			pattern:  "site/{premise_id}/#",
+			topic:    "site/p-2/floor/1/smoke",
+			match:    true,
+			expected: map[string]string{"premise_id": "p-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, err := ParseTopicPattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("Failed to parse pattern: %v", err)
+			}
+			values, ok := pattern.Match(tt.topic)
+			if ok != tt.match {
+				t.Fatalf("Expected match %v, got %v", tt.match, ok)
+			}
+			for key, value := range tt.expected {
+				if values[key] != value {
+					t.Errorf("Expected %s=%s, got %s", key, value, values[key])
+				}
+			}
+		})
+	}
+}
+
+func TestParseTopicPatternsInvalid(t *testing.T) {
+	if _, err := ParseTopicPatterns("sensors/#/alarm"); err == nil {
+		t.Fatal("Should fail when '#' is not the last segment")
+	}
+	if _, err := ParseTopicPatterns("sensors/{premise_id/alarm"); err == nil {
+		t.Fatal("Should fail on unbalanced braces")
+	}
+}
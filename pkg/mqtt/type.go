@@ -0,0 +1,22 @@
+package mqtt_client
+
+import "time"
+
+type Config struct {
+	Brokers  []string
+	ClientID string
+	Username string
+	Password string
+}
+
+// SubscriberConfig specific configuration for subscribers.
+type SubscriberConfig struct {
+	Topics               []string
+	QoS                  byte
+	CleanSession         bool
+	ConnectRetryInterval time.Duration
+	MaxReconnectInterval time.Duration
+	// OnSubscribeError is called when subscribing after a connect fails,
+	// before it is retried
+	OnSubscribeError func(err error)
+}
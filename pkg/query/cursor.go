@@ -0,0 +1,74 @@
+package query
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// cursor is the opaque position after the last row of a page.
+type cursor struct {
+	// Sort is the sort the cursor was issued for
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+	// Times marks the values to decode as time, JSON has no time type
+	Times []int `json:"t,omitempty"`
+}
+
+func encodeCursor(sort string, values []interface{}) (string, error) {
+	c := cursor{Sort: sort, Values: make([]interface{}, len(values))}
+	for i, value := range values {
+		if t, ok := value.(time.Time); ok {
+			c.Times = append(c.Times, i)
+			value = t.Format(time.RFC3339Nano)
+		}
+		c.Values[i] = value
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(raw string, sort string, size int) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var c cursor
+	if err := decoder.Decode(&c); err != nil || len(c.Values) != size {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("cursor was issued for another sort")
+	}
+	for i, value := range c.Values {
+		if number, ok := value.(json.Number); ok {
+			if n, err := number.Int64(); err == nil {
+				c.Values[i] = n
+			} else if f, err := number.Float64(); err == nil {
+				c.Values[i] = f
+			}
+		}
+	}
+	for _, i := range c.Times {
+		if i < 0 || i >= len(c.Values) {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		value, ok := c.Values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		c.Values[i] = t
+	}
+	return c.Values, nil
+}
@@ -0,0 +1,159 @@
+// Package query turns the list parameters shared by every list endpoint into
+// GORM scopes: sorting, offset or keyset pagination and optional totals.
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+// Request holds the list parameters of a list endpoint. DTOs embed it so it is
+// bound and validated together with their filters.
+type Request struct {
+	Page  int `query:"page" validate:"gte=1"`
+	Limit int `query:"limit" validate:"gte=1,lte=100"`
+	// Sort is a comma separated list of fields, prefixed with "-" for descending order
+	Sort string `query:"sort"`
+	// Cursor is the next_cursor of the previous page, it replaces page
+	Cursor string `query:"cursor"`
+	// IncludeTotal counts the matching rows, by default only without a cursor
+	IncludeTotal *bool `query:"include_total"`
+}
+
+// NewRequest returns a Request for the first page with the default limit.
+func NewRequest() Request {
+	return Request{Page: 1, Limit: DefaultLimit}
+}
+
+// Field is a sortable field of a Schema.
+type Field struct {
+	// Column must be a NOT NULL column of the model, the cursor reads its
+	// value from the last row of a page
+	Column string
+	// Expr sorts by an expression of the column instead, %s stands for the
+	// column or the cursor value
+	Expr string
+}
+
+// Schema lists the fields a list endpoint can be sorted by. Every sort ends
+// with the primary key so pages are stable.
+type Schema struct {
+	Fields map[string]Field
+	// DefaultSort applies when the request has no sort, e.g. "-created_at"
+	DefaultSort string
+}
+
+// Order is a single sort key.
+type Order struct {
+	Field string
+	Desc  bool
+}
+
+// Params are the validated list parameters of a request.
+type Params struct {
+	Page         int
+	Limit        int
+	IncludeTotal bool
+	Orders       []Order
+	schema       Schema
+	// after holds the sort values of the last row of the previous page
+	after []interface{}
+}
+
+// New validates the request against the schema.
+func New(schema Schema, request Request) (Params, error) {
+	params := Params{Page: request.Page, Limit: request.Limit, schema: schema}
+	if params.Page == 0 {
+		params.Page = 1
+	}
+	if params.Limit == 0 {
+		params.Limit = DefaultLimit
+	}
+	if params.Page < 1 {
+		return Params{}, fmt.Errorf("page must be at least 1")
+	}
+	if params.Limit < 1 || params.Limit > MaxLimit {
+		return Params{}, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	}
+
+	sort := request.Sort
+	if strings.TrimSpace(sort) == "" {
+		sort = schema.DefaultSort
+	}
+	orders, err := parseSort(schema, sort)
+	if err != nil {
+		return Params{}, err
+	}
+	params.Orders = orders
+
+	if request.Cursor != "" {
+		if params.Page > 1 {
+			return Params{}, fmt.Errorf("page and cursor cannot be combined")
+		}
+		params.Page = 0
+		params.after, err = decodeCursor(request.Cursor, params.sortKey(), len(orders)+1)
+		if err != nil {
+			return Params{}, err
+		}
+	}
+	params.IncludeTotal = request.Cursor == ""
+	if request.IncludeTotal != nil {
+		params.IncludeTotal = *request.IncludeTotal
+	}
+	return params, nil
+}
+
+// parseSort parses a sort expression such as "-severity,triggered_at".
+func parseSort(schema Schema, sort string) ([]Order, error) {
+	var orders []Order
+	seen := map[string]bool{}
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		order := Order{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+		if _, ok := schema.Fields[order.Field]; !ok {
+			return nil, fmt.Errorf("unsupported sort field %q", order.Field)
+		}
+		if seen[order.Field] {
+			return nil, fmt.Errorf("duplicate sort field %q", order.Field)
+		}
+		seen[order.Field] = true
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// sortKey identifies the sort a cursor was issued for.
+func (p Params) sortKey() string {
+	fields := make([]string, 0, len(p.Orders))
+	for _, order := range p.Orders {
+		if order.Desc {
+			fields = append(fields, "-"+order.Field)
+		} else {
+			fields = append(fields, order.Field)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// keyDesc sorts the primary key in the direction of the last sort field.
+func (p Params) keyDesc() bool {
+	return len(p.Orders) > 0 && p.Orders[len(p.Orders)-1].Desc
+}
+
+// PageInfo describes where a page is in the full list. Total is only set when
+// it was requested.
+type PageInfo struct {
+	Page       int
+	Limit      int
+	HasMore    bool
+	NextCursor string
+	Total      *int64
+}
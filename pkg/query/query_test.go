@@ -0,0 +1,144 @@
+package query
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type ticket struct {
+	ID        uuid.UUID
+	Severity  string
+	CreatedAt time.Time
+}
+
+var ticketSchema = Schema{
+	Fields: map[string]Field{
+		"created_at": {Column: "created_at"},
+		"severity":   {Column: "severity", Expr: "CASE %s WHEN 'high' THEN 2 WHEN 'low' THEN 1 ELSE 0 END"},
+	},
+	DefaultSort: "-created_at",
+}
+
+func newTestDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open gorm: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet database expectations: %v", err)
+		}
+	})
+	return db, mock
+}
+
+func TestNew(t *testing.T) {
+	includeTotal := true
+	tests := []struct {
+		name          string
+		request       Request
+		expectedError bool
+		expectedTotal bool
+	}{
+		{name: "Defaults", request: Request{}, expectedTotal: true},
+		{name: "Zero limit is defaulted", request: Request{Page: 2, Limit: 0}, expectedTotal: true},
+		{name: "Negative limit", request: Request{Page: 1, Limit: -1}, expectedError: true},
+		{name: "Limit above maximum", request: Request{Page: 1, Limit: MaxLimit + 1}, expectedError: true},
+		{name: "Unknown sort field", request: Request{Sort: "name"}, expectedError: true},
+		{name: "Duplicate sort field", request: Request{Sort: "severity,-severity"}, expectedError: true},
+		{name: "Malformed cursor", request: Request{Cursor: "not a cursor"}, expectedError: true},
+		{name: "Page and cursor", request: Request{Page: 2, Cursor: mustCursor(t, "-created_at", time.Now(), uuid.New())}, expectedError: true},
+		{name: "Cursor of another sort", request: Request{Sort: "severity", Cursor: mustCursor(t, "-created_at", time.Now(), uuid.New())}, expectedError: true},
+		{name: "Cursor skips the total", request: Request{Cursor: mustCursor(t, "-created_at", time.Now(), uuid.New())}},
+		{name: "Total on request", request: Request{Cursor: mustCursor(t, "-created_at", time.Now(), uuid.New()), IncludeTotal: &includeTotal}, expectedTotal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := New(ticketSchema, tt.request)
+			if tt.expectedError {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if params.IncludeTotal != tt.expectedTotal {
+				t.Errorf("expected include total %v, got %v", tt.expectedTotal, params.IncludeTotal)
+			}
+		})
+	}
+}
+
+func mustCursor(t *testing.T, sort string, values ...interface{}) string {
+	t.Helper()
+	cursor, err := encodeCursor(sort, values)
+	if err != nil {
+		t.Fatalf("failed to encode cursor: %v", err)
+	}
+	return cursor
+}
+
+func TestFindFollowsCursor(t *testing.T) {
+	db, mock := newTestDB(t)
+	first := time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC)
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	params, err := New(ticketSchema, Request{Page: 1, Limit: 2, Sort: "-severity"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tickets" ORDER BY CASE "tickets"."severity" WHEN 'high' THEN 2 WHEN 'low' THEN 1 ELSE 0 END DESC, "tickets"."id" DESC LIMIT $1`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "severity", "created_at"}).
+			AddRow(ids[0], "high", first).
+			AddRow(ids[1], "low", first).
+			AddRow(ids[2], "low", first))
+	rows, page, err := Find[ticket](db.Model(&ticket{}), params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 2 || !page.HasMore || page.NextCursor == "" {
+		t.Fatalf("expected 2 rows and a next cursor, got %d rows and %+v", len(rows), page)
+	}
+
+	params, err = New(ticketSchema, Request{Limit: 2, Sort: "-severity", Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tickets" WHERE ((CASE "tickets"."severity" WHEN 'high' THEN 2 WHEN 'low' THEN 1 ELSE 0 END < CASE $1 WHEN 'high' THEN 2 WHEN 'low' THEN 1 ELSE 0 END) OR (CASE "tickets"."severity" WHEN 'high' THEN 2 WHEN 'low' THEN 1 ELSE 0 END = CASE $2 WHEN 'high' THEN 2 WHEN 'low' THEN 1 ELSE 0 END AND "tickets"."id" < $3)) ORDER BY`)).
+		WithArgs("low", "low", ids[1].String(), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "severity", "created_at"}).AddRow(ids[2], "low", first))
+	rows, page, err = Find[ticket](db.Model(&ticket{}), params)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 || page.HasMore || page.NextCursor != "" || page.Page != 0 {
+		t.Errorf("expected the last row and no next cursor, got %d rows and %+v", len(rows), page)
+	}
+}
+
+func TestCursorKeepsTimePrecision(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC)
+	values, err := decodeCursor(mustCursor(t, "-created_at", createdAt, "id"), "-created_at", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded, ok := values[0].(time.Time); !ok || !decoded.Equal(createdAt) {
+		t.Errorf("expected %v, got %v", createdAt, values[0])
+	}
+}
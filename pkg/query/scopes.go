@@ -0,0 +1,166 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sortColumn is a resolved sort key, the primary key included.
+type sortColumn struct {
+	column string
+	expr   string
+	desc   bool
+}
+
+func (p Params) sortColumns() []sortColumn {
+	columns := make([]sortColumn, 0, len(p.Orders)+1)
+	for _, order := range p.Orders {
+		field := p.schema.Fields[order.Field]
+		columns = append(columns, sortColumn{column: field.Column, expr: field.Expr, desc: order.Desc})
+	}
+	return append(columns, sortColumn{column: "id", desc: p.keyDesc()})
+}
+
+// sql renders the sort expression around placeholder.
+func (c sortColumn) sql(placeholder string) string {
+	if c.expr == "" {
+		return placeholder
+	}
+	return fmt.Sprintf(c.expr, placeholder)
+}
+
+func (c sortColumn) ref() clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: c.column}
+}
+
+// Paginate sorts the query and selects the page, one row more than the limit
+// to tell whether another page follows. Use Find to read the page.
+func (p Params) Paginate(db *gorm.DB) *gorm.DB {
+	columns := p.sortColumns()
+	if p.after != nil {
+		db = db.Where(p.keyset(columns))
+	} else if p.Page > 1 {
+		db = db.Offset((p.Page - 1) * p.Limit)
+	}
+
+	orders := make([]string, 0, len(columns))
+	vars := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		direction := " ASC"
+		if column.desc {
+			direction = " DESC"
+		}
+		orders = append(orders, column.sql("?")+direction)
+		vars = append(vars, column.ref())
+	}
+	return db.Order(clause.OrderBy{Expression: clause.Expr{SQL: strings.Join(orders, ", "), Vars: vars}}).
+		Limit(p.Limit + 1)
+}
+
+// keyset selects the rows sorted after the cursor: (a > x) OR (a = x AND b > y) ...
+func (p Params) keyset(columns []sortColumn) clause.Expr {
+	var disjuncts []string
+	var vars []interface{}
+	for i, column := range columns {
+		var conjuncts []string
+		for j := 0; j < i; j++ {
+			conjuncts = append(conjuncts, columns[j].sql("?")+" = "+columns[j].sql("?"))
+			vars = append(vars, columns[j].ref(), p.after[j])
+		}
+		operator := " > "
+		if column.desc {
+			operator = " < "
+		}
+		conjuncts = append(conjuncts, column.sql("?")+operator+column.sql("?"))
+		vars = append(vars, column.ref(), p.after[i])
+		disjuncts = append(disjuncts, "("+strings.Join(conjuncts, " AND ")+")")
+	}
+	return clause.Expr{SQL: "(" + strings.Join(disjuncts, " OR ") + ")", Vars: vars}
+}
+
+// Find reads the page selected by params. The next cursor is taken from the
+// last row of the page.
+func Find[T any](db *gorm.DB, params Params) ([]T, PageInfo, error) {
+	var rows []T
+	tx := db.Scopes(params.Paginate).Find(&rows)
+	if tx.Error != nil {
+		return nil, PageInfo{}, tx.Error
+	}
+	info := PageInfo{Page: params.Page, Limit: params.Limit}
+	if len(rows) <= params.Limit {
+		return rows, info, nil
+	}
+	rows = rows[:params.Limit]
+	info.HasMore = true
+
+	last := reflect.ValueOf(&rows[len(rows)-1]).Elem()
+	columns := params.sortColumns()
+	values := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		field := tx.Statement.Schema.LookUpField(column.column)
+		if field == nil {
+			return nil, PageInfo{}, fmt.Errorf("unknown sort column %q", column.column)
+		}
+		value, _ := field.ValueOf(tx.Statement.Context, last)
+		if t, ok := value.(*time.Time); ok && t != nil {
+			value = *t
+		}
+		values = append(values, value)
+	}
+	cursor, err := encodeCursor(params.sortKey(), values)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	info.NextCursor = cursor
+	return rows, info, nil
+}
+
+// Equal keeps the rows whose column equals value. An empty value keeps all rows.
+func Equal(column string, value string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if value == "" {
+			return db
+		}
+		return db.Where(column+" = ?", value)
+	}
+}
+
+// In keeps the rows whose column is one of values. No values keeps all rows.
+func In[T any](column string, values []T) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(values) == 0 {
+			return db
+		}
+		return db.Where(column+" IN ?", values)
+	}
+}
+
+// Between keeps the rows whose column is in [from, to). Either bound may be nil.
+func Between(column string, from *time.Time, to *time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if from != nil {
+			db = db.Where(column+" >= ?", *from)
+		}
+		if to != nil {
+			db = db.Where(column+" < ?", *to)
+		}
+		return db
+	}
+}
+
+// ParseTime parses an RFC3339 filter value, an empty value is no bound.
+func ParseTime(name string, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s format, expected RFC3339", name)
+	}
+	return &t, nil
+}
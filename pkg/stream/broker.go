@@ -0,0 +1,144 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is
+// dropped. Dropped clients reconnect and resume from their last event ID.
+const subscriberBuffer = 256
+
+// Event is a change pushed to streaming clients.
+type Event struct {
+	// ID is "<epoch>-<sequence>", the epoch changes whenever the broker restarts
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	PremiseID string          `json:"premise_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Subscription receives the events matching its filter. Events is closed when
+// the subscriber is dropped for falling behind.
+type Subscription struct {
+	Events <-chan Event
+	// Replay holds the buffered events missed since the last event ID
+	Replay []Event
+	// Reset is set when the last event ID can no longer be resumed from, the
+	// client must reload its state
+	Reset  bool
+	events chan Event
+	match  func(Event) bool
+}
+
+// Broker fans events out to subscribers and keeps the most recent ones so
+// reconnecting clients can resume. Copies share the same state.
+type Broker struct {
+	hub *hub
+}
+
+type hub struct {
+	mu          sync.Mutex
+	epoch       string
+	sequence    uint64
+	buffer      []Event
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+func NewBroker(bufferSize int) *Broker {
+	return &Broker{hub: &hub{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		bufferSize:  bufferSize,
+		subscribers: make(map[*Subscription]struct{}),
+	}}
+}
+
+// Publish sends an event to every matching subscriber. Subscribers too slow to
+// keep up are dropped rather than blocking the publisher.
+func (b Broker) Publish(eventType string, premiseID string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	h := b.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sequence++
+	event := Event{
+		ID:        fmt.Sprintf("%s-%d", h.epoch, h.sequence),
+		Type:      eventType,
+		PremiseID: premiseID,
+		Data:      payload,
+	}
+	h.buffer = append(h.buffer, event)
+	if len(h.buffer) > h.bufferSize {
+		h.buffer = h.buffer[len(h.buffer)-h.bufferSize:]
+	}
+	for sub := range h.subscribers {
+		if !sub.match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the events accepted by match. Buffered
+// events published after lastEventID are returned in Replay.
+func (b Broker) Subscribe(lastEventID string, match func(Event) bool) *Subscription {
+	events := make(chan Event, subscriberBuffer)
+	sub := &Subscription{Events: events, events: events, match: match}
+	h := b.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lastEventID != "" {
+		sub.Replay, sub.Reset = h.since(lastEventID, match)
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops delivering events to the subscriber.
+func (b Broker) Unsubscribe(sub *Subscription) {
+	h := b.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// since returns the buffered events after lastEventID, or reset when that event
+// is from another epoch or no longer buffered.
+func (h *hub) since(lastEventID string, match func(Event) bool) ([]Event, bool) {
+	epoch, rawSequence, ok := strings.Cut(lastEventID, "-")
+	if !ok || epoch != h.epoch {
+		return nil, true
+	}
+	sequence, err := strconv.ParseUint(rawSequence, 10, 64)
+	if err != nil || sequence > h.sequence {
+		return nil, true
+	}
+	// The oldest buffered event has sequence h.sequence-len(h.buffer)+1
+	first := h.sequence - uint64(len(h.buffer)) + 1
+	if sequence+1 < first {
+		return nil, true
+	}
+	var replay []Event
+	for _, event := range h.buffer[sequence+1-first:] {
+		if match(event) {
+			replay = append(replay, event)
+		}
+	}
+	return replay, false
+}
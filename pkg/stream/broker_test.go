@@ -0,0 +1,82 @@
+package stream
+
+import (
+	"testing"
+)
+
+func matchAll(Event) bool { return true }
+
+func TestSubscriberReceivesMatchingEvents(t *testing.T) {
+	broker := NewBroker(10)
+	sub := broker.Subscribe("", func(event Event) bool { return event.PremiseID == "p1" })
+	defer broker.Unsubscribe(sub)
+
+	broker.Publish("alarm.created", "p2", map[string]string{"id": "a1"})
+	broker.Publish("alarm.created", "p1", map[string]string{"id": "a2"})
+
+	event := <-sub.Events
+	if event.PremiseID != "p1" || string(event.Data) != `{"id":"a2"}` {
+		t.Errorf("expected the p1 event, got %+v", event)
+	}
+	select {
+	case event := <-sub.Events:
+		t.Errorf("expected no more events, got %+v", event)
+	default:
+	}
+}
+
+func TestSubscribeResumesFromLastEventID(t *testing.T) {
+	broker := NewBroker(3)
+	first := broker.Subscribe("", matchAll)
+	for i := 0; i < 5; i++ {
+		broker.Publish("incident.updated", "", i)
+	}
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, (<-first.Events).ID)
+	}
+	broker.Unsubscribe(first)
+
+	tests := []struct {
+		name          string
+		lastEventID   string
+		expectedCount int
+		expectedReset bool
+	}{
+		{name: "Caught up client gets nothing", lastEventID: ids[4]},
+		{name: "Buffered events are replayed", lastEventID: ids[2], expectedCount: 2},
+		{name: "Oldest buffered event is still resumable", lastEventID: ids[1], expectedCount: 3},
+		{name: "Evicted event resets", lastEventID: ids[0], expectedReset: true},
+		{name: "Other epoch resets", lastEventID: "0-1", expectedReset: true},
+		{name: "Malformed ID resets", lastEventID: "garbage", expectedReset: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := broker.Subscribe(tt.lastEventID, matchAll)
+			defer broker.Unsubscribe(sub)
+			if sub.Reset != tt.expectedReset {
+				t.Errorf("expected reset %v, got %v", tt.expectedReset, sub.Reset)
+			}
+			if len(sub.Replay) != tt.expectedCount {
+				t.Errorf("expected %d replayed events, got %d", tt.expectedCount, len(sub.Replay))
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	broker := NewBroker(1)
+	sub := broker.Subscribe("", matchAll)
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish("alarm.updated", "", i)
+	}
+	received := 0
+	for range sub.Events {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("expected %d events before the drop, got %d", subscriberBuffer, received)
+	}
+	// Unsubscribing a dropped subscriber must not close the channel twice
+	broker.Unsubscribe(sub)
+}